@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+/*
+* Backgrounds
+*
+* A scenario can draw a sky and scenery behind the world rather than the
+* plain background colour:
+*
+*	"background": {"sky": ["#0b1a33", "#3a6ea5"], "layers": [
+*		{"image": "hills.png", "parallax": 0.3},
+*		{"image": "trees.png", "parallax": 0.6, "y": 120}]}
+*
+* The sky fades from its first colour at the top of the window to its
+* second at the bottom, or is one colour if only one is given. Layers are
+* images found next to the scenario file, drawn over the sky in the order
+* listed, a world pixel per pixel and repeated across. Parallax is how far
+* a layer moves with the view: 0 stays put, 1 moves with the world, and in
+* between the layer seems further away the closer it is to 0. A layer's
+* top is y world pixels down, or it sits on the bottom of the world without
+* it. Like tilesets, backgrounds keep their own colours whatever the theme.
+ */
+
+type scenarioBackground struct {
+	Sky    []string        `json:"sky,omitempty"` // Top and bottom colour, as #rrggbb
+	Layers []scenarioLayer `json:"layers,omitempty"`
+}
+
+type scenarioLayer struct {
+	Image    string  `json:"image"`       // PNG, relative to the scenario file
+	Parallax float32 `json:"parallax"`    // 0 to 1, the share of the view's movement the layer follows
+	Y        *int    `json:"y,omitempty"` // World pixels from the top, nil to sit on the bottom
+}
+
+// check reports what is wrong with the background, if anything
+func (b *scenarioBackground) check() error {
+	if len(b.Sky) > 2 {
+		return fmt.Errorf("sky: want one or two colours, got %d", len(b.Sky))
+	}
+	for _, v := range b.Sky {
+		if _, err := parseColor(v); err != nil {
+			return fmt.Errorf("sky: %v", err)
+		}
+	}
+	for i, l := range b.Layers {
+		if l.Image == "" {
+			return fmt.Errorf("layer %d: needs an image", i)
+		}
+		if l.Parallax < 0 || l.Parallax > 1 {
+			return fmt.Errorf("layer %d: want a parallax of 0 to 1, got %g", i, l.Parallax)
+		}
+	}
+	return nil
+}
+
+// backdrop is a scenario's background with its layers loaded into
+// textures
+type backdrop struct {
+	spec     *scenarioBackground // What it was loaded from
+	top      rl.Color            // Sky colours, if it has a sky
+	bottom   rl.Color
+	textures []rl.Texture2D // One per layer, zero if it failed to load
+}
+
+// loadBackdrop loads the background of a scenario whose file is in dir,
+// reporting and remembering the layers that fail rather than trying every
+// frame
+func loadBackdrop(spec *scenarioBackground, dir string) *backdrop {
+	b := &backdrop{spec: spec}
+	if len(spec.Sky) > 0 {
+		b.top, _ = parseColor(spec.Sky[0])
+		b.bottom = b.top
+	}
+	if len(spec.Sky) > 1 {
+		b.bottom, _ = parseColor(spec.Sky[1])
+	}
+	for _, l := range spec.Layers {
+		var tex rl.Texture2D
+		path := l.Image
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if _, err := os.Stat(path); err != nil {
+			log.Printf("background: %v, leaving the layer out", err)
+		} else if t := rl.LoadTexture(path); rl.IsTextureValid(t) {
+			rl.SetTextureWrap(t, rl.WrapRepeat)
+			tex = t
+		} else {
+			log.Printf("background: can't load %s, leaving the layer out", path)
+		}
+		b.textures = append(b.textures, tex)
+	}
+	return b
+}
+
+func (b *backdrop) unload() {
+	if b == nil {
+		return
+	}
+	for _, t := range b.textures {
+		if t.ID != 0 {
+			rl.UnloadTexture(t)
+		}
+	}
+}
+
+// backdrop is the loaded background of the scene, nil if it has none. The
+// textures are loaded the first time it's drawn, once there is a window.
+func (g *Game) backdrop() *backdrop {
+	s, ok := g.level.(*scenario)
+	if !ok || s.Background == nil {
+		return nil
+	}
+	if g.scenery == nil || g.scenery.spec != s.Background {
+		g.scenery.unload()
+		g.scenery = loadBackdrop(s.Background, s.dir)
+	}
+	return g.scenery
+}
+
+// drawBackground draws the sky and the layers behind the part of the world
+// in view, in world pixels
+func (g *Game) drawBackground() {
+	b := g.backdrop()
+	if b == nil {
+		return
+	}
+	v := g.view
+	if len(b.spec.Sky) > 0 {
+		rl.DrawRectangleGradientV(int32(v.X), int32(v.Y), int32(v.Width)+1, int32(v.Height)+1, b.top, b.bottom)
+	}
+	worldHeight := float32(len(g.State) * g.TileSize())
+	for i, l := range b.spec.Layers {
+		tex := b.textures[i]
+		if tex.ID == 0 {
+			continue
+		}
+		h := float32(tex.Height)
+		top := worldHeight - h
+		if l.Y != nil {
+			top = float32(*l.Y)
+		}
+		// Following the view only part of the way puts the layer further
+		// along the more the view has moved
+		src := rl.Rectangle{X: v.X * l.Parallax, Width: v.Width, Height: h}
+		dst := rl.Rectangle{X: v.X, Y: top + v.Y*(1-l.Parallax), Width: v.Width, Height: h}
+		rl.DrawTexturePro(tex, src, dst, rl.Vector2{}, 0, rl.White)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Boundaries
+ */
+
+// drawBoundaries marks open and wrapping edges and prints how much water
+// left through the open ones
+func (g *Game) drawBoundaries() {
+	ts := int32(g.TileSize())
+	gw, gh := int32(len(g.State[0]))*ts, int32(len(g.State))*ts
+	open := false
+	for edge := range gridfluid.EdgeCount {
+		var c rl.Color
+		switch g.Boundary[edge] {
+		case gridfluid.BoundaryOpen:
+			c = rl.Fade(rl.Red, 0.6)
+			open = true
+		case gridfluid.BoundaryWrap:
+			c = rl.Fade(rl.SkyBlue, 0.6)
+		default:
+			continue
+		}
+		switch edge {
+		case gridfluid.EdgeLeft:
+			rl.DrawRectangle(0, 0, 2, gh, c)
+		case gridfluid.EdgeRight:
+			rl.DrawRectangle(gw-2, 0, 2, gh, c)
+		case gridfluid.EdgeTop:
+			rl.DrawRectangle(0, 0, gw, 2, c)
+		case gridfluid.EdgeBottom:
+			rl.DrawRectangle(0, gh-2, gw, 2, c)
+		}
+	}
+	if !open {
+		return
+	}
+
+	text := "outflow"
+	for edge := range gridfluid.EdgeCount {
+		if g.Boundary[edge] == gridfluid.BoundaryOpen {
+			text += fmt.Sprintf("  %s %.1f", gridfluid.EdgeNames[edge], g.Outflow[edge])
+		}
+	}
+	rl.DrawText(text, 10, gh-20, 10, rl.RayWhite)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+/*
+* Water brush
+*
+* While the sim runs, holding the left mouse button pours water into every
+* cell within the brush radius of the cursor and holding the right one
+* takes it out again, brushRate of a cell per second. The mouse wheel with
+* Ctrl held sizes the brush; a radius of 0 is the cell under the cursor
+* alone.
+ */
+
+const (
+	brushRate          = 4.0 // Volume per second the brush adds to or takes from a cell
+	defaultBrushRadius = 2   // Cells
+	maxBrushRadius     = 12
+)
+
+// handleBrush resizes the brush and pours or erases water with it
+func (g *Game) handleBrush(shift, ctrl bool) {
+	// Without Ctrl the wheel zooms
+	if wheel := g.mouseWheel(); ctrl && wheel > 0 {
+		g.brushRadius = min(g.brushRadius+1, maxBrushRadius)
+	} else if ctrl && wheel < 0 {
+		g.brushRadius = max(g.brushRadius-1, 0)
+	}
+	pour := g.mouseDown(rl.MouseButtonLeft)
+	// Shift+right mouse drags the view instead
+	g.brushErasing = !pour && !shift && g.mouseDown(rl.MouseButtonRight)
+	if !pour && !g.brushErasing {
+		return
+	}
+	amount := brushRate * float64(g.frameTime())
+	cx, cy := g.cellAtMouse()
+	r := g.brushRadius
+	for y := cy - r; y <= cy+r; y++ {
+		for x := cx - r; x <= cx+r; x++ {
+			// r*r+r rounds the circle out a little, so small brushes aren't
+			// plus shaped
+			if dx, dy := x-cx, y-cy; dx*dx+dy*dy > r*r+r {
+				continue
+			}
+			if pour {
+				g.DropWater(x, y, amount)
+			} else {
+				g.TakeWater(x, y, amount)
+			}
+		}
+	}
+}
+
+// drawBrush outlines the brush at the cursor, red while erasing
+func (g *Game) drawBrush() {
+	if g.editor != nil {
+		return
+	}
+	x, y := g.cellAtMouse()
+	ts := float32(g.TileSize())
+	c := rl.SkyBlue
+	if g.brushErasing {
+		c = rl.Red
+	}
+	center := rl.Vector2{X: (float32(x) + 0.5) * ts, Y: (float32(y) + 0.5) * ts}
+	rl.DrawCircleLinesV(center, (float32(g.brushRadius)+0.5)*ts, rl.Fade(c, 0.7))
+	rl.DrawText(fmt.Sprintf("r%d", g.brushRadius), int32(center.X)+int32(ts)/2+2, int32(center.Y)+4, 10, c)
+}
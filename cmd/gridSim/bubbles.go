@@ -0,0 +1,23 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+/*
+* Bubbles
+ */
+
+// drawBubbles draws the bubbles rising through the water, sized by the
+// air they carry
+func (g *Game) drawBubbles() {
+	ts := float32(g.TileSize())
+	for _, b := range g.Bubbles() {
+		pos := rl.Vector2{X: float32(b.Pos.X) * ts, Y: float32(b.Pos.Y) * ts}
+		r := max(ts*0.8*float32(math.Sqrt(b.Air)), 1.5)
+		rl.DrawCircleV(pos, r, rl.Fade(colors.bubble, 0.25))
+		rl.DrawCircleLinesV(pos, r, colors.bubble)
+	}
+}
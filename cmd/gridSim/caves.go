@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Cave scenes
+*
+* -caves carves a cave system filling the window out of stone, see
+* gridfluid.GenerateCaves, and sets a generator going in the highest
+* chamber for the water to find its way down from. -cave-seed picks the
+* caves, -cave-fill how much of the rock is left standing and -cave-smooth
+* how rounded the chambers are.
+*
+* Like image scenes the caves are turned into a scenario, so the editor and
+* recordings work with them.
+ */
+
+const caveSpring = 3 // Width of the generator in cells, and of the opening it needs
+
+// caveScenario carves caves of cols by rows cells of tileSize pixels
+func caveScenario(p gridfluid.CaveParams, cols, rows, tileSize int) (*scenario, error) {
+	state := make([][]gridfluid.Droplet, rows)
+	for y := range state {
+		state[y] = make([]gridfluid.Droplet, cols)
+	}
+	gridfluid.GenerateCaves(p, &state)
+
+	s := &scenario{Cols: cols, Rows: rows, TileSize: tileSize, Params: gridfluid.DefaultParams(), name: "caves"}
+	for _, r := range cellRects(cols, rows, func(x, y int) string {
+		if m := state[y][x].Material; m.IsSolid() {
+			return m.Name()
+		}
+		return ""
+	}) {
+		s.Obstacles = append(s.Obstacles, scenarioObstacle{Rect: r.rect, Material: r.key})
+	}
+	x, y, ok := caveOpening(state)
+	if !ok {
+		return nil, fmt.Errorf("seed %d carved no opening of %d cells, try another seed or a lower -cave-fill", p.Seed, caveSpring)
+	}
+	s.Generators = append(s.Generators, scenarioGenerator{X: x, Y: y, Width: caveSpring, Every: 5})
+	var err error
+	if s.source, err = json.Marshal(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// caveOpening is the left end of the highest run of caveSpring open cells
+func caveOpening(state [][]gridfluid.Droplet) (x, y int, ok bool) {
+	for y := range state {
+		run := 0
+		for x := range state[y] {
+			if state[y][x].Material.IsSolid() {
+				run = 0
+				continue
+			}
+			if run++; run == caveSpring {
+				return x - caveSpring + 1, y, true
+			}
+		}
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Conservation audit
+*
+* -debug-conservation weighs every pass of every update and logs the ones
+* that create or destroy more than -debug-conservation-tolerance of water,
+* with the cell the change centres on, see pkg/gridfluid/audit.go. With
+* -debug-conservation-panic the first leak saves the world it left behind
+* as conservation_<update>.wss and panics, so a regression stops the run
+* where it happened. Copy the file to quicksave.wss and press F9 to look
+* at it.
+ */
+
+type conservationAudit struct {
+	tolerance   float64
+	panicOnLeak bool
+}
+
+// auditConservation turns the audit a on, and again for the world that
+// replaces this one on a reload
+func (g *Game) auditConservation(a *conservationAudit) {
+	g.audit = a
+	g.AuditConservation(a.tolerance, func(l gridfluid.Leak) {
+		log.Printf("conservation: %v", l)
+		if !a.panicOnLeak {
+			return
+		}
+		name := fmt.Sprintf("conservation_%d.wss", l.Frame)
+		if err := g.SaveState(name); err != nil {
+			log.Printf("conservation: %v", err)
+		} else {
+			log.Printf("conservation: state saved to %s", name)
+		}
+		panic(fmt.Sprintf("conservation: %v", l))
+	})
+}
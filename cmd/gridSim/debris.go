@@ -0,0 +1,24 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Floating debris
+ */
+
+func (g *Game) drawDebris() {
+	ts := float32(g.TileSize())
+	for _, d := range g.Debris() {
+		x, y, size := float32(d.Pos.X)*ts, float32(d.Pos.Y)*ts, float32(d.Size)*ts
+		switch d.Kind {
+		case gridfluid.DebrisCrate:
+			rl.DrawRectangleV(rl.Vector2{X: x, Y: y}, rl.Vector2{X: size, Y: size}, colors.crate)
+			rl.DrawRectangleLinesEx(rl.Rectangle{X: x, Y: y, Width: size, Height: size}, 2, colors.crateEdge)
+		case gridfluid.DebrisBall:
+			rl.DrawCircleV(rl.Vector2{X: x + size/2, Y: y + size/2}, size/2, rl.Red)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import "watersim/pkg/gridfluid"
+
+/*
+* Demo scene
+ */
+
+// The demo is laid out in cells, on a grid at least this large
+const (
+	demoCols = 96
+	demoRows = 54
+)
+
+// demoScene remembers where the demo's water and oil streams enter, in
+// cells of the tile size it was built at
+type demoScene struct {
+	flowX, flowY int
+	oilX, oilY   int
+	tileSize     int
+}
+
+// setupDemo builds the obstacles, materials and entities of the demo
+func setupDemo(game *Game) *demoScene {
+	s := &demoScene{
+		flowX: 20,
+		flowY: 0,
+		oilX:  70,
+		oilY:  3,
+
+		tileSize: game.TileSize(),
+	}
+
+	stone := func(shape gridfluid.Shape) { gridfluid.FillShape(shape, gridfluid.MaterialStone, &game.State) }
+	gridWidth := len(game.State[0])
+	gridHeight := len(game.State)
+
+	// Borders three cells thick, with a gap in the top one for the stream
+	stone(gridfluid.Rect{X: 0, Y: 0, W: gridWidth, H: 3})
+	gridfluid.ClearShape(gridfluid.Rect{X: s.flowX, Y: 0, W: 5, H: 3}, &game.State)
+	stone(gridfluid.Rect{X: 0, Y: gridHeight - 3, W: gridWidth, H: 3})
+	stone(gridfluid.Rect{X: 0, Y: 0, W: 3, H: gridHeight})
+	stone(gridfluid.Rect{X: gridWidth - 3, Y: 0, W: 3, H: gridHeight})
+
+	gridfluid.CreateWaterGenerator(s.flowX, s.flowY, game.TileSize(), &game.State)
+	stone(gridfluid.Rect{X: 10, Y: 10, W: 3, H: 20})
+	stone(gridfluid.Rect{X: 10, Y: 30, W: 50, H: 3})
+	stone(gridfluid.Rect{X: 40, Y: 20, W: 40, H: 3})
+	gridfluid.CreateRamp(19, 8, 10, gridfluid.RampRight, &game.State)
+	gridfluid.CreatePorousBlock(40, 26, 10, 4, gridfluid.MaterialGravel, &game.State)
+	gridfluid.CreatePorousBlock(34, 25, 1, 5, gridfluid.MaterialCloth, &game.State)
+	// Drains the shelf through the floor and lifts it back up the left wall
+	gridfluid.CreatePipe([][2]int{{14, 29}, {14, 36}, {6, 36}, {6, 31}}, &game.State)
+	// A wooden plank to set alight with E
+	gridfluid.CreateWoodBlock(62, 14, 12, 1, &game.State)
+	// A salt lick slowly turning the pool briny
+	gridfluid.CreateSaltBlock(44, 48, 3, 3, &game.State)
+	// A whirlpool slowly draining the pool
+	game.PlaceVortex(56, 50)
+	// A fan on the pool floor lifting spray off the water
+	gridfluid.CreateFan(80, 50, gridfluid.FanUp, &game.State)
+	// A portal lifting pool water back up to the top shelf
+	gridfluid.CreatePortalPair([2]int{24, 50}, [2]int{52, 4}, 1, &game.State)
+	// A block of ice on the upper shelf and a heater warming the pool
+	gridfluid.CreateIceBlock(75, 17, 3, 3, &game.State)
+	gridfluid.CreateHeater(36, 50, &game.State)
+	// A float switch that runs the fan once the pool is deep
+	gridfluid.CreateSensor(30, 42, gridfluid.SensorFloat, &game.State)
+	game.Connect([2]int{30, 42}, [2]int{80, 50}, false)
+	// A patch of reeds on the pool floor
+	for x := 64; x < 70; x += 2 {
+		game.PlantSeed(x, 50)
+	}
+	// An elevator in the bottom right corner
+	game.AddMover(6, 1, []gridfluid.Vector{{X: 86, Y: 50}, {X: 86, Y: 22}}, 0.05)
+	// A wheel turned by the water spilling off the upper shelf
+	game.AddWheel(48, 23, 2.5)
+	game.AddDebris(gridfluid.DebrisCrate, 30, 20)
+	game.AddDebris(gridfluid.DebrisBall, 50, 15)
+	// Absorb side slosh, toggled with Z
+	game.Sponge = gridfluid.Sponge{Left: 8, Right: 8, Strength: 0.2}
+	return s
+}
+
+// scenario describes the demo's streams as the generators of a scenario,
+// for the editor to change
+func (s *demoScene) scenario(game *Game) *scenario {
+	return &scenario{
+		Cols: len(game.State[0]) * game.TileSize() / s.tileSize, Rows: len(game.State) * game.TileSize() / s.tileSize,
+		TileSize: s.tileSize, Params: game.Params,
+		Generators: []scenarioGenerator{
+			{X: s.flowX, Y: s.flowY, Width: 5, Every: 5},
+			{X: s.oilX, Y: s.oilY, Width: 3, Every: 10, Fluid: "oil"},
+		},
+	}
+}
+
+// spawn tops up the demo's streams and returns the volume added
+func (s *demoScene) spawn(game *Game, frameCount int) float64 {
+	added := 0.0
+	// The grid may have been resampled since the scene was built
+	cells := func(n int) int { return max(n*s.tileSize/game.TileSize(), 1) }
+	flowX, flowY := s.flowX*s.tileSize/game.TileSize(), s.flowY*s.tileSize/game.TileSize()
+	oilX, oilY := s.oilX*s.tileSize/game.TileSize(), s.oilY*s.tileSize/game.TileSize()
+
+	// Add new water every 5 frames (creates a continuous water stream)
+	if frameCount%5 == 0 {
+		for x := 0; x < cells(5); x++ {
+			cell := &game.State[flowY][flowX+x]
+			if !cell.Material.IsSolid() && cell.Volume < 0.7 {
+				before := cell.Volume
+				cell.Volume = min(cell.Volume+game.Params.GeneratorRate, 1)
+				added += cell.Volume - before
+				cell.Stagnation = 0
+			}
+		}
+		// CreateWaterGenerator(flowStartX, flowStartY, game.tileSize, &game.State)
+	}
+
+	// A slower stream of oil to show the fluids layering
+	if frameCount%10 == 0 {
+		for x := 0; x < cells(3); x++ {
+			cell := &game.State[oilY][oilX+x]
+			if !cell.Material.IsSolid() && cell.Volume < 0.7 {
+				before := cell.Volume
+				cell.Fluid = gridfluid.FluidOil
+				cell.Volume = min(cell.Volume+game.Params.GeneratorRate, 1)
+				added += cell.Volume - before
+				cell.Stagnation = 0
+			}
+		}
+	}
+	return added
+}
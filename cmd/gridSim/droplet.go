@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+/*
+* Single droplets
+ */
+
+const defaultDropVolume = 0.5
+
+// selectDropVolume maps the number keys to droplet sizes
+func (g *Game) selectDropVolume() {
+	for i := range 10 {
+		if g.keyPressed(rl.KeyZero + int32(i)) {
+			if i == 0 {
+				i = 10
+			}
+			g.dropVolume = float64(i) / 10
+		}
+	}
+}
+
+// drawDropCursor previews the droplet size at the cursor
+func (g *Game) drawDropCursor() {
+	x, y := g.cellAtMouse()
+	if !g.InBounds(x, y) {
+		return
+	}
+	ts := int32(g.TileSize())
+	fill := int32(float64(ts) * g.dropVolume)
+	rl.DrawRectangleLines(int32(x)*ts, int32(y)*ts, ts, ts, rl.Fade(rl.SkyBlue, 0.5))
+	rl.DrawRectangle(int32(x)*ts, int32(y)*ts+ts-fill, 3, fill, rl.Fade(rl.SkyBlue, 0.8))
+	rl.DrawText(fmt.Sprintf("%.1f", g.dropVolume), int32(x)*ts+ts+2, int32(y)*ts, 10, rl.SkyBlue)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Dye
+ */
+
+// Palette cycled through with the dye colour key
+var dyePalette = []gridfluid.Dye{
+	{R: 1.0, G: 0.1, B: 0.1, Amount: 1},
+	{R: 0.1, G: 1.0, B: 0.2, Amount: 1},
+	{R: 1.0, G: 0.9, B: 0.1, Amount: 1},
+	{R: 0.9, G: 0.2, B: 1.0, Amount: 1},
+}
+
+// dyedColor tints the fluid colour by its dye
+func dyedColor(base rl.Color, dye gridfluid.Dye) rl.Color {
+	if dye.Amount <= 0.01 {
+		return base
+	}
+	tint := colors.toned(rl.NewColor(uint8(dye.R*255), uint8(dye.G*255), uint8(dye.B*255), 255))
+	return rl.ColorLerp(base, tint, float32(math.Min(1.0, dye.Amount)))
+}
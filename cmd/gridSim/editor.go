@@ -0,0 +1,337 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Editor
+*
+* Tab pauses the sim and turns the mouse into a brush for building a scene,
+* which Ctrl+S saves as a scenario file for -scenario.
+*
+*	1-4         pick the tool: obstacle, water, generator, drain
+*	C           cycle the obstacle material, or the fluid of water and
+*	            generators
+*	Left mouse  paint obstacles or water, place a generator or a drain
+*	            (with Shift: erase)
+*	Ctrl+S      save the scene as scenario_<time>.json
+*	Ctrl+Z      undo, Ctrl+Y  redo
+*	Tab         leave the editor and let the sim run on
+*
+* A scenario only holds the grid size, the parameters, the obstacles, the
+* generators, the drains and the water, so pipes, devices and the like are
+* left out of the file. Generators belong to the scene rather than the grid
+* and are not undone; placing one in the demo turns its streams into
+* generators too.
+ */
+
+const (
+	editorGeneratorWidth = 3 // Cells a placed generator tops up
+	editorGeneratorEvery = 5 // Updates between its top ups
+)
+
+type editorTool int
+
+const (
+	toolObstacle editorTool = iota
+	toolWater
+	toolGenerator
+	toolDrain
+	editorToolCount
+)
+
+var editorToolNames = [editorToolCount]string{"obstacle", "water", "generator", "drain"}
+
+// Materials and fluids C cycles through
+var (
+	editorMaterials = []gridfluid.MaterialID{gridfluid.MaterialStone, gridfluid.MaterialWood, gridfluid.MaterialSand, gridfluid.MaterialGravel, gridfluid.MaterialCloth}
+	editorFluids    = []gridfluid.Fluid{gridfluid.FluidWater, gridfluid.FluidOil, gridfluid.FluidAcid}
+)
+
+type editorMode struct {
+	Tool     editorTool
+	Material gridfluid.MaterialID // Painted by the obstacle tool
+	Fluid    gridfluid.Fluid      // Painted by the water tool and generators
+	Stroke   bool                 // The held mouse button has changed a cell
+}
+
+// clone copies the editor's settings, nil if e is
+func (e *editorMode) clone() *editorMode {
+	if e == nil {
+		return nil
+	}
+	c := *e
+	return &c
+}
+
+// toggleEditor enters or leaves the editor
+func (g *Game) toggleEditor() {
+	if g.editor != nil {
+		g.editor = nil
+		return
+	}
+	if g.teach != nil {
+		log.Printf("editor: can't edit during teaching mode")
+		return
+	}
+	g.editor = &editorMode{Material: gridfluid.MaterialStone}
+}
+
+// handleEditorInput handles the keys and the mouse in the editor, in place
+// of the usual tools
+func (g *Game) handleEditorInput(shift, ctrl bool) {
+	e := g.editor
+	for i, key := range []int32{rl.KeyOne, rl.KeyTwo, rl.KeyThree, rl.KeyFour} {
+		if g.keyPressed(key) {
+			e.Tool = editorTool(i)
+		}
+	}
+	if g.keyPressed(rl.KeyC) {
+		if e.Tool == toolObstacle {
+			e.Material = next(editorMaterials, e.Material)
+		} else {
+			e.Fluid = next(editorFluids, e.Fluid)
+		}
+	}
+	switch {
+	case ctrl && g.keyPressed(rl.KeyS):
+		g.saveScenario()
+	case ctrl && g.keyPressed(rl.KeyZ):
+		g.undoEdit(false)
+	case ctrl && g.keyPressed(rl.KeyY):
+		g.undoEdit(true)
+	}
+
+	if !g.mouseDown(rl.MouseButtonLeft) {
+		e.Stroke = false
+		return
+	}
+	x, y := g.cellAtMouse()
+	if !g.InBounds(x, y) {
+		return
+	}
+	switch e.Tool {
+	case toolObstacle, toolWater:
+		paint := func() { g.paintCell(x, y, shift) }
+		if e.Stroke {
+			g.ExtendEdit(x, y, paint)
+		} else {
+			e.Stroke = g.Edit(x, y, paint)
+		}
+	case toolGenerator:
+		if g.mousePressed(rl.MouseButtonLeft) {
+			g.placeGenerator(x, y, shift)
+		}
+	case toolDrain:
+		if g.mousePressed(rl.MouseButtonLeft) && g.State[y][x].Vortex == shift {
+			g.Edit(x, y, func() { g.PlaceVortex(x, y) })
+		}
+	}
+}
+
+// next is the entry after v in list, wrapping around
+func next[T comparable](list []T, v T) T {
+	return list[(slices.Index(list, v)+1)%len(list)]
+}
+
+// paintCell paints or erases an obstacle or water at (x, y)
+func (g *Game) paintCell(x, y int, erase bool) {
+	d := &g.State[y][x]
+	if d.Pipe != gridfluid.PipeNone {
+		return
+	}
+	switch {
+	case g.editor.Tool == toolObstacle && erase:
+		if slices.Contains(editorMaterials, d.Material) {
+			d.Material = gridfluid.MaterialOpen
+		}
+	case g.editor.Tool == toolObstacle:
+		d.Material = g.editor.Material
+		if d.Material.IsSolid() {
+			d.Volume, d.VX, d.VY = 0, 0, 0
+		}
+	case d.Material.IsSolid():
+	case erase:
+		d.Volume, d.VX, d.VY = 0, 0, 0
+	default:
+		d.Fluid, d.Volume, d.Stagnation = g.editor.Fluid, 1.0, 0
+	}
+}
+
+// editedScene is the scene as a scenario whose generators the editor can
+// change, turning the demo into one the first time
+func (g *Game) editedScene() *scenario {
+	s := g.sceneAsScenario()
+	g.level = s
+	return s
+}
+
+// sceneAsScenario is the scene as a scenario, without changing it
+func (g *Game) sceneAsScenario() *scenario {
+	switch s := g.level.(type) {
+	case *scenario:
+		return s
+	case *demoScene:
+		return s.scenario(g)
+	}
+	return &scenario{TileSize: g.TileSize()}
+}
+
+// placeGenerator places a generator at (x, y), or erases the ones there
+func (g *Game) placeGenerator(x, y int, erase bool) {
+	s := g.editedScene()
+	if erase {
+		s.Generators = slices.DeleteFunc(s.Generators, func(gen scenarioGenerator) bool {
+			x0, x1, gy := generatorCells(gen, s.TileSize, g.TileSize())
+			return gy == y && x >= x0 && x < x1
+		})
+		return
+	}
+	gen := scenarioGenerator{
+		X: x * g.TileSize() / s.TileSize, Y: y * g.TileSize() / s.TileSize,
+		Width: max(editorGeneratorWidth*g.TileSize()/s.TileSize, 1), Every: editorGeneratorEvery,
+	}
+	if g.editor.Fluid != gridfluid.FluidWater {
+		gen.Fluid = g.editor.Fluid.Name()
+	}
+	s.Generators = append(s.Generators, gen)
+}
+
+// saveScenario writes the scene as it is now to a scenario file
+func (g *Game) saveScenario() {
+	// Playing back a recording leaves the files alone
+	if g.input.player != nil {
+		return
+	}
+	data, err := json.MarshalIndent(g.captureScenario(), "", "  ")
+	if err != nil {
+		log.Printf("save scenario: %v", err)
+		return
+	}
+	name := fmt.Sprintf("scenario_%s.json", time.Now().Format("20060102_150405"))
+	if err := os.WriteFile(name, append(data, '\n'), 0o644); err != nil {
+		log.Printf("save scenario: %v", err)
+		return
+	}
+	log.Printf("scenario saved to %s", name)
+}
+
+// captureScenario describes the grid as it is now as a scenario
+func (g *Game) captureScenario() *scenario {
+	ts := g.TileSize()
+	s := &scenario{Cols: len(g.State[0]), Rows: len(g.State), TileSize: ts, Params: g.Params}
+
+	material := func(x, y int) string {
+		if m := g.State[y][x].Material; slices.Contains(editorMaterials, m) {
+			return m.Name()
+		}
+		return ""
+	}
+	for _, r := range cellRects(s.Cols, s.Rows, material) {
+		s.Obstacles = append(s.Obstacles, scenarioObstacle{Rect: r.rect, Material: r.key})
+	}
+	fluid := func(x, y int) string {
+		if d := g.State[y][x]; !d.Material.IsSolid() && d.Volume >= 0.5 {
+			return d.Fluid.Name()
+		}
+		return ""
+	}
+	for _, r := range cellRects(s.Cols, s.Rows, fluid) {
+		w := scenarioWater{Rect: r.rect}
+		if r.key != gridfluid.FluidWater.Name() {
+			w.Fluid = r.key
+		}
+		s.Water = append(s.Water, w)
+	}
+	for y := range g.State {
+		for x := range g.State[y] {
+			if g.State[y][x].Vortex {
+				s.Drains = append(s.Drains, scenarioDrain{x, y})
+			}
+		}
+	}
+	// Generators are kept in cells of the tile size the scene was built at
+	scene := g.sceneAsScenario()
+	for _, gen := range scene.Generators {
+		x0, x1, y := generatorCells(gen, scene.TileSize, ts)
+		gen.X, gen.Y, gen.Width = x0, y, x1-x0
+		if s.inside(gen.X, gen.Y) && s.inside(gen.X+gen.Width-1, gen.Y) {
+			s.Generators = append(s.Generators, gen)
+		}
+	}
+	return s
+}
+
+// cellRect is a rectangle of cells sharing a key
+type cellRect struct {
+	rect []int // x, y, width, height
+	key  string
+}
+
+// cellRects covers the cells of a cols by rows grid with rectangles of
+// cells sharing a key, leaving out cells whose key is empty. Runs along a
+// row grow downwards while the row below has the same run.
+func cellRects(cols, rows int, key func(x, y int) string) []cellRect {
+	var rects []cellRect
+	above := map[[2]int]int{} // Runs of the row above by x and width, as indices into rects
+	for y := range rows {
+		runs := map[[2]int]int{}
+		for x := 0; x < cols; {
+			k, w := key(x, y), 1
+			for x+w < cols && key(x+w, y) == k {
+				w++
+			}
+			if k != "" {
+				run := [2]int{x, w}
+				if i, ok := above[run]; ok && rects[i].key == k {
+					rects[i].rect[3]++
+					runs[run] = i
+				} else {
+					rects = append(rects, cellRect{[]int{x, y, w, 1}, k})
+					runs[run] = len(rects) - 1
+				}
+			}
+			x += w
+		}
+		above = runs
+	}
+	return rects
+}
+
+// drawEditor outlines the generators and the cell under the mouse, and
+// shows the tool in use
+func (g *Game) drawEditor() {
+	e := g.editor
+	if e == nil {
+		return
+	}
+	ts := float32(g.TileSize())
+	scene := g.sceneAsScenario()
+	for _, gen := range scene.Generators {
+		x0, x1, y := generatorCells(gen, scene.TileSize, g.TileSize())
+		r := rl.Rectangle{X: float32(x0) * ts, Y: float32(y) * ts, Width: float32(x1-x0) * ts, Height: ts}
+		rl.DrawRectangleLinesEx(g.screenRect(r), 2, rl.SkyBlue)
+	}
+	x, y := g.cellAtMouse()
+	rl.DrawRectangleLinesEx(g.screenRect(rl.Rectangle{X: float32(x) * ts, Y: float32(y) * ts, Width: ts, Height: ts}), 1, rl.Yellow)
+
+	brush := ""
+	switch e.Tool {
+	case toolObstacle:
+		brush = e.Material.Name()
+	case toolWater, toolGenerator:
+		brush = e.Fluid.Name()
+	}
+	text := fmt.Sprintf("EDITOR  [1-4] %s %s  [C] cycle  [Ctrl+S] save  [Tab] run    undo %d  redo %d",
+		editorToolNames[e.Tool], brush, g.CanUndo(), g.CanRedo())
+	rl.DrawText(text, 10, 6, 10, rl.Yellow)
+}
@@ -0,0 +1,40 @@
+package main
+
+import rl "github.com/gen2brain/raylib-go/raylib"
+
+/*
+* Explosions
+ */
+
+const (
+	explosionRadius = 4  // Default crater radius in cells
+	flashFrames     = 12 // How long the flash stays visible
+)
+
+// flash is the short-lived visual of an explosion
+type flash struct {
+	x, y, radius int
+	frames       int
+}
+
+// Explode blows a crater at (cx, cy) and shows a flash over it
+func (g *Game) Explode(cx, cy, radius int) {
+	g.Game.Explode(cx, cy, radius)
+	g.flashes = append(g.flashes, flash{x: cx, y: cy, radius: radius, frames: flashFrames})
+}
+
+// drawFlashes renders and ages the explosion flashes
+func (g *Game) drawFlashes() {
+	ts := g.TileSize()
+	alive := g.flashes[:0]
+	for _, f := range g.flashes {
+		alpha := float32(f.frames) / flashFrames
+		center := rl.Vector2{X: float32(f.x*ts + ts/2), Y: float32(f.y*ts + ts/2)}
+		rl.DrawCircleV(center, float32(f.radius*ts)*(2-alpha), rl.Fade(rl.Orange, alpha*0.6))
+		f.frames--
+		if f.frames > 0 {
+			alive = append(alive, f)
+		}
+	}
+	g.flashes = alive
+}
@@ -0,0 +1,57 @@
+//go:build !noexport
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"time"
+
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Exporters
+*
+* Writing PNGs and report bundles, left out of binaries built with the
+* noexport tag.
+ */
+
+func init() {
+	gridfluid.RegisterFeature("export", "flux heat map PNGs and issue report bundles")
+}
+
+// ExportFlux writes the flux heat map as a PNG with one pixel per cell
+func (g *Game) ExportFlux() (string, error) {
+	if !g.FeatureEnabled("export") {
+		return "", gridfluid.FeatureOffError("export")
+	}
+	h := len(g.State)
+	w := len(g.State[0])
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	maxF := g.MaxFlux()
+	for y := range g.State {
+		for x := range g.State[y] {
+			d := &g.State[y][x]
+			c := fluxColor(normalizedFlux(d.Flux, maxF))
+			if d.Material.IsSolid() {
+				c = color.RGBA{80, 80, 80, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+
+	name := fmt.Sprintf("flux_%s.png", time.Now().Format("20060102_150405"))
+	f, err := os.Create(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		return "", err
+	}
+	return name, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Fans
+ */
+
+// drawFans draws a spinning rotor and an arrow on every fan
+func (g *Game) drawFans() {
+	ts := float32(g.TileSize())
+	x0, y0, x1, y1 := g.visibleCells()
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			d := &g.State[y][x]
+			if d.Fan == gridfluid.FanNone {
+				continue
+			}
+			cx, cy := (float32(x)+0.5)*ts, (float32(y)+0.5)*ts
+			angle := 0.0
+			if d.FanOn {
+				angle = float64(g.Frame()) * 0.4
+			}
+			for blade := range 2 {
+				a := angle + float64(blade)*math.Pi/2
+				off := rl.Vector2{X: float32(math.Cos(a)) * ts * 0.4, Y: float32(math.Sin(a)) * ts * 0.4}
+				rl.DrawLineEx(rl.Vector2{X: cx - off.X, Y: cy - off.Y}, rl.Vector2{X: cx + off.X, Y: cy + off.Y}, 2, colors.fan)
+			}
+			dx, dy := d.Fan.Dir()
+			tip := rl.Vector2{X: cx + float32(dx)*ts*0.8, Y: cy + float32(dy)*ts*0.8}
+			c := rl.SkyBlue
+			if !d.FanOn {
+				c = rl.Gray
+			}
+			rl.DrawLineEx(rl.Vector2{X: cx, Y: cy}, tip, 1.5, c)
+			rl.DrawCircleV(tip, 2, c)
+		}
+	}
+}
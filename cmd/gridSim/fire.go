@@ -0,0 +1,43 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Fire
+ */
+
+const fireFlickerMin = 0.6 // Shortest flame relative to the tallest
+
+// drawFire draws flickering flames over the burning cells
+func (g *Game) drawFire() {
+	ts := float32(g.TileSize())
+	x0, y0, x1, y1 := g.visibleCells()
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			d := &g.State[y][x]
+			if d.Fire <= 0 {
+				continue
+			}
+			// Flicker from a cheap hash of the cell and the frame
+			h := uint32(x)*73856093 ^ uint32(y)*19349663 ^ uint32(g.Frame()/4)*83492791
+			h ^= h >> 13
+			flicker := fireFlickerMin + (1-fireFlickerMin)*float32(h%100)/100
+			cx, bottom := (float32(x)+0.5)*ts, (float32(y)+1)*ts
+			height := ts * 1.4 * flicker
+
+			// Vertices go top, bottom-left, bottom-right
+			rl.DrawTriangle(rl.Vector2{X: cx, Y: bottom - height}, rl.Vector2{X: cx - ts/2, Y: bottom}, rl.Vector2{X: cx + ts/2, Y: bottom}, rl.Fade(colors.flameOuter, 0.85))
+			rl.DrawTriangle(rl.Vector2{X: cx, Y: bottom - height*0.6}, rl.Vector2{X: cx - ts/4, Y: bottom}, rl.Vector2{X: cx + ts/4, Y: bottom}, rl.Fade(colors.flameInner, 0.9))
+		}
+	}
+}
+
+// woodTint chars wood towards black as it burns
+func woodTint(d *gridfluid.Droplet) rl.Color {
+	return rl.ColorLerp(colors.material(d.Material), colors.char, float32(math.Min(d.Burnt*2, 1)))
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Fluids
+ */
+
+// fluidColor tints the fluid colour by depth and pressure like the original
+// water rendering, paled by salt, muddied by sand and clouded by how long
+// the water has been still
+func fluidColor(d *gridfluid.Droplet) rl.Color {
+	intensity := math.Min(d.Pressure*40+d.Volume*100, 255) / 255
+	base := dyedColor(colors.fluids[d.Fluid], d.Dye)
+	base = rl.ColorLerp(base, colors.brine, float32(min(d.Salinity, 1)*0.6))
+	base = rl.ColorLerp(base, colors.material(gridfluid.MaterialSand), float32(min(d.Sediment/gridfluid.SedimentCapacity, 1)*0.6))
+	base = rl.ColorLerp(base, colors.murk, float32(gridfluid.Murkiness(d)*0.7))
+	return rl.NewColor(
+		uint8(float64(base.R)*intensity),
+		uint8(float64(base.G)*intensity),
+		uint8(float64(base.B)*intensity),
+		255,
+	)
+}
+
+// drawInterfaces outlines the boundaries between different fluids
+func (g *Game) drawInterfaces() {
+	ts := int32(g.TileSize())
+	x0, y0, x1, y1 := g.visibleCells()
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			d := &g.State[y][x]
+			if d.Material.IsSolid() || d.Volume <= gridfluid.WetThreshold {
+				continue
+			}
+			px, py := int32(x)*ts, int32(y)*ts
+			if x+1 < len(g.State[y]) {
+				r := &g.State[y][x+1]
+				if !r.Material.IsSolid() && r.Volume > gridfluid.WetThreshold && r.Fluid != d.Fluid {
+					rl.DrawLine(px+ts, py, px+ts, py+ts, rl.Yellow)
+				}
+			}
+			if y+1 < len(g.State) {
+				b := &g.State[y+1][x]
+				if !b.Material.IsSolid() && b.Volume > gridfluid.WetThreshold && b.Fluid != d.Fluid {
+					rl.DrawLine(px, py+ts, px+ts, py+ts, rl.Yellow)
+				}
+			}
+		}
+	}
+}
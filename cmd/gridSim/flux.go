@@ -0,0 +1,61 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+/*
+* Flux accumulation
+ */
+
+// fluxColor maps a normalised flux (0.0 to 1.0) onto a black-blue-yellow-white ramp
+func fluxColor(t float64) color.RGBA {
+	return rampColor([]color.RGBA{
+		{0, 0, 0, 255},
+		{20, 40, 160, 255},
+		{230, 200, 40, 255},
+		{255, 255, 255, 255},
+	}, t)
+}
+
+// rampColor picks the colour t (0.0 to 1.0) of the way along evenly spaced
+// stops
+func rampColor(stops []color.RGBA, t float64) color.RGBA {
+	t = math.Min(1.0, math.Max(0.0, t))
+	pos := t * float64(len(stops)-1)
+	i := int(pos)
+	if i >= len(stops)-1 {
+		return stops[len(stops)-1]
+	}
+	f := pos - float64(i)
+	lerp := func(a, b uint8) uint8 { return uint8(float64(a) + (float64(b)-float64(a))*f) }
+	a, b := stops[i], stops[i+1]
+	return color.RGBA{lerp(a.R, b.R), lerp(a.G, b.G), lerp(a.B, b.B), 255}
+}
+
+// Flux uses a log scale so small side channels remain visible next to the main stream
+func normalizedFlux(flux, maxF float64) float64 {
+	if maxF <= 0 {
+		return 0
+	}
+	return math.Log1p(flux) / math.Log1p(maxF)
+}
+
+// DrawFlux renders the accumulated flow heat map instead of the water
+func (g *Game) DrawFlux() {
+	maxF := g.MaxFlux()
+	x0, y0, x1, y1 := g.visibleCells()
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			d := &g.State[y][x]
+			c := fluxColor(normalizedFlux(d.Flux, maxF))
+			if d.Material.IsSolid() {
+				c = rl.DarkGray
+			}
+			rl.DrawRectangle(int32(x*g.TileSize()), int32(y*g.TileSize()), int32(g.TileSize()), int32(g.TileSize()), c)
+		}
+	}
+}
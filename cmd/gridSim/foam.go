@@ -0,0 +1,32 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Foam / turbulence
+ */
+
+const foamSpeckles = 10 // Speckles drawn in a fully foamy cell
+
+// drawFoam scatters white speckles over the water of a foamy cell. The
+// speckle positions are hashed from the cell so they don't flicker.
+func drawFoam(d *gridfluid.Droplet, pixelX, pixelY, tileSize, offsetY int) {
+	count := int(d.Foam * foamSpeckles)
+	waterHeight := tileSize - offsetY
+	if count == 0 || waterHeight <= 0 {
+		return
+	}
+	seed := uint32(pixelX)*73856093 ^ uint32(pixelY)*19349663
+	for i := range count {
+		h := seed ^ uint32(i)*83492791
+		h ^= h >> 13
+		h *= 0x5bd1e995
+		h ^= h >> 15
+		sx := int32(pixelX) + int32(h%uint32(tileSize))
+		sy := int32(pixelY+offsetY) + int32((h>>8)%uint32(waterHeight))
+		rl.DrawRectangle(sx, sy, 2, 2, rl.Fade(colors.foam, float32(0.4+0.6*d.Foam)))
+	}
+}
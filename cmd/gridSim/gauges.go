@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Gauges
+ */
+
+type gaugeProps struct {
+	label    string
+	unit     string
+	min, max float64
+	color    rl.Color
+}
+
+var gaugeKinds = []gaugeProps{
+	gridfluid.GaugePressure:    {"pressure", "", 0, 20, rl.SkyBlue},
+	gridfluid.GaugeTemperature: {"temp", "°C", 0, 100, rl.Orange},
+	gridfluid.GaugeFlow:        {"flow", "/t", 0, 1, rl.Lime},
+}
+
+func (g *Game) drawGauges() {
+	for _, gauge := range g.Gauges() {
+		props := gaugeKinds[gauge.Kind]
+		t := float32((gauge.Value - props.min) / (props.max - props.min))
+		t = float32(math.Min(1, math.Max(0, float64(t))))
+		cx := float32(gauge.X*g.TileSize() + g.TileSize()/2)
+		cy := float32(gauge.Y*g.TileSize() + g.TileSize()/2)
+		label := fmt.Sprintf("%s %.2f%s", props.label, gauge.Value, props.unit)
+
+		switch gauge.Style {
+		case gridfluid.GaugeDial:
+			const radius = 14
+			rl.DrawCircleV(rl.Vector2{X: cx, Y: cy}, radius, rl.Fade(rl.Black, 0.8))
+			rl.DrawCircleLinesV(rl.Vector2{X: cx, Y: cy}, radius, rl.LightGray)
+			// Needle sweeps 270° starting at the bottom left
+			angle := float64(135+270*t) * math.Pi / 180
+			tip := rl.Vector2{X: cx + float32(math.Cos(angle))*(radius-3), Y: cy + float32(math.Sin(angle))*(radius-3)}
+			rl.DrawLineEx(rl.Vector2{X: cx, Y: cy}, tip, 2, props.color)
+			rl.DrawText(label, int32(cx)-radius, int32(cy)+radius+2, 10, props.color)
+		case gridfluid.GaugeBar:
+			const width, height = 8, 30
+			x, y := int32(cx)-width/2, int32(cy)-height/2
+			rl.DrawRectangle(x, y, width, height, rl.Fade(rl.Black, 0.8))
+			filled := int32(float32(height) * t)
+			rl.DrawRectangle(x, y+height-filled, width, filled, props.color)
+			rl.DrawRectangleLines(x, y, width, height, rl.LightGray)
+			rl.DrawText(label, x+width+3, y+height-10, 10, props.color)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+//go:build !noexport
+
+package main
+
+import (
+	"fmt"
+	"image"
+	gifpalette "image/color/palette"
+	"image/draw"
+	"image/gif"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* GIF clips
+*
+* Shift+F12 starts capturing the window into an animated GIF, and again
+* stops and saves it as screenshots/<scene>_<time>.gif. Only every
+* gifEvery frame is kept, scaled down to at most gifWidth pixels across,
+* each shown for as long as it took to come round, and a clip stops on its
+* own after gifMaxFrames. The GIF is encoded in the background, and one
+* still being captured when the window closes is saved first. Like
+* screenshots, the key is read live and -screenshot-clean leaves the
+* readouts and tools out.
+ */
+
+const (
+	gifEvery     = 3   // Frames between the ones kept
+	gifWidth     = 480 // Most pixels across
+	gifMaxFrames = 400 // Frames a clip stops at
+	gifMinDelay  = 2   // Hundredths of a second, viewers slow down shorter frames
+)
+
+type gifClip struct {
+	name   string
+	frames []*image.Paletted
+	delays []int   // Hundredths of a second each frame is shown
+	wait   float32 // Seconds since the last frame kept
+	skip   int     // Frames since the last frame kept
+}
+
+// clipWrites are the clips being encoded
+var clipWrites sync.WaitGroup
+
+// toggleClip starts capturing a clip, or stops and saves the one being
+// captured
+func (g *Game) toggleClip() {
+	if g.clip != nil {
+		g.finishClip()
+		return
+	}
+	if !g.FeatureEnabled("export") {
+		log.Printf("gif: %v", gridfluid.FeatureOffError("export"))
+		return
+	}
+	name := filepath.Join(screenshotDir, fmt.Sprintf("%s_%s.gif", g.sceneName(), time.Now().Format("20060102_150405")))
+	g.clip = &gifClip{name: name}
+	log.Printf("capturing %s, Shift+F12 to stop", name)
+}
+
+// clipFrameDue reports whether this frame goes into the clip
+func (g *Game) clipFrameDue() bool {
+	c := g.clip
+	if c == nil {
+		return false
+	}
+	c.wait += rl.GetFrameTime()
+	c.skip++
+	return len(c.frames) == 0 || c.skip >= gifEvery
+}
+
+// captureClipFrame adds what has been drawn of the frame so far to the
+// clip
+func (g *Game) captureClipFrame() {
+	c := g.clip
+	rl.DrawRenderBatchActive()
+	img := rl.LoadImageFromScreen()
+	frame := gifFrame(img.ToImage())
+	rl.UnloadImage(img)
+
+	if len(c.frames) > 0 {
+		c.delays = append(c.delays, clipDelay(c.wait))
+	}
+	c.frames = append(c.frames, frame)
+	c.wait, c.skip = 0, 0
+	if len(c.frames) >= gifMaxFrames {
+		g.finishClip()
+	}
+}
+
+// clipDelay is how long a frame shown for seconds is, in hundredths of a
+// second
+func clipDelay(seconds float32) int {
+	return max(int(math.Round(float64(seconds)*100)), gifMinDelay)
+}
+
+// gifFrame scales the window down and maps it to the GIF palette
+func gifFrame(src image.Image) *image.Paletted {
+	b := src.Bounds()
+	step := max((b.Dx()+gifWidth-1)/gifWidth, 1)
+	small := image.NewRGBA(image.Rect(0, 0, b.Dx()/step, b.Dy()/step))
+	for y := range small.Rect.Dy() {
+		for x := range small.Rect.Dx() {
+			small.Set(x, y, src.At(b.Min.X+x*step, b.Min.Y+y*step))
+		}
+	}
+	frame := image.NewPaletted(small.Rect, gifpalette.Plan9)
+	draw.FloydSteinberg.Draw(frame, frame.Rect, small, image.Point{})
+	return frame
+}
+
+// finishClip stops capturing and saves the clip in the background
+func (g *Game) finishClip() {
+	c := g.clip
+	g.clip = nil
+	if len(c.frames) == 0 {
+		return
+	}
+	// The last frame is shown as long as the one before it
+	last := clipDelay(c.wait)
+	if n := len(c.delays); n > 0 {
+		last = c.delays[n-1]
+	}
+	c.delays = append(c.delays, last)
+	clipWrites.Add(1)
+	go func() {
+		defer clipWrites.Done()
+		if err := writeClip(c); err != nil {
+			log.Printf("gif: %v", err)
+			return
+		}
+		log.Printf("clip of %d frames saved to %s", len(c.frames), c.name)
+	}()
+}
+
+// finishClips saves the clip being captured and waits for the ones being
+// saved, before the program exits
+func (g *Game) finishClips() {
+	if g.clip != nil {
+		g.finishClip()
+	}
+	clipWrites.Wait()
+}
+
+func writeClip(c *gifClip) error {
+	if err := os.MkdirAll(filepath.Dir(c.name), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(c.name)
+	if err != nil {
+		return err
+	}
+	if err := gif.EncodeAll(f, &gif.GIF{Image: c.frames, Delay: c.delays}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
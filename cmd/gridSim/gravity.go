@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Gravity direction
+ */
+
+// drawGravity shows the gravity direction while it is rotated
+func (g *Game) drawGravity() {
+	if g.Gravity == gridfluid.GravityDown {
+		return
+	}
+	gx, gy := g.Gravity.Vector()
+	cx, cy := float32(g.Width-60), float32(120)
+	tip := rl.Vector2{X: cx + float32(gx)*15, Y: cy + float32(gy)*15}
+	rl.DrawLineEx(rl.Vector2{X: cx, Y: cy}, tip, 3, rl.RayWhite)
+	rl.DrawCircleV(tip, 4, rl.RayWhite)
+	rl.DrawText(fmt.Sprintf("gravity %s", gridfluid.GravityNames[g.Gravity]), int32(cx)-40, int32(cy)+22, 10, rl.RayWhite)
+}
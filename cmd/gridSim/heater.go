@@ -0,0 +1,17 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Heaters, ice and boiling
+ */
+
+// iceTint shows ice turning clear as it melts
+func iceTint(d *gridfluid.Droplet) rl.Color {
+	return rl.Fade(colors.ice, float32(1-0.5*math.Min(d.Thawed, 1)))
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Image scenes
+*
+* -image loads a PNG painted in any paint program as the scene, one pixel
+* per cell. Every pixel is read as the nearest of these colours:
+*
+*	white (or transparent)  empty
+*	blue                    water, filled to the brim
+*	black                   stone
+*	a material's own colour that material, see editorMaterials
+*
+* The picture is turned into a scenario of rectangles, so the editor,
+* recordings and everything else that works with scenarios works with it.
+ */
+
+const imageWater = "water" // Key of the water colour
+
+// imageColor is a colour of an image scene and what it stands for: a
+// material name, imageWater, or empty for open cells
+type imageColor struct {
+	color color.RGBA
+	key   string
+}
+
+// imagePalette lists the colours image scenes are read with
+func imagePalette() []imageColor {
+	palette := []imageColor{
+		{color.RGBA{255, 255, 255, 255}, ""},
+		{color.RGBA{0, 0, 255, 255}, imageWater},
+		{color.RGBA{0, 0, 0, 255}, gridfluid.MaterialStone.Name()},
+	}
+	for _, m := range editorMaterials {
+		palette = append(palette, imageColor{m.Color(), m.Name()})
+	}
+	return palette
+}
+
+// loadImageScenario reads a PNG and builds a scenario with cells of
+// tileSize pixels from it
+func loadImageScenario(path string, tileSize int) (*scenario, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	palette := imagePalette()
+	keys := make([][]string, b.Dy())
+	for y := range keys {
+		keys[y] = make([]string, b.Dx())
+		for x := range keys[y] {
+			keys[y][x] = nearestColor(palette, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+
+	s := &scenario{Cols: b.Dx(), Rows: b.Dy(), TileSize: tileSize, Params: gridfluid.DefaultParams()}
+	s.name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	for _, r := range cellRects(s.Cols, s.Rows, func(x, y int) string { return keys[y][x] }) {
+		if r.key == imageWater {
+			s.Water = append(s.Water, scenarioWater{Rect: r.rect})
+		} else {
+			s.Obstacles = append(s.Obstacles, scenarioObstacle{Rect: r.rect, Material: r.key})
+		}
+	}
+	// Recordings store the scene as scenario JSON
+	if s.source, err = json.Marshal(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// nearestColor is the key of the palette colour closest to c
+func nearestColor(palette []imageColor, c color.Color) string {
+	r, g, b, a := c.RGBA()
+	// Mostly transparent pixels are left empty
+	if a < 0x8000 {
+		return ""
+	}
+	best, key := -1, ""
+	for _, p := range palette {
+		dr, dg, db := int(r>>8)-int(p.color.R), int(g>>8)-int(p.color.G), int(b>>8)-int(p.color.B)
+		if d := dr*dr + dg*dg + db*db; best < 0 || d < best {
+			best, key = d, p.key
+		}
+	}
+	return key
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"log"
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Input
+*
+* F  toggle flux heat map (with Shift: the velocity arrows, with Ctrl: the
+*    streamlines)
+* R  reset accumulated flux
+* X  export flux heat map as PNG
+* M  randomize solver parameters (with Ctrl: toggle the parameter panel,
+*    see panel.go)
+* K  keep mutated parameters and save them as a preset
+* Backspace  revert mutated parameters
+* L  load the latest preset
+* [ ]  decrease / increase horizontal wind (with Shift: vertical)
+* \  calm the wind
+* I  toggle fluid interface outlines (with Shift: the light shafts and
+*    caustics)
+* O  toggle VOF surface reconstruction (with Shift: switch between the
+*    smooth water surface and a block per cell, with Ctrl: toggle the
+*    water shader)
+* F1  toggle the statistics: frame rate, updates per second, volume, wet
+*     and active cells, pressure, flow and generator output
+* F2  mark a corner of a measurement region, the second press adds it
+*     (inside a region: remove it)
+* F3  toggle the measurement region labels
+* F4  cycle the weather (clear, rain, snow)
+* F5  save the world to quicksave.wss
+* F6  connect the cell at the cursor to the neighbouring layer, or cut it
+* F7  focus the next depth layer (with Shift: add a layer behind)
+* + -  halve / double the cell size, resampling the scene
+* Ctrl+C  copy the scene code to the clipboard
+* Ctrl+V  load a scene code from the clipboard
+* Ctrl+Z  undo the last edit, Ctrl+Y  redo it
+* Tab  enter / leave the editor, see editor.go for its keys
+* F8  write an issue report bundle
+* F9  load the world from quicksave.wss
+* F10  rewind to the last checkpoint, see rewind.go
+* F11  toggle the grid lines and the tooltip of the cell under the mouse
+* F12  save a screenshot to screenshots/ (with Shift: start / stop
+*      capturing a GIF clip there), see screenshot.go and gif.go
+* B  drop a crate at the cursor (with Shift: a ball)
+* D (hold)  inject dye at the cursor
+* C  cycle the dye colour (with Shift: the colour theme)
+* Z  toggle the sponge boundary zones
+* Alt+Arrow  cycle the left/right/top/bottom edge through wall, open and wrap
+* T  toggle teaching mode (slow motion replay of one update)
+* G  place or remove a gauge at the cursor
+* Shift+G  cycle the gauge kind, Ctrl+G  switch dial/bar
+* 1-9, 0  pick the droplet size, 0.1 to 1.0 (with Ctrl, 1-3: split the
+*    window into that many panes, see split.go)
+* Enter  drop a single droplet at the cursor, Alt+Enter  toggle
+*    fullscreen, see resize.go
+* Space  pause / run the sim, .  run one update (pausing first)
+* ; '  slow the sim down / speed it up, 0.1x to 8x
+* A  drop a droplet of acid at the cursor
+* P  toggle the pressure heat map (with Shift: plant a seedling at the
+*    cursor; with Ctrl: toggle the pressure isolines)
+* E  set the wood at the cursor on fire
+* S  place a salt block at the cursor (with Shift: sand)
+* W  place or remove a vortex drain at the cursor (with Shift: toggle the
+*    shimmer of the water)
+* H  place a fan at the cursor, or turn it (with Shift: switch it on/off)
+* N  place or remove a heater at the cursor (with Shift: place ice)
+* V  place or remove an air vent at the cursor
+* U  place a pressure plate at the cursor, again for a float switch
+* Y  place or remove a gate at the cursor (with Shift: a spring)
+* J  start a wire at the sensor under the cursor, again on a device to
+*    connect it (with Shift: inverted); on a device alone, cut its wires
+* Q  place a portal end at the cursor, every second one closes the pair
+* Shift+, Shift+.  rotate gravity a quarter turn counter-clockwise /
+*    clockwise
+* Middle mouse  explosion at the cursor
+* Left mouse (hold)  pour water under the brush, Right mouse (hold)  take
+*    it out, Ctrl+Mouse wheel  resize the brush
+* Arrows  scroll the view over a world larger than the window (with Shift:
+*    faster), Shift+Right mouse  drag the view, Mouse wheel  zoom in / out
+*    at the cursor, Left mouse on the minimap  move the view there
+ */
+
+func (g *Game) HandleInput() {
+	shift := g.keyDown(rl.KeyLeftShift) || g.keyDown(rl.KeyRightShift)
+	ctrl := g.keyDown(rl.KeyLeftControl) || g.keyDown(rl.KeyRightControl)
+	alt := g.keyDown(rl.KeyLeftAlt) || g.keyDown(rl.KeyRightAlt)
+
+	if !alt && g.teach == nil {
+		g.handleScroll(shift, ctrl)
+	}
+	if g.keyPressed(rl.KeyTab) {
+		g.toggleEditor()
+	}
+	if g.editor != nil {
+		g.handleEditorInput(shift, ctrl)
+		return
+	}
+	if alt {
+		edgeKeys := [gridfluid.EdgeCount]int32{gridfluid.EdgeLeft: rl.KeyLeft, gridfluid.EdgeRight: rl.KeyRight, gridfluid.EdgeTop: rl.KeyUp, gridfluid.EdgeBottom: rl.KeyDown}
+		for edge, key := range edgeKeys {
+			if g.keyPressed(key) {
+				g.ToggleBoundary(gridfluid.Edge(edge))
+			}
+		}
+	}
+
+	if g.keyPressed(rl.KeyF) {
+		switch {
+		case ctrl:
+			g.showStreamlines = !g.showStreamlines
+		case shift:
+			g.showVelocity = !g.showVelocity
+		default:
+			g.showFlux = !g.showFlux
+		}
+	}
+	if g.keyPressed(rl.KeyI) {
+		if shift {
+			g.noLight = !g.noLight
+		} else {
+			g.showInterface = !g.showInterface
+		}
+	}
+	if g.keyPressed(rl.KeyO) {
+		switch {
+		case ctrl:
+			g.plainWater = !g.plainWater
+		case shift:
+			g.blockyWater = !g.blockyWater
+		default:
+			g.Params.VOF = !g.Params.VOF
+		}
+	}
+	if g.keyPressed(rl.KeyR) {
+		g.ResetFlux()
+	}
+	if g.keyPressed(rl.KeyX) {
+		name, err := g.ExportFlux()
+		if err != nil {
+			log.Printf("export flux: %v", err)
+		} else {
+			log.Printf("flux heat map written to %s", name)
+		}
+	}
+
+	if !g.handlePanel() && !g.handleTimeline() && !g.handleMinimap() {
+		g.handleBrush(shift, ctrl)
+	}
+	if g.mousePressed(rl.MouseButtonMiddle) {
+		x, y := g.cellAtMouse()
+		g.Explode(x, y, explosionRadius)
+	}
+
+	if ctrl {
+		g.selectPanes()
+	} else {
+		g.selectDropVolume()
+	}
+	if g.keyPressed(rl.KeyEnter) && !alt {
+		g.editAtMouse(func(x, y int) { g.DropWater(x, y, g.dropVolume) })
+	}
+
+	if g.keyPressed(rl.KeyA) {
+		g.editAtMouse(func(x, y int) { g.DropAcid(x, y, g.dropVolume) })
+	}
+	if g.keyPressed(rl.KeyP) {
+		switch {
+		case ctrl:
+			g.showIsolines = !g.showIsolines
+		case shift:
+			g.editAtMouse(func(x, y int) { g.PlantSeed(x, y) })
+		default:
+			g.showPressure = !g.showPressure
+		}
+	}
+	if g.keyPressed(rl.KeyE) {
+		g.editAtMouse(func(x, y int) { g.Ignite(x, y) })
+	}
+	if g.keyPressed(rl.KeyS) {
+		g.editAtMouse(func(x, y int) {
+			if shift {
+				g.PlaceSand(x, y)
+			} else {
+				g.PlaceSalt(x, y)
+			}
+		})
+	}
+	if g.keyPressed(rl.KeyW) {
+		if shift {
+			g.stillWater = !g.stillWater
+		} else {
+			g.editAtMouse(func(x, y int) { g.PlaceVortex(x, y) })
+		}
+	}
+	if g.keyPressed(rl.KeyN) {
+		g.editAtMouse(func(x, y int) {
+			if shift {
+				g.PlaceIce(x, y)
+			} else {
+				g.PlaceHeater(x, y)
+			}
+		})
+	}
+	if !ctrl && g.keyPressed(rl.KeyV) {
+		g.editAtMouse(func(x, y int) { g.PlaceVent(x, y) })
+	}
+	if g.keyPressed(rl.KeyU) {
+		g.editAtMouse(func(x, y int) { g.PlaceSensor(x, y) })
+	}
+	if !ctrl && g.keyPressed(rl.KeyY) {
+		g.editAtMouse(func(x, y int) {
+			if shift {
+				g.PlaceSpring(x, y)
+			} else {
+				g.PlaceGate(x, y)
+			}
+		})
+	}
+	if g.keyPressed(rl.KeyJ) {
+		g.editAtMouse(func(x, y int) { g.wireAt(x, y, shift) })
+	}
+	if g.keyPressed(rl.KeyQ) {
+		g.editAtMouse(func(x, y int) { g.PlacePortal(x, y) })
+	}
+	if g.keyPressed(rl.KeyH) {
+		g.editAtMouse(func(x, y int) {
+			if shift {
+				g.ToggleFan(x, y)
+			} else {
+				g.PlaceFan(x, y)
+			}
+		})
+	}
+	if g.keyPressed(rl.KeyB) {
+		kind := gridfluid.DebrisCrate
+		if shift {
+			kind = gridfluid.DebrisBall
+		}
+		x, y := g.mouseGrid()
+		g.AddDebris(kind, x, y)
+	}
+	if g.keyDown(rl.KeyD) {
+		x, y := g.cellAtMouse()
+		g.InjectDye(x, y, dyePalette[g.dyeIndex])
+	}
+	if g.keyPressed(rl.KeyC) && !ctrl {
+		if shift {
+			g.cycleTheme()
+		} else {
+			g.dyeIndex = (g.dyeIndex + 1) % len(dyePalette)
+		}
+	}
+	if g.keyPressed(rl.KeyG) {
+		switch {
+		case shift:
+			g.gaugeKind = (g.gaugeKind + 1) % gridfluid.GaugeKindCount
+		case ctrl:
+			g.gaugeStyle = 1 - g.gaugeStyle
+		default:
+			g.editAtMouse(func(x, y int) { g.ToggleGauge(x, y, g.gaugeKind, g.gaugeStyle) })
+		}
+	}
+	if g.keyPressed(rl.KeyT) {
+		g.ToggleTeaching()
+	}
+	if g.teach != nil {
+		g.handleTeachInput()
+	}
+	if !ctrl && g.keyPressed(rl.KeyZ) {
+		g.Sponge.Enabled = !g.Sponge.Enabled
+	}
+	if ctrl && g.keyPressed(rl.KeyZ) {
+		g.undoEdit(false)
+	}
+	if ctrl && g.keyPressed(rl.KeyY) {
+		g.undoEdit(true)
+	}
+
+	if g.keyPressed(rl.KeyF10) {
+		g.rewindOnce()
+	}
+	if g.keyPressed(rl.KeyF8) {
+		g.wantReport = true
+	}
+
+	if g.keyPressed(rl.KeyM) {
+		if ctrl {
+			g.showPanel = !g.showPanel
+		} else {
+			g.Mutate()
+		}
+	}
+	if g.keyPressed(rl.KeyK) {
+		name, err := g.KeepMutation()
+		if err != nil {
+			log.Printf("save preset: %v", err)
+		} else if name != "" {
+			log.Printf("preset saved to %s", name)
+		}
+	}
+	if g.keyPressed(rl.KeyBackspace) {
+		g.RevertMutation()
+	}
+	if ctrl && g.keyPressed(rl.KeyC) {
+		code, err := g.SceneCode()
+		if err != nil {
+			log.Printf("encode scene: %v", err)
+		} else {
+			rl.SetClipboardText(code)
+			log.Printf("scene code copied to clipboard (%d chars)", len(code))
+		}
+	}
+	if ctrl && g.keyPressed(rl.KeyV) {
+		if err := g.LoadSceneCode(g.clipboardText()); err != nil {
+			log.Printf("paste scene: %v", err)
+		} else {
+			// The pending wire may not start at a sensor any more
+			g.wireFrom = nil
+		}
+	}
+	if g.keyPressed(rl.KeyF1) {
+		g.showStats = !g.showStats
+	}
+	if g.keyPressed(rl.KeyF11) {
+		g.inspect = !g.inspect
+	}
+	if g.keyPressed(rl.KeyF2) {
+		x, y := g.cellAtMouse()
+		g.markRegion(x, y)
+	}
+	if g.keyPressed(rl.KeyF3) {
+		g.showRegionLabels = !g.showRegionLabels
+	}
+	if g.keyPressed(rl.KeyF4) {
+		g.CycleWeather()
+	}
+	if g.keyPressed(rl.KeyF5) {
+		g.quickSave()
+	}
+	if g.keyPressed(rl.KeyF9) {
+		g.quickLoad()
+	}
+	if g.keyPressed(rl.KeyF7) {
+		if shift {
+			g.AddLayer()
+		} else {
+			g.FocusLayer((g.FocusedLayer() + 1) % g.Layers())
+		}
+	}
+	if g.keyPressed(rl.KeyF6) {
+		g.editAtMouse(func(x, y int) { g.PlaceConnector(x, y) })
+	}
+	if g.keyPressed(rl.KeyEqual) || g.keyPressed(rl.KeyKpAdd) {
+		if err := g.SetTileSize(g.TileSize() / 2); err != nil {
+			log.Printf("resample: %v", err)
+		}
+	}
+	if g.keyPressed(rl.KeyMinus) || g.keyPressed(rl.KeyKpSubtract) {
+		if err := g.SetTileSize(g.TileSize() * 2); err != nil {
+			log.Printf("resample: %v", err)
+		}
+	}
+	if g.keyPressed(rl.KeyLeftBracket) {
+		if shift {
+			g.AdjustWind(0, -windStep)
+		} else {
+			g.AdjustWind(-windStep, 0)
+		}
+	}
+	if g.keyPressed(rl.KeyRightBracket) {
+		if shift {
+			g.AdjustWind(0, windStep)
+		} else {
+			g.AdjustWind(windStep, 0)
+		}
+	}
+	if g.keyPressed(rl.KeyBackSlash) {
+		g.Wind.X, g.Wind.Y = 0, 0
+	}
+
+	if g.keyPressed(rl.KeySpace) {
+		g.togglePause()
+	}
+	if g.keyPressed(rl.KeyPeriod) && !shift {
+		g.stepPaused()
+	}
+	if g.keyPressed(rl.KeySemicolon) {
+		g.stepTimeScale(-1)
+	}
+	if g.keyPressed(rl.KeyApostrophe) {
+		g.stepTimeScale(1)
+	}
+	if g.keyPressed(rl.KeyComma) && shift {
+		g.RotateGravity(-1)
+	}
+	if g.keyPressed(rl.KeyPeriod) && shift {
+		g.RotateGravity(1)
+	}
+
+	if g.keyPressed(rl.KeyL) {
+		name, err := g.LoadLatestPreset()
+		if err != nil {
+			log.Printf("load preset: %v", err)
+		} else {
+			log.Printf("preset loaded from %s", name)
+			g.input.resync = true
+		}
+	}
+}
+
+// mouseGrid converts the mouse position into fractional grid coordinates
+func (g *Game) mouseGrid() (float64, float64) {
+	pos := g.mouseWorld()
+	return float64(pos.X) / float64(g.TileSize()), float64(pos.Y) / float64(g.TileSize())
+}
+
+// cellAtMouse returns the grid cell under the mouse
+func (g *Game) cellAtMouse() (int, int) {
+	x, y := g.mouseGrid()
+	return int(math.Floor(x)), int(math.Floor(y))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+/*
+* Cell inspector
+*
+* F11 draws faint lines between the cells and a tooltip next to the mouse
+* with the exact readings of the cell under it: where it is, what it is
+* made of, and its fluid, volume, pressure and velocity. The lines are left
+* out while the cells are too small on screen to tell apart.
+ */
+
+const (
+	gridLineMinPixels = 4  // Window pixels a cell needs for the lines to be drawn
+	tooltipOffset     = 14 // Window pixels from the mouse to the tooltip
+)
+
+// drawGridLines draws the lines between the cells in view
+func (g *Game) drawGridLines() {
+	z := g.zoomLevel()
+	ts := float32(g.TileSize())
+	if !g.inspect || ts*z < gridLineMinPixels {
+		return
+	}
+	x0, y0, x1, y1 := g.visibleCells()
+	c := rl.Fade(rl.Gray, 0.25)
+	// A window pixel wide at any zoom
+	width := 1 / z
+	for x := x0; x <= x1; x++ {
+		rl.DrawLineEx(rl.Vector2{X: float32(x) * ts, Y: float32(y0) * ts}, rl.Vector2{X: float32(x) * ts, Y: float32(y1) * ts}, width, c)
+	}
+	for y := y0; y <= y1; y++ {
+		rl.DrawLineEx(rl.Vector2{X: float32(x0) * ts, Y: float32(y) * ts}, rl.Vector2{X: float32(x1) * ts, Y: float32(y) * ts}, width, c)
+	}
+}
+
+// drawInspector shows the readings of the cell under the mouse
+func (g *Game) drawInspector() {
+	if !g.inspect {
+		return
+	}
+	x, y := g.cellAtMouse()
+	if !g.InBounds(x, y) {
+		return
+	}
+	d := &g.State[y][x]
+	lines := []string{
+		fmt.Sprintf("cell %d, %d", x, y),
+		fmt.Sprintf("material %s", d.Material.Name()),
+	}
+	if d.Material.IsSolid() {
+		lines = append(lines, fmt.Sprintf("wetness %.4f", d.Wetness))
+	} else {
+		lines = append(lines,
+			fmt.Sprintf("%s %.4f", d.Fluid.Name(), d.Volume),
+			fmt.Sprintf("pressure %.4f", d.Pressure),
+			fmt.Sprintf("velocity %+.4f, %+.4f", d.VX, d.VY),
+		)
+	}
+
+	w := int32(0)
+	for _, line := range lines {
+		w = max(w, rl.MeasureText(line, 10))
+	}
+	w += 8
+	h := int32(len(lines))*14 + 6
+	m := g.mousePosition()
+	px := min(int32(m.X)+tooltipOffset, int32(g.Width)-w)
+	py := min(int32(m.Y)+tooltipOffset, int32(g.Height)-h)
+	rl.DrawRectangle(px, py, w, h, rl.Fade(rl.Black, 0.75))
+	for i, line := range lines {
+		rl.DrawText(line, px+4, py+4+int32(i)*14, 10, rl.RayWhite)
+	}
+}
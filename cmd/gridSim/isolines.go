@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Pressure isolines
+*
+* Ctrl+P draws lines of equal pressure through the water, traced with
+* marching squares over the pressure of the wet cells, at every multiple of
+* -isoline-step or, without it, of a tenth of the highest pressure. In
+* still water they lie flat and evenly spaced; where they bunch up or tilt
+* the water is still finding its level. Squares touching a dry or solid
+* cell are left out, so lines stop at the surface and at walls.
+ */
+
+const (
+	isolineLevels = 10  // Lines up to the highest pressure without -isoline-step
+	isolineWidth  = 1.5 // Window pixels
+)
+
+// isolineInterval is the pressure between neighbouring lines, 0 if there
+// are none to draw
+func (g *Game) isolineInterval() float64 {
+	if g.isolineStep > 0 {
+		return g.isolineStep
+	}
+	return g.maxPressure() / isolineLevels
+}
+
+// drawIsolines draws the lines of equal pressure inside the view
+func (g *Game) drawIsolines() {
+	if !g.showIsolines {
+		return
+	}
+	step := g.isolineInterval()
+	if step <= 0 {
+		return
+	}
+	ts := float32(g.TileSize())
+	width := isolineWidth / g.zoomLevel()
+	c := rl.Fade(rl.White, 0.7)
+	wet := func(x, y int) bool {
+		d := &g.State[y][x]
+		return !d.Material.IsSolid() && d.Volume > gridfluid.WetThreshold
+	}
+	x0, y0, x1, y1 := g.visibleCells()
+	// Squares join the centres of four cells, clockwise from the top left
+	corners := [4][2]int{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+	for y := max(y0-1, 0); y < min(y1, len(g.State)-1); y++ {
+		for x := max(x0-1, 0); x < min(x1, len(g.State[y])-1); x++ {
+			var p [4]float64
+			var at [4]rl.Vector2
+			lo, hi, ok := math.Inf(1), math.Inf(-1), true
+			for i, k := range corners {
+				cx, cy := x+k[0], y+k[1]
+				if !wet(cx, cy) {
+					ok = false
+					break
+				}
+				p[i] = g.State[cy][cx].Pressure
+				at[i] = rl.Vector2{X: (float32(cx) + 0.5) * ts, Y: (float32(cy) + 0.5) * ts}
+				lo, hi = min(lo, p[i]), max(hi, p[i])
+			}
+			if !ok {
+				continue
+			}
+			for level := math.Ceil(lo/step) * step; level < hi; level += step {
+				drawIsolineSquare(at, p, level, width, c)
+			}
+		}
+	}
+}
+
+// drawIsolineSquare draws where level crosses a square with corners at,
+// holding pressures p. A saddle's four crossings are joined in pairs
+// along the edges.
+func drawIsolineSquare(at [4]rl.Vector2, p [4]float64, level float64, width float32, c rl.Color) {
+	var crossings []rl.Vector2
+	for i := range at {
+		j := (i + 1) % len(at)
+		if (p[i] >= level) != (p[j] >= level) {
+			crossings = append(crossings, rl.Vector2Lerp(at[i], at[j], float32((level-p[i])/(p[j]-p[i]))))
+		}
+	}
+	for i := 0; i+1 < len(crossings); i += 2 {
+		rl.DrawLineEx(crossings[i], crossings[i+1], width, c)
+	}
+}
+
+// drawIsolineLegend shows the pressure between the lines
+func (g *Game) drawIsolineLegend() {
+	if !g.showIsolines {
+		return
+	}
+	text := fmt.Sprintf("isolines every %.3f", g.isolineInterval())
+	x, y := int32(g.Width-legendWidth-10), int32(g.Height-130)
+	rl.DrawRectangle(x-4, y-4, legendWidth+8, 18, rl.Fade(rl.Black, 0.6))
+	rl.DrawText(text, x, y, 10, rl.RayWhite)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+/*
+* Depth layers
+ */
+
+const layerDim = 0.45 // Darkening laid over everything behind a layer
+
+// drawLayers draws the layers back to front, dimming everything behind
+// each one
+func (g *Game) drawLayers() {
+	if g.Layers() == 1 {
+		g.drawGrid()
+		return
+	}
+	focus := g.FocusedLayer()
+	for i := g.Layers() - 1; i >= 0; i-- {
+		if i < g.Layers()-1 {
+			rl.DrawRectangleRec(g.view, rl.Fade(rl.Black, layerDim))
+		}
+		g.FocusLayer(i)
+		g.drawGrid()
+	}
+	g.FocusLayer(focus)
+
+	ts := int32(g.TileSize())
+	x0, y0, x1, y1 := g.visibleCells()
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			if g.State[y][x].Connector {
+				rl.DrawRectangleLines(int32(x)*ts+2, int32(y)*ts+2, ts-4, ts-4, colors.connector)
+			}
+		}
+	}
+}
+
+// drawLayerLabel shows which layer has the focus
+func (g *Game) drawLayerLabel() {
+	if g.Layers() == 1 {
+		return
+	}
+	rl.DrawText(fmt.Sprintf("layer %d/%d", g.FocusedLayer()+1, g.Layers()), int32(g.Width-110), 110, 10, colors.connector)
+}
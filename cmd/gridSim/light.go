@@ -0,0 +1,119 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Light
+*
+* Sunlight comes down through the water: shafts of it fall from the surface
+* and fade with depth, and the floor and the tops of obstacles under water
+* carry moving caustics, a tiling pattern of bright lines drawn twice
+* drifting apart. Both fade out the deeper below its surface they are, the
+* surface found for every column. Shift+I leaves the light out.
+ */
+
+const (
+	causticSize   = 96   // Pixels across the caustic pattern, drawn a world pixel per pixel
+	causticDrift  = 9    // Pixels per second the pattern drifts by
+	causticAlpha  = 0.35 // Opacity of the caustics just under the surface
+	shaftAlpha    = 0.18 // Opacity of the brightest shaft at the surface
+	lightDepth    = 14   // Cells below the surface the light is gone by
+	shaftWaveRate = 0.5  // Radians per second the shafts sway by
+)
+
+type light struct {
+	caustics rl.Texture2D
+}
+
+// loadLight makes the caustic texture, once there is a window
+func loadLight() *light {
+	img := rl.NewImageFromImage(causticImage())
+	defer rl.UnloadImage(img)
+	tex := rl.LoadTextureFromImage(img)
+	rl.SetTextureWrap(tex, rl.WrapRepeat)
+	return &light{caustics: tex}
+}
+
+func (l *light) unload() {
+	if l != nil {
+		rl.UnloadTexture(l.caustics)
+	}
+}
+
+// causticImage is the caustic pattern: white lines along where waves of
+// whole periods across it cancel out, so it tiles
+func causticImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, causticSize, causticSize))
+	for y := range causticSize {
+		for x := range causticSize {
+			u := float64(x) / causticSize * 2 * math.Pi
+			v := float64(y) / causticSize * 2 * math.Pi
+			s := math.Sin(2*u+v) + math.Sin(u-3*v) + math.Sin(3*u+2*v)
+			a := math.Pow(1-math.Abs(s)/3, 8)
+			img.SetRGBA(x, y, color.RGBA{255, 255, 255, uint8(a * 255)})
+		}
+	}
+	return img
+}
+
+// drawLight draws the light shafts and the caustics under the water
+func (g *Game) drawLight() {
+	if g.light == nil || g.noLight {
+		return
+	}
+	ts := g.TileSize()
+	t := rl.GetTime()
+	// The surface may be above the view, so every column is followed down
+	// from the top
+	x0, y0, x1, y1 := g.visibleCells()
+	for x := x0; x < x1; x++ {
+		sway := math.Sin(float64(x)*0.35+t*shaftWaveRate) * math.Sin(float64(x)*0.13-t*shaftWaveRate*0.7)
+		shaft := float32(sway*sway) * shaftAlpha
+		surface := -1 // Row of the surface of the water the cell is in
+		for y := 0; y < y1; y++ {
+			d := &g.State[y][x]
+			switch {
+			case d.Material.IsSolid():
+				if surface >= 0 && y >= y0 {
+					g.drawCaustics(x, y, y-surface, t)
+				}
+				surface = -1
+			case d.Volume <= gridfluid.WetThreshold:
+				surface = -1
+			case surface < 0:
+				surface = y
+				fallthrough
+			default:
+				depth := float32(y - surface)
+				if shaft == 0 || depth >= lightDepth || y < y0 {
+					continue
+				}
+				top, bottom := shaft*(1-depth/lightDepth), shaft*(1-(depth+1)/lightDepth)
+				rl.DrawRectangleGradientV(int32(x*ts), int32(y*ts), int32(ts), int32(ts), rl.Fade(colors.foam, top), rl.Fade(colors.foam, bottom))
+			}
+		}
+	}
+}
+
+// drawCaustics lays the caustics over the obstacle at (x, y), depth cells
+// below the surface of the water on top of it
+func (g *Game) drawCaustics(x, y, depth int, t float64) {
+	if depth >= lightDepth {
+		return
+	}
+	ts := float32(g.TileSize())
+	dst := rl.Rectangle{X: float32(x) * ts, Y: float32(y) * ts, Width: ts, Height: ts}
+	tint := rl.Fade(colors.foam, causticAlpha*(1-float32(depth)/lightDepth))
+	drift := float32(t * causticDrift)
+	for _, dir := range []float32{1, -1} {
+		src := rl.Rectangle{X: dst.X + drift*dir, Y: dst.Y + drift*0.6, Width: ts, Height: ts}
+		rl.DrawTexturePro(g.light.caustics, src, dst, rl.Vector2{}, 0, tint)
+	}
+}
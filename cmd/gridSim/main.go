@@ -1,387 +1,677 @@
 package main
 
 import (
-	"math"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"runtime"
+	"time"
 
 	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
 )
 
 /*
 * Droplets
  */
 
-type Droplet struct {
-	volume     float64 // How much water this cell contains (0.0 to 1.0)
-	size       int
-	isObstacle bool // Is this cell an obstacle?
-
-	vx, vy   float64 // Velocity components
-	pressure float64 // hydrostatic pressure
-}
-
-func (d *Droplet) Draw(x, y, tileSize int, hasWaterAbove bool) {
+// drawDroplet draws the cell at (x, y), its water with drawWater, which
+// returns how far down the cell the water starts
+func drawDroplet(d *gridfluid.Droplet, x, y, tileSize int, drawWater func() int) {
 	// Convert grid coordinates to pixel coordinates
 	pixelX := x * tileSize
 	pixelY := y * tileSize
 
-	if d.isObstacle {
-		// Draw obstacle as brown rectangle
-		rl.DrawRectangle(int32(pixelX), int32(pixelY), int32(tileSize), int32(tileSize), rl.Brown)
+	if d.Pipe != gridfluid.PipeNone {
+		drawPipe(d, pixelX, pixelY, tileSize)
+		return
 	}
 
-	if d.volume > 0 {
-		// Calculate visual height based on volume
-		// Full volume (1.0) = full tile height, half volume (0.5) = half tile height
-		height := int(float64(tileSize) * d.volume)
+	drawMaterial(d, pixelX, pixelY, tileSize)
 
-		// Fill up from the bottom
-		offsetY := tileSize - height
-		// if water above, fill from the top
-		if hasWaterAbove {
-			offsetY = 0
+	if d.Material.IsSolid() {
+		if d.Ramp != gridfluid.RampNone {
+			drawRamp(d, pixelX, pixelY, tileSize)
+		} else {
+			// Draw obstacle as brown rectangle, darkened where it is wet
+			rl.DrawRectangle(int32(pixelX), int32(pixelY), int32(tileSize), int32(tileSize), obstacleColor(d))
 		}
-		// Draw the droplet
-		pressureColor := uint8(math.Min(d.pressure*40+d.volume*100, 255))
-		rl.DrawRectangle(int32(pixelX), int32(pixelY+offsetY), int32(tileSize), int32(tileSize), rl.NewColor(0, 0, pressureColor, 255))
 	}
-}
 
-func CreateWaterGenerator(x, y, tileSize int, state *[][]Droplet) {
-	for xOffset := 0; xOffset <= 4; xOffset++ {
-		droplet := Droplet{size: tileSize, volume: 1.0}
-		(*state)[y][x+xOffset] = droplet
-	}
+	drawFoam(d, pixelX, pixelY, tileSize, drawWater())
+	drawMaterialGrain(d, pixelX, pixelY, tileSize)
+	drawFoamLine(d, pixelX, pixelY, tileSize)
 }
 
-func CreateHorizontalObstacle(x, y, size int, state *[][]Droplet) {
-	for offset := 0; offset < size; offset++ {
-		(*state)[y][x+offset].isObstacle = true
-		(*state)[y+1][x+offset].isObstacle = true
-		(*state)[y+2][x+offset].isObstacle = true
+// drawWaterBlock draws the water of a cell at (pixelX, pixelY) as a block
+// of colour c as deep as it is full, returning how far down the cell it
+// starts
+func drawWaterBlock(d *gridfluid.Droplet, pixelX, pixelY, tileSize int, hasWaterAbove bool, c rl.Color) int {
+	if d.Volume <= 0 {
+		return tileSize
 	}
-}
-func CreateVerticalObstacle(x, y, size int, state *[][]Droplet) {
-	for offset := 0; offset < size; offset++ {
-		(*state)[y+offset][x].isObstacle = true
-		(*state)[y+offset][x+1].isObstacle = true
-		(*state)[y+offset][x+2].isObstacle = true
+	// Calculate visual height based on volume
+	// Full volume (1.0) = full tile height, half volume (0.5) = half tile height
+	height := int(float64(tileSize) * d.Volume)
+
+	// Fill up from the bottom
+	offsetY := tileSize - height
+	// if water above, fill from the top
+	if hasWaterAbove {
+		offsetY = 0
 	}
+	// Draw the droplet, kept inside the cell so see-through water doesn't
+	// overlap the cell below
+	rl.DrawRectangle(int32(pixelX), int32(pixelY+offsetY), int32(tileSize), int32(tileSize-offsetY), c)
+	return offsetY
 }
 
 /*
-* Game / GameState
+* Game
+*
+* The simulation lives in pkg/gridfluid. Game wraps it with what only the
+* window needs: the view, the tools picked with the keyboard and the
+* visuals that outlast a single update.
  */
 
 type Game struct {
-	Width    int
-	Height   int
-	State    [][]Droplet // 2D grid of droplets
-	tileSize int
+	*gridfluid.Game
+
+	recentFrames []string // Scene codes of recent frames for issue reports
+	wantReport   bool     // Write a report once the current frame is drawn
+	capturing    bool     // Drawing a clean screenshot, see screenshot.go
+	clip         *gifClip // The GIF clip being captured, see gif.go
+
+	showFlux         bool                 // Render the flux heat map instead of the water
+	showInterface    bool                 // Outline boundaries between different fluids
+	showRegionLabels bool                 // Label the regions with their readings
+	showStats        bool                 // Show the sim's statistics, see stats.go
+	inspect          bool                 // Grid lines and the cell tooltip, see inspect.go
+	rates            rateCounter          // Updates and generator output per second, see stats.go
+	showPressure     bool                 // Colour the water by pressure, see pressure.go
+	showVelocity     bool                 // Draw the velocity arrows, see velocity.go
+	theme            int                  // Index into themes, see theme.go
+	blockyWater      bool                 // Draw the water a block per cell, see smooth.go
+	plainWater       bool                 // Draw the water without the shader, see shader.go
+	noLight          bool                 // Leave out the light shafts and caustics, see light.go
+	stillWater       bool                 // Leave out the shimmer, see shimmer.go
+	showIsolines     bool                 // Draw lines of equal pressure, see isolines.go
+	showStreamlines  bool                 // Draw tracers carried by the flow, see streamlines.go
+	showPanel        bool                 // Show the parameter sliders, see panel.go
+	panelMoved       map[string]float64   // Sliders moved while the panel was drawn, by parameter
+	mutation         *mutation            // Pending randomized parameters awaiting keep/revert
+	dyeIndex         int                  // Selected colour in dyePalette
+	flashes          []flash              // Explosion flashes still fading out
+	gaugeKind        gridfluid.GaugeKind  // Kind of gauge placed next
+	gaugeStyle       gridfluid.GaugeStyle // Style of gauge placed next
+	wireFrom         *[2]int              // Sensor of a wire still waiting for its device
+	regionCorner     *[2]int              // First corner of a region being marked
+	dropVolume       float64              // Size of the droplet dropped with Enter
+	brushRadius      int                  // Reach of the water brush in cells, see brush.go
+	brushErasing     bool                 // The brush takes water out this frame
+
+	view       rl.Rectangle // Part of the world being drawn, in world pixels
+	viewOffset float32      // Window pixels from the left the view is drawn at, see split.go
+	scroll     rl.Vector2   // Top left corner of the view, see view.go
+	zoom       float32      // Window pixels per world pixel, 0 for the render scale
+	fit        windowSize   // Window the cells were sized for before the first resize, see resize.go
+	panes      int          // Panes the window is split into, 0 or 1 for none, see split.go
+
+	renderScale float32 // Window pixels per world pixel unzoomed, 0 for 1, see view.go
+	isolineStep float64 // Pressure between isolines, 0 for a tenth of the highest, see isolines.go
+
+	teach    *teachMode     // Slow motion playback of a traced update
+	editor   *editorMode    // Paused scene building, see editor.go
+	paused   bool           // Updates stopped, see pause.go
+	stepOnce bool           // Run one update this frame despite the pause
+	level    scene          // What tops the water up every update
+	watch    *scenarioWatch // The -scenario file, rebuilt when it changes
+
+	checkpoints []checkpoint       // Recent worlds to rewind to, see rewind.go
+	audit       *conservationAudit // Nil unless -debug-conservation, see conservation.go
+
+	waterShader *waterShader // Nil without a window or when it fails to compile
+	tiles       *tileset     // The scene's tileset once drawn, see tileset.go
+	scenery     *backdrop    // The scene's background once drawn, see background.go
+	spray       *spray       // Nil without a window, see spray.go
+	mist        *mist        // Nil without a window, see mist.go
+	streams     *streamlines // Nil unless shown, see streamlines.go
+	light       *light       // Nil without a window, see light.go
+	minimap     *minimap     // Made when first drawn, see minimap.go
+	pass        gridPass     // What drawGrid draws of the front layer
+
+	input      inputTape     // Keyboard and mouse, live or from a recording, see replay.go
+	frameCount int           // Updates the scene has been topped up for
+	statsLog   *json.Encoder // The -stats file, nil without one
 }
 
+// NewGame creates a game with a grid filling a w by h window
 func NewGame(w, h, ts int) *Game {
+	return &Game{Game: gridfluid.NewGame(w, h, ts), dropVolume: defaultDropVolume, brushRadius: defaultBrushRadius, showRegionLabels: true}
+}
 
-	g := &Game{Width: w, Height: h, tileSize: ts}
-
-	// Create the new game state
-	// divide pixel dimensions by tile size to get grid size
-	g.State = CreateGameState(g.Width/g.tileSize, g.Height/g.tileSize, ts)
-	return g
+// Update advances the simulation by one step
+func (g *Game) Update() {
+	g.Game.Update()
+	g.updateSpray()
+	g.updateMist()
+	g.recordReportFrame()
+	g.logStats()
+	g.takeCheckpoint()
 }
 
-func (g *Game) Draw() {
-	// Loop through the grid and draw each droplet
-	for y := range g.State {
-		for x := 0; x < len(g.State[y]); x++ {
-			// Check if there is water above this cell
-			hasWaterAbove := y > 0 && g.State[y-1][x].volume > 0
-			g.State[y][x].Draw(x, y, g.tileSize, hasWaterAbove)
+// capture saves a screenshot of the frame drawn so far, or adds it to the
+// GIF clip
+func (g *Game) capture(shot, clip bool) {
+	if shot {
+		if name, err := g.saveScreenshot(); err != nil {
+			log.Printf("screenshot: %v", err)
+		} else {
+			log.Printf("screenshot saved to %s", name)
 		}
 	}
+	if clip {
+		g.captureClipFrame()
+	}
 }
 
-func CreateGameState(w, h, ts int) [][]Droplet {
-	// Create the new game state
-	newState := make([][]Droplet, h)
-	// Loop through each row of the grid
-	for y := range h {
-		// Create the columns
-		newState[y] = make([]Droplet, w)
-
-		// Loop through each cell and create a new droplet
-		for x := range newState[y] {
-			newState[y][x] = Droplet{
-				size: ts,
-			}
-		}
+// runUpdates runs the updates due this frame, in fixed steps so the water
+// keeps the same pace whatever the frame rate, topping up the scene before
+// each
+func (g *Game) runUpdates() {
+	n, spawned := 0, 0.0
+	defer func() { g.rates.add(n, spawned, g.frameTime()) }()
+	step := g.stepOnce
+	g.stepOnce = false
+	if g.teach != nil {
+		g.frameCount++
+		spawned += g.level.spawn(g, g.frameCount)
+		g.UpdateTeaching()
+		return
+	}
+	if g.editor != nil {
+		return
+	}
+	// Paused, the time doesn't pile up to be caught up on later
+	switch {
+	case step:
+		n = 1
+	case !g.paused:
+		n = g.Due(float64(g.frameTime()))
+	}
+	for range n {
+		g.frameCount++
+		spawned += g.level.spawn(g, g.frameCount)
+		g.Update()
 	}
-	return newState
 }
 
-func (g *Game) Update() {
-	// Create a new state to avoid modifying the current one
-	newState := CreateGameState(len(g.State[0]), len(g.State), g.tileSize)
-
-	// Copy current state to new state
-	for y := range g.State {
-		copy(newState[y], g.State[y])
+// Draw draws the part of the world inside view, given in world pixels
+func (g *Game) Draw(view rl.Rectangle) {
+	g.view = view
+	colors = g.palette()
+	rl.ClearBackground(colors.background)
+	if g.split() {
+		g.drawPanes(view)
+	} else {
+		rl.BeginMode2D(g.camera())
+		g.drawWorld()
+		rl.EndMode2D()
 	}
-
-	computePressures(&newState)
-	dampenPressure(&newState)
-	for y := len(g.State) - 1; y >= 0; y-- {
-		for x := range g.State[y] {
-
-			if g.State[y][x].isObstacle {
-				newState[y][x] = g.State[y][x]
-				continue
-			}
-			// Only process cells that contain water
-			if g.State[y][x].volume > 0 {
-				// Check if we are at the bottom
-				if y+1 < len(g.State) {
-					processWaterCell(x, y, &newState)
-
-				}
-			}
-		}
+	if g.teach != nil {
+		g.drawTeachingHeader()
 	}
-
-	// Replace old state with new calculated state
-	g.State = newState
 }
 
-func processWaterCell(x, y int, newState *[][]Droplet) {
-	// Try to flow downards, as if by gravity(but not into obstacles)
-	if y+1 < len(*newState) && !(*newState)[y+1][x].isObstacle {
-		fill(&(*newState)[y][x], &(*newState)[y+1][x], 1.0, 0.5)
+// drawWorld draws the grid and everything in it, in world pixels
+func (g *Game) drawWorld() {
+	if g.teach != nil {
+		// Teaching mode shows the partially applied update instead of the
+		// state the update already produced
+		state := g.State
+		g.State = g.teach.view
+		defer func() {
+			g.State = state
+			g.drawTeaching()
+		}()
 	}
 
-	// If water can still flow down, don't try other directions yet
-	if canFlowDown(x, y, newState) {
+	if g.showFlux {
+		g.DrawFlux()
 		return
 	}
 
-	// Water spreads sideways when blocked below
-	tryHorizontalFlow(x, y, newState)
-
-	if (*newState)[y][x].volume > 0 {
-		tryDiagonalFlow(x, y, newState)
+	if g.waterShader != nil && !g.plainWater {
+		g.drawShadedLayers()
+	} else {
+		g.drawBackground()
+		g.drawLayers()
 	}
-
-	applyPressureFlow(x, y, newState)
+	g.drawLight()
+	g.drawPressure()
+	g.drawIsolines()
+	g.drawVelocity()
+	g.drawStreamlines()
+
+	if g.showInterface {
+		g.drawInterfaces()
+	}
+	g.drawSponge()
+	g.drawVortices()
+	g.drawFans()
+	g.drawPortals()
+	g.drawSignals()
+	g.drawFire()
+	g.drawSediment()
+	g.drawSteam()
+	g.drawSnow()
+	g.drawSplashes()
+	g.drawSpray()
+	g.drawMist()
+	g.drawBubbles()
+	g.drawDebris()
+	g.drawWheels()
+	g.drawGauges()
+	g.drawRegions()
+	g.drawBoundaries()
+	if !g.capturing {
+		g.drawGridLines()
+		g.drawDropCursor()
+		g.drawBrush()
+	}
+	g.drawFlashes()
 }
 
-func applyPressureFlow(x, y int, newState *[][]Droplet) {
-	current := &(*newState)[y][x]
-	if current.isObstacle || current.volume <= 0 {
-		return
+// drawGrid draws the droplets of the grid in State inside the view, the
+// front layer's split up by pass when the water is shaded
+func (g *Game) drawGrid() {
+	pass := g.pass
+	if g.FocusedLayer() != 0 {
+		// The layers behind are drawn whole under the shaded water
+		pass = passAll
 	}
-
-	// Directions: up, down, left, right
-	directions := [][2]int{{0, -1}, {0, 1}, {-1, 0}, {1, 0}}
-	for _, dpos := range directions {
-		nx, ny := x+dpos[0], y+dpos[1]
-		if ny < 0 || ny >= len(*newState) || nx < 0 || nx >= len((*newState)[0]) {
-			continue
-		}
-		neighbor := &(*newState)[ny][nx]
-		if neighbor.isObstacle {
-			continue
-		}
-		if dpos[1] == -1 && (*newState)[y-1][x].isObstacle {
-			continue
-		}
-
-		// Compute combined pressure difference
-		pressureDiff := (current.pressure + current.volume) - (neighbor.pressure + neighbor.volume)
-
-		dy := float64(y - ny)
-
-		if pressureDiff > 0 {
-			flow := 0.05 * pressureDiff
-			if flow > current.volume {
-				flow = current.volume
-			}
-			if dy == 0 {
-				flow *= 0.7
+	tiles := g.tileset()
+	x0, y0, x1, y1 := g.visibleCells()
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			d, ts := &g.State[y][x], g.TileSize()
+			if tiles != nil && pass != passWater && pass != passFlow && tiles.tiled(d) {
+				g.drawTile(tiles, x, y)
+				continue
 			}
-			if pressureDiff >= 0.0001 {
+			if pass == passFlow {
+				if d.Volume > 0 && !d.Material.IsSolid() {
+					c := flowColor(d)
+					c.B = uint8(255 * g.depthAlpha(x, y))
+					rl.DrawRectangle(int32(x*ts), int32(y*ts), int32(ts), int32(ts), c)
+				}
 				continue
 			}
-			current.volume -= flow
-			neighbor.volume += flow
-			current.volume = math.Min(1.0, math.Max(0.0, current.volume))
-			neighbor.volume = math.Min(1.0, math.Max(0.0, neighbor.volume))
-			dvx := float64(nx - x)
-			dvy := float64(ny - y)
-			current.vx += dvx * flow * 0.1
-			current.vy += dvy * flow * 0.1
-		}
-	}
-}
-func dampenPressure(state *[][]Droplet) {
-	for y := range *state {
-		for x := range (*state)[y] {
-			d := &(*state)[y][x]
-			d.vx *= 0.9
-			d.vy *= 0.9
-			d.pressure *= 1.1
-		}
-	}
-}
-func computePressures(state *[][]Droplet) {
-	for y := len(*state) - 1; y >= 0; y-- {
-		for x := range (*state)[y] {
-			d := &(*state)[y][x]
-			if d.isObstacle {
-				d.pressure = 0
+			if g.Params.VOF && gridfluid.IsSurfaceCell(x, y, &g.State) {
+				if pass != passDry {
+					g.drawVOFCell(x, y)
+				}
 				continue
 			}
-			if y == len(*state)-1 || (*state)[y+1][x].isObstacle {
-				// bottom row
-				d.pressure = d.volume
-			} else {
-				d.pressure = (*state)[y+1][x].pressure + d.volume
+			drawWater := func() int {
+				// Check if there is water above this cell
+				hasWaterAbove := y > 0 && g.State[y-1][x].Volume > 0
+				if d.Ramp != gridfluid.RampNone && d.Material.IsSolid() {
+					return drawRampWater(d, x*ts, y*ts, ts, g.waterColor(fluidColor(d), x, y))
+				}
+				if g.blockyWater || d.Material.IsSolid() {
+					c := g.waterColor(fluidColor(d), x, y)
+					top := drawWaterBlock(d, x*ts, y*ts, ts, hasWaterAbove, c)
+					if !hasWaterAbove {
+						g.drawSurfaceBand(x, y, top, c)
+					}
+					return top
+				}
+				return g.drawSmoothWater(x, y, hasWaterAbove)
+			}
+			switch pass {
+			case passDry:
+				drawDroplet(d, x, y, ts, func() int { return ts })
+			case passWater:
+				drawFoam(d, x*ts, y*ts, ts, drawWater())
+			default:
+				drawDroplet(d, x, y, ts, drawWater)
 			}
 		}
 	}
 }
-func canFlowDown(x, y int, state *[][]Droplet) bool {
-	return y+1 < len(*state) && (*state)[y+1][x].volume < 1.0 && !(*state)[y+1][x].isObstacle
-}
 
-func tryHorizontalFlow(x, y int, state *[][]Droplet) {
-	current := &(*state)[y][x]
+/*
+* Main
+ */
 
-	// Only cascade if there's water below
-	hasWaterBelow := y+1 < len(*state) && (*state)[y+1][x].volume > 0.5
-	if !hasWaterBelow {
+func main() {
+	disable := flag.String("disable", "", "comma separated features to switch off, see the startup log")
+	soak := flag.Duration("soak", 0, "run the demo headless for this long, checking invariants")
+	soakCheck := flag.Int("soak-check", 600, "updates between soak invariant checks")
+	soakMass := flag.Float64("soak-mass", 0.01, "allowed relative mass drift during a soak")
+	soakEnergy := flag.Float64("soak-energy", 5, "allowed kinetic energy relative to its running average")
+	soakDir := flag.String("soak-dir", "", "soak output directory (default soak_<time>)")
+	seed := flag.Uint64("seed", 0, "seed of the simulation, the same seed replays the same run (default: random)")
+	workers := flag.Int("workers", runtime.NumCPU(), "goroutines the update runs on, large grids only")
+	world := flag.String("world", "", "grid size as COLSxROWS, scrolled through the window (default: fit the window)")
+	scenarioPath := flag.String("scenario", "", "JSON scenario file to load instead of the demo, see scenario.go")
+	imagePath := flag.String("image", "", "PNG to build the scene from instead of the demo, one pixel per cell, see image.go")
+	caves := flag.Bool("caves", false, "carve a cave system to fill with water instead of the demo, see caves.go")
+	caveSeed := flag.Uint64("cave-seed", 0, "seed of the caves, the same seed carves the same caves (default: random)")
+	caveFill := flag.Float64("cave-fill", 0.45, "share of the cells -caves starts as rock, higher leaves narrower channels")
+	caveSmooth := flag.Int("cave-smooth", 5, "smoothing passes of -caves, more give rounder chambers")
+	reloadWater := flag.Bool("reload-water", true, "keep the water in the scene when the -scenario file changes and is reloaded")
+	width := flag.Int("width", 1920, "window width in pixels")
+	height := flag.Int("height", 1080, "window height in pixels")
+	tile := flag.Int("tile", 20, "cell size in pixels, the grid is the window size divided by it")
+	cellPixels := flag.Int("cell-pixels", 0, "window pixels every cell is drawn across, the grid is the window size divided by it (default: -tile)")
+	fps := flag.Int("fps", 60, "frame rate cap, 0 for none")
+	vsync := flag.Bool("vsync", false, "wait for the display's vertical sync")
+	headless := flag.Bool("headless", false, "run -steps updates without a window and print throughput and water statistics")
+	steps := flag.Int("steps", 1000, "updates run by -headless")
+	record := flag.String("record", "", "record the input to this file for -replay")
+	replayPath := flag.String("replay", "", "play back a recording made with -record")
+	conservation := flag.Bool("debug-conservation", false, "log every pass of an update that creates or destroys water, see conservation.go")
+	conservationTolerance := flag.Float64("debug-conservation-tolerance", 1e-6, "volume a pass may gain or lose before -debug-conservation logs it")
+	conservationPanic := flag.Bool("debug-conservation-panic", false, "save the state and panic on the first leak -debug-conservation finds")
+	themeName := flag.String("theme", "classic", "colour theme: classic, thermal, monochrome or retro-green, see theme.go")
+	statsPath := flag.String("stats", "", "write the sim's statistics to this file as JSON lines, see stats.go")
+	screenshotClean := flag.Bool("screenshot-clean", false, "take F12 screenshots and Shift+F12 clips of the world alone, without the readouts and tools")
+	isolineStep := flag.Float64("isoline-step", 0, "pressure between the Ctrl+P isolines, 0 for a tenth of the highest, see isolines.go")
+	flag.Parse()
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if *soak > 0 {
+		dir := *soakDir
+		if dir == "" {
+			dir = "soak_" + time.Now().Format("20060102_150405")
+		}
+		anomalies, err := RunSoak(SoakConfig{Duration: *soak, CheckEvery: max(*soakCheck, 1), MassBand: *soakMass, EnergySpike: *soakEnergy, Dir: dir, Seed: *seed, Workers: *workers, Disable: *disable})
+		if err != nil {
+			log.Fatalf("soak: %v", err)
+		}
+		if anomalies > 0 {
+			os.Exit(1)
+		}
 		return
 	}
 
-	// Cascade right - distribute to multiple cells
-	for offset := 1; offset <= 3 && x+offset < len((*state)[y]); offset++ {
-		target := &(*state)[y][x+offset]
-		if target.volume < current.volume && !target.isObstacle {
-			flowRate := (current.volume - target.volume) * 0.1 / float64(offset)
-			fill(current, target, 1.0, flowRate)
-		}
+	switch {
+	case *width <= 0 || *height <= 0:
+		log.Fatalf("-width, -height: want a positive window size, got %dx%d", *width, *height)
+	case *tile <= 0 || *tile > min(*width, *height):
+		log.Fatalf("-tile: want 1 to %d pixels for a %dx%d window, got %d", min(*width, *height), *width, *height, *tile)
+	case *cellPixels < 0 || *cellPixels > min(*width, *height):
+		log.Fatalf("-cell-pixels: want 1 to %d pixels for a %dx%d window, got %d", min(*width, *height), *width, *height, *cellPixels)
+	case *fps < 0:
+		log.Fatalf("-fps: want 0 or more, got %d", *fps)
+	case *steps <= 0:
+		log.Fatalf("-steps: want a positive count, got %d", *steps)
+	case *isolineStep < 0:
+		log.Fatalf("-isoline-step: want 0 or more, got %g", *isolineStep)
 	}
 
-	// Cascade left - distribute to multiple cells
-	for offset := 1; offset <= 3 && x-offset >= 0; offset++ {
-		target := &(*state)[y][x-offset]
-		if target.volume < current.volume && !target.isObstacle {
-			flowRate := (current.volume - target.volume) * 0.1 / float64(offset)
-			fill(current, target, 1.0, flowRate)
+	// Create a new game, its grid filling the window at the size the cells
+	// are drawn at
+	cellSize := *tile
+	if *cellPixels > 0 {
+		cellSize = *cellPixels
+	}
+	var game = NewGame(*width, *height, *tile)
+	if cellSize != *tile {
+		game = NewWorld(*width, *height, *tile, *width/cellSize, *height/cellSize)
+	}
+	var player *replayPlayer
+	if *replayPath != "" {
+		if *record != "" || *headless || *scenarioPath != "" || *imagePath != "" || *caves || *world != "" || *cellPixels > 0 {
+			log.Fatalf("-replay: the recording sets the scene, can't be combined with -record, -headless, -scenario, -image, -caves, -world or -cell-pixels")
+		}
+		p, err := loadReplay(*replayPath)
+		if err == nil {
+			game, err = p.scene()
+		}
+		if err != nil {
+			log.Fatalf("-replay: %v", err)
+		}
+		player = p
+	} else if *scenarioPath != "" {
+		switch {
+		case *imagePath != "":
+			log.Fatalf("-image: can't be combined with -scenario")
+		case *caves:
+			log.Fatalf("-caves: can't be combined with -scenario")
+		case *world != "":
+			log.Fatalf("-world: the scenario sets the grid size")
+		case set["tile"]:
+			log.Fatalf("-tile: the scenario sets the tile size")
 		}
+		s, err := loadScenario(*scenarioPath)
+		if err == nil {
+			game, err = s.build(*width, *height)
+		}
+		if err != nil {
+			log.Fatalf("-scenario: %v", err)
+		}
+		game.level = s
+	} else if *imagePath != "" {
+		switch {
+		case *caves:
+			log.Fatalf("-caves: can't be combined with -image")
+		case *world != "":
+			log.Fatalf("-world: the image sets the grid size")
+		}
+		s, err := loadImageScenario(*imagePath, *tile)
+		if err == nil {
+			game, err = s.build(*width, *height)
+		}
+		if err != nil {
+			log.Fatalf("-image: %v", err)
+		}
+		game.level = s
+	} else if *caves {
+		switch {
+		case *world != "":
+			log.Fatalf("-world: can't be combined with -caves")
+		case *caveFill < 0 || *caveFill >= 1:
+			log.Fatalf("-cave-fill: want 0 to below 1, got %g", *caveFill)
+		case *caveSmooth < 0:
+			log.Fatalf("-cave-smooth: want 0 or more, got %d", *caveSmooth)
+		}
+		p := gridfluid.DefaultCaveParams()
+		p.Seed, p.Fill, p.Smoothing = *caveSeed, *caveFill, *caveSmooth
+		if p.Seed == 0 {
+			p.Seed = rand.Uint64()
+		}
+		log.Printf("cave seed %d", p.Seed)
+		s, err := caveScenario(p, *width/cellSize, *height/cellSize, *tile)
+		if err == nil {
+			game, err = s.build(*width, *height)
+		}
+		if err != nil {
+			log.Fatalf("-caves: %v", err)
+		}
+		game.level = s
 	}
-}
-
-func tryDiagonalFlow(x, y int, state *[][]Droplet) {
-	current := &(*state)[y][x]
-
-	// Flow diagonally down-right if space is available
-	if x+1 < len((*state)[y]) && y+1 < len(*state) && (*state)[y+1][x+1].volume < 1.0 && !(*state)[y+1][x+1].isObstacle {
-		fill(current, &(*state)[y+1][x+1], 1.0, 0.25)
+	if *world != "" {
+		var cols, rows int
+		if _, err := fmt.Sscanf(*world, "%dx%d", &cols, &rows); err != nil {
+			log.Fatalf("-world: want COLSxROWS, got %q", *world)
+		}
+		// The demo is laid out for a grid filling the window
+		if cols < len(game.State[0]) || rows < len(game.State) {
+			log.Fatalf("-world: %dx%d is smaller than the window's %dx%d cells", cols, rows, len(game.State[0]), len(game.State))
+		}
+		game = NewWorld(*width, *height, *tile, cols, rows)
 	}
-
-	// Flow diagonally down-left if space is available
-	if x-1 > 0 && y+1 < len(*state) && (*state)[y+1][x-1].volume < 1.0 && !(*state)[y+1][x-1].isObstacle {
-		fill(current, &(*state)[y+1][x-1], 1.0, 0.25)
+	if game.level == nil && (len(game.State[0]) < demoCols || len(game.State) < demoRows) {
+		log.Fatalf("the demo needs at least %dx%d cells, a %dx%d window at %d pixels a cell gives %dx%d; use smaller cells or -world",
+			demoCols, demoRows, game.Width, game.Height, cellSize, len(game.State[0]), len(game.State))
 	}
-
-}
-
-// Calculate how much more water a droplet can hold
-func remainder(droplet Droplet, maxVolume float64) float64 {
-	return maxVolume - droplet.volume
-}
-
-// Fill transfers water between two droplets at a controlled rate
-func fill(current, target *Droplet, maxVolume, flowRate float64) {
-
-	// Calculate how much water can be transferred
-	transfer := remainder(*target, maxVolume)
-
-	// Limit transfer to the flow rate (prevents instant teleportation)
-	if transfer > flowRate {
-		transfer = flowRate
+	if *cellPixels > 0 {
+		game.SetCellPixels(*cellPixels, *width, *height)
+	}
+	log.Printf("window %dx%d, grid %dx%d cells of %d pixels", game.Width, game.Height, len(game.State[0]), len(game.State), game.TileSize())
+	if *cellPixels > 0 {
+		log.Printf("cells drawn %d pixels across", *cellPixels)
+	}
+	if *seed != 0 {
+		game.SetSeed(*seed)
+	}
+	game.Workers = *workers
+	if err := game.DisableFeatures(*disable); err != nil {
+		log.Fatalf("-disable: %v", err)
+	}
+	log.Print(game.FeaturesReport())
+	theme, err := lookupTheme(*themeName)
+	if err != nil {
+		log.Fatalf("-theme: %v", err)
+	}
+	game.theme = theme
+	game.isolineStep = *isolineStep
+	log.Printf("seed %d", game.Seed)
+	if game.level == nil {
+		game.level = setupDemo(game)
+	}
+	if *conservation {
+		game.auditConservation(&conservationAudit{tolerance: *conservationTolerance, panicOnLeak: *conservationPanic})
+	}
+	if *statsPath != "" {
+		f, err := os.Create(*statsPath)
+		if err != nil {
+			log.Fatalf("-stats: %v", err)
+		}
+		defer f.Close()
+		game.statsLog = json.NewEncoder(f)
+	}
+	if *headless {
+		if RunHeadless(game, *steps, os.Stdout) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	if *record != "" {
+		header := replayHeader{Width: game.Width, Height: game.Height, TileSize: game.TileSize(), CellPixels: *cellPixels, Disabled: game.DisabledFeatures()}
+		if s, ok := game.level.(*scenario); ok {
+			header.Scenario = s.source
+		}
+		r, err := startRecording(*record, header)
+		if err != nil {
+			log.Fatalf("-record: %v", err)
+		}
+		defer r.close()
+		game.input.recorder = r
+		log.Printf("recording to %s", *record)
 	}
 
-	// Move water from source to target
-	current.volume -= transfer
-	target.volume += transfer
-}
-
-/*
-* Main
- */
+	if *scenarioPath != "" {
+		game.watch = watchScenario(*scenarioPath, *reloadWater)
+	}
 
-func main() {
-	// Create a new game
-	var game = NewGame(1920, 1080, 20)
 	// Initialize Raylib
+	flags := uint32(rl.FlagWindowResizable)
+	if *vsync {
+		flags |= rl.FlagVsyncHint
+	}
+	rl.SetConfigFlags(flags)
 	rl.InitWindow(int32(game.Width), int32(game.Height), "WaterSim")
+	rl.SetWindowMinSize(minWindowWidth, minWindowHeight)
 	defer rl.CloseWindow()
+	defer game.finishClips()
+	game.waterShader = loadWaterShader(game.Width, game.Height)
+	// A resize swaps the shader for one the new size
+	defer func() { game.waterShader.unload() }()
+	defer func() { game.tiles.unload() }()
+	defer func() { game.scenery.unload() }()
+	game.startSpray()
+	game.startMist()
+	game.light = loadLight()
+	defer game.light.unload()
 
-	// Set up a counter, so we can spawn new water at a rate
-	frameCount := 0
-	flowStartX := 400 / game.tileSize
-	flowStartY := 10 / game.tileSize
-
-	CreateWaterGenerator(flowStartX, flowStartY, game.tileSize, &game.State)
-	CreateVerticalObstacle(10, 10, 20, &game.State)
-	CreateHorizontalObstacle(10, 30, 50, &game.State)
-	CreateHorizontalObstacle(40, 20, 40, &game.State)
-	gridWidth := len(game.State[0])
-	gridHeight := len(game.State)
+	// Set the target frame rate
+	rl.SetTargetFPS(int32(*fps))
 
-	// Top border
-	CreateHorizontalObstacle(0, 0, gridWidth, &game.State)
-	for x := flowStartX; x < flowStartX+5; x++ {
-		game.State[0][x].isObstacle = false
-		game.State[1][x].isObstacle = false
-		game.State[2][x].isObstacle = false
+	if player != nil {
+		game.input.player = player
+		player.seek(game, 0)
 	}
 
-	// Bottom border (y = last few rows)
-	CreateHorizontalObstacle(0, gridHeight-3, gridWidth, &game.State)
-
-	// Left border
-	CreateVerticalObstacle(0, 0, gridHeight, &game.State)
-
-	// Right border (x = last few columns)
-	CreateVerticalObstacle(gridWidth-3, 0, gridHeight, &game.State)
-
-	// Set the target frame rate
-	rl.SetTargetFPS(60)
-
 	// Main game loop
 	for !rl.WindowShouldClose() {
-		frameCount++
+		play := true
+		if player != nil {
+			play = player.control(game)
+		}
+		if play {
+			game.beginFrame()
+			game.HandleInput()
+			game.pollScenario()
+		}
+
+		toggleFullscreen()
+		// Screenshots and clips are taken live, in playback too, and not
+		// recorded
+		shift := rl.IsKeyDown(rl.KeyLeftShift) || rl.IsKeyDown(rl.KeyRightShift)
+		shot := rl.IsKeyPressed(rl.KeyF12) && !shift
+		if rl.IsKeyPressed(rl.KeyF12) && shift {
+			game.toggleClip()
+		}
+		clip := game.clipFrameDue()
+		game.capturing = (shot || clip) && *screenshotClean
+
 		// Begin drawing
 		rl.BeginDrawing()
-		rl.ClearBackground(rl.Black)
-
-		// Add new water every 5 frames (creates a continuous water stream)
-		if frameCount%5 == 0 {
-			for x := 0; x < 5; x++ {
-				cell := &game.State[flowStartY][flowStartX+x]
-				if !cell.isObstacle && cell.volume < 0.7 {
-					cell.volume = 1.0
-				}
-			}
-			// CreateWaterGenerator(flowStartX, flowStartY, game.tileSize, &game.State)
+		// Draw the game
+		game.Draw(game.View())
+		if game.capturing {
+			game.capture(shot, clip)
+			game.capturing = false
+		}
+		game.drawMutation()
+		game.drawWind()
+		game.drawWeather()
+		game.drawLayerLabel()
+		game.drawEditor()
+		game.drawGravity()
+		game.drawStats()
+		game.drawPanel()
+		game.drawInspector()
+		game.drawPressureLegend()
+		game.drawIsolineLegend()
+		game.drawMinimap()
+		game.drawPaused()
+		game.drawTimeline()
+		if player != nil {
+			player.draw(game)
 		}
 
-		// Draw the game
-		game.Draw()
+		// The report is written before the updates, so its scene is the
+		// one in its screenshot
+		if game.wantReport {
+			game.wantReport = false
+			if name, err := game.WriteReport(); err != nil {
+				log.Printf("write report: %v", err)
+			} else {
+				log.Printf("issue report written to %s", name)
+			}
+		}
 
 		// Update the game state based on the rules
-		game.Update()
+		if play {
+			game.runUpdates()
+			game.updateStreamlines()
+		}
+
+		if (shot || clip) && !*screenshotClean {
+			game.capture(shot, clip)
+		}
 
 		rl.EndDrawing()
+		if play {
+			game.endFrame()
+		}
 	}
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+/*
+* Measurement regions
+ */
+
+const regionLabel = 10 // Font size of the region labels
+
+// drawRegions outlines the regions and labels them with their readings
+func (g *Game) drawRegions() {
+	ts := int32(g.TileSize())
+	for _, r := range g.Regions() {
+		c := rl.Lime
+		if r.Alert {
+			c = rl.Red
+		}
+		rl.DrawRectangleLines(int32(r.X)*ts, int32(r.Y)*ts, int32(r.W)*ts, int32(r.H)*ts, rl.Fade(c, 0.7))
+		if !g.showRegionLabels {
+			continue
+		}
+		label := fmt.Sprintf("%s %.1f  %+.2f/s", r.Name, r.Volume, r.Flow)
+		if r.Lost > 0.005 {
+			label += fmt.Sprintf("  lost %.2f", r.Lost)
+		}
+		rl.DrawText(label, int32(r.X)*ts+2, int32(r.Y)*ts-regionLabel-2, regionLabel, c)
+	}
+	if g.regionCorner != nil {
+		x, y := g.cellAtMouse()
+		c := *g.regionCorner
+		x0, y0 := min(x, c[0]), min(y, c[1])
+		x1, y1 := max(x, c[0]), max(y, c[1])
+		rl.DrawRectangleLines(int32(x0)*ts, int32(y0)*ts, int32(x1-x0+1)*ts, int32(y1-y0+1)*ts, rl.Fade(rl.Lime, 0.4))
+	}
+}
+
+// markRegion handles the region key on (x, y): inside a region it removes
+// it, otherwise the first press marks a corner and the second adds the
+// region spanning both
+func (g *Game) markRegion(x, y int) {
+	if !g.InBounds(x, y) {
+		return
+	}
+	if g.regionCorner == nil {
+		if r := g.RegionAt(x, y); r != nil {
+			g.RemoveRegion(r)
+			return
+		}
+		g.regionCorner = &[2]int{x, y}
+		return
+	}
+	c := *g.regionCorner
+	g.regionCorner = nil
+	x0, y0 := min(x, c[0]), min(y, c[1])
+	x1, y1 := max(x, c[0]), max(y, c[1])
+	g.AddRegion(fmt.Sprintf("region %d", len(g.Regions())+1), x0, y0, x1-x0+1, y1-y0+1)
+}
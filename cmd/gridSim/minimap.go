@@ -0,0 +1,128 @@
+package main
+
+import (
+	"image/color"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Minimap
+*
+* When the view shows only part of the world, a map of all of it sits in
+* the bottom left corner, a pixel for every block of cells small enough to
+* keep it within minimapSize, coloured like the water where there is water
+* and like stone where there is rock. The view is outlined on it, and clicking or dragging on the map
+* moves the view there. The map is redrawn every minimapEvery frames.
+ */
+
+const (
+	minimapSize  = 200 // Window pixels the map takes up at most either way
+	minimapEvery = 15  // Frames between redraws
+)
+
+type minimap struct {
+	texture rl.Texture2D
+	pixels  []color.RGBA
+	w, h    int // Pixels
+	age     int // Frames since the last redraw
+}
+
+// minimapScale is the cells along each side of a minimap pixel
+func (g *Game) minimapScale() int {
+	cols, rows := len(g.State[0]), len(g.State)
+	return max((cols+minimapSize-1)/minimapSize, (rows+minimapSize-1)/minimapSize, 1)
+}
+
+// minimapRect is where the minimap is drawn, in window pixels, and false
+// when it isn't as the view shows the whole world
+func (g *Game) minimapRect() (rl.Rectangle, bool) {
+	ts := float32(g.TileSize())
+	view := g.View()
+	if view.Width >= float32(len(g.State[0]))*ts && view.Height >= float32(len(g.State))*ts {
+		return rl.Rectangle{}, false
+	}
+	s := g.minimapScale()
+	w, h := float32((len(g.State[0])+s-1)/s), float32((len(g.State)+s-1)/s)
+	// Clear of the checkpoint timeline
+	return rl.Rectangle{X: 10, Y: float32(g.Height) - 70 - h, Width: w, Height: h}, true
+}
+
+// handleMinimap moves the view to where the minimap is clicked and reports
+// whether the mouse is over it, so the brush leaves it alone
+func (g *Game) handleMinimap() bool {
+	r, ok := g.minimapRect()
+	m := g.mousePosition()
+	if !ok || !rl.CheckCollisionPointRec(m, r) {
+		return false
+	}
+	if g.mouseDown(rl.MouseButtonLeft) {
+		px := float32(g.minimapScale() * g.TileSize())
+		view := g.View()
+		g.ScrollTo((m.X-r.X)*px-view.Width/2, (m.Y-r.Y)*px-view.Height/2)
+	}
+	return true
+}
+
+// drawMinimap redraws the map when it is due and shows it with the view
+// outlined
+func (g *Game) drawMinimap() {
+	r, ok := g.minimapRect()
+	if !ok {
+		return
+	}
+	m := g.minimap
+	if w, h := int(r.Width), int(r.Height); m == nil || m.w != w || m.h != h {
+		if m != nil {
+			rl.UnloadTexture(m.texture)
+		}
+		img := rl.GenImageColor(w, h, rl.Blank)
+		m = &minimap{texture: rl.LoadTextureFromImage(img), pixels: make([]color.RGBA, w*h), w: w, h: h, age: minimapEvery}
+		rl.UnloadImage(img)
+		g.minimap = m
+	}
+	if m.age++; m.age >= minimapEvery {
+		m.age = 0
+		g.paintMinimap(m)
+		rl.UpdateTexture(m.texture, m.pixels)
+	}
+	rl.DrawTexture(m.texture, int32(r.X), int32(r.Y), rl.White)
+	rl.DrawRectangleLinesEx(r, 1, rl.Gray)
+
+	px := float32(g.minimapScale() * g.TileSize())
+	view := g.View()
+	outline := rl.Rectangle{X: r.X + view.X/px, Y: r.Y + view.Y/px, Width: view.Width / px, Height: view.Height / px}
+	rl.DrawRectangleLinesEx(outline, 1, rl.Yellow)
+}
+
+// paintMinimap colours every pixel of the map by the cells it covers
+func (g *Game) paintMinimap(m *minimap) {
+	s := g.minimapScale()
+	empty, rock, water := rl.Fade(colors.background, 0.8), colors.material(gridfluid.MaterialStone), colors.fluids[gridfluid.FluidWater]
+	for py := range m.h {
+		for px := range m.w {
+			wet, solid, n := 0.0, 0, 0
+			for y := py * s; y < min((py+1)*s, len(g.State)); y++ {
+				for x := px * s; x < min((px+1)*s, len(g.State[y])); x++ {
+					d := &g.State[y][x]
+					if d.Material.IsSolid() {
+						solid++
+					} else {
+						wet += min(d.Volume, 1)
+					}
+					n++
+				}
+			}
+			c := empty
+			switch {
+			case n == 0:
+			case wet >= 0.25*float64(n):
+				c = rl.ColorLerp(empty, water, float32(min(wet/float64(n)+0.3, 1)))
+			case 2*solid >= n:
+				c = rock
+			}
+			m.pixels[py*m.w+px] = c
+		}
+	}
+}
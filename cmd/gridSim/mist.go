@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+/*
+* Waterfall mist
+*
+* Where water keeps landing after falling freely, a cone of mist rises from
+* where it lands. Every landing the sim reports with OnImpact feeds the
+* mist of its cell with the height the water fell from, worked out from
+* its speed, times the volume that landed, so a tall fall or a heavy flow
+* makes thicker mist and a taller cone. Mist thins out by mistDecay every
+* update, so it lingers a moment after the water stops. Like spray it is
+* only drawn, and left out of recordings and headless runs.
+ */
+
+const (
+	mistPerFall  = 0.08 // Strength a cell of water landing after a fall of a cell adds
+	mistMax      = 1    // Strength of the thickest mist
+	mistMin      = 0.02 // Strength below which the mist is gone
+	mistDecay    = 0.96 // Share of the strength left after an update
+	mistMaxRise  = 6    // Cells the tallest cone rises
+	mistPuffs    = 6    // Puffs a cone is drawn with
+	mistAlpha    = 0.12 // Opacity of a puff of the thickest mist where it lands
+	mistSwayRate = 1.3  // Radians per second the cones sway by
+)
+
+type mistCloud struct {
+	strength float64 // 0 to mistMax
+	fall     float64 // Height in cells of the falls feeding it, smoothed
+}
+
+type mist struct {
+	clouds map[[2]int]*mistCloud
+}
+
+// startMist raises mist wherever falling water lands from now on
+func (g *Game) startMist() {
+	g.mist = &mist{clouds: map[[2]int]*mistCloud{}}
+	g.OnImpact(g.feedMist)
+}
+
+// feedMist adds the water landing in (x, y) at speed to its mist
+func (g *Game) feedMist(x, y int, speed float64) {
+	// Falling from rest, water reaches speed after sqrt(2 g h)
+	fall := speed * speed / (2 * g.Params.GravityAccel)
+	c := g.mist.clouds[[2]int{x, y}]
+	if c == nil {
+		c = &mistCloud{fall: fall}
+		g.mist.clouds[[2]int{x, y}] = c
+	}
+	c.strength = min(c.strength+fall*g.State[y][x].Volume*mistPerFall, mistMax)
+	c.fall += (fall - c.fall) * 0.1
+}
+
+// updateMist thins the mist out, forgetting the clouds that are gone
+func (g *Game) updateMist() {
+	if g.mist == nil {
+		return
+	}
+	for cell, c := range g.mist.clouds {
+		c.strength *= mistDecay
+		if c.strength < mistMin || !g.InBounds(cell[0], cell[1]) {
+			delete(g.mist.clouds, cell)
+		}
+	}
+}
+
+// drawMist draws a cone of puffs rising from every cloud, opening up and
+// fading as it rises
+func (g *Game) drawMist() {
+	if g.mist == nil {
+		return
+	}
+	ts := float32(g.TileSize())
+	gx, gy := g.Gravity.Vector()
+	t := rl.GetTime()
+	for cell, c := range g.mist.clouds {
+		x, y := cell[0], cell[1]
+		base := rl.Vector2{X: (float32(x) + 0.5) * ts, Y: (float32(y) + 0.5) * ts}
+		rise := float32(min(c.fall/2, mistMaxRise)*c.strength) * ts
+		sway := float32(math.Sin(t*mistSwayRate+float64(x)*0.7)) * ts * 0.3
+		for i := range mistPuffs {
+			f := float32(i) / (mistPuffs - 1)
+			// Up is against gravity, sideways across it
+			up, side := rise*f, sway*f
+			at := rl.Vector2{X: base.X - float32(gx)*up - float32(gy)*side, Y: base.Y - float32(gy)*up + float32(gx)*side}
+			r := ts * (0.6 + f*float32(c.strength)*2)
+			rl.DrawCircleV(at, r, rl.Fade(colors.foam, mistAlpha*float32(c.strength)*(1-f*0.8)))
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Moss
+ */
+
+// mossTint greens an obstacle by its moss cover
+func mossTint(base rl.Color, d *gridfluid.Droplet) rl.Color {
+	return rl.ColorLerp(base, colors.moss, float32(0.8*min(d.Moss, 1)))
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Parameter mutation
+ */
+
+const presetDir = "presets"
+
+// mutation remembers the parameters in use before a randomization so it can
+// be reverted
+type mutation struct {
+	previous gridfluid.Params
+	changed  []string // Human readable "name old -> new" lines
+}
+
+// Mutate randomizes a random subset of the solver parameters within their
+// safe ranges. Repeated mutations keep the original parameters for revert.
+func (g *Game) Mutate() {
+	previous := g.Params
+	if g.mutation != nil {
+		previous = g.mutation.previous
+	}
+
+	next := g.Params
+	for _, spec := range gridfluid.ParamSpecs() {
+		if g.Rand().Float64() < 0.5 {
+			*spec.Field(&next) = spec.Min + g.Rand().Float64()*(spec.Max-spec.Min)
+		}
+	}
+
+	g.Params = next
+	g.mutation = &mutation{previous: previous, changed: diffParams(previous, next)}
+}
+
+// KeepMutation accepts the mutated parameters and stores them as a preset
+func (g *Game) KeepMutation() (string, error) {
+	if g.mutation == nil {
+		return "", nil
+	}
+	g.mutation = nil
+
+	if err := os.MkdirAll(presetDir, 0o755); err != nil {
+		return "", err
+	}
+	name := filepath.Join(presetDir, fmt.Sprintf("preset_%s.json", time.Now().Format("20060102_150405")))
+	return name, gridfluid.SaveParams(name, g.Params)
+}
+
+// RevertMutation restores the parameters from before the mutation
+func (g *Game) RevertMutation() {
+	if g.mutation == nil {
+		return
+	}
+	g.Params = g.mutation.previous
+	g.mutation = nil
+}
+
+// LoadLatestPreset applies the most recently saved preset
+func (g *Game) LoadLatestPreset() (string, error) {
+	matches, err := filepath.Glob(filepath.Join(presetDir, "preset_*.json"))
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no presets in %s", presetDir)
+	}
+	// Timestamped names sort chronologically
+	sort.Strings(matches)
+	name := matches[len(matches)-1]
+
+	p, err := gridfluid.LoadParams(name)
+	if err != nil {
+		return "", err
+	}
+	g.Params = p
+	g.mutation = nil
+	return name, nil
+}
+
+func diffParams(a, b gridfluid.Params) []string {
+	var lines []string
+	for _, spec := range gridfluid.ParamSpecs() {
+		before, after := *spec.Field(&a), *spec.Field(&b)
+		if before != after {
+			lines = append(lines, fmt.Sprintf("%s %.3f -> %.3f", spec.Name, before, after))
+		}
+	}
+	return lines
+}
+
+// drawMutation shows the pending parameter diff
+func (g *Game) drawMutation() {
+	if g.mutation == nil {
+		return
+	}
+	x, y := int32(10), int32(10)
+	height := int32(30 + 14*len(g.mutation.changed))
+	rl.DrawRectangle(x-5, y-5, 260, height, rl.Fade(rl.Black, 0.7))
+	rl.DrawText("MUTATED  [K] keep  [Backspace] revert", x, y, 10, rl.Yellow)
+	for i, line := range g.mutation.changed {
+		rl.DrawText(line, x, y+20+int32(i)*14, 10, rl.RayWhite)
+	}
+}
@@ -0,0 +1,35 @@
+//go:build noexport
+
+package main
+
+import (
+	"log"
+
+	"watersim/pkg/gridfluid"
+)
+
+// Stand-ins for the exporters when they are compiled out
+
+func (g *Game) ExportFlux() (string, error) {
+	return "", gridfluid.FeatureOffError("export")
+}
+
+func (g *Game) WriteReport() (string, error) {
+	return "", gridfluid.FeatureOffError("export")
+}
+
+func (g *Game) recordReportFrame() {}
+
+func (g *Game) saveScreenshot() (string, error) {
+	return "", gridfluid.FeatureOffError("export")
+}
+
+type gifClip struct{}
+
+func (g *Game) toggleClip() {
+	log.Printf("gif: %v", gridfluid.FeatureOffError("export"))
+}
+
+func (g *Game) clipFrameDue() bool { return false }
+func (g *Game) captureClipFrame()  {}
+func (g *Game) finishClips()       {}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gen2brain/raylib-go/raygui"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Parameter panel
+*
+* Ctrl+M opens a raygui panel of sliders at the top right for the solver
+* parameters tuned most often: the flow rates, the pressure coefficients,
+* the damping, how much water the generators pour in and how fast open
+* water evaporates. Dragging a slider applies it to the running sim,
+* within the parameter's range in ParamSpecs, and the sim doesn't see
+* clicks on the panel. raygui reads the mouse itself while the panel is
+* drawn, so what the sliders were moved to is held until the next frame's
+* input and recorded with it; in playback the sliders are locked and the
+* recording moves them instead.
+ */
+
+const (
+	panelWidth  = 240 // Window pixels
+	panelTitle  = 24  // Window pixels of raygui's title bar
+	panelRow    = 30  // Window pixels a slider takes up, its label included
+	panelBar    = 10  // Window pixels high a slider's bar is
+	panelMargin = 10  // Window pixels from the window's edge and inside the panel
+)
+
+// panelParams are the parameters on the panel, by their names in
+// ParamSpecs
+var panelParams = []string{
+	"fallRate", "cascadeRate", "diagonalRate",
+	"pressureRate", "pressureSideScale",
+	"velocityDamping", "waveDamping",
+	"generatorRate", "evaporation",
+}
+
+// panelSpec is the range of the parameter on slider i of the panel
+func panelSpec(i int) (name string, field func(*gridfluid.Params) *float64, lo, hi float64) {
+	for _, s := range gridfluid.ParamSpecs() {
+		if s.Name == panelParams[i] {
+			return s.Name, s.Field, s.Min, s.Max
+		}
+	}
+	panic("panel: no parameter " + panelParams[i])
+}
+
+// panelRect is where the panel is drawn, in window pixels
+func (g *Game) panelRect() rl.Rectangle {
+	h := float32(panelTitle + len(panelParams)*panelRow + 2*panelMargin)
+	return rl.Rectangle{X: float32(g.Width - panelWidth - panelMargin), Y: panelMargin, Width: panelWidth, Height: h}
+}
+
+// panelBarRect is where the bar of slider i is drawn
+func (g *Game) panelBarRect(i int) rl.Rectangle {
+	r := g.panelRect()
+	y := r.Y + panelTitle + panelMargin + float32(i*panelRow) + panelRow - panelBar - 6
+	return rl.Rectangle{X: r.X + panelMargin, Y: y, Width: r.Width - 2*panelMargin, Height: panelBar}
+}
+
+// handlePanel applies the sliders moved when the panel was last drawn, or
+// in playback the ones recorded, and reports whether the mouse is on the
+// panel, so the brush leaves it alone
+func (g *Game) handlePanel() bool {
+	t := &g.input
+	if t.player == nil && g.panelMoved != nil {
+		t.frame.Panel, g.panelMoved = g.panelMoved, nil
+	}
+	for i := range panelParams {
+		if v, ok := t.frame.Panel[panelParams[i]]; ok {
+			_, field, _, _ := panelSpec(i)
+			*field(&g.Params) = v
+		}
+	}
+	return g.showPanel && rl.CheckCollisionPointRec(g.mousePosition(), g.panelRect())
+}
+
+// drawPanel draws the sliders with their parameters' values and notes the
+// ones the mouse moves
+func (g *Game) drawPanel() {
+	if !g.showPanel {
+		g.panelMoved = nil
+		return
+	}
+	if g.input.player != nil {
+		raygui.Lock()
+		defer raygui.Unlock()
+	}
+	raygui.Panel(g.panelRect(), "parameters")
+	for i := range panelParams {
+		name, field, lo, hi := panelSpec(i)
+		v := *field(&g.Params)
+		bar := g.panelBarRect(i)
+		raygui.Label(rl.Rectangle{X: bar.X, Y: bar.Y - 14, Width: bar.Width, Height: 12}, fmt.Sprintf("%s %.3f", name, v))
+		// raygui clamps the value it is given, which isn't a move
+		at := float32(min(max(v, lo), hi))
+		if moved := raygui.SliderBar(bar, "", "", at, float32(lo), float32(hi)); moved != at {
+			if g.panelMoved == nil {
+				g.panelMoved = map[string]float64{}
+			}
+			g.panelMoved[name] = float64(moved)
+		}
+	}
+}
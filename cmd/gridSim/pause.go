@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Pause and time scale
+*
+* Space stops the sim and starts it again, and period runs exactly one
+* update, pausing first if the sim was running. The tools, the view and the
+* editor keep working while the water stands still, so a flow rule can be
+* watched one update at a time on a scene set up for it.
+*
+* Semicolon and apostrophe step the time scale down and up through
+* timeScales, from slow motion for watching splashes to fast forward for
+* filling a large reservoir. The frame rate stays the same; only the number
+* of updates run per frame changes.
+ */
+
+// Time scales ; and ' step through
+var timeScales = []float64{gridfluid.MinTimeScale, 0.25, 0.5, 1, 2, 4, gridfluid.MaxTimeScale}
+
+// togglePause stops or restarts the updates
+func (g *Game) togglePause() {
+	g.paused = !g.paused
+}
+
+// stepPaused pauses the sim and lets one update through
+func (g *Game) stepPaused() {
+	g.paused = true
+	g.stepOnce = true
+}
+
+// stepTimeScale moves the time scale by steps places along timeScales
+func (g *Game) stepTimeScale(steps int) {
+	i, _ := slices.BinarySearch(timeScales, g.Speed())
+	i = min(max(i+steps, 0), len(timeScales)-1)
+	g.TimeScale = timeScales[i]
+}
+
+// drawPaused shows that the sim is paused, or how fast it runs when that
+// isn't real time
+func (g *Game) drawPaused() {
+	text := ""
+	switch {
+	case g.paused:
+		text = "PAUSED  [Space] run  [.] step"
+	case g.Speed() != 1:
+		text = fmt.Sprintf("%gx  [;] slower  ['] faster", g.Speed())
+	default:
+		return
+	}
+	w := rl.MeasureText(text, 20)
+	rl.DrawText(text, int32(g.Width)/2-w/2, 30, 20, rl.Yellow)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Pipes
+ */
+
+// drawPipe draws the casing of a pipe cell with the water inside it
+func drawPipe(d *gridfluid.Droplet, pixelX, pixelY, tileSize int) {
+	ts := int32(tileSize)
+	px, py := int32(pixelX), int32(pixelY)
+	wall, bore := ts/2, ts/4
+	water := rl.Fade(fluidColor(d), float32(min(max(d.Volume, 0), 1)))
+
+	rect := func(width int32, c rl.Color) {
+		inset := (ts - width) / 2
+		rl.DrawRectangle(px+inset, py+inset, width, width, c)
+		if d.Pipe&gridfluid.PipeUp != 0 {
+			rl.DrawRectangle(px+inset, py, width, inset, c)
+		}
+		if d.Pipe&gridfluid.PipeDown != 0 {
+			rl.DrawRectangle(px+inset, py+ts-inset, width, inset, c)
+		}
+		if d.Pipe&gridfluid.PipeLeft != 0 {
+			rl.DrawRectangle(px, py+inset, inset, width, c)
+		}
+		if d.Pipe&gridfluid.PipeRight != 0 {
+			rl.DrawRectangle(px+ts-inset, py+inset, inset, width, c)
+		}
+	}
+	rect(wall+2, colors.pipeRim)
+	rect(wall, colors.pipe)
+	rect(bore, rl.Black)
+	if d.Volume > gridfluid.WetThreshold {
+		rect(bore, water)
+	}
+}
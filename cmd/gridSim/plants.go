@@ -0,0 +1,27 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Plants
+ */
+
+// plantTint fades a plant from green to brown as it runs out of water
+func plantTint(d *gridfluid.Droplet) rl.Color {
+	return rl.ColorLerp(colors.wither, colors.material(gridfluid.MaterialPlant), float32(min(d.Growth, 1)))
+}
+
+// drawLeaves draws a pair of leaves on a plant cell so it reads as foliage
+// even when soaked
+func drawLeaves(d *gridfluid.Droplet, pixelX, pixelY, tileSize int) {
+	c := rl.ColorBrightness(plantTint(d), 0.2)
+	mid := float32(tileSize) / 2
+	x, y := float32(pixelX), float32(pixelY)
+	stem := rl.Vector2{X: x + mid, Y: y + float32(tileSize)*0.75}
+	rl.DrawLineEx(rl.Vector2{X: x + mid, Y: y + float32(tileSize)}, rl.Vector2{X: x + mid, Y: y}, 2, c)
+	rl.DrawTriangle(stem, rl.Vector2{X: x + mid, Y: y + mid}, rl.Vector2{X: x + float32(tileSize)*0.1, Y: y + mid}, c)
+	rl.DrawTriangle(rl.Vector2{X: x + mid, Y: y + mid*0.6}, rl.Vector2{X: x + float32(tileSize)*0.9, Y: y + mid*0.4}, rl.Vector2{X: x + mid, Y: y + mid*0.2}, c)
+}
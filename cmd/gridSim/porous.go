@@ -0,0 +1,44 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Porous materials
+ */
+
+// drawMaterial draws the porous background behind the water in a cell
+func drawMaterial(d *gridfluid.Droplet, pixelX, pixelY, tileSize int) {
+	if d.Material.IsSolid() || d.Material.Color().A == 0 {
+		return
+	}
+	c := colors.material(d.Material)
+	if d.Material == gridfluid.MaterialPlant {
+		c = plantTint(d)
+	}
+	rl.DrawRectangle(int32(pixelX), int32(pixelY), int32(tileSize), int32(tileSize), c)
+}
+
+// drawMaterialGrain overlays a grain pattern so porous cells stay
+// recognizable while soaked
+func drawMaterialGrain(d *gridfluid.Droplet, pixelX, pixelY, tileSize int) {
+	if d.Material.IsSolid() || d.Material.Color().A == 0 {
+		return
+	}
+	if d.Material == gridfluid.MaterialPlant {
+		drawLeaves(d, pixelX, pixelY, tileSize)
+		return
+	}
+	c := rl.Fade(colors.material(d.Material), 0.8)
+	step := max(tileSize/4, 2)
+	for oy := step / 2; oy < tileSize; oy += step {
+		for ox := step / 2; ox < tileSize; ox += step {
+			if d.Material == gridfluid.MaterialCloth && (ox/step)%2 == (oy/step)%2 {
+				continue
+			}
+			rl.DrawRectangle(int32(pixelX+ox), int32(pixelY+oy), 2, 2, c)
+		}
+	}
+}
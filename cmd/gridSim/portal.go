@@ -0,0 +1,34 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+/*
+* Portals
+ */
+
+// drawPortals draws a pulsing ring over every portal end, coloured by
+// channel so the twins are easy to match up
+func (g *Game) drawPortals() {
+	ts := float32(g.TileSize())
+	pulse := float32(0.5 + 0.5*math.Sin(float64(g.Frame())*0.1))
+	x0, y0, x1, y1 := g.visibleCells()
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			channel := g.State[y][x].Portal
+			if channel == 0 {
+				continue
+			}
+			// Golden angle steps keep neighbouring channels apart in hue
+			c := rl.ColorFromHSV(float32(math.Mod(float64(channel)*137.5, 360)), 0.7, 1)
+			if channel == g.OpenPortal() {
+				c = rl.Fade(c, 0.5)
+			}
+			center := rl.Vector2{X: (float32(x) + 0.5) * ts, Y: (float32(y) + 0.5) * ts}
+			rl.DrawRing(center, ts*(0.3+0.1*pulse), ts*0.5, 0, 360, 16, c)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+/*
+* Pressure heat map
+*
+* P lays a heat map of the pressure over the water, blue where it is
+* lowest and red at the highest pressure in the grid, with a legend in the
+* bottom right corner. The water's own colour mixes pressure and depth, the
+* heat map shows the pressure alone.
+ */
+
+const legendWidth = 200 // Pixels
+
+var pressureStops = []color.RGBA{
+	{20, 40, 200, 255},
+	{40, 200, 220, 255},
+	{240, 220, 40, 255},
+	{220, 30, 20, 255},
+}
+
+// maxPressure is the highest pressure in the grid, the top of the ramp
+func (g *Game) maxPressure() float64 {
+	m := 0.0
+	for y := range g.State {
+		for x := range g.State[y] {
+			m = max(m, g.State[y][x].Pressure)
+		}
+	}
+	return m
+}
+
+// drawPressure colours the wet cells inside the view by pressure
+func (g *Game) drawPressure() {
+	if !g.showPressure {
+		return
+	}
+	top := g.maxPressure()
+	ts := int32(g.TileSize())
+	x0, y0, x1, y1 := g.visibleCells()
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			d := &g.State[y][x]
+			if d.Volume <= 0 || d.Material.IsSolid() {
+				continue
+			}
+			t := 0.0
+			if top > 0 {
+				t = d.Pressure / top
+			}
+			rl.DrawRectangle(int32(x)*ts, int32(y)*ts, ts, ts, rampColor(pressureStops, t))
+		}
+	}
+}
+
+// drawPressureLegend shows the ramp the heat map is coloured with
+func (g *Game) drawPressureLegend() {
+	if !g.showPressure {
+		return
+	}
+	x, y := int32(g.Width-legendWidth-10), int32(g.Height-90)
+	rl.DrawRectangle(x-4, y-16, legendWidth+8, 44, rl.Fade(rl.Black, 0.6))
+	rl.DrawText("pressure", x, y-12, 10, rl.RayWhite)
+	for i := range int32(legendWidth) {
+		rl.DrawRectangle(x+i, y, 1, 12, rampColor(pressureStops, float64(i)/(legendWidth-1)))
+	}
+	top := fmt.Sprintf("%.3f", g.maxPressure())
+	rl.DrawText("0", x, y+14, 10, rl.RayWhite)
+	rl.DrawText(top, x+legendWidth-rl.MeasureText(top, 10), y+14, 10, rl.RayWhite)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Ramps
+*
+* A ramp tile is drawn as its solid half, with the water in its open half
+* over it. The water fills the open half from the corner at the low side,
+* so it is a triangle like the open half, smaller the less there is.
+ */
+
+// drawRamp draws the solid half of a ramp tile
+func drawRamp(d *gridfluid.Droplet, pixelX, pixelY, tileSize int) {
+	x0, y0 := float32(pixelX), float32(pixelY)
+	x1, y1 := float32(pixelX+tileSize), float32(pixelY+tileSize)
+
+	// Vertices go top, bottom-left, bottom-right (counter-clockwise on screen)
+	top := rl.Vector2{X: x1, Y: y0}
+	if d.Ramp == gridfluid.RampRight {
+		top = rl.Vector2{X: x0, Y: y0}
+	}
+	rl.DrawTriangle(top, rl.Vector2{X: x0, Y: y1}, rl.Vector2{X: x1, Y: y1}, obstacleColor(d))
+}
+
+// drawRampWater draws the water in the open half of a ramp tile in colour
+// c, returning how far down the tile it starts
+func drawRampWater(d *gridfluid.Droplet, pixelX, pixelY, tileSize int, c rl.Color) int {
+	if d.Volume <= 0 {
+		return tileSize
+	}
+	// The triangle's area goes with the square of its height
+	h := float32(tileSize) * float32(math.Sqrt(min(d.Volume/gridfluid.RampCapacity, 1)))
+	x0, x1, y1 := float32(pixelX), float32(pixelX+tileSize), float32(pixelY+tileSize)
+	if d.Ramp == gridfluid.RampRight {
+		rl.DrawTriangle(rl.Vector2{X: x1, Y: y1}, rl.Vector2{X: x1, Y: y1 - h}, rl.Vector2{X: x1 - h, Y: y1 - h}, c)
+	} else {
+		rl.DrawTriangle(rl.Vector2{X: x0, Y: y1}, rl.Vector2{X: x0 + h, Y: y1 - h}, rl.Vector2{X: x0, Y: y1 - h}, c)
+	}
+	return tileSize - int(h)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Scenario hot reload
+*
+* With a window open, the -scenario file is watched: saving it rebuilds the
+* scene in place, so a level can be worked on without restarting. The
+* water already in the scene is kept wherever it still fits, unless
+* -reload-water=false, in which case the scenario's own water refills the
+* scene. A file that doesn't load is reported and the scene left as it is.
+ */
+
+const reloadPoll = 500 * time.Millisecond // Time between looks at the file
+
+type scenarioWatch struct {
+	path      string
+	modTime   time.Time
+	size      int64
+	polled    time.Time
+	keepWater bool
+}
+
+// watchScenario starts watching the scenario file at path
+func watchScenario(path string, keepWater bool) *scenarioWatch {
+	w := &scenarioWatch{path: path, keepWater: keepWater, polled: time.Now()}
+	if info, err := os.Stat(path); err == nil {
+		w.modTime, w.size = info.ModTime(), info.Size()
+	}
+	return w
+}
+
+// pollScenario rebuilds the scene if the watched file changed
+func (g *Game) pollScenario() {
+	w := g.watch
+	// Teaching mode replays an update of the scene being replaced, the
+	// reload waits for it to end
+	if w == nil || g.teach != nil || time.Since(w.polled) < reloadPoll {
+		return
+	}
+	w.polled = time.Now()
+	info, err := os.Stat(w.path)
+	if err != nil || (info.ModTime().Equal(w.modTime) && info.Size() == w.size) {
+		return
+	}
+	w.modTime, w.size = info.ModTime(), info.Size()
+
+	s, err := loadScenario(w.path)
+	if err == nil {
+		err = g.rebuild(s, w.keepWater)
+	}
+	if err != nil {
+		log.Printf("reload %s: %v", w.path, err)
+		return
+	}
+	log.Printf("reloaded %s", w.path)
+}
+
+// rebuild replaces the world with the one s builds, carrying the water
+// over if keepWater is set and the cells still line up
+func (g *Game) rebuild(s *scenario, keepWater bool) error {
+	fresh, err := s.build(g.Width, g.Height)
+	if err != nil {
+		return err
+	}
+	if keepWater && fresh.TileSize() == g.TileSize() {
+		for y := range min(len(fresh.State), len(g.State)) {
+			for x := range min(len(fresh.State[y]), len(g.State[y])) {
+				if d := &fresh.State[y][x]; !d.Material.IsSolid() {
+					carryWater(d, &g.State[y][x])
+				}
+			}
+		}
+	} else if keepWater {
+		log.Printf("reload: the tile size changed, starting with the scenario's water")
+	}
+	fresh.SetSeed(g.Seed)
+	fresh.Workers, fresh.TimeScale = g.Workers, g.TimeScale
+	if err := fresh.DisableFeatures(g.DisabledFeatures()); err != nil {
+		return err
+	}
+	// The window stays the size it is
+	fresh.Width, fresh.Height = g.Width, g.Height
+
+	g.Game = fresh.Game
+	g.level = s
+	g.forgetTools()
+	if g.spray != nil {
+		g.startSpray()
+	}
+	if g.mist != nil {
+		g.startMist()
+	}
+	if g.audit != nil {
+		g.auditConservation(g.audit)
+	}
+	return nil
+}
+
+// carryWater puts the water of from, with what it carries, into to
+func carryWater(to, from *gridfluid.Droplet) {
+	to.Volume, to.Fluid, to.VX, to.VY, to.Pressure = from.Volume, from.Fluid, from.VX, from.VY, from.Pressure
+	to.Dye, to.Temperature, to.Stagnation = from.Dye, from.Temperature, from.Stagnation
+	to.Acidity, to.Salinity, to.Sediment = from.Acidity, from.Salinity, from.Sediment
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Replays
+*
+* -record FILE writes what the scene read from the keyboard and mouse every
+* frame to FILE, along with the frame time. The sim is deterministic for a
+* seed, so the same input on the same scene gives the same run. Every
+* replayKeyframeEvery frames the whole world is stored as well, so playback
+* can jump around without running from the start; loading a state or a
+* preset from disk stores one on the next frame, as the file may have
+* changed by the time the recording is played.
+*
+* -replay FILE plays a recording back. The keyboard drives the playback
+* instead of the scene:
+*
+*	Space       pause / resume
+*	Right Left  step one frame forward / back while paused
+*	PgDn PgUp   jump 10 seconds forward / back
+*	Home        back to the start
+*
+* The file is zlib compressed gob: a replayHeader, then one replayEntry per
+* frame. It is flushed at every keyframe, so a run that crashed can still
+* be played up to shortly before.
+ */
+
+const (
+	replayKeyframeEvery = 600 // Frames between keyframes
+	replayJump          = 600 // Frames skipped by PgUp and PgDn
+)
+
+// replayHeader describes how to rebuild the scene a recording was made in
+type replayHeader struct {
+	Width, Height int
+	TileSize      int
+	CellPixels    int    // Window pixels a cell is drawn across, 0 for the tile size
+	Scenario      []byte // Scenario file, nil for the demo
+	Disabled      string // Features switched off with -disable
+}
+
+// inputFrame is what the scene read from the keyboard and mouse in one
+// frame. Only the keys and buttons found down are kept; every other query
+// was answered no.
+type inputFrame struct {
+	Dt         float32
+	Mouse      rl.Vector2
+	MouseDelta rl.Vector2
+	Wheel      float32
+	Pressed    []int32 // Keys
+	Down       []int32
+	Clicked    []rl.MouseButton
+	Held       []rl.MouseButton
+	Clipboard  string             // What the clipboard held, if it was read
+	Window     *windowSize        // What the window was resized to, if it was, see resize.go
+	Panel      map[string]float64 // Parameters the panel's sliders were moved to, see panel.go
+}
+
+type replayEntry struct {
+	Input    inputFrame
+	Keyframe *replayKeyframe // World at the start of the frame, if stored
+}
+
+// replayKeyframe is the world and the tools at the start of a frame
+type replayKeyframe struct {
+	State  []byte // Written by WriteState
+	Resync bool   // Stored after a load from disk, playback applies it
+
+	FrameCount       int
+	DyeIndex         int
+	GaugeKind        gridfluid.GaugeKind
+	GaugeStyle       gridfluid.GaugeStyle
+	WireFrom         *[2]int
+	RegionCorner     *[2]int
+	DropVolume       float64
+	BrushRadius      int
+	Scroll           rl.Vector2
+	Zoom             float32
+	Window           windowSize
+	ShowFlux         bool
+	ShowInterface    bool
+	ShowRegionLabels bool
+	ShowPressure     bool
+	ShowVelocity     bool
+	BlockyWater      bool
+	PlainWater       bool
+	NoLight          bool
+	StillWater       bool
+	ShowIsolines     bool
+	ShowStreamlines  bool
+	ShowPanel        bool
+	Panes            int
+	Theme            int
+	Editor           *editorMode
+	Paused           bool
+	Scene            *scenario         // The scene, unless it is the demo
+	Mutation         *gridfluid.Params // Parameters a pending mutation reverts to
+	MutationChanged  []string
+}
+
+// inputTape stands between the scene and raylib's input. Live, it passes
+// raylib's answers on and notes them for the recording; in playback it
+// answers from the recording.
+type inputTape struct {
+	frame    inputFrame
+	recorder *replayRecorder
+	player   *replayPlayer
+	resync   bool // Store a resync keyframe on the next frame
+}
+
+func (g *Game) keyPressed(key int32) bool {
+	t := &g.input
+	if t.player != nil {
+		return slices.Contains(t.frame.Pressed, key)
+	}
+	if !rl.IsKeyPressed(key) {
+		return false
+	}
+	t.frame.Pressed = append(t.frame.Pressed, key)
+	return true
+}
+
+func (g *Game) keyDown(key int32) bool {
+	t := &g.input
+	if t.player != nil {
+		return slices.Contains(t.frame.Down, key)
+	}
+	if !rl.IsKeyDown(key) {
+		return false
+	}
+	t.frame.Down = append(t.frame.Down, key)
+	return true
+}
+
+func (g *Game) mousePressed(button rl.MouseButton) bool {
+	t := &g.input
+	if t.player != nil {
+		return slices.Contains(t.frame.Clicked, button)
+	}
+	if !rl.IsMouseButtonPressed(button) {
+		return false
+	}
+	t.frame.Clicked = append(t.frame.Clicked, button)
+	return true
+}
+
+func (g *Game) mouseDown(button rl.MouseButton) bool {
+	t := &g.input
+	if t.player != nil {
+		return slices.Contains(t.frame.Held, button)
+	}
+	if !rl.IsMouseButtonDown(button) {
+		return false
+	}
+	t.frame.Held = append(t.frame.Held, button)
+	return true
+}
+
+func (g *Game) mousePosition() rl.Vector2 { return g.input.frame.Mouse }
+func (g *Game) frameTime() float32        { return g.input.frame.Dt }
+
+func (g *Game) mouseDelta() rl.Vector2 {
+	t := &g.input
+	if t.player == nil {
+		t.frame.MouseDelta = rl.GetMouseDelta()
+	}
+	return t.frame.MouseDelta
+}
+
+func (g *Game) mouseWheel() float32 {
+	t := &g.input
+	if t.player == nil {
+		t.frame.Wheel = rl.GetMouseWheelMove()
+	}
+	return t.frame.Wheel
+}
+
+func (g *Game) clipboardText() string {
+	t := &g.input
+	if t.player == nil {
+		t.frame.Clipboard = rl.GetClipboardText()
+	}
+	return t.frame.Clipboard
+}
+
+// beginFrame starts a frame of input, from raylib or the recording
+func (g *Game) beginFrame() {
+	t := &g.input
+	if p := t.player; p != nil {
+		e := p.entries[p.next]
+		if e.Keyframe != nil && e.Keyframe.Resync {
+			if err := g.restoreKeyframe(e.Keyframe); err != nil {
+				log.Printf("replay: %v", err)
+			}
+		}
+		t.frame = e.Input
+		if w := t.frame.Window; w != nil {
+			g.setWindow(*w)
+		}
+		p.next++
+		return
+	}
+	t.frame = inputFrame{Dt: rl.GetFrameTime(), Mouse: rl.GetMousePosition()}
+	if rl.IsWindowResized() {
+		g.fitWindow(rl.GetScreenWidth(), rl.GetScreenHeight())
+		w := g.window()
+		t.frame.Window = &w
+	}
+
+	// Teaching mode can't be resumed from a keyframe, so one falling due
+	// waits for it to end
+	r := t.recorder
+	if r == nil || g.teach != nil || (r.frames-r.lastKeyframe < replayKeyframeEvery && !t.resync && r.frames > 0) {
+		return
+	}
+	k, err := g.keyframe()
+	if err != nil {
+		g.stopRecording(err)
+		return
+	}
+	k.Resync, t.resync = t.resync, false
+	r.pending, r.lastKeyframe = k, r.frames
+}
+
+// endFrame writes the frame to the recording, if there is one
+func (g *Game) endFrame() {
+	if r := g.input.recorder; r != nil {
+		if err := r.write(g.input.frame); err != nil {
+			g.stopRecording(err)
+		}
+	}
+}
+
+// stopRecording gives up on a recording that failed
+func (g *Game) stopRecording(err error) {
+	log.Printf("record: %v, recording stopped", err)
+	g.input.recorder.close()
+	g.input.recorder = nil
+}
+
+// keyframe stores the world and the tools as they are now
+func (g *Game) keyframe() (*replayKeyframe, error) {
+	var buf bytes.Buffer
+	if err := g.WriteState(&buf); err != nil {
+		return nil, err
+	}
+	k := &replayKeyframe{
+		State: buf.Bytes(), FrameCount: g.frameCount,
+		DyeIndex: g.dyeIndex, GaugeKind: g.gaugeKind, GaugeStyle: g.gaugeStyle,
+		WireFrom: g.wireFrom, RegionCorner: g.regionCorner, DropVolume: g.dropVolume, BrushRadius: g.brushRadius, Scroll: g.scroll, Zoom: g.zoom, Window: g.window(),
+		ShowFlux: g.showFlux, ShowInterface: g.showInterface, ShowRegionLabels: g.showRegionLabels,
+		ShowPressure: g.showPressure, ShowVelocity: g.showVelocity, BlockyWater: g.blockyWater, PlainWater: g.plainWater, NoLight: g.noLight, StillWater: g.stillWater, ShowIsolines: g.showIsolines, ShowStreamlines: g.showStreamlines, ShowPanel: g.showPanel, Panes: g.panes, Theme: g.theme, Editor: g.editor.clone(), Paused: g.paused,
+	}
+	if s, ok := g.level.(*scenario); ok {
+		k.Scene = s.clone()
+	}
+	if g.mutation != nil {
+		k.Mutation, k.MutationChanged = &g.mutation.previous, g.mutation.changed
+	}
+	return k, nil
+}
+
+// restoreKeyframe puts the world and the tools back as k stored them
+func (g *Game) restoreKeyframe(k *replayKeyframe) error {
+	if err := g.ReadState(bytes.NewReader(k.State)); err != nil {
+		return err
+	}
+	g.frameCount = k.FrameCount
+	g.dyeIndex, g.gaugeKind, g.gaugeStyle = k.DyeIndex, k.GaugeKind, k.GaugeStyle
+	// Recordings from before windows could be resized have no size
+	if k.Window.Width > 0 {
+		g.setWindow(k.Window)
+	}
+	g.wireFrom, g.regionCorner, g.dropVolume, g.scroll, g.zoom = k.WireFrom, k.RegionCorner, k.DropVolume, k.Scroll, k.Zoom
+	g.brushRadius = k.BrushRadius
+	g.showFlux, g.showInterface, g.showRegionLabels = k.ShowFlux, k.ShowInterface, k.ShowRegionLabels
+	g.showPressure, g.showVelocity, g.showIsolines, g.showStreamlines = k.ShowPressure, k.ShowVelocity, k.ShowIsolines, k.ShowStreamlines
+	g.blockyWater, g.plainWater, g.noLight, g.stillWater, g.theme = k.BlockyWater, k.PlainWater, k.NoLight, k.StillWater, k.Theme
+	g.editor, g.paused, g.stepOnce = k.Editor.clone(), k.Paused, false
+	g.showPanel, g.panelMoved, g.panes = k.ShowPanel, nil, k.Panes
+	g.level = g.input.player.level
+	if k.Scene != nil {
+		g.level = k.Scene.clone()
+	}
+	g.mutation = nil
+	if k.Mutation != nil {
+		g.mutation = &mutation{previous: *k.Mutation, changed: k.MutationChanged}
+	}
+	g.teach, g.flashes, g.wantReport = nil, nil, false
+	g.view = g.View()
+	return nil
+}
+
+/*
+* Recording
+ */
+
+type replayRecorder struct {
+	f            *os.File
+	zw           *zlib.Writer
+	enc          *gob.Encoder
+	frames       int             // Frames written so far
+	lastKeyframe int             // Frame the last keyframe was stored at
+	pending      *replayKeyframe // Keyframe of the frame being recorded
+}
+
+// startRecording writes the header of a recording of the scene to path
+func startRecording(path string, h replayHeader) (*replayRecorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &replayRecorder{f: f, zw: zlib.NewWriter(f)}
+	r.enc = gob.NewEncoder(r.zw)
+	if err := r.enc.Encode(&h); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// write appends a frame, with its keyframe if one was stored
+func (r *replayRecorder) write(in inputFrame) error {
+	e := replayEntry{Input: in, Keyframe: r.pending}
+	r.pending = nil
+	r.frames++
+	if err := r.enc.Encode(&e); err != nil {
+		return err
+	}
+	if e.Keyframe != nil {
+		return r.zw.Flush()
+	}
+	return nil
+}
+
+// close finishes the recording
+func (r *replayRecorder) close() error {
+	err := r.zw.Close()
+	return errors.Join(err, r.f.Close())
+}
+
+/*
+* Playback
+ */
+
+type replayPlayer struct {
+	header  replayHeader
+	entries []replayEntry
+	next    int // Entry played on the next frame
+	paused  bool
+	level   scene // Scene the recording started with
+}
+
+// loadReplay reads a recording, up to where it ends or was cut off
+func loadReplay(path string) (*replayPlayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := zlib.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return nil, err
+	}
+	dec := gob.NewDecoder(zr)
+	p := &replayPlayer{}
+	if err := dec.Decode(&p.header); err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	for {
+		var e replayEntry
+		if err := dec.Decode(&e); err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("replay: recording cut off after %d frames: %v", len(p.entries), err)
+			}
+			break
+		}
+		p.entries = append(p.entries, e)
+	}
+	if len(p.entries) == 0 || p.entries[0].Keyframe == nil {
+		return nil, errors.New("recording holds no frames")
+	}
+	return p, nil
+}
+
+// scene rebuilds the game and scene the recording was made in. The world
+// itself comes from the first keyframe.
+func (p *replayPlayer) scene() (*Game, error) {
+	h := p.header
+	var game *Game
+	if h.Scenario != nil {
+		s, err := parseScenario(h.Scenario)
+		if err != nil {
+			return nil, err
+		}
+		if game, err = s.build(h.Width, h.Height); err != nil {
+			return nil, err
+		}
+		game.level = s
+	} else if h.CellPixels > 0 {
+		game = NewWorld(h.Width, h.Height, h.TileSize, h.Width/h.CellPixels, h.Height/h.CellPixels)
+		game.level = setupDemo(game)
+	} else {
+		game = NewGame(h.Width, h.Height, h.TileSize)
+		game.level = setupDemo(game)
+	}
+	if h.CellPixels > 0 {
+		game.SetCellPixels(h.CellPixels, h.Width, h.Height)
+	}
+	if err := game.DisableFeatures(h.Disabled); err != nil {
+		return nil, err
+	}
+	p.level = game.level
+	return game, nil
+}
+
+// control handles the playback keys and reports whether the next recorded
+// frame is played this frame
+func (p *replayPlayer) control(g *Game) bool {
+	switch {
+	case rl.IsKeyPressed(rl.KeySpace):
+		p.paused = !p.paused
+	case rl.IsKeyPressed(rl.KeyHome):
+		p.seek(g, 0)
+	case rl.IsKeyPressed(rl.KeyPageUp):
+		p.seek(g, p.next-replayJump)
+	case rl.IsKeyPressed(rl.KeyPageDown):
+		p.seek(g, p.next+replayJump)
+	case p.paused && rl.IsKeyPressed(rl.KeyLeft):
+		p.seek(g, p.next-1)
+	case p.paused && rl.IsKeyPressed(rl.KeyRight):
+		return p.next < len(p.entries)
+	}
+	return !p.paused && p.next < len(p.entries)
+}
+
+// seek plays up to the start of frame, from the closest keyframe before it
+// or from where playback is, whichever is closer
+func (p *replayPlayer) seek(g *Game, frame int) {
+	frame = max(min(frame, len(p.entries)), 0)
+	start := min(frame, len(p.entries)-1)
+	for p.entries[start].Keyframe == nil {
+		start--
+	}
+	if start > p.next || frame < p.next {
+		if err := g.restoreKeyframe(p.entries[start].Keyframe); err != nil {
+			log.Printf("replay: %v", err)
+			return
+		}
+		p.next = start
+	}
+	for p.next < frame {
+		g.runFrame()
+	}
+}
+
+// runFrame plays one frame of the scene without drawing it
+func (g *Game) runFrame() {
+	g.beginFrame()
+	g.HandleInput()
+	g.view = g.View()
+	g.runUpdates()
+	g.wantReport = false
+	g.endFrame()
+}
+
+// draw shows where playback is
+func (p *replayPlayer) draw(g *Game) {
+	state := ""
+	switch {
+	case p.next >= len(p.entries):
+		state = "  end"
+	case p.paused:
+		state = "  paused"
+	}
+	rl.DrawText(fmt.Sprintf("REPLAY %d/%d%s", p.next, len(p.entries), state), int32(g.Width-160), 140, 10, rl.Yellow)
+}
@@ -0,0 +1,131 @@
+//go:build !noexport
+
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Issue report bundles
+*
+* A report is a zip meant to be attached to bug reports:
+*
+*	info.json        seed, frame, grid size, wind, platform
+*	params.json      solver parameters
+*	scene.txt        scene code of the current frame
+*	frames/NNNN.txt  scene codes of recent frames, oldest first
+*	screenshot.png   the frame the report was requested on
+ */
+
+const (
+	reportFrameEvery = 10 // Record a scene code every N updates
+	reportFrameCount = 30 // How many recent frames a report includes
+)
+
+type reportInfo struct {
+	Seed     uint64           `json:"seed"`
+	Frame    int              `json:"frame"`
+	GridW    int              `json:"gridWidth"`
+	GridH    int              `json:"gridHeight"`
+	TileSize int              `json:"tileSize"`
+	Wind     gridfluid.Vector `json:"wind"`
+	OS       string           `json:"os"`
+	Arch     string           `json:"arch"`
+	Go       string           `json:"go"`
+}
+
+// recordReportFrame keeps a ring of recent scene codes for reports
+func (g *Game) recordReportFrame() {
+	if g.Frame()%reportFrameEvery != 0 || !g.FeatureEnabled("export") {
+		return
+	}
+	code, err := g.SceneCode()
+	if err != nil {
+		return
+	}
+	g.recentFrames = append(g.recentFrames, code)
+	if len(g.recentFrames) > reportFrameCount {
+		g.recentFrames = g.recentFrames[1:]
+	}
+}
+
+// WriteReport packages the current state into report_<time>.zip. The
+// screenshot is read from the framebuffer, so call it after drawing.
+func (g *Game) WriteReport() (string, error) {
+	if !g.FeatureEnabled("export") {
+		return "", gridfluid.FeatureOffError("export")
+	}
+	name := fmt.Sprintf("report_%s.zip", time.Now().Format("20060102_150405"))
+	f, err := os.Create(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	add := func(path string, data []byte) error {
+		w, err := zw.Create(path)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	info, err := json.MarshalIndent(reportInfo{
+		Seed:     g.Seed,
+		Frame:    g.Frame(),
+		GridW:    len(g.State[0]),
+		GridH:    len(g.State),
+		TileSize: g.TileSize(),
+		Wind:     g.Wind,
+		OS:       runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		Go:       runtime.Version(),
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := add("info.json", info); err != nil {
+		return "", err
+	}
+
+	params, err := json.MarshalIndent(g.Params, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := add("params.json", params); err != nil {
+		return "", err
+	}
+
+	scene, err := g.SceneCode()
+	if err != nil {
+		return "", err
+	}
+	if err := add("scene.txt", []byte(scene)); err != nil {
+		return "", err
+	}
+	for i, code := range g.recentFrames {
+		if err := add(fmt.Sprintf("frames/%04d.txt", i), []byte(code)); err != nil {
+			return "", err
+		}
+	}
+
+	img := rl.LoadImageFromScreen()
+	screenshot := rl.ExportImageToMemory(*img, ".png")
+	rl.UnloadImage(img)
+	if err := add("screenshot.png", screenshot); err != nil {
+		return "", err
+	}
+
+	return name, zw.Close()
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"math"
+)
+
+/*
+* Re-resolution
+ */
+
+// SetTileSize rebuilds the scene at half or double the current tile size,
+// see gridfluid.Game.SetTileSize, and moves the flashes and the view along
+func (g *Game) SetTileSize(ts int) error {
+	if g.teach != nil {
+		return errors.New("can't resample during teaching mode")
+	}
+	scale := float64(g.TileSize()) / float64(ts)
+	if err := g.Game.SetTileSize(ts); err != nil {
+		return err
+	}
+	cell := func(v int) int { return int(math.Floor(float64(v) * scale)) }
+	span := func(v int) int { return max(int(math.Round(float64(v)*scale)), 1) }
+	for i, f := range g.flashes {
+		g.flashes[i].x, g.flashes[i].y, g.flashes[i].radius = cell(f.x), cell(f.y), span(f.radius)
+	}
+	g.wireFrom, g.regionCorner = nil, nil
+	g.ScrollTo(g.scroll.X, g.scroll.Y)
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+/*
+* Window size
+*
+* The window can be resized, and Alt+Enter switches it to borderless
+* fullscreen and back. The grid stays as it is: the cells are drawn bigger
+* or smaller so what the window showed at first still fits it, and a world
+* smaller than the window sits in the middle with the background either
+* side. Resizes are recorded with the frame they happen in, so a recording
+* plays back at the sizes it was made at. Alt+Enter is read live, like
+* F12.
+ */
+
+const (
+	minWindowWidth  = 320 // Window pixels
+	minWindowHeight = 240
+)
+
+// windowSize is a window size with the render scale cells are drawn at in
+// it
+type windowSize struct {
+	Width, Height int
+	Scale         float32 // Window pixels per world pixel unzoomed, 0 for 1
+}
+
+// window is the window size and render scale now
+func (g *Game) window() windowSize {
+	return windowSize{g.Width, g.Height, g.renderScale}
+}
+
+// fitWindow rescales the cells for a window resized to w by h, in
+// proportion to the window they were first drawn in
+func (g *Game) fitWindow(w, h int) {
+	// A minimized window is 0 by 0
+	if w <= 0 || h <= 0 || (w == g.Width && h == g.Height) {
+		return
+	}
+	if g.fit.Width == 0 {
+		g.fit = g.window()
+		g.fit.Scale = g.scale()
+	}
+	scale := g.fit.Scale * min(float32(w)/float32(g.fit.Width), float32(h)/float32(g.fit.Height))
+	g.setWindow(windowSize{w, h, scale})
+}
+
+// setWindow switches to the window size and render scale in s, keeping
+// the zoom where it was relative to the render scale
+func (g *Game) setWindow(s windowSize) {
+	if g.zoom != 0 {
+		scale := s.Scale
+		if scale == 0 {
+			scale = 1
+		}
+		g.zoom *= scale / g.scale()
+	}
+	g.renderScale = s.Scale
+	if s.Width != g.Width || s.Height != g.Height {
+		g.Width, g.Height = s.Width, s.Height
+		// The shader draws through textures the size of the window
+		if g.waterShader != nil {
+			g.waterShader.unload()
+			g.waterShader = loadWaterShader(g.Width, g.Height)
+		}
+		// In playback the window follows the recording
+		if rl.IsWindowReady() && (rl.GetScreenWidth() != g.Width || rl.GetScreenHeight() != g.Height) {
+			rl.SetWindowSize(g.Width, g.Height)
+		}
+	}
+	g.ScrollTo(g.scroll.X, g.scroll.Y)
+	g.view = g.View()
+}
+
+// toggleFullscreen switches between the window and borderless fullscreen
+// on Alt+Enter
+func toggleFullscreen() {
+	alt := rl.IsKeyDown(rl.KeyLeftAlt) || rl.IsKeyDown(rl.KeyRightAlt)
+	if alt && rl.IsKeyPressed(rl.KeyEnter) {
+		rl.ToggleBorderlessWindowed()
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Checkpoints
+*
+* Every checkpointEvery updates the world is stored in memory as a save
+* state, keeping the last checkpointCount. F10 rewinds to the newest one
+* taken before the current update, so pressing it again keeps going back.
+* While the sim is paused a timeline of the checkpoints runs along the
+* bottom of the window, and clicking one rewinds to it. Rewinding drops the
+* checkpoints after the one rewound to, as the world they lead to is gone.
+ */
+
+const (
+	checkpointEvery = 120 // Updates between checkpoints, two seconds
+	checkpointCount = 30  // Checkpoints kept, a minute back
+	timelineHeight  = 14  // Pixels
+)
+
+type checkpoint struct {
+	state      []byte // Written by WriteState
+	frame      int    // Update the checkpoint was taken after
+	frameCount int
+	level      scene
+}
+
+// takeCheckpoint stores the world when a checkpoint is due
+func (g *Game) takeCheckpoint() {
+	if g.Frame()%checkpointEvery != 0 {
+		return
+	}
+	var buf bytes.Buffer
+	if err := g.WriteState(&buf); err != nil {
+		log.Printf("checkpoint: %v", err)
+		return
+	}
+	c := checkpoint{state: buf.Bytes(), frame: g.Frame(), frameCount: g.frameCount, level: g.level}
+	if s, ok := g.level.(*scenario); ok {
+		// The editor changes the scenario in place
+		c.level = s.clone()
+	}
+	g.checkpoints = append(g.checkpoints, c)
+	if len(g.checkpoints) > checkpointCount {
+		g.checkpoints = g.checkpoints[1:]
+	}
+}
+
+// rewind puts the world back to checkpoint i
+func (g *Game) rewind(i int) {
+	if g.teach != nil {
+		log.Printf("rewind: can't rewind during teaching mode")
+		return
+	}
+	// A recording holds the rewound world as a keyframe
+	if g.input.player != nil {
+		return
+	}
+	c := g.checkpoints[i]
+	if err := g.ReadState(bytes.NewReader(c.state)); err != nil {
+		log.Printf("rewind: %v", err)
+		return
+	}
+	g.frameCount, g.level = c.frameCount, c.level
+	if s, ok := c.level.(*scenario); ok {
+		g.level = s.clone()
+	}
+	g.checkpoints = g.checkpoints[:i+1]
+	g.forgetTools()
+	log.Printf("rewound to update %d", c.frame)
+}
+
+// rewindOnce rewinds to the newest checkpoint taken before the current
+// update
+func (g *Game) rewindOnce() {
+	for i := len(g.checkpoints) - 1; i >= 0; i-- {
+		if g.checkpoints[i].frame < g.Frame() {
+			g.rewind(i)
+			return
+		}
+	}
+}
+
+// timelineSlot is the checkpoint under the mouse on the timeline, or -1
+func (g *Game) timelineSlot() int {
+	if !g.paused || len(g.checkpoints) == 0 {
+		return -1
+	}
+	m := g.mousePosition()
+	x, y, w := g.timelineRect()
+	if m.Y < y || m.Y >= y+timelineHeight || m.X < x || m.X >= x+w {
+		return -1
+	}
+	return min(int((m.X-x)/w*checkpointCount), len(g.checkpoints)-1)
+}
+
+// timelineRect is where the timeline is drawn, in window pixels
+func (g *Game) timelineRect() (x, y, w float32) {
+	return 10, float32(g.Height) - 50, float32(g.Width) - 20
+}
+
+// handleTimeline rewinds to the checkpoint clicked on the timeline and
+// reports whether the mouse is over it, so the brush leaves it alone
+func (g *Game) handleTimeline() bool {
+	i := g.timelineSlot()
+	if i < 0 {
+		return false
+	}
+	if g.mousePressed(rl.MouseButtonLeft) {
+		g.rewind(i)
+	}
+	return true
+}
+
+// drawTimeline shows the checkpoints while the sim is paused
+func (g *Game) drawTimeline() {
+	if !g.paused || len(g.checkpoints) == 0 {
+		return
+	}
+	x, y, w := g.timelineRect()
+	slot := w / checkpointCount
+	hover := g.timelineSlot()
+	rl.DrawRectangleV(rl.Vector2{X: x, Y: y}, rl.Vector2{X: w, Y: timelineHeight}, rl.Fade(rl.Black, 0.6))
+	for i := range g.checkpoints {
+		c := rl.SkyBlue
+		if i == hover {
+			c = rl.Yellow
+		}
+		rl.DrawRectangleV(rl.Vector2{X: x + float32(i)*slot + 1, Y: y + 2}, rl.Vector2{X: slot - 2, Y: timelineHeight - 4}, c)
+	}
+	text := "[F10] rewind, or click a checkpoint"
+	if hover >= 0 {
+		back := float64(g.Frame()-g.checkpoints[hover].frame) * gridfluid.TickDuration
+		text = fmt.Sprintf("rewind to update %d, %.0f s back", g.checkpoints[hover].frame, back)
+	}
+	rl.DrawText(text, int32(x), int32(y)-14, 10, rl.RayWhite)
+}
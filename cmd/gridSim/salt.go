@@ -0,0 +1,17 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Salt
+ */
+
+// saltTint shows salt blocks shrinking towards the wet obstacle colour
+func saltTint(d *gridfluid.Droplet) rl.Color {
+	return rl.ColorLerp(colors.salt, rl.Brown, float32(math.Min(d.Dissolved, 1)*0.5))
+}
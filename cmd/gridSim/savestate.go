@@ -0,0 +1,50 @@
+package main
+
+import "log"
+
+/*
+* Save states
+ */
+
+const stateFile = "quicksave.wss"
+
+// quickSave writes the world to stateFile
+func (g *Game) quickSave() {
+	// Playing back a recording leaves the file alone
+	if g.input.player != nil {
+		return
+	}
+	if err := g.SaveState(stateFile); err != nil {
+		log.Printf("save state: %v", err)
+		return
+	}
+	log.Printf("state saved to %s", stateFile)
+}
+
+// quickLoad replaces the world with the one in stateFile
+func (g *Game) quickLoad() {
+	if g.teach != nil {
+		log.Printf("load state: can't load during teaching mode")
+		return
+	}
+	// A recording holds what was loaded as a keyframe, the file may have
+	// changed since
+	if g.input.player != nil {
+		return
+	}
+	if err := g.LoadState(stateFile); err != nil {
+		log.Printf("load state: %v", err)
+		return
+	}
+	g.forgetTools()
+	log.Printf("state loaded from %s", stateFile)
+}
+
+// forgetTools lets go of what the tools were half way through, which
+// refers to the world that was just replaced, and fits the view and the
+// recording to the new one
+func (g *Game) forgetTools() {
+	g.wireFrom, g.regionCorner, g.mutation, g.flashes = nil, nil, nil, nil
+	g.ScrollTo(g.scroll.X, g.scroll.Y)
+	g.input.resync = true
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Scenarios
+*
+* A scenario is a scene written as data rather than code, loaded with
+* -scenario in place of the demo. It is a JSON file:
+*
+*	{
+*	  "cols": 96, "rows": 54, "tileSize": 20,
+*	  "params": {"fallRate": 0.8},
+*	  "obstacles": [
+*	    {"rect": [10, 30, 50, 3]},
+*	    {"line": [60, 10, 80, 25], "width": 2, "material": "gravel"},
+*	    {"circle": [40, 12, 3]},
+*	    {"polygon": [20, 40, 30, 34, 34, 44]}
+*	  ],
+*	  "generators": [{"x": 20, "y": 0, "width": 5, "every": 5, "fluid": "oil"}],
+*	  "drains": [{"x": 50, "y": 52}],
+*	  "water": [{"rect": [12, 20, 30, 9]}],
+*	  "colors": {"water": "#2060ff", "obstacle": "#505058", "background": "#101020"},
+*	  "tileset": {"image": "rock.png", "tileSize": 16, "materials": {"stone": 0}}
+*	}
+*
+* Cell sizes default to 20 pixels. Parameters not listed keep their
+* defaults, see params.go. Obstacles are shapes filled with a material by
+* name, stone unless given, see pkg/gridfluid/shapes.go. Generators top up
+* a row of cells every few updates like the demo's streams, by the
+* generatorRate parameter, and drains are vortex drains. Water
+* fills its rectangles to the brim at the start, with water unless a fluid
+* is given. Colours, all optional, are drawn over the theme's, the obstacle
+* colour standing in for stone, see theme.go. A tileset draws obstacles
+* with tiles from an image, see tileset.go, and a background puts a sky
+* and scenery behind the world, see background.go.
+*
+* The editor writes scenarios too, see editor.go.
+ */
+
+const defaultScenarioTileSize = 20
+
+type scenario struct {
+	Cols       int                 `json:"cols"`
+	Rows       int                 `json:"rows"`
+	TileSize   int                 `json:"tileSize"`
+	Params     gridfluid.Params    `json:"params"`
+	Obstacles  []scenarioObstacle  `json:"obstacles"`
+	Generators []scenarioGenerator `json:"generators"`
+	Drains     []scenarioDrain     `json:"drains"`
+	Water      []scenarioWater     `json:"water,omitempty"`
+	Colors     *scenarioColors     `json:"colors,omitempty"`
+	Tileset    *scenarioTileset    `json:"tileset,omitempty"`
+	Background *scenarioBackground `json:"background,omitempty"`
+
+	source []byte // The file as read, kept for recordings
+	dir    string // Directory of the file, for the tileset's and background's images
+	name   string // Name of the file without the extension, for screenshots
+}
+
+// scenarioColors are a scenario's own colours, as #rrggbb
+type scenarioColors struct {
+	Water      string `json:"water,omitempty"`
+	Obstacle   string `json:"obstacle,omitempty"`
+	Background string `json:"background,omitempty"`
+}
+
+type scenarioObstacle struct {
+	Rect     []int  `json:"rect"`              // x, y, width, height
+	Line     []int  `json:"line"`              // x0, y0, x1, y1
+	Width    int    `json:"width"`             // Thickness of a line, 1 if unset
+	Circle   []int  `json:"circle,omitempty"`  // x, y, radius
+	Polygon  []int  `json:"polygon,omitempty"` // x0, y0, x1, y1, x2, y2, ...
+	Material string `json:"material"`          // Stone if unset
+}
+
+type scenarioGenerator struct {
+	X     int    `json:"x"`
+	Y     int    `json:"y"`
+	Width int    `json:"width"`           // Cells topped up, 1 if unset
+	Every int    `json:"every"`           // Updates between top ups, 1 if unset
+	Fluid string `json:"fluid,omitempty"` // Water if unset
+}
+
+type scenarioDrain struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type scenarioWater struct {
+	Rect  []int  `json:"rect"`            // x, y, width, height
+	Fluid string `json:"fluid,omitempty"` // Water if unset
+}
+
+// scene is what the main loop tops up every update: the demo or a scenario
+type scene interface {
+	spawn(game *Game, frameCount int) float64
+}
+
+// loadScenario reads and checks a scenario file
+func loadScenario(path string) (*scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := parseScenario(data)
+	if err != nil {
+		return nil, err
+	}
+	s.dir = filepath.Dir(path)
+	s.name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return s, nil
+}
+
+// parseScenario checks the scenario held in data
+func parseScenario(data []byte) (*scenario, error) {
+	s := &scenario{TileSize: defaultScenarioTileSize, Params: gridfluid.DefaultParams(), source: data}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Cols <= 0 || s.Rows <= 0 || s.TileSize <= 0 {
+		return nil, errors.New("cols, rows and tileSize must be positive")
+	}
+	for i := range s.Generators {
+		gen := &s.Generators[i]
+		gen.Width, gen.Every = max(gen.Width, 1), max(gen.Every, 1)
+		if _, ok := lookupFluid(gen.Fluid); !ok {
+			return nil, fmt.Errorf("generator %d: unknown fluid %q", i, gen.Fluid)
+		}
+		if !s.inside(gen.X, gen.Y) || !s.inside(gen.X+gen.Width-1, gen.Y) {
+			return nil, fmt.Errorf("generator %d: outside the grid", i)
+		}
+	}
+	for i, d := range s.Drains {
+		if !s.inside(d.X, d.Y) {
+			return nil, fmt.Errorf("drain %d: outside the grid", i)
+		}
+	}
+	for i, w := range s.Water {
+		if _, ok := lookupFluid(w.Fluid); !ok {
+			return nil, fmt.Errorf("water %d: unknown fluid %q", i, w.Fluid)
+		}
+		if len(w.Rect) != 4 || !s.inside(w.Rect[0], w.Rect[1]) || !s.inside(w.Rect[0]+w.Rect[2]-1, w.Rect[1]+w.Rect[3]-1) {
+			return nil, fmt.Errorf("water %d: needs a rect of four numbers inside the grid", i)
+		}
+	}
+	if c := s.Colors; c != nil {
+		for name, v := range map[string]string{"water": c.Water, "obstacle": c.Obstacle, "background": c.Background} {
+			if _, err := parseColor(v); v != "" && err != nil {
+				return nil, fmt.Errorf("colors: %s: %v", name, err)
+			}
+		}
+	}
+	if t := s.Tileset; t != nil {
+		if err := t.check(); err != nil {
+			return nil, fmt.Errorf("tileset: %v", err)
+		}
+	}
+	if b := s.Background; b != nil {
+		if err := b.check(); err != nil {
+			return nil, fmt.Errorf("background: %v", err)
+		}
+	}
+	return s, nil
+}
+
+// lookupFluid finds a fluid by name, water if the name is empty
+func lookupFluid(name string) (gridfluid.Fluid, bool) {
+	if name == "" {
+		return gridfluid.FluidWater, true
+	}
+	return gridfluid.LookupFluid(name)
+}
+
+func (s *scenario) inside(x, y int) bool {
+	return x >= 0 && y >= 0 && x < s.Cols && y < s.Rows
+}
+
+// build creates a game holding the scenario's scene. The window fits the
+// grid, up to maxW by maxH pixels; larger grids scroll, see view.go.
+func (s *scenario) build(maxW, maxH int) (*Game, error) {
+	w, h := min(s.Cols*s.TileSize, maxW), min(s.Rows*s.TileSize, maxH)
+	game := NewWorld(w, h, s.TileSize, s.Cols, s.Rows)
+	game.Params = s.Params
+	for i, o := range s.Obstacles {
+		if err := s.place(game, o); err != nil {
+			return nil, fmt.Errorf("obstacle %d: %v", i, err)
+		}
+	}
+	for _, d := range s.Drains {
+		if !game.State[d.Y][d.X].Vortex {
+			game.PlaceVortex(d.X, d.Y)
+		}
+	}
+	for _, w := range s.Water {
+		fluid, _ := lookupFluid(w.Fluid)
+		for y := w.Rect[1]; y < w.Rect[1]+w.Rect[3]; y++ {
+			for x := w.Rect[0]; x < w.Rect[0]+w.Rect[2]; x++ {
+				if d := &game.State[y][x]; !d.Material.IsSolid() {
+					d.Fluid, d.Volume = fluid, 1.0
+				}
+			}
+		}
+	}
+	return game, nil
+}
+
+// place fills the cells of one obstacle with its material
+func (s *scenario) place(game *Game, o scenarioObstacle) error {
+	material := gridfluid.MaterialStone
+	if o.Material != "" {
+		id, ok := gridfluid.LookupMaterial(o.Material)
+		if !ok {
+			return fmt.Errorf("unknown material %q", o.Material)
+		}
+		material = id
+	}
+	shape, err := o.shape()
+	if err != nil {
+		return err
+	}
+	outside := false
+	shape.Cells(func(x, y int) { outside = outside || !s.inside(x, y) })
+	if outside {
+		return errors.New("outside the grid")
+	}
+	gridfluid.FillShape(shape, material, &game.State)
+	return nil
+}
+
+// shape is the area of the grid the obstacle covers
+func (o scenarioObstacle) shape() (gridfluid.Shape, error) {
+	switch {
+	case len(o.Rect) == 4:
+		return gridfluid.Rect{X: o.Rect[0], Y: o.Rect[1], W: o.Rect[2], H: o.Rect[3]}, nil
+	case len(o.Line) == 4:
+		return gridfluid.Line{X0: o.Line[0], Y0: o.Line[1], X1: o.Line[2], Y1: o.Line[3], Thickness: o.Width}, nil
+	case len(o.Circle) == 3:
+		return gridfluid.Circle{X: o.Circle[0], Y: o.Circle[1], Radius: o.Circle[2]}, nil
+	case len(o.Polygon) >= 6 && len(o.Polygon)%2 == 0:
+		var p gridfluid.Polygon
+		for i := 0; i < len(o.Polygon); i += 2 {
+			p = append(p, [2]int{o.Polygon[i], o.Polygon[i+1]})
+		}
+		return p, nil
+	}
+	return nil, errors.New("needs a rect or a line of four numbers, a circle of three or a polygon of three corners or more")
+}
+
+// clone copies the scenario, so the editor can change the copy
+func (s *scenario) clone() *scenario {
+	c := *s
+	c.Obstacles = slices.Clone(s.Obstacles)
+	c.Generators = slices.Clone(s.Generators)
+	c.Drains = slices.Clone(s.Drains)
+	c.Water = slices.Clone(s.Water)
+	return &c
+}
+
+// generatorCells is the row of cells gen tops up, given in cells of
+// tileSize, on a grid of gridTileSize
+func generatorCells(gen scenarioGenerator, tileSize, gridTileSize int) (x0, x1, y int) {
+	scale := func(n int) int { return n * tileSize / gridTileSize }
+	return scale(gen.X), scale(gen.X) + max(scale(gen.Width), 1), scale(gen.Y)
+}
+
+// spawn tops up the scenario's generators and returns the volume added
+func (s *scenario) spawn(game *Game, frameCount int) float64 {
+	added := 0.0
+	for _, gen := range s.Generators {
+		if frameCount%gen.Every != 0 {
+			continue
+		}
+		fluid, _ := lookupFluid(gen.Fluid)
+		// The grid may have been resampled since the scene was built
+		x0, x1, y := generatorCells(gen, s.TileSize, game.TileSize())
+		for x := x0; x < x1; x++ {
+			if !game.InBounds(x, y) {
+				continue
+			}
+			cell := &game.State[y][x]
+			if !cell.Material.IsSolid() && cell.Volume < 0.7 {
+				before := cell.Volume
+				cell.Fluid = fluid
+				cell.Volume = min(cell.Volume+game.Params.GeneratorRate, 1)
+				added += cell.Volume - before
+				cell.Stagnation = 0
+			}
+		}
+	}
+	return added
+}
@@ -0,0 +1,59 @@
+//go:build !noexport
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Screenshots
+*
+* F12 saves the window to screenshots/<scene>_<frame>_<time>.png, the
+* scene being the demo, caves or the scenario or image file's name and the
+* frame the sim's update count. The key is read live, in playback too, and
+* never recorded. With -screenshot-clean the shot is taken as soon as the
+* world is drawn, before the readouts, the editor, the brush and the
+* cursor.
+ */
+
+const screenshotDir = "screenshots"
+
+// saveScreenshot writes what has been drawn of the frame so far
+func (g *Game) saveScreenshot() (string, error) {
+	if !g.FeatureEnabled("export") {
+		return "", gridfluid.FeatureOffError("export")
+	}
+	// What was drawn lately is still waiting in the batch
+	rl.DrawRenderBatchActive()
+	img := rl.LoadImageFromScreen()
+	defer rl.UnloadImage(img)
+
+	if err := os.MkdirAll(screenshotDir, 0o755); err != nil {
+		return "", err
+	}
+	name := filepath.Join(screenshotDir, fmt.Sprintf("%s_%06d_%s.png", g.sceneName(), g.Frame(), time.Now().Format("20060102_150405")))
+	if !rl.ExportImage(*img, name) {
+		return "", errors.New("can't write " + name)
+	}
+	return name, nil
+}
+
+// sceneName names the scene for screenshots
+func (g *Game) sceneName() string {
+	switch s := g.level.(type) {
+	case *scenario:
+		if s.name != "" {
+			return s.name
+		}
+		return "scenario"
+	}
+	return "demo"
+}
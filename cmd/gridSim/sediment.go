@@ -0,0 +1,29 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Sediment
+ */
+
+// sandTint shows sand darkening as it is scoured away
+func sandTint(d *gridfluid.Droplet) rl.Color {
+	return rl.ColorLerp(colors.material(gridfluid.MaterialSand), rl.Brown, float32(0.5*math.Min(d.Scoured, 1)))
+}
+
+// drawSediment draws the layers of settled sand
+func (g *Game) drawSediment() {
+	x0, y0, x1, y1 := g.visibleCells()
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			if depth := g.State[y][x].Settled; depth > 0 {
+				g.drawLayer(x, y, float32(math.Min(depth/gridfluid.SettledFull, 1)), colors.material(gridfluid.MaterialSand))
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"log"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Water shader
+*
+* The water of the front layer is drawn into a texture of its own, apart
+* from everything behind it, and a fragment shader lays it over the rest:
+* the background shows through bent by ripples that drift along with the
+* flow, and the water darkens the deeper below the surface it is. The flow
+* is passed to the shader as a third texture, every wet cell's velocity
+* drawn as a colour along with how see-through the water is, see
+* transparency.go. Ctrl+O draws the water plain, and so does a machine the
+* shader fails to compile on.
+ */
+
+const flowColorScale = 127 // Colour steps of one cell per tick of velocity
+
+const waterFragmentShader = `#version 330
+
+in vec2 fragTexCoord;
+in vec4 fragColor;
+
+uniform sampler2D texture0; // The water
+uniform sampler2D scene;    // Everything behind the water
+uniform sampler2D flow;     // Velocity, 0.5 for still water, and opacity
+uniform float time;         // Seconds
+uniform vec2 size;          // Pixels
+
+out vec4 finalColor;
+
+const float rippleSize = 24.0;  // Pixels per ripple
+const float flowStretch = 4.0;  // Ripples the flow carries them per cycle
+const float refraction = 6.0;   // Pixels the background is bent by
+const float depthScale = 48.0;  // Pixels below the surface of the darkest water
+const float darkest = 0.45;     // Brightness of the darkest water
+const float opacity = 0.8;
+
+vec2 ripple(vec2 p) {
+	return vec2(sin(p.y*1.3 + p.x*0.7 + time*1.7), cos(p.x*1.1 - p.y*0.9 + time*1.3));
+}
+
+void main() {
+	if (texture(texture0, fragTexCoord).a == 0.0) discard;
+	vec2 px = 1.0/size;
+
+	// Render textures are upside down, so up the screen is up the texture
+	float depth = 0.0;
+	for (int i = 1; i <= 12; i++) {
+		if (texture(texture0, fragTexCoord + vec2(0.0, depth + 4.0)*px).a == 0.0) break;
+		depth += 4.0;
+	}
+	float deep = clamp(depth/depthScale, 0.0, 1.0);
+
+	// Two sets of ripples drift with the flow half a cycle apart, each
+	// faded out as it jumps back, so they never stretch out of shape
+	vec4 f = texture(flow, fragTexCoord);
+	vec2 v = (f.rg - 0.5)*2.0;
+	vec2 dir = vec2(v.x, -v.y)*flowStretch;
+	vec2 p = fragTexCoord*size/rippleSize;
+	float phase = fract(time*0.5);
+	vec2 r = mix(ripple(p - dir*phase), ripple(p - dir*fract(phase + 0.5)), abs(1.0 - 2.0*phase));
+
+	// Ripples bend the light most near the surface
+	vec2 offset = r*refraction*px*(1.0 - 0.6*deep);
+	vec3 behind = texture(scene, fragTexCoord + offset).rgb;
+	vec4 water = texture(texture0, fragTexCoord + offset*0.5);
+	if (water.a == 0.0) water = texture(texture0, fragTexCoord);
+	vec3 tint = water.rgb*mix(1.0, darkest, deep);
+	finalColor = vec4(mix(behind, tint, opacity*f.b), 1.0)*fragColor;
+}
+`
+
+// gridPass picks what drawGrid draws of the front layer
+type gridPass int
+
+const (
+	passAll   gridPass = iota // Everything
+	passDry                   // Everything but the water
+	passWater                 // Only the water
+	passFlow                  // The velocity of the water, see flowColor
+)
+
+type waterShader struct {
+	shader             rl.Shader
+	scene, water, flow rl.RenderTexture2D
+	sceneLoc, flowLoc  int32
+	timeLoc, sizeLoc   int32
+}
+
+// loadWaterShader compiles the shader and makes the textures for a window
+// of w by h pixels, nil if it doesn't compile
+func loadWaterShader(w, h int) *waterShader {
+	shader := rl.LoadShaderFromMemory("", waterFragmentShader)
+	if !rl.IsShaderValid(shader) {
+		log.Printf("water shader: failed to compile, drawing the water plain")
+		return nil
+	}
+	s := &waterShader{
+		shader:   shader,
+		scene:    rl.LoadRenderTexture(int32(w), int32(h)),
+		water:    rl.LoadRenderTexture(int32(w), int32(h)),
+		flow:     rl.LoadRenderTexture(int32(w), int32(h)),
+		sceneLoc: rl.GetShaderLocation(shader, "scene"),
+		flowLoc:  rl.GetShaderLocation(shader, "flow"),
+		timeLoc:  rl.GetShaderLocation(shader, "time"),
+		sizeLoc:  rl.GetShaderLocation(shader, "size"),
+	}
+	rl.SetShaderValue(shader, s.sizeLoc, []float32{float32(w), float32(h)}, rl.ShaderUniformVec2)
+	return s
+}
+
+func (s *waterShader) unload() {
+	if s == nil {
+		return
+	}
+	rl.UnloadRenderTexture(s.scene)
+	rl.UnloadRenderTexture(s.water)
+	rl.UnloadRenderTexture(s.flow)
+	rl.UnloadShader(s.shader)
+}
+
+// drawShadedLayers draws the layers with the front layer's water through
+// the shader. It is called in world pixels and leaves them set.
+func (g *Game) drawShadedLayers() {
+	s := g.waterShader
+	camera := g.camera()
+	rl.EndMode2D()
+
+	render := func(target rl.RenderTexture2D, clear rl.Color, draw func()) {
+		rl.BeginTextureMode(target)
+		rl.ClearBackground(clear)
+		rl.BeginMode2D(camera)
+		draw()
+		rl.EndMode2D()
+		rl.EndTextureMode()
+	}
+	g.pass = passDry
+	render(s.scene, colors.background, func() {
+		g.drawBackground()
+		g.drawLayers()
+	})
+	if focus := g.FocusedLayer(); focus != 0 {
+		g.FocusLayer(0)
+		defer g.FocusLayer(focus)
+	}
+	g.pass = passWater
+	render(s.water, rl.Blank, g.drawGrid)
+	g.pass = passFlow
+	render(s.flow, rl.NewColor(128, 128, 0, 0), g.drawGrid)
+	g.pass = passAll
+
+	// Render textures are upside down
+	src := rl.Rectangle{Width: float32(g.Width), Height: -float32(g.Height)}
+	rl.DrawTextureRec(s.scene.Texture, src, rl.Vector2{}, rl.White)
+	rl.BeginShaderMode(s.shader)
+	rl.SetShaderValueTexture(s.shader, s.sceneLoc, s.scene.Texture)
+	rl.SetShaderValueTexture(s.shader, s.flowLoc, s.flow.Texture)
+	rl.SetShaderValue(s.shader, s.timeLoc, []float32{float32(rl.GetTime())}, rl.ShaderUniformFloat)
+	rl.DrawTextureRec(s.water.Texture, src, rl.Vector2{}, rl.White)
+	rl.EndShaderMode()
+
+	rl.BeginMode2D(camera)
+}
+
+// flowColor is the velocity of a wet cell as a colour: red across, green
+// down, half way for still water. Blue is left for the opacity.
+func flowColor(d *gridfluid.Droplet) rl.Color {
+	channel := func(v float64) uint8 {
+		return uint8(128 + max(min(v, 1), -1)*flowColorScale)
+	}
+	return rl.NewColor(channel(d.VX), channel(d.VY), 0, 255)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+/*
+* Shimmer
+*
+* Still water shouldn't look like a dead block: its brightness rises and
+* falls in slow waves drifting across the grid, and drawn a block per cell
+* its top carries a lighter band, as the smooth surface does its line.
+* Shift+W stills it.
+ */
+
+const (
+	shimmerAmount = 0.08 // Most the brightness changes by
+	shimmerSpeed  = 1.6  // Radians per second
+	surfaceBand   = 2    // Pixels of the lighter band along the top of the water
+	bandHighlight = 0.3  // Brightening of the band
+)
+
+// shimmer is colour c of the water over cell (x, y) brightened or darkened
+// for the moment
+func (g *Game) shimmer(c rl.Color, x, y int) rl.Color {
+	if g.stillWater {
+		return c
+	}
+	t := rl.GetTime() * shimmerSpeed
+	s := math.Sin(t+float64(x)*0.9+float64(y)*0.4) * math.Sin(t*0.7-float64(x)*0.3+float64(y)*1.1)
+	return rl.ColorBrightness(c, float32(s*shimmerAmount))
+}
+
+// drawSurfaceBand draws the band along the top of the water of cell (x, y)
+// in colour c, top pixels down the cell
+func (g *Game) drawSurfaceBand(x, y, top int, c rl.Color) {
+	ts := g.TileSize()
+	if g.stillWater || g.State[y][x].Volume <= 0 || top >= ts {
+		return
+	}
+	rl.DrawRectangle(int32(x*ts), int32(y*ts+top), int32(ts), int32(min(surfaceBand, ts-top)), rl.ColorBrightness(c, bandHighlight))
+}
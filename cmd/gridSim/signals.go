@@ -0,0 +1,77 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Sensors and signals
+ */
+
+// wireAt handles the wiring key on (x, y): a sensor starts a wire, a device
+// finishes the pending one or, with none pending, loses its wires
+func (g *Game) wireAt(x, y int, invert bool) {
+	if !g.InBounds(x, y) {
+		return
+	}
+	d := &g.State[y][x]
+	switch {
+	case d.Sensor != gridfluid.SensorNone:
+		g.wireFrom = &[2]int{x, y}
+	case gridfluid.IsDevice(d) && g.wireFrom != nil:
+		g.Connect(*g.wireFrom, [2]int{x, y}, invert)
+		g.wireFrom = nil
+	case gridfluid.IsDevice(d):
+		g.Unwire([2]int{x, y})
+	default:
+		g.wireFrom = nil
+	}
+}
+
+// drawSignals draws the sensors, the open gates, the springs and the wires
+// between them
+func (g *Game) drawSignals() {
+	ts := float32(g.TileSize())
+	center := func(c [2]int) rl.Vector2 {
+		return rl.Vector2{X: (float32(c[0]) + 0.5) * ts, Y: (float32(c[1]) + 0.5) * ts}
+	}
+	for _, w := range g.Wires() {
+		c := rl.DarkGray
+		if g.InBounds(w.From[0], w.From[1]) && g.State[w.From[1]][w.From[0]].Signal != w.Invert {
+			c = colors.sensor
+		}
+		rl.DrawLineEx(center(w.From), center(w.To), 1, rl.Fade(c, 0.6))
+	}
+	if g.wireFrom != nil {
+		rl.DrawLineEx(center(*g.wireFrom), g.mouseWorld(), 1, rl.Fade(colors.sensor, 0.6))
+	}
+
+	x0, y0, x1, y1 := g.visibleCells()
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			d := &g.State[y][x]
+			px, py := float32(x)*ts, float32(y)*ts
+			c := rl.Fade(colors.sensor, 0.5)
+			if d.Signal {
+				c = colors.sensor
+			}
+			switch d.Sensor {
+			case gridfluid.SensorPlate:
+				rl.DrawRectangleV(rl.Vector2{X: px + ts*0.1, Y: py + ts*0.8}, rl.Vector2{X: ts * 0.8, Y: ts * 0.2}, c)
+			case gridfluid.SensorFloat:
+				rl.DrawCircleV(rl.Vector2{X: px + ts/2, Y: py + ts/2}, ts*0.2, c)
+			}
+			if d.Gate && !d.Material.IsSolid() {
+				rl.DrawRectangleLinesEx(rl.Rectangle{X: px, Y: py, Width: ts, Height: ts}, 1, colors.gate)
+			}
+			if d.Spring {
+				c := rl.Fade(colors.spring, 0.4)
+				if d.SpringOn {
+					c = colors.spring
+				}
+				rl.DrawCircleLinesV(rl.Vector2{X: px + ts/2, Y: py + ts/2}, ts*0.4, c)
+			}
+		}
+	}
+}
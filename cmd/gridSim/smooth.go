@@ -0,0 +1,160 @@
+package main
+
+import rl "github.com/gen2brain/raylib-go/raylib"
+
+/*
+* Smooth water surface
+*
+* The water is drawn as one body rather than a block per cell. Every cell
+* corner holds the mean fill of the open cells around it, and marching
+* squares traces the contour where that crosses surfaceLevel, filling each
+* cell up to it and drawing the contour as a lighter surface line. Solid
+* cells are left out of the means, so the water meets walls flush. Films
+* too thin to reach the contour are drawn as blocks, so no water vanishes
+* from view. Cells drawn large, zoomed in or with -cell-pixels, are traced
+* in smaller steps over the fill interpolated between their corners.
+* Shift+O switches back to blocks.
+ */
+
+const (
+	surfaceLevel     = 0.5 // Corner fill the contour is traced at
+	surfaceLine      = 2   // Width of the surface line in pixels
+	surfaceHighlight = 0.4 // Brightening of the surface line
+
+	surfaceStepPixels = 10 // Window pixels per step a cell the contour crosses is split into
+	maxSurfaceSteps   = 4  // Most steps across a cell
+)
+
+// surfacePoint is where the contour crosses a cell edge, leaving the water
+// when exit is set and entering it otherwise
+type surfacePoint struct {
+	at   rl.Vector2
+	exit bool
+}
+
+// drawSmoothWater draws the water over cell (x, y) up to the contour,
+// returning how far down the cell it starts
+func (g *Game) drawSmoothWater(x, y int, hasWaterAbove bool) int {
+	d := &g.State[y][x]
+	ts := g.TileSize()
+	var fill [4]float64
+	var low, high int
+	for i, c := range cellCorners {
+		fill[i] = g.cornerFill(x+c[0], y+c[1])
+		if fill[i] >= surfaceLevel {
+			high++
+		} else {
+			low++
+		}
+	}
+	if high == 0 {
+		// Too thin to reach the contour
+		return drawWaterBlock(d, x*ts, y*ts, ts, hasWaterAbove, g.waterColor(fluidColor(d), x, y))
+	}
+
+	// A cell the contour crosses is split up when drawn large, the fill in
+	// between its corners interpolated bilinearly, so the contour curves
+	// rather than cutting straight across
+	n := 1
+	if low > 0 {
+		n = max(min(int(float32(ts)*g.zoomLevel()/surfaceStepPixels), maxSurfaceSteps), 1)
+	}
+	c := g.waterColor(g.smoothColor(x, y), x, y)
+	step := float32(ts) / float32(n)
+	top := float32(ts)
+	for sy := range n {
+		for sx := range n {
+			var sub [4]float64
+			var at [4]rl.Vector2
+			for i, k := range cellCorners {
+				u, v := float64(sx+k[0])/float64(n), float64(sy+k[1])/float64(n)
+				sub[i] = fill[0]*(1-u)*(1-v) + fill[3]*u*(1-v) + fill[1]*(1-u)*v + fill[2]*u*v
+				at[i] = rl.Vector2{X: float32(x*ts) + float32(sx+k[0])*step, Y: float32(y*ts) + float32(sy+k[1])*step}
+			}
+			if t, ok := drawContour(at, sub, c); ok {
+				top = min(top, t-float32(y*ts))
+			}
+		}
+	}
+	return int(top)
+}
+
+// Counter-clockwise from the top left, the winding raylib wants
+var cellCorners = [4][2]int{{0, 0}, {0, 1}, {1, 1}, {1, 0}}
+
+// drawContour fills the square with corners at, holding fill, up to the
+// contour and draws the surface line along it, returning the top of the
+// water drawn and whether there was any
+func drawContour(at [4]rl.Vector2, fill [4]float64, c rl.Color) (float32, bool) {
+	var points []rl.Vector2
+	var crossings []surfacePoint
+	for i := range at {
+		j := (i + 1) % len(at)
+		in := fill[i] >= surfaceLevel
+		if in {
+			points = append(points, at[i])
+		}
+		if in != (fill[j] >= surfaceLevel) {
+			p := rl.Vector2Lerp(at[i], at[j], float32((surfaceLevel-fill[i])/(fill[j]-fill[i])))
+			points = append(points, p)
+			crossings = append(crossings, surfacePoint{p, in})
+		}
+	}
+	if len(points) < 3 {
+		return 0, false
+	}
+
+	rl.DrawTriangleFan(points, c)
+	// The contour runs from where it leaves the water to where it next
+	// enters, two crossings of a square cut across the corners once and
+	// four twice
+	for i, p := range crossings {
+		if p.exit {
+			rl.DrawLineEx(p.at, crossings[(i+1)%len(crossings)].at, surfaceLine, rl.ColorBrightness(c, surfaceHighlight))
+		}
+	}
+	top := points[0].Y
+	for _, p := range points {
+		top = min(top, p.Y)
+	}
+	return top, true
+}
+
+// cornerFill is the mean fill of the open cells around the top left corner
+// of cell (x, y), 0 if they are all solid
+func (g *Game) cornerFill(x, y int) float64 {
+	sum, n := 0.0, 0
+	for cy := y - 1; cy <= y; cy++ {
+		for cx := x - 1; cx <= x; cx++ {
+			if cy < 0 || cy >= len(g.State) || cx < 0 || cx >= len(g.State[cy]) {
+				continue
+			}
+			if d := &g.State[cy][cx]; !d.Material.IsSolid() {
+				sum += min(d.Volume, 1)
+				n++
+			}
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+// smoothColor is the colour of the water over cell (x, y): its own, or for
+// an empty cell the contour reaches into, its fullest neighbour's
+func (g *Game) smoothColor(x, y int) rl.Color {
+	best := &g.State[y][x]
+	if best.Volume > 0 {
+		return fluidColor(best)
+	}
+	for _, n := range [4][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+		if n[1] < 0 || n[1] >= len(g.State) || n[0] < 0 || n[0] >= len(g.State[n[1]]) {
+			continue
+		}
+		if d := &g.State[n[1]][n[0]]; !d.Material.IsSolid() && d.Volume > best.Volume {
+			best = d
+		}
+	}
+	return fluidColor(best)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+* Soak mode
+*
+* Soak mode runs the demo scene headless for a long time, checking the
+* invariants every few hundred updates:
+*
+*	mass drift    grid + airborne volume against what was spawned minus what
+*	              left through open edges, was drunk by plants or boiled off
+*	energy spike  kinetic energy jumping well above its running average
+*	bad cells     NaN, negative or overfull volumes
+*
+* Every check lands in timeline.csv. When a metric leaves its band the
+* scene code is written as a snapshot, so rare instabilities can be loaded
+* and replayed later.
+ */
+
+const soakMaxSnapshots = 50
+
+// SoakConfig holds the bands of a soak run
+type SoakConfig struct {
+	Duration    time.Duration
+	CheckEvery  int     // Updates between invariant checks
+	MassBand    float64 // Allowed relative mass drift
+	EnergySpike float64 // Allowed energy relative to its running average
+	Dir         string  // Output directory
+	Seed        uint64  // Seed of the sim, 0 for a random one
+	Workers     int     // Goroutines the update runs on
+	Disable     string  // Features switched off, as -disable takes them
+}
+
+type soakMetrics struct {
+	mass, expected, energy float64
+	badCells               int
+}
+
+// kineticEnergy sums ½mv² over the water cells
+func (g *Game) kineticEnergy() float64 {
+	var total float64
+	for y := range g.State {
+		for x := range g.State[y] {
+			d := &g.State[y][x]
+			if d.Material.IsSolid() || d.Volume <= 0 {
+				continue
+			}
+			total += 0.5 * d.Volume * (d.VX*d.VX + d.VY*d.VY)
+		}
+	}
+	return total
+}
+
+// badCells counts cells holding NaN, negative or overfull volume
+func (g *Game) badCells() int {
+	n := 0
+	for y := range g.State {
+		for x := range g.State[y] {
+			v := g.State[y][x].Volume
+			if math.IsNaN(v) || v < -1e-9 || v > 1+1e-9 {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// RunSoak runs the demo headless until the duration is up and returns the
+// number of anomalies seen
+func RunSoak(cfg SoakConfig) (int, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return 0, err
+	}
+	f, err := os.Create(filepath.Join(cfg.Dir, "timeline.csv"))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	timeline := csv.NewWriter(f)
+	defer timeline.Flush()
+	timeline.Write([]string{"frame", "elapsed", "mass", "expected", "drift", "energy", "bad_cells", "anomalies"})
+
+	game := NewGame(1920, 1080, 20)
+	if cfg.Seed != 0 {
+		game.SetSeed(cfg.Seed)
+	}
+	game.Workers = cfg.Workers
+	if err := game.DisableFeatures(cfg.Disable); err != nil {
+		return 0, err
+	}
+	log.Print(game.FeaturesReport())
+	demo := setupDemo(game)
+	log.Printf("soak: seed %d, running for %s, writing to %s", game.Seed, cfg.Duration, cfg.Dir)
+
+	start := time.Now()
+	initial := game.TotalVolume()
+	spawned := 0.0
+	averageEnergy := -1.0
+	anomalies, snapshots := 0, 0
+	previous := map[string]bool{}
+	for frame := 1; time.Since(start) < cfg.Duration; frame++ {
+		spawned += demo.spawn(game, frame)
+		game.Update()
+		if frame%cfg.CheckEvery != 0 {
+			continue
+		}
+
+		m := soakMetrics{mass: game.TotalVolume(), energy: game.kineticEnergy(), badCells: game.badCells()}
+		m.expected = initial + spawned + game.Supplied
+		for _, out := range game.Outflow {
+			m.expected -= out
+		}
+		m.expected -= game.Absorbed + game.Evaporated + game.Swallowed
+		drift := (m.mass - m.expected) / math.Max(m.expected, 1)
+
+		current := map[string]bool{}
+		if math.Abs(drift) > cfg.MassBand {
+			current["mass-drift"] = true
+		}
+		if averageEnergy > 0 && m.energy > averageEnergy*cfg.EnergySpike {
+			current["energy-spike"] = true
+		}
+		if m.badCells > 0 {
+			current["bad-cells"] = true
+		}
+		if averageEnergy < 0 {
+			averageEnergy = m.energy
+		}
+		averageEnergy = 0.9*averageEnergy + 0.1*m.energy
+
+		var kinds []string
+		for _, kind := range []string{"mass-drift", "energy-spike", "bad-cells"} {
+			if !current[kind] {
+				continue
+			}
+			kinds = append(kinds, kind)
+			// Only snapshot when a metric leaves its band, not while it stays out
+			if previous[kind] {
+				continue
+			}
+			anomalies++
+			if snapshots < soakMaxSnapshots {
+				snapshots++
+				if err := writeSoakSnapshot(cfg.Dir, game, frame, kind); err != nil {
+					return anomalies, err
+				}
+			}
+			log.Printf("soak: frame %d %s (drift %.4f%%, energy %.3f)", frame, kind, drift*100, m.energy)
+		}
+		previous = current
+
+		timeline.Write([]string{
+			strconv.Itoa(frame),
+			time.Since(start).Round(time.Second).String(),
+			strconv.FormatFloat(m.mass, 'f', 4, 64),
+			strconv.FormatFloat(m.expected, 'f', 4, 64),
+			strconv.FormatFloat(drift, 'g', 4, 64),
+			strconv.FormatFloat(m.energy, 'f', 4, 64),
+			strconv.Itoa(m.badCells),
+			strings.Join(kinds, " "),
+		})
+		timeline.Flush()
+	}
+	log.Printf("soak: done after %s, %d anomalies", time.Since(start).Round(time.Second), anomalies)
+	return anomalies, timeline.Error()
+}
+
+// writeSoakSnapshot saves the scene code of an anomalous frame
+func writeSoakSnapshot(dir string, game *Game, frame int, kind string) error {
+	code, err := game.SceneCode()
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(dir, fmt.Sprintf("snapshot_%08d_%s.txt", frame, kind))
+	return os.WriteFile(name, []byte(code+"\n"), 0o644)
+}
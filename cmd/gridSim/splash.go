@@ -0,0 +1,20 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+/*
+* Splashes
+ */
+
+func (g *Game) drawSplashes() {
+	ts := float32(g.TileSize())
+	for _, s := range g.Splashes() {
+		c := dyedColor(colors.fluids[s.Fluid], s.Dye)
+		r := float32(math.Sqrt(s.Volume)) * ts * 0.6
+		rl.DrawCircleV(rl.Vector2{X: float32(s.Pos.X) * ts, Y: float32(s.Pos.Y) * ts}, max(r, 1.5), rl.Fade(c, 0.8))
+	}
+}
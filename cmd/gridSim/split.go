@@ -0,0 +1,108 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+/*
+* Split view
+*
+* Ctrl+2 and Ctrl+3 split the window into two or three panes side by side,
+* all showing the middle of the view at the same zoom: the first as it is
+* drawn otherwise, the second with the pressure heat map and the third with
+* the velocity arrows, so what the water does can be held up against the
+* fields behind it. Ctrl+1 goes back to a single view. The mouse works in
+* whichever pane it is over, and the brush and cursor show in all of them.
+* While the editor or teaching mode is on the window isn't split.
+ */
+
+const maxPanes = 3
+
+// paneOverlays are what the panes after the first show instead of the
+// overlays picked with the keys
+var paneOverlays = []struct {
+	name string
+	set  func(g *Game)
+}{
+	{"pressure", func(g *Game) { g.showPressure, g.showVelocity = true, false }},
+	{"velocity", func(g *Game) { g.showPressure, g.showVelocity = false, true }},
+}
+
+type pane struct {
+	window rl.Rectangle // Window pixels
+	view   rl.Rectangle // World pixels
+}
+
+// selectPanes splits the window with Ctrl and a digit
+func (g *Game) selectPanes() {
+	for n := 1; n <= maxPanes; n++ {
+		if g.keyPressed(rl.KeyZero + int32(n)) {
+			g.panes = n
+		}
+	}
+}
+
+// split reports whether the window is split into panes
+func (g *Game) split() bool {
+	return g.panes > 1 && g.editor == nil && g.teach == nil
+}
+
+// paneLayout is where the panes are in the window and which part of view
+// each shows
+func (g *Game) paneLayout(view rl.Rectangle) []pane {
+	z := g.zoomLevel()
+	w := float32(g.Width) / float32(g.panes)
+	// Every pane shows the middle of the view
+	x := view.X + view.Width/2 - w/z/2
+	panes := make([]pane, g.panes)
+	for i := range panes {
+		panes[i] = pane{
+			window: rl.Rectangle{X: float32(i) * w, Width: w, Height: float32(g.Height)},
+			view:   rl.Rectangle{X: x, Y: view.Y, Width: w / z, Height: view.Height},
+		}
+	}
+	return panes
+}
+
+// paneAt is the pane the window point p is in
+func (g *Game) paneAt(p rl.Vector2) (pane, bool) {
+	if !g.split() {
+		return pane{}, false
+	}
+	panes := g.paneLayout(g.view)
+	for _, pn := range panes {
+		if p.X < pn.window.X+pn.window.Width {
+			return pn, true
+		}
+	}
+	return panes[len(panes)-1], true
+}
+
+// drawPanes draws the world into every pane, each with its own overlays
+func (g *Game) drawPanes(view rl.Rectangle) {
+	flux, pressure, velocity := g.showFlux, g.showPressure, g.showVelocity
+	defer func() {
+		g.showFlux, g.showPressure, g.showVelocity = flux, pressure, velocity
+		g.view, g.viewOffset = view, 0
+	}()
+	for i, pn := range g.paneLayout(view) {
+		if i > 0 {
+			g.showFlux = false
+			paneOverlays[(i-1)%len(paneOverlays)].set(g)
+		}
+		g.view, g.viewOffset = pn.view, pn.window.X
+		rl.BeginScissorMode(int32(pn.window.X), int32(pn.window.Y), int32(pn.window.Width), int32(pn.window.Height))
+		rl.BeginMode2D(g.camera())
+		g.drawWorld()
+		rl.EndMode2D()
+		rl.EndScissorMode()
+	}
+	for i, pn := range g.paneLayout(view) {
+		name := "as set"
+		if i > 0 {
+			name = paneOverlays[(i-1)%len(paneOverlays)].name
+			rl.DrawLineEx(rl.Vector2{X: pn.window.X, Y: 0}, rl.Vector2{X: pn.window.X, Y: pn.window.Height}, 2, rl.Fade(rl.RayWhite, 0.6))
+		}
+		rl.DrawText(name, int32(pn.window.X)+8, int32(pn.window.Height)-20, 10, rl.Fade(rl.RayWhite, 0.8))
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import rl "github.com/gen2brain/raylib-go/raylib"
+
+/*
+* Sponge boundaries
+ */
+
+// drawSponge shades the sponge bands so it's clear where waves are absorbed
+func (g *Game) drawSponge() {
+	if !g.Sponge.Enabled {
+		return
+	}
+	ts := int32(g.TileSize())
+	gw, gh := int32(len(g.State[0])), int32(len(g.State))
+	c := rl.Fade(rl.Lime, 0.08)
+	s := g.Sponge
+	rl.DrawRectangle(0, 0, int32(s.Left)*ts, gh*ts, c)
+	rl.DrawRectangle((gw-int32(s.Right))*ts, 0, int32(s.Right)*ts, gh*ts, c)
+	rl.DrawRectangle(0, 0, gw*ts, int32(s.Top)*ts, c)
+	rl.DrawRectangle(0, (gh-int32(s.Bottom))*ts, gw*ts, int32(s.Bottom)*ts, c)
+}
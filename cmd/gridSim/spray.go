@@ -0,0 +1,99 @@
+package main
+
+import (
+	"math"
+	"math/rand/v2"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Spray
+*
+* Where water lands hard, on the floor, an obstacle or a pool, a few drops
+* of spray fly up and fade away. Unlike the sim's splashes they carry no
+* water and are only drawn, so they are left out of recordings and
+* headless runs. The sim reports the landings with OnImpact.
+ */
+
+const (
+	sprayPerSpeed = 6   // Drops per cell per tick of impact speed
+	sprayMaxDrops = 8   // Most drops from one impact
+	sprayMax      = 600 // Most drops in the air at once
+	sprayLife     = 30  // Updates a drop lasts
+	sprayRise     = 0.6 // Speed a drop flies up at relative to the impact
+	spraySpread   = 0.5 // Speed a drop flies out sideways at relative to the impact
+)
+
+type sprayDrop struct {
+	pos, vel gridfluid.Vector // Cells and cells per tick
+	age      int              // Updates since it flew up
+	color    rl.Color
+}
+
+type spray struct {
+	drops []sprayDrop
+}
+
+// startSpray throws up spray wherever the water lands hard from now on
+func (g *Game) startSpray() {
+	g.spray = &spray{}
+	g.OnImpact(g.throwSpray)
+}
+
+// throwSpray throws up drops from the water landing in (x, y)
+func (g *Game) throwSpray(x, y int, speed float64) {
+	s := g.spray
+	d := &g.State[y][x]
+	c := dyedColor(colors.fluids[d.Fluid], d.Dye)
+	gx, gy := g.Gravity.Vector()
+	for range min(int(speed*sprayPerSpeed), sprayMaxDrops, sprayMax-len(s.drops)) {
+		up := speed * sprayRise * (0.5 + rand.Float64())
+		out := speed * spraySpread * (rand.Float64()*2 - 1)
+		s.drops = append(s.drops, sprayDrop{
+			pos:   gridfluid.Vector{X: float64(x) + rand.Float64() - gx*0.5, Y: float64(y) + rand.Float64() - gy*0.5},
+			vel:   gridfluid.Vector{X: -gx*up - gy*out, Y: -gy*up + gx*out},
+			color: c,
+		})
+	}
+}
+
+// updateSpray moves the drops, dropping the ones that hit something or
+// faded out
+func (g *Game) updateSpray() {
+	if g.spray == nil {
+		return
+	}
+	gx, gy := g.Gravity.Vector()
+	alive := g.spray.drops[:0]
+	for _, d := range g.spray.drops {
+		d.vel.X += gx * g.Params.GravityAccel
+		d.vel.Y += gy * g.Params.GravityAccel
+		d.pos.X += d.vel.X
+		d.pos.Y += d.vel.Y
+		d.age++
+		x, y := int(math.Floor(d.pos.X)), int(math.Floor(d.pos.Y))
+		if d.age >= sprayLife || !g.InBounds(x, y) {
+			continue
+		}
+		if c := &g.State[y][x]; c.Material.IsSolid() || c.Volume > 0.5 {
+			continue
+		}
+		alive = append(alive, d)
+	}
+	g.spray.drops = alive
+}
+
+// drawSpray draws the drops, fading as they age
+func (g *Game) drawSpray() {
+	if g.spray == nil {
+		return
+	}
+	ts := float32(g.TileSize())
+	r := max(ts/8, 1)
+	for _, d := range g.spray.drops {
+		fade := 1 - float32(d.age)/sprayLife
+		rl.DrawCircleV(rl.Vector2{X: float32(d.pos.X) * ts, Y: float32(d.pos.Y) * ts}, r, rl.Fade(d.color, fade*0.8))
+	}
+}
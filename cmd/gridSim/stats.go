@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+/*
+* Statistics
+*
+* F1 shows the readings of gridfluid.Stats in the top left corner, along
+* with the frame rate and the updates run and water the generators put in
+* per second, and -stats writes the readings to a file as one JSON object
+* per line every statsEvery updates, in the window and with -headless
+* alike.
+ */
+
+const (
+	statsEvery = 60 // Updates between lines of the -stats log
+	rateWindow = 1  // Seconds the rates per second are counted over
+)
+
+// rateCounter counts the updates and the water spawned over windows of
+// rateWindow seconds
+type rateCounter struct {
+	steps, spawned float64 // In the window so far
+	elapsed        float64 // Seconds of the window so far
+
+	stepRate, spawnRate float64 // Per second over the last whole window
+}
+
+// add counts the updates run and the water spawned in a frame dt seconds
+// long
+func (r *rateCounter) add(steps int, spawned float64, dt float32) {
+	r.steps += float64(steps)
+	r.spawned += spawned
+	r.elapsed += float64(dt)
+	if r.elapsed >= rateWindow {
+		r.stepRate, r.spawnRate = r.steps/r.elapsed, r.spawned/r.elapsed
+		r.steps, r.spawned, r.elapsed = 0, 0, 0
+	}
+}
+
+// logStats writes a line to the -stats log when one is due
+func (g *Game) logStats() {
+	if g.statsLog == nil || g.Frame()%statsEvery != 0 {
+		return
+	}
+	if err := g.statsLog.Encode(g.Stats()); err != nil {
+		log.Printf("-stats: %v", err)
+		g.statsLog = nil
+	}
+}
+
+// drawStats shows the readings while F1 has them on
+func (g *Game) drawStats() {
+	if !g.showStats {
+		return
+	}
+	s := g.Stats()
+	f := s.Flow
+	lines := []string{
+		fmt.Sprintf("fps %d, updates %.0f/s", rl.GetFPS(), g.rates.stepRate),
+		fmt.Sprintf("frame %d  volume %.2f", s.Frame, s.Volume),
+		fmt.Sprintf("wet cells %d, active %d", s.WetCells, s.ActiveCells),
+		fmt.Sprintf("wettest column %d, %.2f cells", s.WettestColumn, s.ColumnHeight),
+		fmt.Sprintf("max pressure %.3f", s.MaxPressure),
+		fmt.Sprintf("flow in %.4f, out %.4f, net %+.4f", f.Supplied, f.Outflow+f.Absorbed+f.Evaporated+f.Swallowed, f.Net()),
+		fmt.Sprintf("generators %.2f/s", g.rates.spawnRate),
+	}
+	rl.DrawRectangle(6, 22, 230, int32(len(lines))*14+6, rl.Fade(rl.Black, 0.6))
+	for i, line := range lines {
+		rl.DrawText(line, 10, 26+int32(i)*14, 10, rl.RayWhite)
+	}
+}
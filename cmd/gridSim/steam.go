@@ -0,0 +1,20 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Steam
+ */
+
+// drawSteam draws the puffs growing and fading as they rise
+func (g *Game) drawSteam() {
+	ts := float32(g.TileSize())
+	for _, s := range g.Steam() {
+		t := float32(s.Life) / gridfluid.SteamLife
+		center := rl.Vector2{X: float32(s.Pos.X) * ts, Y: float32(s.Pos.Y) * ts}
+		rl.DrawCircleV(center, ts*(0.3+0.5*(1-t)), rl.Fade(colors.steam, 0.4*t))
+	}
+}
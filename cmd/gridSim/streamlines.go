@@ -0,0 +1,162 @@
+package main
+
+import (
+	"math"
+	"math/rand/v2"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Streamlines
+*
+* Ctrl+F scatters tracers over the water in view and carries them along
+* the velocity field every frame, each drawing a fading trail of where it
+* has been. Where the water circles the trails curl round, and in dead
+* zones they hardly move and stay dots. Tracers are carried as if the sim
+* ran at streamTickRate updates a second, so they keep showing the flow,
+* frozen, while it is paused. A tracer that reaches dry ground, a wall or
+* the end of its life starts again somewhere else in view. Like spray they
+* are only drawn, and left out of recordings.
+ */
+
+const (
+	streamTracers  = 300 // Tracers in view at once
+	streamTrail    = 24  // Points in a trail
+	streamLife     = 240 // Frames a tracer lasts at the most
+	streamTickRate = 60  // Sim updates a second the tracers move as if at
+	streamTries    = 8   // Random cells tried for a wet one to start a tracer in
+)
+
+type tracer struct {
+	pos   gridfluid.Vector   // Cells
+	trail []gridfluid.Vector // Earlier positions, oldest first
+	age   int                // Frames since it started
+}
+
+type streamlines struct {
+	tracers []tracer
+}
+
+// updateStreamlines carries the tracers along the flow for a frame
+func (g *Game) updateStreamlines() {
+	if !g.showStreamlines {
+		g.streams = nil
+		return
+	}
+	if g.streams == nil {
+		g.streams = &streamlines{}
+	}
+	s := g.streams
+	ticks := float64(g.frameTime()) * streamTickRate
+	alive := s.tracers[:0]
+	for _, t := range s.tracers {
+		// Midpoint steps keep tracers on the curve round an eddy
+		v, ok := g.flowAt(t.pos)
+		if ok {
+			mid := gridfluid.Vector{X: t.pos.X + v.X*ticks/2, Y: t.pos.Y + v.Y*ticks/2}
+			v, ok = g.flowAt(mid)
+		}
+		t.age++
+		if !ok || t.age >= streamLife || !g.inView(t.pos) {
+			continue
+		}
+		t.trail = append(t.trail, t.pos)
+		if len(t.trail) > streamTrail {
+			t.trail = t.trail[1:]
+		}
+		t.pos.X += v.X * ticks
+		t.pos.Y += v.Y * ticks
+		alive = append(alive, t)
+	}
+	s.tracers = alive
+	for len(s.tracers) < streamTracers {
+		pos, ok := g.seedTracer()
+		if !ok {
+			break
+		}
+		// Staggered ages keep the tracers from all starting over together
+		s.tracers = append(s.tracers, tracer{pos: pos, age: rand.IntN(streamLife / 2)})
+	}
+}
+
+// seedTracer picks a random spot in a wet cell in view
+func (g *Game) seedTracer() (gridfluid.Vector, bool) {
+	x0, y0, x1, y1 := g.visibleCells()
+	if x1 <= x0 || y1 <= y0 {
+		return gridfluid.Vector{}, false
+	}
+	for range streamTries {
+		x, y := x0+rand.IntN(x1-x0), y0+rand.IntN(y1-y0)
+		if d := &g.State[y][x]; !d.Material.IsSolid() && d.Volume > gridfluid.WetThreshold {
+			return gridfluid.Vector{X: float64(x) + rand.Float64(), Y: float64(y) + rand.Float64()}, true
+		}
+	}
+	return gridfluid.Vector{}, false
+}
+
+// inView reports whether pos, in cells, is inside the view
+func (g *Game) inView(pos gridfluid.Vector) bool {
+	ts := float64(g.TileSize())
+	return rl.CheckCollisionPointRec(rl.Vector2{X: float32(pos.X * ts), Y: float32(pos.Y * ts)}, g.view)
+}
+
+// flowAt is the velocity of the water at pos in cells, blended from the
+// wet cells around it, and whether there is any
+func (g *Game) flowAt(pos gridfluid.Vector) (gridfluid.Vector, bool) {
+	cx, cy := int(math.Floor(pos.X)), int(math.Floor(pos.Y))
+	if !g.InBounds(cx, cy) {
+		return gridfluid.Vector{}, false
+	}
+	if d := &g.State[cy][cx]; d.Material.IsSolid() || d.Volume <= gridfluid.WetThreshold {
+		return gridfluid.Vector{}, false
+	}
+	// Velocities belong to the cell centres
+	fx, fy := pos.X-0.5, pos.Y-0.5
+	x0, y0 := int(math.Floor(fx)), int(math.Floor(fy))
+	var v gridfluid.Vector
+	weight := 0.0
+	for dy := range 2 {
+		for dx := range 2 {
+			x, y := x0+dx, y0+dy
+			if !g.InBounds(x, y) {
+				continue
+			}
+			d := &g.State[y][x]
+			if d.Material.IsSolid() || d.Volume <= gridfluid.WetThreshold {
+				continue
+			}
+			w := (1 - math.Abs(fx-float64(x))) * (1 - math.Abs(fy-float64(y)))
+			v.X += d.VX * w
+			v.Y += d.VY * w
+			weight += w
+		}
+	}
+	// The cell pos is in is one of the four, so weight is at least 1/4
+	return gridfluid.Vector{X: v.X / weight, Y: v.Y / weight}, true
+}
+
+// drawStreamlines draws the tracers' trails, fading towards their tails
+// and as they start and end
+func (g *Game) drawStreamlines() {
+	if g.streams == nil {
+		return
+	}
+	ts := float32(g.TileSize())
+	width := 1.5 / g.zoomLevel()
+	at := func(p gridfluid.Vector) rl.Vector2 {
+		return rl.Vector2{X: float32(p.X) * ts, Y: float32(p.Y) * ts}
+	}
+	for _, t := range g.streams.tracers {
+		life := min(float32(len(t.trail)+1)/streamTrail, float32(streamLife-t.age)/streamTrail, 1)
+		prev := at(t.pos)
+		rl.DrawCircleV(prev, width, rl.Fade(colors.foam, 0.9*life))
+		for i := len(t.trail) - 1; i >= 0; i-- {
+			p := at(t.trail[i])
+			fade := float32(i+1) / float32(len(t.trail)+1)
+			rl.DrawLineEx(prev, p, width, rl.Fade(colors.foam, 0.8*fade*life))
+			prev = p
+		}
+	}
+}
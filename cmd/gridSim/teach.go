@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Teaching mode
+ */
+
+type teachMode struct {
+	view   [][]gridfluid.Droplet // State shown on screen, advanced step by step
+	steps  []gridfluid.TraceStep
+	index  int // Next step to apply
+	speed  int // Frames per step
+	frames int
+}
+
+// StartTeachStep runs one traced update and begins its slow motion playback
+func (g *Game) StartTeachStep() {
+	view := gridfluid.CopyState(g.State)
+	speed := 10
+	if g.teach != nil {
+		speed = g.teach.speed
+	}
+	steps := g.TraceUpdate()
+	g.recordReportFrame()
+	g.teach = &teachMode{view: view, steps: steps, speed: speed}
+}
+
+// ToggleTeaching enters or leaves teaching mode
+func (g *Game) ToggleTeaching() {
+	if g.teach != nil {
+		g.teach = nil
+		return
+	}
+	if !g.FeatureEnabled("teach") {
+		return
+	}
+	g.StartTeachStep()
+}
+
+// advanceTeach applies the next recorded step to the view
+func (t *teachMode) advance() {
+	if t.index >= len(t.steps) {
+		return
+	}
+	for _, c := range t.steps[t.index].Changes {
+		t.view[c.Y][c.X].Volume += c.Volume
+	}
+	t.index++
+}
+
+// UpdateTeaching plays back the traced update; once it's done the next
+// update is traced
+func (g *Game) UpdateTeaching() {
+	t := g.teach
+	t.frames++
+	if t.frames < t.speed {
+		return
+	}
+	t.frames = 0
+	if t.index >= len(t.steps) {
+		g.StartTeachStep()
+		return
+	}
+	t.advance()
+}
+
+// drawTeachingHeader explains the step just applied along the top of the
+// window
+func (g *Game) drawTeachingHeader() {
+	t := g.teach
+	header := fmt.Sprintf("TEACHING  step %d/%d  (%d frames/step, Up/Down speed, Right skip)", t.index, len(t.steps), t.speed)
+	rl.DrawRectangle(0, 0, int32(g.Width), 44, rl.Fade(rl.Black, 0.75))
+	rl.DrawText(header, 10, 6, 10, rl.Yellow)
+	if t.index == 0 {
+		rl.DrawText("state before the update", 10, 24, 10, rl.RayWhite)
+		return
+	}
+	rl.DrawText(strings.Join(t.steps[t.index-1].Rules, ", "), 10, 24, 10, rl.RayWhite)
+}
+
+// drawTeaching highlights the cells the step just applied changed
+func (g *Game) drawTeaching() {
+	t := g.teach
+	ts := int32(g.TileSize())
+	if t.index == 0 {
+		return
+	}
+	step := t.steps[t.index-1]
+	if step.X < 0 {
+		// Whole-grid pass: outline every changed cell
+		for _, c := range step.Changes {
+			col := rl.Green
+			if c.Volume < 0 {
+				col = rl.Red
+			}
+			rl.DrawRectangleLines(int32(c.X)*ts, int32(c.Y)*ts, ts, ts, rl.Fade(col, 0.6))
+		}
+		return
+	}
+
+	rl.DrawRectangleLinesEx(rl.Rectangle{X: float32(int32(step.X) * ts), Y: float32(int32(step.Y) * ts), Width: float32(ts), Height: float32(ts)}, 2, rl.Yellow)
+	from := rl.Vector2{X: float32(step.X)*float32(ts) + float32(ts)/2, Y: float32(step.Y)*float32(ts) + float32(ts)/2}
+	for _, c := range step.Changes {
+		if c.Volume <= 0 || (c.X == step.X && c.Y == step.Y) {
+			continue
+		}
+		to := rl.Vector2{X: float32(c.X)*float32(ts) + float32(ts)/2, Y: float32(c.Y)*float32(ts) + float32(ts)/2}
+		rl.DrawLineEx(from, to, 2, rl.Orange)
+		rl.DrawCircleV(to, 3, rl.Orange)
+		rl.DrawText(fmt.Sprintf("%.3f", c.Volume), int32(to.X)+4, int32(to.Y)-12, 10, rl.Orange)
+	}
+}
+
+// handleTeachInput adjusts playback while teaching
+func (g *Game) handleTeachInput() {
+	t := g.teach
+	if g.keyPressed(rl.KeyUp) {
+		t.speed = max(1, t.speed/2)
+	}
+	if g.keyPressed(rl.KeyDown) {
+		t.speed = min(120, t.speed*2)
+	}
+	if g.keyPressed(rl.KeyRight) {
+		for t.index < len(t.steps) {
+			t.advance()
+		}
+	}
+}
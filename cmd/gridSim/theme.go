@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"strings"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Themes
+*
+* Every colour the scene is drawn with comes from the palette in colors,
+* set by the theme at the start of every frame: classic, thermal,
+* monochrome or retro-green, picked with -theme or cycled with Shift+C.
+* The themes past classic pass the classic colours through a tone curve of
+* their brightness, so what stands out in one stands out in all of them. A
+* scenario can set its own water, obstacle and background colours on top,
+* see scenario.go.
+*
+* The readouts and the tools' outlines keep raylib's named colours in every
+* theme, as do the heat maps, whose ramps are scales to read values off.
+ */
+
+// palette holds the colours of the scene
+type palette struct {
+	tone     func(rl.Color) rl.Color // The theme's tone curve, nil for none
+	obstacle rl.Color                // Stone, its own colour toned when zero
+
+	background rl.Color
+	fluids     []rl.Color // By fluid
+
+	// Obstacles and devices
+	eroded, wetObstacle                        rl.Color
+	mover, pipe, pipeRim, connector, wheel     rl.Color
+	fan, heater, vent, vortex, sensor          rl.Color
+	gate, spring, crate, crateEdge             rl.Color
+	char, flameInner, flameOuter, wither, moss rl.Color
+	ice, snow, salt                            rl.Color
+
+	// What the water carries and leaves behind
+	foam, foamLine, bubble, steam, brine, murk rl.Color
+}
+
+// classicPalette is the colours the scene was first drawn with
+func classicPalette() palette {
+	return palette{
+		background: rl.Black,
+		fluids: []rl.Color{
+			gridfluid.FluidWater: rl.NewColor(0, 0, 255, 255),
+			gridfluid.FluidOil:   rl.NewColor(200, 150, 30, 255),
+			gridfluid.FluidAcid:  rl.NewColor(120, 230, 40, 255),
+		},
+
+		eroded:      rl.NewColor(90, 110, 60, 255),
+		wetObstacle: rl.NewColor(62, 48, 34, 255),
+		mover:       rl.NewColor(130, 130, 140, 255),
+		pipe:        rl.NewColor(90, 95, 105, 255),
+		pipeRim:     rl.NewColor(150, 155, 165, 255),
+		connector:   rl.NewColor(150, 120, 220, 255),
+		wheel:       rl.NewColor(140, 95, 50, 255),
+		fan:         rl.NewColor(170, 175, 185, 255),
+		heater:      rl.NewColor(200, 70, 30, 255),
+		vent:        rl.NewColor(80, 85, 95, 255),
+		vortex:      rl.NewColor(20, 40, 90, 255),
+		sensor:      rl.NewColor(220, 200, 60, 255),
+		gate:        rl.NewColor(90, 90, 100, 255),
+		spring:      rl.NewColor(60, 200, 230, 255),
+		crate:       rl.NewColor(190, 130, 60, 255),
+		crateEdge:   rl.NewColor(110, 70, 30, 255),
+		char:        rl.NewColor(35, 30, 28, 255),
+		flameInner:  rl.NewColor(255, 220, 90, 255),
+		flameOuter:  rl.NewColor(240, 90, 20, 255),
+		wither:      rl.NewColor(120, 95, 40, 255),
+		moss:        rl.NewColor(70, 125, 45, 255),
+		ice:         rl.NewColor(200, 230, 250, 255),
+		snow:        rl.NewColor(245, 248, 255, 255),
+		salt:        rl.NewColor(235, 235, 225, 255),
+
+		foam:     rl.NewColor(245, 250, 255, 255),
+		foamLine: rl.NewColor(220, 235, 255, 255),
+		bubble:   rl.NewColor(220, 240, 255, 200),
+		steam:    rl.NewColor(225, 230, 235, 255),
+		brine:    rl.NewColor(190, 235, 240, 255),
+		murk:     rl.NewColor(70, 85, 40, 255),
+	}
+}
+
+// all lists the palette's colours, for the tone curve to go over
+func (p *palette) all() []*rl.Color {
+	list := []*rl.Color{
+		&p.background,
+		&p.eroded, &p.wetObstacle,
+		&p.mover, &p.pipe, &p.pipeRim, &p.connector, &p.wheel,
+		&p.fan, &p.heater, &p.vent, &p.vortex, &p.sensor,
+		&p.gate, &p.spring, &p.crate, &p.crateEdge,
+		&p.char, &p.flameInner, &p.flameOuter, &p.wither, &p.moss,
+		&p.ice, &p.snow, &p.salt,
+		&p.foam, &p.foamLine, &p.bubble, &p.steam, &p.brine, &p.murk,
+	}
+	for i := range p.fluids {
+		list = append(list, &p.fluids[i])
+	}
+	return list
+}
+
+// toned is c through the theme's tone curve, for colours that aren't in
+// the palette like dyes
+func (p *palette) toned(c rl.Color) rl.Color {
+	if p.tone == nil {
+		return c
+	}
+	return p.tone(c)
+}
+
+// material is the colour of a material
+func (p *palette) material(id gridfluid.MaterialID) rl.Color {
+	if id == gridfluid.MaterialStone && p.obstacle.A != 0 {
+		return p.obstacle
+	}
+	return p.toned(id.Color())
+}
+
+type theme struct {
+	name string
+	tone func(rl.Color) rl.Color // Nil keeps the classic colours
+}
+
+var themes = []theme{
+	{name: "classic"},
+	{name: "thermal", tone: rampTone([]color.RGBA{{0, 0, 0, 255}, {60, 10, 120, 255}, {200, 30, 90, 255}, {250, 140, 20, 255}, {255, 240, 120, 255}, {255, 255, 255, 255}})},
+	{name: "monochrome", tone: rampTone([]color.RGBA{{0, 0, 0, 255}, {255, 255, 255, 255}})},
+	{name: "retro-green", tone: rampTone([]color.RGBA{{0, 0, 0, 255}, {20, 120, 30, 255}, {120, 255, 110, 255}})},
+}
+
+// colors is the palette of the frame being drawn
+var colors = themes[0].palette()
+
+// rampTone is a tone curve putting colours along stops by their
+// brightness, the brightest of their channels, keeping their alpha
+func rampTone(stops []color.RGBA) func(rl.Color) rl.Color {
+	return func(c rl.Color) rl.Color {
+		t := rampColor(stops, float64(max(c.R, c.G, c.B))/255)
+		t.A = c.A
+		return t
+	}
+}
+
+// palette is the theme's colours
+func (t theme) palette() palette {
+	p := classicPalette()
+	if t.tone != nil {
+		for _, c := range p.all() {
+			*c = t.tone(*c)
+		}
+		p.tone = t.tone
+	}
+	return p
+}
+
+// lookupTheme finds a theme by name
+func lookupTheme(name string) (int, error) {
+	var names []string
+	for i, t := range themes {
+		if t.name == name {
+			return i, nil
+		}
+		names = append(names, t.name)
+	}
+	return 0, fmt.Errorf("unknown theme %q, want one of %s", name, strings.Join(names, ", "))
+}
+
+// palette is the colours to draw the scene with: the theme's, with the
+// scenario's own on top
+func (g *Game) palette() palette {
+	p := themes[g.theme].palette()
+	s, ok := g.level.(*scenario)
+	if !ok || s.Colors == nil {
+		return p
+	}
+	// Checked when the scenario was loaded
+	if c, err := parseColor(s.Colors.Water); err == nil {
+		p.fluids[gridfluid.FluidWater] = c
+	}
+	if c, err := parseColor(s.Colors.Obstacle); err == nil {
+		p.obstacle = c
+	}
+	if c, err := parseColor(s.Colors.Background); err == nil {
+		p.background = c
+	}
+	return p
+}
+
+// cycleTheme switches to the next theme
+func (g *Game) cycleTheme() {
+	g.theme = (g.theme + 1) % len(themes)
+	log.Printf("theme %s", themes[g.theme].name)
+}
+
+// parseColor reads a colour written as #rrggbb
+func parseColor(s string) (rl.Color, error) {
+	var r, g, b uint8
+	if _, err := fmt.Sscanf(s, "#%02x%02x%02x", &r, &g, &b); err != nil || len(s) != 7 {
+		return rl.Color{}, fmt.Errorf("want a colour as #rrggbb, got %q", s)
+	}
+	return rl.NewColor(r, g, b, 255), nil
+}
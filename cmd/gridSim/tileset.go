@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Tilesets
+*
+* A scenario can draw its obstacles with tiles from an image rather than as
+* flat blocks:
+*
+*	"tileset": {"image": "rock.png", "tileSize": 16, "materials": {"stone": 0, "wood": 1}}
+*
+* The image is found next to the scenario file and holds one row of 16
+* tiles for each material listed, the row given by its number. Tile i of a
+* row is for a cell joined to cells of the same material on the sides in i:
+* 1 above, 2 right, 4 below and 8 left, so tile 0 stands alone and tile 15
+* is buried. The world's edges count as joined. Tiles are drawn darkened
+* where wet and keep their own colours whatever the theme. Cells that are
+* more than their material, like ice, ramps or heaters, and materials with
+* no row are drawn as before.
+ */
+
+type scenarioTileset struct {
+	Image     string         `json:"image"`     // PNG, relative to the scenario file
+	TileSize  int            `json:"tileSize"`  // Pixels per tile in the image
+	Materials map[string]int `json:"materials"` // Material to the row of its tiles
+}
+
+// check reports what is wrong with the tileset, if anything
+func (t *scenarioTileset) check() error {
+	if t.Image == "" || t.TileSize <= 0 {
+		return fmt.Errorf("needs an image and a positive tileSize")
+	}
+	for name, row := range t.Materials {
+		id, ok := gridfluid.LookupMaterial(name)
+		if !ok || !id.IsSolid() {
+			return fmt.Errorf("%q is not a solid material", name)
+		}
+		if row < 0 {
+			return fmt.Errorf("%s: negative row", name)
+		}
+	}
+	return nil
+}
+
+// tileset is a scenario's tileset loaded into a texture
+type tileset struct {
+	spec    *scenarioTileset // What it was loaded from
+	texture rl.Texture2D     // Zero if it failed to load
+	rows    map[gridfluid.MaterialID]int
+}
+
+// loadTileset loads the tileset of a scenario whose file is in dir,
+// reporting and remembering a failure rather than trying every frame
+func loadTileset(spec *scenarioTileset, dir string) *tileset {
+	t := &tileset{spec: spec, rows: map[gridfluid.MaterialID]int{}}
+	for name, row := range spec.Materials {
+		id, _ := gridfluid.LookupMaterial(name)
+		t.rows[id] = row
+	}
+	path := spec.Image
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		log.Printf("tileset: %v, drawing obstacles plain", err)
+		return t
+	}
+	if tex := rl.LoadTexture(path); rl.IsTextureValid(tex) {
+		t.texture = tex
+	} else {
+		log.Printf("tileset: can't load %s, drawing obstacles plain", path)
+	}
+	return t
+}
+
+func (t *tileset) unload() {
+	if t != nil && t.texture.ID != 0 {
+		rl.UnloadTexture(t.texture)
+	}
+}
+
+// tileset is the loaded tileset of the scene, nil if it has none. The
+// texture is loaded the first time it's drawn, once there is a window.
+func (g *Game) tileset() *tileset {
+	s, ok := g.level.(*scenario)
+	if !ok || s.Tileset == nil {
+		return nil
+	}
+	if g.tiles == nil || g.tiles.spec != s.Tileset {
+		g.tiles.unload()
+		g.tiles = loadTileset(s.Tileset, s.dir)
+	}
+	if g.tiles.texture.ID == 0 {
+		return nil
+	}
+	return g.tiles
+}
+
+// tiled reports whether the cell is drawn as a tile: a plain obstacle of a
+// material with a row in the tileset
+func (t *tileset) tiled(d *gridfluid.Droplet) bool {
+	if _, ok := t.rows[d.Material]; !ok {
+		return false
+	}
+	return d.Material.IsSolid() && d.Pipe == gridfluid.PipeNone && d.Ramp == gridfluid.RampNone &&
+		!d.Moving && !d.Snow && !d.Ice && !d.Salt && !d.Heater && !d.Gate && !d.Vent
+}
+
+// drawTile draws the tile of the obstacle at (x, y) over its cell
+func (g *Game) drawTile(t *tileset, x, y int) {
+	d := &g.State[y][x]
+	joined := func(x, y int) bool {
+		return !g.InBounds(x, y) || g.State[y][x].Material == d.Material
+	}
+	mask := 0
+	for i, n := range [4][2]int{{0, -1}, {1, 0}, {0, 1}, {-1, 0}} {
+		if joined(x+n[0], y+n[1]) {
+			mask |= 1 << i
+		}
+	}
+	size := float32(t.spec.TileSize)
+	src := rl.Rectangle{X: float32(mask) * size, Y: float32(t.rows[d.Material]) * size, Width: size, Height: size}
+	ts := float32(g.TileSize())
+	dst := rl.Rectangle{X: float32(x) * ts, Y: float32(y) * ts, Width: ts, Height: ts}
+	tint := rl.ColorLerp(rl.White, colors.wetObstacle, float32(d.Wetness))
+	rl.DrawTexturePro(t.texture, src, dst, rl.Vector2{}, 0, tint)
+}
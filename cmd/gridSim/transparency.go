@@ -0,0 +1,45 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Transparency
+*
+* Shallow water is see-through: the background, the layers behind and the
+* materials it soaks show through thin films and puddles, and the water
+* turns opaque opaqueDepth cells below its surface, the depth summed from
+* the fill of the wet cells above in the column. The shaded water of the
+* front layer takes its opacity from the flow texture instead, see
+* shader.go, so it is drawn opaque into its own.
+ */
+
+const (
+	opaqueDepth = 3.0  // Cells of water it takes to hide what is behind
+	minAlpha    = 0.35 // Opacity of the thinnest film
+)
+
+// depthAlpha is how opaque the water over cell (x, y) is
+func (g *Game) depthAlpha(x, y int) float64 {
+	depth := 0.0
+	for cy := y; cy >= 0 && depth < opaqueDepth; cy-- {
+		d := &g.State[cy][x]
+		if d.Material.IsSolid() || d.Volume <= gridfluid.WetThreshold {
+			break
+		}
+		depth += min(d.Volume, 1)
+	}
+	return minAlpha + (1-minAlpha)*min(depth/opaqueDepth, 1)
+}
+
+// waterColor is colour c of the water over cell (x, y) as drawn:
+// shimmering, and as see-through as the water there is shallow
+func (g *Game) waterColor(c rl.Color, x, y int) rl.Color {
+	c = g.shimmer(c, x, y)
+	if g.pass != passWater {
+		c.A = uint8(255 * g.depthAlpha(x, y))
+	}
+	return c
+}
@@ -0,0 +1,30 @@
+package main
+
+import "watersim/pkg/gridfluid"
+
+/*
+* Undo
+*
+* The tools that build a scene go through editAtMouse, so Ctrl+Z takes
+* their edits back and Ctrl+Y makes them again. Edits undone in the editor,
+* where the sim is paused, come back exactly as they were.
+ */
+
+// editAtMouse runs tool on the cell under the mouse as one undoable edit
+func (g *Game) editAtMouse(tool func(x, y int)) {
+	x, y := g.cellAtMouse()
+	g.Edit(x, y, func() { tool(x, y) })
+}
+
+// undoEdit takes the last edit back, or makes the last undone edit again
+func (g *Game) undoEdit(redo bool) {
+	if redo {
+		g.Redo()
+	} else {
+		g.Undo()
+	}
+	// A wire in progress may start at a sensor that is gone now
+	if f := g.wireFrom; f != nil && (!g.InBounds(f[0], f[1]) || g.State[f[1]][f[0]].Sensor == gridfluid.SensorNone) {
+		g.wireFrom = nil
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+/*
+* Velocity arrows
+*
+* Shift+F draws arrows over the water showing which way it moves. An arrow
+* is the speed in cells per tick times the spacing of the arrows long, up
+* to one spacing. Arrows are kept at least arrowSpacing window pixels
+* apart, so on a dense grid each stands for a square block of cells and
+* shows the mean velocity of the water in it.
+ */
+
+const (
+	arrowSpacing = 12   // Window pixels between arrows at the least
+	arrowMin     = 0.01 // Speed in cells per tick below which no arrow is drawn
+	arrowHead    = 0.3  // Length of the head as a share of the arrow, up to arrowSpacing/2
+)
+
+// drawVelocity draws the velocity arrows inside the view
+func (g *Game) drawVelocity() {
+	if !g.showVelocity {
+		return
+	}
+	ts := float64(g.TileSize())
+	step := max(int(math.Ceil(arrowSpacing/(ts*float64(g.zoomLevel())))), 1)
+	x0, y0, x1, y1 := g.visibleCells()
+	// Blocks line up with the grid, so they stay put as the view scrolls
+	x0, y0 = x0-x0%step, y0-y0%step
+	for by := y0; by < y1; by += step {
+		for bx := x0; bx < x1; bx += step {
+			vx, vy, n := 0.0, 0.0, 0
+			for y := by; y < min(by+step, len(g.State)); y++ {
+				for x := bx; x < min(bx+step, len(g.State[y])); x++ {
+					if d := &g.State[y][x]; d.Volume > 0 && !d.Material.IsSolid() {
+						vx, vy, n = vx+d.VX, vy+d.VY, n+1
+					}
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			vx, vy = vx/float64(n), vy/float64(n)
+			speed := math.Hypot(vx, vy)
+			if speed < arrowMin {
+				continue
+			}
+			size := float64(step) * ts
+			length := min(speed, 1) * size
+			from := rl.Vector2{X: float32((float64(bx) + float64(step)/2) * ts), Y: float32((float64(by) + float64(step)/2) * ts)}
+			dx, dy := vx/speed, vy/speed
+			to := rl.Vector2{X: from.X + float32(dx*length), Y: from.Y + float32(dy*length)}
+			head := min(length*arrowHead, size/2)
+			// The head's sides swing back 30 degrees either side of the shaft
+			c := rl.Fade(rl.RayWhite, 0.8)
+			rl.DrawLineV(from, to, c)
+			for _, side := range []float64{-1, 1} {
+				ax := dx*math.Cos(math.Pi/6) - side*dy*math.Sin(math.Pi/6)
+				ay := dy*math.Cos(math.Pi/6) + side*dx*math.Sin(math.Pi/6)
+				rl.DrawLineV(to, rl.Vector2{X: to.X - float32(ax*head), Y: to.Y - float32(ay*head)}, c)
+			}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Viewport
+*
+* The grid doesn't have to fit the window: NewWorld builds one of any
+* size, and the window shows a view rectangle of it, in world pixels.
+* The view scrolls with the arrow keys (scrollSpeed pixels per second,
+* faster with Shift) or by dragging with Shift and the right mouse button,
+* and never leaves the world. The mouse wheel zooms in around the cursor,
+* up to maxZoom, and out until the whole world fits the window. Draw takes
+* the view and only draws the cells inside it; the readouts along the top
+* of the window stay put.
+*
+* Cells are drawn tileSize window pixels across unless -cell-pixels says
+* otherwise, so a coarse grid can fill a large display: the world is still
+* in tileSize pixels per cell, and the render scale is where the zoom
+* starts and what it's measured against.
+ */
+
+const (
+	scrollSpeed = 600.0 // Window pixels per second the arrow keys scroll the view
+	zoomStep    = 1.25  // Zoom per notch of the mouse wheel
+	maxZoom     = 8.0   // Zoom in from the render scale
+)
+
+// NewWorld creates a game with a cols by rows grid, shown through a w by h
+// window
+func NewWorld(w, h, ts, cols, rows int) *Game {
+	g := NewGame(w, h, ts)
+	g.State = gridfluid.CreateGameState(cols, rows, ts)
+	return g
+}
+
+// View is the part of the world the window shows, in world pixels
+func (g *Game) View() rl.Rectangle {
+	z := g.zoomLevel()
+	v := rl.Rectangle{X: g.scroll.X, Y: g.scroll.Y, Width: float32(g.Width) / z, Height: float32(g.Height) / z}
+	// A world narrower or shorter than the window sits in the middle of it
+	if w := float32(len(g.State[0]) * g.TileSize()); w < v.Width {
+		v.X = (w - v.Width) / 2
+	}
+	if h := float32(len(g.State) * g.TileSize()); h < v.Height {
+		v.Y = (h - v.Height) / 2
+	}
+	return v
+}
+
+// ScrollTo moves the top left corner of the view to (x, y) in world
+// pixels, kept inside the world
+func (g *Game) ScrollTo(x, y float32) {
+	view := g.View()
+	maxX := float32(len(g.State[0])*g.TileSize()) - view.Width
+	maxY := float32(len(g.State)*g.TileSize()) - view.Height
+	g.scroll.X = max(min(x, maxX), 0)
+	g.scroll.Y = max(min(y, maxY), 0)
+}
+
+// SetCellPixels draws every cell px window pixels across, shrinking the
+// window to fit the world if it is smaller
+func (g *Game) SetCellPixels(px, maxW, maxH int) {
+	g.renderScale = float32(px) / float32(g.TileSize())
+	g.Width, g.Height = min(len(g.State[0])*px, maxW), min(len(g.State)*px, maxH)
+}
+
+// scale is how many window pixels a world pixel takes up unzoomed
+func (g *Game) scale() float32 {
+	if g.renderScale == 0 {
+		return 1
+	}
+	return g.renderScale
+}
+
+// zoomLevel is how many window pixels a world pixel takes up
+func (g *Game) zoomLevel() float32 {
+	if g.zoom == 0 {
+		return g.scale()
+	}
+	return max(min(g.zoom, maxZoom*g.scale()), g.minZoom())
+}
+
+// minZoom is the zoom the whole world fits the window at, the render scale
+// if it already does
+func (g *Game) minZoom() float32 {
+	w, h := float32(len(g.State[0])*g.TileSize()), float32(len(g.State)*g.TileSize())
+	return min(float32(g.Width)/w, float32(g.Height)/h, g.scale())
+}
+
+// zoomBy zooms in by notches of the mouse wheel, out for negative ones,
+// keeping the world under the cursor where it is
+func (g *Game) zoomBy(notches float32) {
+	m := g.mousePosition()
+	z := g.zoomLevel()
+	view := g.View()
+	x, y := view.X+m.X/z, view.Y+m.Y/z
+	g.zoom = max(min(z*float32(math.Pow(zoomStep, float64(notches))), maxZoom*g.scale()), g.minZoom())
+	g.ScrollTo(x-m.X/g.zoom, y-m.Y/g.zoom)
+}
+
+// camera draws the view into the window
+func (g *Game) camera() rl.Camera2D {
+	return rl.Camera2D{Offset: rl.Vector2{X: g.viewOffset}, Target: rl.Vector2{X: g.view.X, Y: g.view.Y}, Zoom: g.zoomLevel()}
+}
+
+// screenRect converts a rectangle in world pixels to window pixels
+func (g *Game) screenRect(r rl.Rectangle) rl.Rectangle {
+	z := g.zoomLevel()
+	return rl.Rectangle{X: (r.X-g.view.X)*z + g.viewOffset, Y: (r.Y - g.view.Y) * z, Width: r.Width * z, Height: r.Height * z}
+}
+
+// handleScroll moves the view with the arrow keys and Shift+right mouse
+// drags, and zooms it with the mouse wheel unless ctrl is held for the
+// brush
+func (g *Game) handleScroll(shift, ctrl bool) {
+	z := g.zoomLevel()
+	step := float32(scrollSpeed) * g.frameTime() / z
+	if shift {
+		step *= 4
+	}
+	x, y := g.scroll.X, g.scroll.Y
+	if g.keyDown(rl.KeyLeft) {
+		x -= step
+	}
+	if g.keyDown(rl.KeyRight) {
+		x += step
+	}
+	if g.keyDown(rl.KeyUp) {
+		y -= step
+	}
+	if g.keyDown(rl.KeyDown) {
+		y += step
+	}
+	if shift && g.mouseDown(rl.MouseButtonRight) {
+		delta := g.mouseDelta()
+		x, y = x-delta.X/z, y-delta.Y/z
+	}
+	g.ScrollTo(x, y)
+	if wheel := g.mouseWheel(); wheel != 0 && !ctrl {
+		g.zoomBy(wheel)
+	}
+}
+
+// visibleCells is the range of cells inside the view being drawn, x0 and
+// y0 inclusive, x1 and y1 exclusive
+func (g *Game) visibleCells() (x0, y0, x1, y1 int) {
+	h, w := len(g.State), len(g.State[0])
+	if g.view.Width <= 0 || g.view.Height <= 0 {
+		return 0, 0, w, h
+	}
+	ts := float64(g.TileSize())
+	x0 = max(int(math.Floor(float64(g.view.X)/ts)), 0)
+	y0 = max(int(math.Floor(float64(g.view.Y)/ts)), 0)
+	x1 = min(int(math.Ceil(float64(g.view.X+g.view.Width)/ts)), w)
+	y1 = min(int(math.Ceil(float64(g.view.Y+g.view.Height)/ts)), h)
+	return x0, y0, x1, y1
+}
+
+// mouseWorld is the mouse position in world pixels
+func (g *Game) mouseWorld() rl.Vector2 {
+	pos, z := g.mousePosition(), g.zoomLevel()
+	view := g.view
+	// Split, the mouse is in the world of the pane it is over
+	if p, ok := g.paneAt(pos); ok {
+		pos.X -= p.window.X
+		view = p.view
+	}
+	return rl.Vector2{X: pos.X/z + view.X, Y: pos.Y/z + view.Y}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Volume-of-fluid surface reconstruction
+ */
+
+// drawVOFCell renders a surface cell as its reconstructed fluid polygon
+func (g *Game) drawVOFCell(x, y int) {
+	d := &g.State[y][x]
+	nx, ny := gridfluid.InterfaceNormal(x, y, &g.State)
+	poly := gridfluid.PLICPolygon(nx, ny, math.Min(1.0, d.Volume))
+	if len(poly) < 3 {
+		return
+	}
+
+	ts := float32(g.TileSize())
+	points := make([]rl.Vector2, len(poly))
+	for i, p := range poly {
+		points[i] = rl.Vector2{X: float32(x)*ts + float32(p.X)*ts, Y: float32(y)*ts + float32(p.Y)*ts}
+	}
+	// The clip keeps the square's clockwise order, raylib wants counter-clockwise
+	for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+		points[i], points[j] = points[j], points[i]
+	}
+	rl.DrawTriangleFan(points, fluidColor(d))
+}
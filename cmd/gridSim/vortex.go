@@ -0,0 +1,43 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+/*
+* Vortex drains
+ */
+
+const vortexArms = 3 // Spiral arms drawn over the centre
+
+// drawVortices draws turning spiral arms over every vortex
+func (g *Game) drawVortices() {
+	ts := float32(g.TileSize())
+	spin := float64(g.Frame()) * 0.15
+	x0, y0, x1, y1 := g.visibleCells()
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			if !g.State[y][x].Vortex {
+				continue
+			}
+			cx, cy := (float32(x)+0.5)*ts, (float32(y)+0.5)*ts
+			rl.DrawCircleV(rl.Vector2{X: cx, Y: cy}, ts*0.3, colors.vortex)
+			for arm := range vortexArms {
+				// Each arm curls inward from the rim to the centre
+				prev := rl.Vector2{X: cx, Y: cy}
+				for step := 1; step <= 6; step++ {
+					r := float64(step) / 6
+					angle := spin + float64(arm)*2*math.Pi/vortexArms + r*2
+					next := rl.Vector2{
+						X: cx + float32(math.Cos(angle)*r)*ts*1.2,
+						Y: cy + float32(math.Sin(angle)*r)*ts*1.2,
+					}
+					rl.DrawLineEx(prev, next, 1.5, rl.Fade(colors.vortex, float32(1-r*0.7)))
+					prev = next
+				}
+			}
+		}
+	}
+}
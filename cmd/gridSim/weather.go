@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Weather
+ */
+
+// snowTint shows snow going grey and slushy as it melts
+func snowTint(d *gridfluid.Droplet) rl.Color {
+	return rl.ColorLerp(colors.snow, colors.ice, float32(math.Min(d.Thawed, 1)))
+}
+
+// drawLayer draws a layer depth (0.0 to 1.0) of a cell thick, built up
+// from the side gravity pulls towards
+func (g *Game) drawLayer(x, y int, depth float32, c rl.Color) {
+	ts := float32(g.TileSize())
+	gx, gy := g.Gravity.Vector()
+	px, py := float32(x)*ts, float32(y)*ts
+	w, h := ts, ts
+	switch {
+	case gy > 0:
+		py, h = py+ts*(1-depth), ts*depth
+	case gy < 0:
+		h = ts * depth
+	case gx > 0:
+		px, w = px+ts*(1-depth), ts*depth
+	default:
+		w = ts * depth
+	}
+	rl.DrawRectangleV(rl.Vector2{X: px, Y: py}, rl.Vector2{X: w, Y: h}, c)
+}
+
+// drawSnow draws the drifts and the falling flakes
+func (g *Game) drawSnow() {
+	ts := float32(g.TileSize())
+	x0, y0, x1, y1 := g.visibleCells()
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			if depth := g.State[y][x].Drift; depth > 0 {
+				g.drawLayer(x, y, float32(math.Min(depth, 1)), colors.snow)
+			}
+		}
+	}
+	for _, f := range g.Flakes() {
+		rl.DrawCircleV(rl.Vector2{X: float32(f.Pos.X) * ts, Y: float32(f.Pos.Y) * ts}, max(ts*0.12, 1), colors.snow)
+	}
+}
+
+// drawWeather names the weather under the wind arrow, with the gust
+func (g *Game) drawWeather() {
+	if g.Weather == gridfluid.WeatherClear {
+		return
+	}
+	text := fmt.Sprintf("%s  gust %+.2f  air %.0f°C", gridfluid.WeatherNames[g.Weather], g.Gust(), g.AirTemperature)
+	rl.DrawText(text, int32(g.Width-110), 95, 10, rl.SkyBlue)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"watersim/pkg/gridfluid"
+)
+
+/*
+* Wetness / foam lines
+ */
+
+// obstacleColor darkens obstacles that are (or recently were) wet
+func obstacleColor(d *gridfluid.Droplet) rl.Color {
+	if d.Moving {
+		return colors.mover
+	}
+	if d.Snow {
+		return snowTint(d)
+	}
+	if d.Ice {
+		return iceTint(d)
+	}
+	base := colors.material(d.Material)
+	if d.Material == gridfluid.MaterialWood {
+		base = woodTint(d)
+	}
+	if d.Salt {
+		base = saltTint(d)
+	}
+	if d.Material == gridfluid.MaterialSand {
+		base = sandTint(d)
+	}
+	if d.Heater {
+		base = colors.heater
+	}
+	if d.Gate {
+		base = colors.gate
+	}
+	if d.Vent {
+		base = colors.vent
+	}
+	base = rl.ColorLerp(base, colors.eroded, float32(min(d.Eroded, 1)))
+	return mossTint(rl.ColorLerp(base, colors.wetObstacle, float32(d.Wetness)), d)
+}
+
+// drawFoamLine draws the fading line left at recent surface positions
+func drawFoamLine(d *gridfluid.Droplet, pixelX, pixelY, tileSize int) {
+	if d.FoamLine < 0.05 {
+		return
+	}
+	// The current surface is drawn by the water itself, only show the trace
+	// once the surface has moved on
+	if d.Volume > gridfluid.WetThreshold && d.FoamLine >= 1.0 {
+		return
+	}
+	alpha := float32(d.FoamLine) * 0.6
+	rl.DrawRectangle(int32(pixelX), int32(pixelY), int32(tileSize), 2, rl.Fade(colors.foamLine, alpha))
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+/*
+* Water wheels
+ */
+
+const wheelSpokes = 6
+
+// drawWheels draws every wheel as a rim with turning spokes and paddles
+func (g *Game) drawWheels() {
+	ts := float32(g.TileSize())
+	for _, w := range g.Wheels() {
+		hub := rl.Vector2{X: (float32(w.X) + 0.5) * ts, Y: (float32(w.Y) + 0.5) * ts}
+		radius := float32(w.Radius) * ts
+		rl.DrawCircleLinesV(hub, radius*0.85, colors.wheel)
+		for spoke := range wheelSpokes {
+			a := w.Angle + float64(spoke)*2*math.Pi/wheelSpokes
+			dir := rl.Vector2{X: float32(math.Cos(a)), Y: float32(math.Sin(a))}
+			rim := rl.Vector2{X: hub.X + dir.X*radius*0.85, Y: hub.Y + dir.Y*radius*0.85}
+			tip := rl.Vector2{X: hub.X + dir.X*radius, Y: hub.Y + dir.Y*radius}
+			rl.DrawLineEx(hub, rim, 2, colors.wheel)
+			rl.DrawLineEx(rim, tip, 4, colors.wheel)
+		}
+		rl.DrawCircleV(hub, ts*0.25, colors.wheel)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+/*
+* Wind
+ */
+
+const windStep = 0.1 // Wind change per key press
+
+// drawWind shows the current wind vector as an arrow in the top right corner
+func (g *Game) drawWind() {
+	if g.Wind.X == 0 && g.Wind.Y == 0 {
+		return
+	}
+	center := rl.Vector2{X: float32(g.Width - 60), Y: 40}
+	tip := rl.Vector2{X: center.X + float32(g.Wind.X*30), Y: center.Y + float32(g.Wind.Y*30)}
+	rl.DrawLineEx(center, tip, 2, rl.SkyBlue)
+	rl.DrawCircleV(tip, 3, rl.SkyBlue)
+	rl.DrawText(fmt.Sprintf("wind %.1f, %.1f", g.Wind.X, g.Wind.Y), int32(g.Width-110), 80, 10, rl.SkyBlue)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// -------------------------------
+// Scaling Harness
+// -------------------------------
+const (
+	harnessStartCount = 250
+	harnessGrowth     = 1.25 // particle count multiplier between runs
+	harnessWarmup     = 10   // frames run before measuring
+	harnessFrames     = 30   // frames measured per particle count
+)
+
+// measureFrame runs the sim with count particles and returns the average
+// time of one rendered frame worth of substeps
+func measureFrame(count int) time.Duration {
+	sim := NewSPHSim(count)
+	for i := 0; i < harnessWarmup*substeps; i++ {
+		sim.Step()
+	}
+	start := time.Now()
+	for i := 0; i < harnessFrames*substeps; i++ {
+		sim.Step()
+	}
+	return time.Since(start) / harnessFrames
+}
+
+// RunScalingHarness increases the particle count until a frame no longer
+// fits the budget and reports the largest interactive count
+func RunScalingHarness(budget time.Duration) {
+	fmt.Printf("SPH scaling harness: %s/%s, %d CPUs, %s\n", runtime.GOOS, runtime.GOARCH, runtime.NumCPU(), runtime.Version())
+	fmt.Printf("config: h=%.1f mass=%.1f gas=%.1f viscosity=%.1f dt=%.4f substeps=%d budget=%s\n",
+		h, mass, gasConstant, viscosity, timeStep, substeps, budget)
+	fmt.Printf("%10s %12s %12s %8s\n", "particles", "step", "frame", "fps")
+
+	best := 0
+	for count := harnessStartCount; ; count = int(float64(count) * harnessGrowth) {
+		frame := measureFrame(count)
+		fps := float64(time.Second) / float64(frame)
+		fmt.Printf("%10d %12s %12s %8.1f\n", count, frame/substeps, frame, fps)
+		if frame > budget {
+			break
+		}
+		best = count
+	}
+
+	if best == 0 {
+		fmt.Printf("even %d particles exceed the %s budget\n", harnessStartCount, budget)
+		return
+	}
+	fmt.Printf("max interactive particle count: %d\n", best)
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"flag"
+	"log"
 	"math"
 	_ "math/rand"
+	"time"
 
 	rl "github.com/gen2brain/raylib-go/raylib"
 )
@@ -21,6 +24,7 @@ const (
 	gravity       = 3000.0
 	windowWidth   = 800
 	windowHeight  = 400
+	substeps      = 5 // simulation steps per rendered frame
 )
 
 // -------------------------------
@@ -34,6 +38,13 @@ type Particle struct {
 type SPHSim struct {
 	particles []Particle
 	grid      Grid
+	sponge    Sponge
+}
+
+// Sponge holds the width in pixels of the absorbing band along each wall
+type Sponge struct {
+	left, right, top, bottom float32
+	strength                 float32 // velocity damping per step at the wall
 }
 
 type Grid struct {
@@ -171,7 +182,7 @@ func (s *SPHSim) integrate() {
 		}
 
 		drag := float32(0.995)
-		p.vel = rl.Vector2Scale(p.vel, drag)
+		p.vel = rl.Vector2Scale(p.vel, drag*s.sponge.damping(p.pos))
 	}
 }
 
@@ -194,9 +205,9 @@ func (s *SPHSim) Step() {
 // -------------------------------
 // Initialization
 // -------------------------------
-func NewSPHSim() *SPHSim {
+func NewSPHSim(count int) *SPHSim {
 	s := &SPHSim{}
-	s.particles = make([]Particle, particleCount)
+	s.particles = make([]Particle, count)
 	s.grid = Grid{cellSize: float32(h), cells: make(map[[2]int][]int)}
 	// for i := range s.particles {
 	// 	x := float32(300 + rand.Float32()*100)
@@ -207,9 +218,13 @@ func NewSPHSim() *SPHSim {
 	// 	}
 	// }
 
-	cols := int(math.Sqrt(particleCount))
-	rows := cols
+	cols := int(math.Sqrt(float64(count)))
+	rows := (count + cols - 1) / cols
 	spacing := float32(10)
+	// Pack large blocks tighter so they still start inside the walls
+	if fit := float32(math.Sqrt(float64((windowWidth - 220) * (windowHeight - 60) / count))); fit < spacing {
+		spacing = fit
+	}
 	for y := 0; y < rows; y++ {
 		for x := 0; x < cols; x++ {
 			i := y*cols + x
@@ -229,16 +244,29 @@ func NewSPHSim() *SPHSim {
 // Main
 // -------------------------------
 func main() {
+	scale := flag.Bool("scale", false, "run the particle count scaling harness instead of the demo")
+	sponge := flag.String("sponge", "0,0,0,0", "absorbing band width in pixels for the left,right,top,bottom walls")
+	spongeStrength := flag.Float64("sponge-strength", 0.05, "velocity damping per step at the wall")
+	budget := flag.Duration("budget", time.Second/60, "frame budget for the scaling harness")
+	flag.Parse()
+
+	if *scale {
+		RunScalingHarness(*budget)
+		return
+	}
+
 	rl.InitWindow(windowWidth, windowHeight, "Minimal 2D SPH Prototype")
 	defer rl.CloseWindow()
 	rl.SetTargetFPS(60)
 
-	sim := NewSPHSim()
+	sim := NewSPHSim(particleCount)
+	if err := sim.sponge.parse(*sponge, float32(*spongeStrength)); err != nil {
+		log.Fatalf("-sponge: %v", err)
+	}
 	energyHistory := make([]float64, 0, 1000)
 	for !rl.WindowShouldClose() {
 		// Simulation step: small fixed timestep for stability
-		steps := 5 // substeps for smoother integration
-		for i := 0; i < steps; i++ {
+		for i := 0; i < substeps; i++ {
 			sim.Step()
 		}
 		energy := sim.TotalKineticEnergy()
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// -------------------------------
+// Sponge Boundaries
+// -------------------------------
+
+// parse reads "left,right,top,bottom" band widths
+func (s *Sponge) parse(widths string, strength float32) error {
+	parts := strings.Split(widths, ",")
+	if len(parts) != 4 {
+		return fmt.Errorf("want 4 comma separated widths, got %q", widths)
+	}
+	var w [4]float32
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+		if err != nil {
+			return err
+		}
+		w[i] = float32(v)
+	}
+	s.left, s.right, s.top, s.bottom = w[0], w[1], w[2], w[3]
+	s.strength = strength
+	return nil
+}
+
+// bandDepth returns how deep (0 to 1) a distance from the wall sits in a band
+func bandDepth(dist, width float32) float32 {
+	if width <= 0 || dist >= width {
+		return 0
+	}
+	t := (width - dist) / width
+	return t * t
+}
+
+// damping returns the velocity multiplier at pos, falling off
+// quadratically from the wall so waves are absorbed rather than reflected
+func (s Sponge) damping(pos rl.Vector2) float32 {
+	depth := max(
+		bandDepth(pos.X, s.left),
+		bandDepth(windowWidth-pos.X, s.right),
+		bandDepth(pos.Y, s.top),
+		bandDepth(windowHeight-pos.Y, s.bottom),
+	)
+	return 1 - s.strength*depth
+}
@@ -2,9 +2,13 @@ module watersim
 
 go 1.25.3
 
+require (
+	github.com/gen2brain/raylib-go/raygui v0.0.0-20250521210303-fca3bf26c568
+	github.com/gen2brain/raylib-go/raylib v0.55.1
+)
+
 require (
 	github.com/ebitengine/purego v0.9.0 // indirect
-	github.com/gen2brain/raylib-go/raylib v0.55.1 // indirect
 	golang.org/x/exp v0.0.0-20251017212417-90e834f514db // indirect
 	golang.org/x/sys v0.37.0 // indirect
 )
@@ -0,0 +1,109 @@
+package gridfluid
+
+/*
+* Acid
+*
+* Acid flows like water and mixes with it freely. Each water or acid cell
+* carries an acid concentration; cells above acidNeutral are acid, the rest
+* plain water, so enough water dilutes acid until it is harmless. Acid
+* resting against an obstacle eats into it at AcidErosion per second and is
+* used up doing so. Moving obstacles are machinery and don't corrode, and
+* ice is left to melt.
+ */
+
+const (
+	acidNeutral = 0.2 // Concentration below which acid counts as water
+	acidSpend   = 0.5 // Concentration used up per obstacle cell eaten
+)
+
+// miscible reports whether two fluids blend instead of keeping an interface
+func miscible(a, b Fluid) bool {
+	watery := func(f Fluid) bool { return f == FluidWater || f == FluidAcid }
+	return a == b || (watery(a) && watery(b))
+}
+
+// mixConcentration blends amount of incoming water carrying concentration c
+// into a cell holding volume of water at concentration *dst
+func mixConcentration(dst *float64, volume, c, amount float64) {
+	total := volume + amount
+	if amount <= 0 || total <= 0 {
+		return
+	}
+	*dst = (*dst*max(volume, 0) + c*amount) / total
+}
+
+// relabelAcid decides whether a watery cell is acid or water from its
+// concentration
+func relabelAcid(d *Droplet) {
+	if d.Fluid != FluidWater && d.Fluid != FluidAcid {
+		return
+	}
+	if d.Acidity >= acidNeutral {
+		d.Fluid = FluidAcid
+	} else {
+		d.Fluid = FluidWater
+	}
+}
+
+// DropAcid adds a droplet of concentrated acid to a cell and returns how
+// much fit. Acid dropped into oil is lost on the surface, so oil is skipped.
+func (g *Game) DropAcid(x, y int, volume float64) float64 {
+	if !g.InBounds(x, y) {
+		return 0
+	}
+	d := &g.State[y][x]
+	if d.Material.IsSolid() || d.Pipe != PipeNone || (d.Volume > WetThreshold && d.Fluid == FluidOil) {
+		return 0
+	}
+	amount := min(volume, remainder(*d, 1.0))
+	if amount <= 0 {
+		return 0
+	}
+	if d.Volume <= 0 {
+		d.Fluid = FluidAcid
+		d.Acidity = 1
+	}
+	mixConcentration(&d.Acidity, d.Volume, 1, amount)
+	mixConcentration(&d.Salinity, d.Volume, 0, amount)
+	mixConcentration(&d.Sediment, d.Volume, 0, amount)
+	mixTemperature(&d.Temperature, d.Volume, ambientTemperature, amount)
+	d.Volume += amount
+	relabelAcid(d)
+	return amount
+}
+
+// corrode lets acid eat into the obstacles it touches
+func (g *Game) corrode(state *[][]Droplet) {
+	rate := g.Params.AcidErosion / ticksPerSecond
+	if rate <= 0 {
+		return
+	}
+	for y := range *state {
+		for x := range (*state)[y] {
+			d := &(*state)[y][x]
+			if d.Material.IsSolid() || d.Fluid != FluidAcid || d.Volume <= WetThreshold {
+				continue
+			}
+			for _, dpos := range pressureDirections {
+				nx, ny, ok := g.neighbor(x, y, dpos[0], dpos[1], state)
+				if !ok {
+					continue
+				}
+				n := &(*state)[ny][nx]
+				if !n.Material.IsSolid() || n.Moving || n.Ice {
+					continue
+				}
+				bite := rate * d.Acidity * d.Volume
+				n.Eroded += bite
+				d.Acidity = max(d.Acidity-bite*acidSpend, 0)
+				if bite > 0 {
+					g.fizz(state, x, y)
+				}
+				if n.Eroded >= 1 {
+					*n = Droplet{Size: n.Size, Temperature: n.Temperature}
+				}
+			}
+			relabelAcid(d)
+		}
+	}
+}
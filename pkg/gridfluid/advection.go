@@ -0,0 +1,121 @@
+package gridfluid
+
+import "math"
+
+/*
+* Momentum / advection
+ */
+
+// Velocities are clamped to one cell per tick
+const maxCellSpeed = 1.0
+
+// push gives the receiving cell velocity in the direction the water moved
+func (g *Game) push(target *Droplet, dx, dy int, transfer float64) {
+	if transfer <= 0 {
+		return
+	}
+	target.VX += float64(dx) * transfer * g.Params.FlowImpulse
+	target.VY += float64(dy) * transfer * g.Params.FlowImpulse
+}
+
+// carryMomentum blends the incoming water's velocity into the target,
+// weighted by volume, before the transfer is applied
+func carryMomentum(current, target *Droplet, transfer float64) {
+	if transfer <= 0 {
+		return
+	}
+	total := target.Volume + transfer
+	if total <= 0 {
+		return
+	}
+	target.VX = (target.VX*target.Volume + current.VX*transfer) / total
+	target.VY = (target.VY*target.Volume + current.VY*transfer) / total
+}
+
+// advect transports volume along the velocity field so fast water keeps
+// moving (overshooting ledges, forming jets) instead of only seeping
+func (g *Game) advect(state *[][]Droplet) {
+	// Read from a snapshot so water is moved at most once per tick
+	g.snapshot = reuseGrid(g.snapshot, *state, g.tileSize)
+	prev := g.snapshot
+	for y := range *state {
+		for x := 0; x < len(prev[y]); x += chunkSize {
+			if g.chunkActive(x, y) {
+				end := min(x+chunkSize, len(prev[y]))
+				copy(prev[y][x:end], (*state)[y][x:end])
+			}
+		}
+	}
+
+	for y := range prev {
+		for x := range prev[y] {
+			if !g.chunkActive(x, y) {
+				continue
+			}
+			p := prev[y][x]
+			if p.Material.IsSolid() || p.Volume <= 0 {
+				continue
+			}
+			if p.VX != 0 {
+				g.advectAxis(x, y, sign(p.VX), 0, p.Volume*math.Min(math.Abs(p.VX), 1)*g.Params.AdvectionRate, state)
+			}
+			if p.VY != 0 {
+				g.advectAxis(x, y, 0, sign(p.VY), p.Volume*math.Min(math.Abs(p.VY), 1)*g.Params.AdvectionRate, state)
+			}
+		}
+	}
+
+	g.applyGravity(state)
+}
+
+// advectAxis moves up to amount of volume one cell along (dx, dy),
+// bouncing off obstacles and edges
+func (g *Game) advectAxis(x, y, dx, dy int, amount float64, state *[][]Droplet) {
+	current := &(*state)[y][x]
+	nx, ny, ok := g.neighbor(x, y, dx, dy, state)
+	if !ok || g.conductance(&(*state)[ny][nx]) == 0 {
+		// Reflect the blocked component
+		if dx != 0 {
+			current.VX = -current.VX * g.Params.Restitution
+		}
+		if dy != 0 {
+			current.VY = -current.VY * g.Params.Restitution
+		}
+		return
+	}
+
+	target := &(*state)[ny][nx]
+	amount = math.Min(amount, current.Volume)
+	amount = math.Min(amount, remainder(*target, 1.0))
+	if amount <= 0 {
+		return
+	}
+	g.moveVolume(current, target, amount)
+}
+
+// applyGravity accelerates unsupported water downward and clamps speeds
+func (g *Game) applyGravity(state *[][]Droplet) {
+	for y := range *state {
+		for x := range (*state)[y] {
+			d := &(*state)[y][x]
+			if d.Material.IsSolid() || d.Volume <= 0 {
+				d.VX, d.VY = 0, 0
+				continue
+			}
+			if g.canFlowDown(x, y, state) {
+				gx, gy := g.Gravity.Vector()
+				d.VX += gx * g.Params.GravityAccel
+				d.VY += gy * g.Params.GravityAccel
+			}
+			d.VX = math.Min(maxCellSpeed, math.Max(-maxCellSpeed, d.VX))
+			d.VY = math.Min(maxCellSpeed, math.Max(-maxCellSpeed, d.VY))
+		}
+	}
+}
+
+func sign(v float64) int {
+	if v < 0 {
+		return -1
+	}
+	return 1
+}
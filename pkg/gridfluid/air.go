@@ -0,0 +1,152 @@
+package gridfluid
+
+import "math"
+
+/*
+* Air pockets
+*
+* Every open cell that isn't full of water holds air. The cells of air
+* connected to each other form a pocket, found again every tick; pockets
+* smaller than minPocket when they are cut off are froth and ignored. A pocket
+* reaching an edge of the grid that doesn't wrap is open to the sky and
+* stays at atmospheric pressure; the grid is a window onto a bigger world.
+* A pocket sealed in by obstacles and water keeps the air it had when it
+* was cut off: the air is carried per cell, in cell volumes at atmospheric
+* pressure, and spread over the pocket's room every tick, so pockets that
+* merge or split share it out. Squeezed
+* into less room the air pushes back like a gas (Boyle's law): into half
+* its room it pushes with atmosphere cells of water head, into a third
+* with twice that, up to maxSqueeze. The pressure is added to the pocket's
+* cells before the pressure solve, so the water around it feels it like
+* the weight of a taller column. The water stops flowing in once the
+* pressures balance and a sealed chamber never fills up completely.
+*
+* Air is lighter than water. A sealed pocket with water above it lets its
+* air go as bubbles (see bubbles.go) that rise through the water until
+* they reach air again, joining that pocket, or get stuck under a ceiling
+* where they start a new one. A pocket keeps the last of its air as a
+* small bubble clinging to the wall.
+ */
+
+const (
+	atmosphere   = 10.0                 // Head in cells of water that squeezes air to half its room
+	maxSqueeze   = 4.0                  // Densest the air pushes back as, keeps the pressure finite
+	minPocket    = 0.5                  // Least room a pocket being cut off needs to be kept
+	bubbleAir    = 0.1                  // Air carried by a single bubble
+	bubbleChance = 4.0 / ticksPerSecond // Chance per tick a pocket under water lets a bubble go
+)
+
+// pocket is a sealed pocket of air found this tick
+type pocket struct {
+	cells [][2]int
+	air   float64
+}
+
+// holdsAir reports whether a cell has room for air or air left in it
+func holdsAir(d *Droplet) bool {
+	return !d.Material.IsSolid() && d.Pipe == PipeNone && (d.Volume < fullCell || d.Air > 0)
+}
+
+// airRoom is the part of a cell the water leaves free
+func airRoom(d *Droplet) float64 {
+	return math.Min(math.Max(1-d.Volume, 0), 1)
+}
+
+// trapAir finds the pockets of air, spreads each one's air over its room
+// and adds the pressure of the sealed ones to their cells
+func (g *Game) trapAir(state *[][]Droplet) {
+	h, w := len(*state), len((*state)[0])
+	seen := make([]bool, w*h)
+	g.pockets = g.pockets[:0]
+	var cells [][2]int
+	for y := range h {
+		for x := range w {
+			d := &(*state)[y][x]
+			if !holdsAir(d) {
+				d.Air = 0
+				continue
+			}
+			if seen[y*w+x] {
+				continue
+			}
+
+			seen[y*w+x] = true
+			cells = append(cells[:0], [2]int{x, y})
+			vented := false
+			air, room := 0.0, 0.0
+			for i := 0; i < len(cells); i++ {
+				c := &(*state)[cells[i][1]][cells[i][0]]
+				air += c.Air
+				room += airRoom(c)
+				for _, dir := range pressureDirections {
+					nx, ny, ok := g.neighbor(cells[i][0], cells[i][1], dir[0], dir[1], state)
+					if !ok {
+						vented = true
+						continue
+					}
+					if !seen[ny*w+nx] && holdsAir(&(*state)[ny][nx]) {
+						seen[ny*w+nx] = true
+						cells = append(cells, [2]int{nx, ny})
+					}
+				}
+			}
+
+			// Open air isn't kept track of, it can always get out of
+			// the way, and neither is froth in the water. A pocket that
+			// was just cut off starts out at atmospheric pressure.
+			if vented || (air <= 0 && room < minPocket) {
+				for _, c := range cells {
+					(*state)[c[1]][c[0]].Air = 0
+				}
+				continue
+			}
+			if air <= 0 {
+				air = room
+			}
+			pressure := atmosphere * (air/math.Max(room, air/maxSqueeze) - 1)
+			for _, c := range cells {
+				d := &(*state)[c[1]][c[0]]
+				if room > 0 {
+					d.Air = air * airRoom(d) / room
+				} else {
+					d.Air = air / float64(len(cells))
+				}
+				d.Pressure = math.Max(d.Volume, 0) + pressure
+			}
+			g.pockets = append(g.pockets, pocket{cells: append([][2]int(nil), cells...), air: air})
+		}
+	}
+}
+
+// releaseBubbles lets sealed pockets with water above them bubble their air
+// away and moves the bubbles already rising
+func (g *Game) releaseBubbles(state *[][]Droplet) {
+	for _, p := range g.pockets {
+		if p.air <= 2*bubbleAir || g.rng.Float64() >= bubbleChance {
+			continue
+		}
+		// The bubble leaves from a cell with water right above it
+		var exits [][2]int
+		for _, c := range p.cells {
+			ux, uy, ok := g.local(c[0], c[1], 0, -1, state)
+			if !ok {
+				continue
+			}
+			if u := &(*state)[uy][ux]; !u.Material.IsSolid() && u.Pipe == PipeNone && !holdsAir(u) {
+				exits = append(exits, [2]int{ux, uy})
+			}
+		}
+		if len(exits) == 0 {
+			continue
+		}
+		exit := exits[g.rng.IntN(len(exits))]
+		if !g.addBubble(state, exit[0], exit[1], bubbleAir) {
+			continue
+		}
+		keep := (p.air - bubbleAir) / p.air
+		for _, c := range p.cells {
+			(*state)[c[1]][c[0]].Air *= keep
+		}
+	}
+	g.updateBubbles(state)
+}
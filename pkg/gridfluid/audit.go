@@ -0,0 +1,136 @@
+package gridfluid
+
+import (
+	"fmt"
+	"math"
+)
+
+/*
+* Conservation audit
+*
+* With the audit on, every pass of the update is weighed: the water in the
+* grid and in the air plus what the sim took out, less what it added, the
+* same balance the measurement regions use. A pass that changes it by more
+* than the tolerance has created or destroyed water, and the audit finds
+* the cell at the heart of the change by comparing the grid before and
+* after the pass: the one whose 3x3 neighbourhood changed the most the same
+* way. Water that goes missing between the passes, in the movers, debris
+* or the links between layers, is put down to the update as a whole.
+*
+* Weighing copies the grid for every pass, so the audit is a debugging aid
+* and slows the update down several times over. Leaks are delivered once
+* the update is over, so the callback may save the world as it was left.
+ */
+
+// Leak is water created or destroyed by one pass of an update
+type Leak struct {
+	Frame  int     // Update the leak happened in
+	Pass   string  // Pass that leaked, "update" for what happened between the passes
+	Layer  int     // Layer the pass ran on
+	Amount float64 // Volume created, negative when destroyed
+	X, Y   int     // Cell at the heart of the change, -1 if none changed
+}
+
+func (l Leak) String() string {
+	verb := "created"
+	if l.Amount < 0 {
+		verb = "destroyed"
+	}
+	return fmt.Sprintf("update %d, layer %d, %s %s %.3g around (%d, %d)", l.Frame, l.Layer, l.Pass, verb, math.Abs(l.Amount), l.X, l.Y)
+}
+
+type audit struct {
+	tolerance float64
+	fn        func(Leak)
+	leaks     []Leak  // Leaks of the update running
+	passes    float64 // Sum of what the passes of the update leaked
+}
+
+// AuditConservation weighs every pass of every update from now on and
+// calls fn for each that creates or destroys more than tolerance of water.
+// A nil fn turns the audit off.
+func (g *Game) AuditConservation(tolerance float64, fn func(Leak)) {
+	if fn == nil {
+		g.audit = nil
+		return
+	}
+	g.audit = &audit{tolerance: tolerance, fn: fn}
+}
+
+// passBalance is balance for a pass: the water in state and in the air of
+// the layer being updated, plus what the sim took out, less what it added
+func (g *Game) passBalance(state [][]Droplet) float64 {
+	b := g.Absorbed + g.Evaporated + g.Swallowed - g.Supplied
+	for _, out := range g.Outflow {
+		b += out
+	}
+	// Counted like TotalVolume does, so the passes add up to the update
+	for y := range state {
+		for x := range state[y] {
+			if d := &state[y][x]; holdsWater(d) {
+				b += d.Volume
+			}
+		}
+	}
+	for _, s := range g.splashes {
+		b += s.Volume
+	}
+	return b
+}
+
+// auditPass weighs state before a pass, returning what weighs it again
+// once the pass has run
+func (g *Game) auditPass(name string, state *[][]Droplet) func() {
+	a := g.audit
+	if a == nil {
+		return func() {}
+	}
+	before, grid := g.passBalance(*state), CopyState(*state)
+	return func() {
+		change := g.passBalance(*state) - before
+		a.passes += change
+		if math.Abs(change) <= a.tolerance {
+			return
+		}
+		x, y := leakCenter(grid, *state, change)
+		a.leaks = append(a.leaks, Leak{Frame: g.frame + 1, Pass: name, Layer: g.layer, Amount: change, X: x, Y: y})
+	}
+}
+
+// auditUpdate puts what leaked between the passes down to the update and
+// delivers the leaks, given the balance before the update
+func (g *Game) auditUpdate(before float64) {
+	a := g.audit
+	if a == nil {
+		return
+	}
+	if change := g.balance() - before - a.passes; math.Abs(change) > a.tolerance {
+		a.leaks = append(a.leaks, Leak{Frame: g.frame, Pass: "update", Layer: g.layer, Amount: change, X: -1, Y: -1})
+	}
+	leaks := a.leaks
+	a.leaks, a.passes = nil, 0
+	for _, l := range leaks {
+		a.fn(l)
+	}
+}
+
+// leakCenter is the cell whose 3x3 neighbourhood changed the most between
+// before and after in the direction of change, or -1, -1 if none did
+func leakCenter(before, after [][]Droplet, change float64) (int, int) {
+	sign := math.Copysign(1, change)
+	bx, by, best := -1, -1, 0.0
+	for y := range after {
+		for x := range after[y] {
+			sum := 0.0
+			for ny := max(y-1, 0); ny <= min(y+1, len(after)-1); ny++ {
+				for nx := max(x-1, 0); nx <= min(x+1, len(after[ny])-1); nx++ {
+					sum += heldVolume(&after[ny][nx]) - heldVolume(&before[ny][nx])
+				}
+			}
+			if sum*sign > best {
+				bx, by, best = x, y, sum*sign
+			}
+		}
+	}
+	return bx, by
+}
@@ -0,0 +1,114 @@
+package gridfluid
+
+/*
+* Boundaries
+*
+* Each grid edge is either a hard wall (water piles up against it), open
+* (water reaching the edge cells leaves the simulation) or wrapping (water
+* leaving the edge re-enters on the opposite one). The volume lost through
+* every edge is tallied so spillways can be measured. Wrapping always
+* applies to both edges of an axis, turning the grid into a torus for
+* steady-state channel flows.
+ */
+
+type Edge int
+
+const (
+	EdgeLeft Edge = iota
+	EdgeRight
+	EdgeTop
+	EdgeBottom
+	EdgeCount
+)
+
+var EdgeNames = [EdgeCount]string{"left", "right", "top", "bottom"}
+
+// opposite returns the edge on the other side of the grid
+func (e Edge) opposite() Edge {
+	return e ^ 1
+}
+
+type BoundaryMode int
+
+const (
+	BoundaryWall BoundaryMode = iota
+	BoundaryOpen
+	BoundaryWrap
+)
+
+// SetBoundary changes how an edge treats water reaching it. Wrapping is
+// set on, or removed from, both edges of the axis together.
+func (g *Game) SetBoundary(edge Edge, mode BoundaryMode) {
+	if mode == BoundaryWrap || g.Boundary[edge] == BoundaryWrap {
+		g.Boundary[edge.opposite()] = mode
+	}
+	g.Boundary[edge] = mode
+}
+
+// ToggleBoundary cycles an edge through wall, open and wrap
+func (g *Game) ToggleBoundary(edge Edge) {
+	switch g.Boundary[edge] {
+	case BoundaryWall:
+		g.SetBoundary(edge, BoundaryOpen)
+	case BoundaryOpen:
+		g.SetBoundary(edge, BoundaryWrap)
+	default:
+		g.SetBoundary(edge, BoundaryWall)
+	}
+}
+
+func (g *Game) wrapsX() bool { return g.Boundary[EdgeLeft] == BoundaryWrap }
+func (g *Game) wrapsY() bool { return g.Boundary[EdgeTop] == BoundaryWrap }
+
+// neighbor resolves the cell at offset (dx, dy) from (x, y), wrapping the
+// index around wrapping edges. ok is false when the offset leaves the grid.
+func (g *Game) neighbor(x, y, dx, dy int, state *[][]Droplet) (nx, ny int, ok bool) {
+	h, w := len(*state), len((*state)[0])
+	nx, ny = x+dx, y+dy
+	if g.wrapsX() {
+		nx = (nx%w + w) % w
+	}
+	if g.wrapsY() {
+		ny = (ny%h + h) % h
+	}
+	return nx, ny, nx >= 0 && nx < w && ny >= 0 && ny < h
+}
+
+// drainOpenEdges removes the water sitting in the cells of open edges and
+// adds it to the per-edge outflow totals
+func (g *Game) drainOpenEdges(state *[][]Droplet) {
+	h, w := len(*state), len((*state)[0])
+	drain := func(edge Edge, x, y int) {
+		d := &(*state)[y][x]
+		if d.Material.IsSolid() || d.Volume <= 0 {
+			return
+		}
+		g.Outflow[edge] += d.Volume
+		g.recordSink(x, y, d.Volume)
+		d.Volume = 0
+		d.VX, d.VY = 0, 0
+	}
+	for edge := range EdgeCount {
+		if g.Boundary[edge] != BoundaryOpen {
+			continue
+		}
+		switch edge {
+		case EdgeLeft, EdgeRight:
+			x := 0
+			if edge == EdgeRight {
+				x = w - 1
+			}
+			for y := range h {
+				drain(edge, x, y)
+			}
+		case EdgeTop, EdgeBottom:
+			y := 0
+			if edge == EdgeBottom {
+				y = h - 1
+			}
+			for x := range w {
+				drain(edge, x, y)
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package gridfluid
+
+import "math"
+
+/*
+* Bubbles
+*
+* Bubbles are air let go under water. Sealed air pockets release them
+* (see air.go), and so do vents: obstacles blowing ventRate bubbles per
+* second into each cell of water touching them. Water plunging into a
+* pool and splashes landing in it drag a little air under, and salt
+* dissolving or acid eating an obstacle fizzes. Only a cell full of water
+* takes a bubble, and at most maxBubbles rise at a time.
+*
+* A bubble rises bubbleRise cells per tick, wobbling from side to side. It
+* pops as soon as it reaches air: its air joins the pocket there, and the
+* water it broke through is kicked up by popKick and foams a little,
+* which sets off a small ripple. A bubble that hits a ceiling stays there
+* as air.
+ */
+
+const (
+	maxBubbles   = 400                  // Most bubbles rising at once
+	bubbleRise   = 0.15                 // Cells per tick a bubble rises
+	bubbleWobble = 0.05                 // Largest random sideways drift of a bubble per tick
+	entrainAir   = 0.02                 // Air dragged under by plunging water or a splash
+	ventAir      = 0.05                 // Air in a bubble from a vent
+	ventRate     = 3.0                  // Bubbles per second a vent blows into each wet face
+	fizzAir      = 0.01                 // Air in a bubble fizzing off salt or acid
+	fizzChance   = 2.0 / ticksPerSecond // Chance per tick a dissolving face fizzes
+	popKick      = 0.15                 // Upward speed a popping bubble gives the surface
+	popFoam      = 0.3                  // Foam left where a bubble pops
+)
+
+// Bubble is air rising through the water
+type Bubble struct {
+	Pos Vector // Cells
+	Air float64
+}
+
+// Bubbles lists the bubbles rising through the water
+func (g *Game) Bubbles() []*Bubble {
+	return g.bubbles
+}
+
+// addBubble lets air go as a bubble in the cell at (x, y), as long as the
+// cell is under water
+func (g *Game) addBubble(state *[][]Droplet, x, y int, air float64) bool {
+	d := &(*state)[y][x]
+	if d.Material.IsSolid() || d.Pipe != PipeNone || d.Volume < fullCell || len(g.bubbles) >= maxBubbles {
+		return false
+	}
+	pos := Vector{float64(x) + 0.25 + 0.5*g.rng.Float64(), float64(y) + 0.25 + 0.5*g.rng.Float64()}
+	g.bubbles = append(g.bubbles, &Bubble{Pos: pos, Air: air})
+	return true
+}
+
+// entrain drags air under the water landing on the cell at (x, y)
+func (g *Game) entrain(state *[][]Droplet, x, y int) {
+	if bx, by, ok := g.below(x, y, state); ok {
+		g.addBubble(state, bx, by, entrainAir)
+	}
+}
+
+// CreateVent turns the cell at (x, y) into a vent
+func CreateVent(x, y int, state *[][]Droplet) {
+	d := &(*state)[y][x]
+	d.Material = MaterialStone
+	d.Vent = true
+	d.Volume = 0
+}
+
+// PlaceVent puts a vent on a dry open cell, or removes the vent there
+func (g *Game) PlaceVent(x, y int) bool {
+	if !g.InBounds(x, y) {
+		return false
+	}
+	d := &g.State[y][x]
+	if d.Vent {
+		*d = Droplet{Size: d.Size, Temperature: ambientTemperature}
+		return true
+	}
+	if d.Pipe != PipeNone || d.Material != MaterialOpen || d.Volume > WetThreshold {
+		return false
+	}
+	CreateVent(x, y, &g.State)
+	return true
+}
+
+// blowVents lets the vents bubble into the water around them
+func (g *Game) blowVents(state *[][]Droplet) {
+	chance := ventRate / ticksPerSecond
+	for y := range *state {
+		for x := range (*state)[y] {
+			if !(*state)[y][x].Vent {
+				continue
+			}
+			for _, dpos := range pressureDirections {
+				nx, ny, ok := g.neighbor(x, y, dpos[0], dpos[1], state)
+				if ok && g.rng.Float64() < chance {
+					g.addBubble(state, nx, ny, ventAir)
+				}
+			}
+		}
+	}
+}
+
+// fizz may let a bubble go in the water eating away at an obstacle
+func (g *Game) fizz(state *[][]Droplet, x, y int) {
+	if g.rng.Float64() < fizzChance {
+		g.addBubble(state, x, y, fizzAir)
+	}
+}
+
+// updateBubbles lets the bubbles rise and pops the ones that reach air
+func (g *Game) updateBubbles(state *[][]Droplet) {
+	gx, gy := g.Gravity.Vector()
+	sx, sy := g.Gravity.toGrid(1, 0)
+	h, w := len(*state), len((*state)[0])
+	alive := g.bubbles[:0]
+	for _, b := range g.bubbles {
+		prev := b.Pos
+		side := (2*g.rng.Float64() - 1) * bubbleWobble
+		b.Pos.X += -gx*bubbleRise + float64(sx)*side
+		b.Pos.Y += -gy*bubbleRise + float64(sy)*side
+		if b.Pos.X < 0 || b.Pos.Y < 0 || b.Pos.X >= float64(w) || b.Pos.Y >= float64(h) {
+			// Out into the open sky
+			continue
+		}
+		d := &(*state)[int(b.Pos.Y)][int(b.Pos.X)]
+		if d.Material.IsSolid() || d.Pipe != PipeNone {
+			// Stuck under a ceiling, where it starts a pocket of its own
+			(*state)[int(prev.Y)][int(prev.X)].Air += b.Air
+			continue
+		}
+		if d.Volume < fullCell {
+			d.Air += b.Air
+			if p := &(*state)[int(prev.Y)][int(prev.X)]; p != d && p.Volume > WetThreshold {
+				p.VX -= gx * popKick
+				p.VY -= gy * popKick
+				p.Foam = math.Max(p.Foam, popFoam)
+			}
+			continue
+		}
+		alive = append(alive, b)
+	}
+	g.bubbles = alive
+}
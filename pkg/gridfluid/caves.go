@@ -0,0 +1,135 @@
+package gridfluid
+
+import "math/rand/v2"
+
+/*
+* Caves
+*
+* GenerateCaves carves a cave system out of solid rock with a cellular
+* automaton. Every cell starts as rock with a chance of Fill, then each
+* smoothing pass turns a cell into rock when five or more of its eight
+* neighbours are rock and opens it when three or fewer are, which grows
+* the noise into rounded chambers joined by channels. Pockets cut off from
+* the largest cave are filled in, so all the open space is one system the
+* water can reach, and the edges are left as rock.
+*
+* The same seed and parameters always carve the same caves.
+ */
+
+// CaveParams tune the caves GenerateCaves carves
+type CaveParams struct {
+	Seed      uint64
+	Fill      float64 // Share of the cells that start as rock, 0.45 gives open caves
+	Smoothing int     // Automaton passes, more give rounder chambers
+	Material  MaterialID
+}
+
+// DefaultCaveParams are caves with roomy chambers in stone
+func DefaultCaveParams() CaveParams {
+	return CaveParams{Fill: 0.45, Smoothing: 5, Material: MaterialStone}
+}
+
+// GenerateCaves fills state with rock and carves caves out of it
+func GenerateCaves(p CaveParams, state *[][]Droplet) {
+	h := len(*state)
+	if h == 0 {
+		return
+	}
+	w := len((*state)[0])
+	rng := rand.New(rand.NewPCG(p.Seed, p.Seed))
+	edge := func(x, y int) bool { return x == 0 || y == 0 || x == w-1 || y == h-1 }
+
+	rock := make([][]bool, h)
+	for y := range rock {
+		rock[y] = make([]bool, w)
+		for x := range rock[y] {
+			rock[y][x] = edge(x, y) || rng.Float64() < p.Fill
+		}
+	}
+	next := make([][]bool, h)
+	for y := range next {
+		next[y] = make([]bool, w)
+	}
+	for range p.Smoothing {
+		for y := range rock {
+			for x := range rock[y] {
+				n := 0
+				for dy := -1; dy <= 1; dy++ {
+					for dx := -1; dx <= 1; dx++ {
+						nx, ny := x+dx, y+dy
+						// Outside the grid counts as rock
+						if (dx != 0 || dy != 0) && (nx < 0 || ny < 0 || nx >= w || ny >= h || rock[ny][nx]) {
+							n++
+						}
+					}
+				}
+				switch {
+				case edge(x, y) || n >= 5:
+					next[y][x] = true
+				case n <= 3:
+					next[y][x] = false
+				default:
+					next[y][x] = rock[y][x]
+				}
+			}
+		}
+		rock, next = next, rock
+	}
+	fillPockets(rock)
+
+	for y := range rock {
+		for x := range rock[y] {
+			if rock[y][x] {
+				(*state)[y][x].Material = p.Material
+				(*state)[y][x].Volume = 0
+			}
+		}
+	}
+}
+
+// fillPockets turns every open area but the largest into rock
+func fillPockets(rock [][]bool) {
+	h, w := len(rock), len(rock[0])
+	area := make([][]int, h) // Index of the area an open cell belongs to, from 1
+	for y := range area {
+		area[y] = make([]int, w)
+	}
+	var sizes []int
+	for y := range rock {
+		for x := range rock[y] {
+			if rock[y][x] || area[y][x] != 0 {
+				continue
+			}
+			id := len(sizes) + 1
+			size := 0
+			stack := [][2]int{{x, y}}
+			area[y][x] = id
+			for len(stack) > 0 {
+				c := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				size++
+				for _, d := range [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+					nx, ny := c[0]+d[0], c[1]+d[1]
+					if nx >= 0 && ny >= 0 && nx < w && ny < h && !rock[ny][nx] && area[ny][nx] == 0 {
+						area[ny][nx] = id
+						stack = append(stack, [2]int{nx, ny})
+					}
+				}
+			}
+			sizes = append(sizes, size)
+		}
+	}
+	largest := 0
+	for i, size := range sizes {
+		if largest == 0 || size > sizes[largest-1] {
+			largest = i + 1
+		}
+	}
+	for y := range rock {
+		for x := range rock[y] {
+			if !rock[y][x] && area[y][x] != largest {
+				rock[y][x] = true
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package gridfluid
+
+import "math"
+
+/*
+* Active chunks
+*
+* The grid is split into square chunks of chunkSize cells. A chunk is active
+* when one of its cells was stirred by the last update or since: water moved
+* in or out of it, or the cell was built, knocked down or retyped. Chunks
+* bordering an active one are active too, so water can always flow into its
+* neighbours. The per-cell flow rules, the pressure solve and advection skip
+* the other chunks, where water rests in pools or there is none at all. The
+* remaining passes still run everywhere, and whatever they change wakes its
+* chunk for the next update.
+*
+* Spotting stirred cells costs no extra copy: the back buffer still holds
+* the grid as it was before the last update. Anything that swaps in a
+* different grid, as layers and scene loading do, or changes gravity or the
+* parameters, wakes every chunk.
+ */
+
+const (
+	chunkSize  = 16   // Width and height of a chunk in cells
+	chunkStill = 1e-5 // Volume or flux change below which a cell counts as still
+)
+
+type chunkMap struct {
+	w, h    int    // Size in chunks
+	active  []bool // Chunks the flow rules visit this update
+	stirred []bool // Chunks with a stirred cell, before spreading to neighbours
+
+	// What the back buffer was compared against after the last update
+	after   *Droplet
+	gravity Gravity
+	params  Params
+}
+
+// markChunks works out which chunks the flow rules visit this update. Call
+// it before the back buffer is overwritten.
+func (g *Game) markChunks() {
+	c := &g.chunks
+	h, w := len(g.State), len(g.State[0])
+	cw, ch := (w+chunkSize-1)/chunkSize, (h+chunkSize-1)/chunkSize
+	if c.w != cw || c.h != ch {
+		c.w, c.h = cw, ch
+		c.active = make([]bool, cw*ch)
+		c.stirred = make([]bool, cw*ch)
+		c.after = nil
+	}
+	if c.after != &g.State[0][0] || len(g.back) != h || len(g.back[0]) != w || c.gravity != g.Gravity || c.params != g.Params {
+		for i := range c.active {
+			c.active[i] = true
+		}
+		return
+	}
+
+	clear(c.stirred)
+	for cy := range ch {
+		for cx := range cw {
+			c.stirred[cy*cw+cx] = g.chunkStirred(cx, cy)
+		}
+	}
+	for cy := range ch {
+		for cx := range cw {
+			on := false
+			for ny := max(cy-1, 0); ny <= min(cy+1, ch-1) && !on; ny++ {
+				for nx := max(cx-1, 0); nx <= min(cx+1, cw-1) && !on; nx++ {
+					on = c.stirred[ny*cw+nx]
+				}
+			}
+			c.active[cy*cw+cx] = on
+		}
+	}
+}
+
+// chunkStirred reports whether any cell of the chunk differs from the back
+// buffer in a way the flow rules care about
+func (g *Game) chunkStirred(cx, cy int) bool {
+	for y := cy * chunkSize; y < min((cy+1)*chunkSize, len(g.State)); y++ {
+		for x := cx * chunkSize; x < min((cx+1)*chunkSize, len(g.State[y])); x++ {
+			d, was := &g.State[y][x], &g.back[y][x]
+			if math.Abs(d.Volume-was.Volume) > chunkStill || math.Abs(d.Flux-was.Flux) > chunkStill ||
+				d.Material != was.Material || d.Pipe != was.Pipe || d.Ramp != was.Ramp || d.Fluid != was.Fluid {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// settleChunks remembers what the next update compares against, once
+// State holds the result of this one
+func (g *Game) settleChunks() {
+	g.chunks.after = &g.State[0][0]
+	g.chunks.gravity = g.Gravity
+	g.chunks.params = g.Params
+}
+
+// chunkActive reports whether the flow rules visit the cell at (x, y)
+func (g *Game) chunkActive(x, y int) bool {
+	return g.chunks.active[(y/chunkSize)*g.chunks.w+x/chunkSize]
+}
+
+// ActiveChunks returns how many chunks the last update ran the flow rules
+// on, out of how many there are
+func (g *Game) ActiveChunks() (active, total int) {
+	for _, on := range g.chunks.active {
+		if on {
+			active++
+		}
+	}
+	return active, len(g.chunks.active)
+}
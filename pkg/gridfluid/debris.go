@@ -0,0 +1,125 @@
+package gridfluid
+
+import "math"
+
+/*
+* Floating debris
+*
+* Debris are lightweight entities that float on the grid water. Buoyancy
+* comes from how much of their box is covered by water, and drag pulls
+* them along with the local flow. They don't affect the water.
+ */
+
+type DebrisKind int
+
+const (
+	DebrisCrate DebrisKind = iota
+	DebrisBall
+)
+
+const (
+	debrisGravity     = 0.02 // Downward acceleration (cells per tick²)
+	debrisDrag        = 0.2  // How quickly debris matches the water velocity
+	debrisDamping     = 0.98
+	debrisRestitution = 0.3
+)
+
+type Debris struct {
+	Kind    DebrisKind
+	Pos     Vector  // Top-left corner in cells
+	Vel     Vector  // Cells per tick
+	Size    float64 // Width and height in cells
+	Density float64 // Relative to water, below 1 floats
+}
+
+// AddDebris drops a floating object with its centre at (x, y)
+func (g *Game) AddDebris(kind DebrisKind, x, y float64) {
+	size, density := 2.0, 0.5
+	if kind == DebrisBall {
+		size, density = 1.5, 0.3
+	}
+	g.debris = append(g.debris, &Debris{
+		Kind:    kind,
+		Pos:     Vector{x - size/2, y - size/2},
+		Size:    size,
+		Density: density,
+	})
+}
+
+// Debris lists the objects floating in the scene
+func (g *Game) Debris() []*Debris {
+	return g.debris
+}
+
+// sampleWater returns the fraction of the box covered by water and the
+// average velocity of that water
+func (g *Game) sampleWater(pos Vector, size float64) (float64, Vector) {
+	var covered, area float64
+	var flow Vector
+	for y := int(math.Floor(pos.Y)); y < int(math.Ceil(pos.Y+size)); y++ {
+		for x := int(math.Floor(pos.X)); x < int(math.Ceil(pos.X+size)); x++ {
+			if !g.InBounds(x, y) {
+				continue
+			}
+			d := &g.State[y][x]
+			area++
+			if d.Material.IsSolid() {
+				continue
+			}
+			v := math.Min(1.0, math.Max(0.0, d.Volume))
+			covered += v
+			flow.X += d.VX * v
+			flow.Y += d.VY * v
+		}
+	}
+	if area == 0 {
+		return 0, Vector{}
+	}
+	if covered > 0 {
+		flow.X /= covered
+		flow.Y /= covered
+	}
+	return covered / area, flow
+}
+
+// collides reports whether a box at pos overlaps an obstacle or the edge
+func (g *Game) collides(pos Vector, size float64) bool {
+	for y := int(math.Floor(pos.Y)); y < int(math.Ceil(pos.Y+size)); y++ {
+		for x := int(math.Floor(pos.X)); x < int(math.Ceil(pos.X+size)); x++ {
+			if !g.InBounds(x, y) || g.State[y][x].Material.IsSolid() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// updateDebris applies gravity, buoyancy against the local water and drag
+func (g *Game) updateDebris() {
+	for _, d := range g.debris {
+		submerged, flow := g.sampleWater(d.Pos, d.Size)
+
+		gx, gy := g.Gravity.Vector()
+		weight := debrisGravity - debrisGravity*submerged/d.Density
+		d.Vel.X += gx * weight
+		d.Vel.Y += gy * weight
+		d.Vel.X += (flow.X - d.Vel.X) * debrisDrag * submerged
+		d.Vel.Y += (flow.Y - d.Vel.Y) * debrisDrag * submerged * 0.5
+		d.Vel.X *= debrisDamping
+		d.Vel.Y *= debrisDamping
+
+		// Move one axis at a time so debris slides along walls
+		next := Vector{d.Pos.X + d.Vel.X, d.Pos.Y}
+		if g.collides(next, d.Size) {
+			d.Vel.X = -d.Vel.X * debrisRestitution
+		} else {
+			d.Pos = next
+		}
+		next = Vector{d.Pos.X, d.Pos.Y + d.Vel.Y}
+		if g.collides(next, d.Size) {
+			d.Vel.Y = -d.Vel.Y * debrisRestitution
+		} else {
+			d.Pos = next
+		}
+	}
+}
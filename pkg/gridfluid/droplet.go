@@ -0,0 +1,56 @@
+package gridfluid
+
+/*
+* Single droplets
+*
+* Number keys pick a droplet size from 0.1 (1) to 1.0 (0) and Space drops
+* one droplet of that size into the cell under the cursor, for precise
+* small-scale experiments like a single droplet on a dry ledge.
+ */
+
+// DropWater adds a droplet of fresh water to a cell and returns how much
+// fit. Water already in the cell keeps its fluid.
+func (g *Game) DropWater(x, y int, volume float64) float64 {
+	if !g.InBounds(x, y) {
+		return 0
+	}
+	d := &g.State[y][x]
+	if d.Material.IsSolid() {
+		return 0
+	}
+	amount := min(volume, remainder(*d, 1.0))
+	if amount <= 0 {
+		return 0
+	}
+	if d.Volume <= 0 {
+		d.Fluid = FluidWater
+		d.Dye = Dye{}
+	}
+	mixDye(&d.Dye, d.Volume, Dye{}, amount)
+	mixTemperature(&d.Temperature, d.Volume, ambientTemperature, amount)
+	mixStagnation(&d.Stagnation, d.Volume, 0, amount)
+	mixConcentration(&d.Acidity, d.Volume, 0, amount)
+	mixConcentration(&d.Salinity, d.Volume, 0, amount)
+	mixConcentration(&d.Sediment, d.Volume, 0, amount)
+	d.Volume += amount
+	relabelAcid(d)
+	return amount
+}
+
+// TakeWater removes up to volume of fluid from a cell and returns how much
+// it took
+func (g *Game) TakeWater(x, y int, volume float64) float64 {
+	if !g.InBounds(x, y) {
+		return 0
+	}
+	d := &g.State[y][x]
+	amount := min(volume, max(d.Volume, 0))
+	if amount <= 0 {
+		return 0
+	}
+	d.Volume -= amount
+	if d.Volume <= 0 {
+		d.Volume, d.VX, d.VY = 0, 0, 0
+	}
+	return amount
+}
@@ -0,0 +1,80 @@
+package gridfluid
+
+import "math"
+
+/*
+* Dye
+*
+* Dye is a passive tracer carried by the water. Each cell stores a dye
+* colour and its concentration; whenever volume moves, the dye goes with it
+* and colours blend weighted by the amount of dye on either side.
+ */
+
+type Dye struct {
+	R, G, B float64 // Dye colour, 0.0 to 1.0 per channel
+	Amount  float64 // Concentration, 0.0 (clear) to 1.0 (saturated)
+}
+
+// mixDye blends amount of incoming water with dye src into a cell already
+// holding volume of water with dye dst
+func mixDye(dst *Dye, volume float64, src Dye, amount float64) {
+	total := volume + amount
+	if amount <= 0 || total <= 0 {
+		return
+	}
+	dstMass := dst.Amount * math.Max(volume, 0)
+	srcMass := src.Amount * amount
+	if dstMass+srcMass > 0 {
+		dst.R = (dst.R*dstMass + src.R*srcMass) / (dstMass + srcMass)
+		dst.G = (dst.G*dstMass + src.G*srcMass) / (dstMass + srcMass)
+		dst.B = (dst.B*dstMass + src.B*srcMass) / (dstMass + srcMass)
+	}
+	dst.Amount = (dstMass + srcMass) / total
+}
+
+// InjectDye saturates the water around (cx, cy) with the given dye
+func (g *Game) InjectDye(cx, cy int, dye Dye) {
+	for y := cy - 1; y <= cy+1; y++ {
+		for x := cx - 1; x <= cx+1; x++ {
+			if !g.InBounds(x, y) {
+				continue
+			}
+			d := &g.State[y][x]
+			if d.Material.IsSolid() || d.Volume <= WetThreshold {
+				continue
+			}
+			d.Dye = dye
+		}
+	}
+}
+
+// diffuseDye lets dye spread slowly between touching water cells
+func (g *Game) diffuseDye(state *[][]Droplet) {
+	rate := g.Params.DyeDiffusion
+	if rate <= 0 {
+		return
+	}
+	blend := func(a, b *Droplet) {
+		if a.Material.IsSolid() || b.Material.IsSolid() || a.Volume <= WetThreshold || b.Volume <= WetThreshold {
+			return
+		}
+		if a.Dye.Amount == 0 && b.Dye.Amount == 0 {
+			return
+		}
+		// Exchange a small amount of water's worth of dye both ways
+		amount := rate * math.Min(a.Volume, b.Volume)
+		da, db := a.Dye, b.Dye
+		mixDye(&a.Dye, a.Volume-amount, db, amount)
+		mixDye(&b.Dye, b.Volume-amount, da, amount)
+	}
+	for y := range *state {
+		for x := range (*state)[y] {
+			if x+1 < len((*state)[y]) {
+				blend(&(*state)[y][x], &(*state)[y][x+1])
+			}
+			if y+1 < len(*state) {
+				blend(&(*state)[y][x], &(*state)[y+1][x])
+			}
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package gridfluid
+
+/*
+* Events
+*
+* An application embedding the sim can register callbacks for what happens
+* in it instead of polling the grid:
+*
+*	OnCellOverflow       a cell of the focused layer filled to the brim
+*	OnRegionFilled       the water in a rectangle reached a share of its open cells
+*	OnObstacleDestroyed  an obstacle burnt, melted, dissolved, was eaten or
+*	                     blown away, or was removed between updates
+*	OnGeneratorEmpty     a spring with a limited supply ran dry, see SetSpringSupply
+*	OnImpact             water in the focused layer landed fast enough to
+*	                     splash, whether or not it threw any up
+*
+* Events are gathered during an update and delivered once it is over, in
+* grid order, so a callback may change the world freely. Cells and regions
+* that filled up have to drain by eventSlack before they fire again, so
+* water lapping at the brim doesn't fire every update. Nothing is tracked
+* until a callback is registered, and loading, resampling or refocusing
+* the grid starts the tracking afresh rather than firing for everything
+* that changed.
+ */
+
+const (
+	fullVolume = 0.999 // Volume at which a cell counts as full
+	eventSlack = 0.05  // Share below a threshold at which it lets go
+)
+
+type regionHook struct {
+	x, y, w, h int
+	threshold  float64
+	filled     bool
+	fn         func(fill float64)
+}
+
+// events holds the callbacks and what they are compared against
+type events struct {
+	overflow       []func(x, y int)
+	destroyed      []func(x, y int, material MaterialID)
+	generatorEmpty []func(x, y int)
+	impact         []func(x, y int, speed float64)
+	regions        []*regionHook
+
+	full     [][]bool       // Cells full after the last update
+	obstacle [][]MaterialID // Solid material of every cell after the last update
+	dry      [][2]int       // Springs that ran dry during this update
+	impacts  []impact       // Landings during this update
+	quiet    bool           // Stepping a layer out of focus, landings aren't gathered
+}
+
+type impact struct {
+	x, y  int
+	speed float64
+}
+
+// OnCellOverflow calls fn for every cell that fills to the brim
+func (g *Game) OnCellOverflow(fn func(x, y int)) {
+	g.events.overflow = append(g.events.overflow, fn)
+	g.events.full = nil
+}
+
+// OnRegionFilled calls fn once the water in the w by h cells at (x, y)
+// fills threshold (0 to 1) of their open cells, with the share filled
+func (g *Game) OnRegionFilled(x, y, w, h int, threshold float64, fn func(fill float64)) {
+	g.events.regions = append(g.events.regions, &regionHook{x: x, y: y, w: w, h: h, threshold: threshold, fn: fn})
+}
+
+// OnObstacleDestroyed calls fn for every obstacle cell that opens up, with
+// the material it was made of
+func (g *Game) OnObstacleDestroyed(fn func(x, y int, material MaterialID)) {
+	g.events.destroyed = append(g.events.destroyed, fn)
+	g.events.obstacle = nil
+}
+
+// OnGeneratorEmpty calls fn for every spring that runs dry
+func (g *Game) OnGeneratorEmpty(fn func(x, y int)) {
+	g.events.generatorEmpty = append(g.events.generatorEmpty, fn)
+}
+
+// OnImpact calls fn for every cell where water lands fast enough to
+// splash, with its speed in cells per tick
+func (g *Game) OnImpact(fn func(x, y int, speed float64)) {
+	g.events.impact = append(g.events.impact, fn)
+}
+
+// SetSpringSupply limits the spring at (x, y) to volume more water, after
+// which it stops being a spring. Zero makes it endless again.
+func (g *Game) SetSpringSupply(x, y int, volume float64) bool {
+	if !g.InBounds(x, y) || !g.State[y][x].Spring {
+		return false
+	}
+	g.State[y][x].Supply = max(volume, 0)
+	return true
+}
+
+// resetEvents forgets the grid the events are compared against, after the
+// grid was replaced
+func (g *Game) resetEvents() {
+	g.events.full, g.events.obstacle = nil, nil
+}
+
+// fireEvents delivers the events of the update that just ended
+func (g *Game) fireEvents() {
+	e := &g.events
+	dry := e.dry
+	e.dry = nil
+	for _, fn := range e.generatorEmpty {
+		for _, cell := range dry {
+			fn(cell[0], cell[1])
+		}
+	}
+	impacts := e.impacts
+	e.impacts = nil
+	for _, fn := range e.impact {
+		for _, i := range impacts {
+			fn(i.x, i.y, i.speed)
+		}
+	}
+
+	if len(e.overflow) > 0 {
+		var overflowed [][2]int
+		var fresh bool
+		e.full, fresh = trackCells(e.full, g.State, func(x, y int, was bool) bool {
+			d := &g.State[y][x]
+			switch {
+			case d.Material.IsSolid():
+				return false
+			case was:
+				return d.Volume >= fullVolume*(1-eventSlack)
+			case d.Volume >= fullVolume:
+				overflowed = append(overflowed, [2]int{x, y})
+				return true
+			}
+			return false
+		})
+		if fresh {
+			overflowed = nil
+		}
+		for _, fn := range e.overflow {
+			for _, cell := range overflowed {
+				fn(cell[0], cell[1])
+			}
+		}
+	}
+
+	if len(e.destroyed) > 0 {
+		type destroyed struct {
+			x, y     int
+			material MaterialID
+		}
+		var lost []destroyed
+		e.obstacle, _ = trackCells(e.obstacle, g.State, func(x, y int, was MaterialID) MaterialID {
+			d := &g.State[y][x]
+			// Movers and gates open and close without anything being lost
+			if d.Moving || d.Gate {
+				return MaterialOpen
+			}
+			if d.Material.IsSolid() {
+				return d.Material
+			}
+			if was != MaterialOpen {
+				lost = append(lost, destroyed{x, y, was})
+			}
+			return MaterialOpen
+		})
+		for _, fn := range e.destroyed {
+			for _, c := range lost {
+				fn(c.x, c.y, c.material)
+			}
+		}
+	}
+
+	for _, r := range e.regions {
+		fill := g.regionFill(r.x, r.y, r.w, r.h)
+		if r.filled {
+			r.filled = fill >= r.threshold*(1-eventSlack)
+			continue
+		}
+		if fill >= r.threshold {
+			r.filled = true
+			r.fn(fill)
+		}
+	}
+}
+
+// trackCells updates the value kept for every cell with update, given the
+// value it had. A grid that doesn't match the state is started afresh from
+// zero values and reported as fresh, so what update found is no event.
+func trackCells[T any](cells [][]T, state [][]Droplet, update func(x, y int, was T) T) ([][]T, bool) {
+	fresh := len(cells) != len(state) || len(cells) == 0 || len(cells[0]) != len(state[0])
+	if fresh {
+		cells = make([][]T, len(state))
+		for y := range cells {
+			cells[y] = make([]T, len(state[y]))
+		}
+	}
+	for y := range cells {
+		for x := range cells[y] {
+			cells[y][x] = update(x, y, cells[y][x])
+		}
+	}
+	return cells, fresh
+}
+
+// regionFill is the share of the open cells in a rectangle filled with
+// water
+func (g *Game) regionFill(x, y, w, h int) float64 {
+	volume, open := 0.0, 0
+	for cy := max(y, 0); cy < min(y+h, len(g.State)); cy++ {
+		for cx := max(x, 0); cx < min(x+w, len(g.State[cy])); cx++ {
+			if d := &g.State[cy][cx]; !d.Material.IsSolid() {
+				volume += max(d.Volume, 0)
+				open++
+			}
+		}
+	}
+	if open == 0 {
+		return 0
+	}
+	return volume / float64(open)
+}
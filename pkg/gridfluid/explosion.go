@@ -0,0 +1,44 @@
+package gridfluid
+
+import "math"
+
+/*
+* Explosions
+ */
+
+const explosionForce = 1.0 // Outward speed given to water at the centre
+
+// Explode removes all obstacle cells within radius of (cx, cy) and flings the
+// water around it outward. Water further away is pushed less.
+func (g *Game) Explode(cx, cy, radius int) {
+	reach := radius * 2
+	for y := cy - reach; y <= cy+reach; y++ {
+		for x := cx - reach; x <= cx+reach; x++ {
+			if y < 0 || y >= len(g.State) || x < 0 || x >= len(g.State[0]) {
+				continue
+			}
+			dx, dy := float64(x-cx), float64(y-cy)
+			dist := math.Hypot(dx, dy)
+			if dist > float64(reach) {
+				continue
+			}
+
+			d := &g.State[y][x]
+			if dist <= float64(radius) && d.Ice {
+				// Shattered ice is flung around as water
+				d.Material, d.Ice, d.Snow = MaterialOpen, false, false
+			}
+			if dist <= float64(radius) && d.Material.IsSolid() && !d.Moving {
+				clearObstacle(d)
+				continue
+			}
+			if d.Material.IsSolid() || d.Volume <= 0 || dist == 0 {
+				continue
+			}
+
+			strength := explosionForce * (1 - dist/float64(reach))
+			d.VX += dx / dist * strength
+			d.VY += dy / dist * strength
+		}
+	}
+}
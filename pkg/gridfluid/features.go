@@ -0,0 +1,98 @@
+package gridfluid
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+* Features
+*
+* Optional subsystems register themselves here, so a program can list what
+* it was built with. Which of them run is up to each Game: DisableFeatures
+* switches some off for that game alone, so two games in one program can
+* differ, and the startup log lists what ended up enabled. Build tags only
+* decide what is compiled in; gridSim's `-tags noexport` leaves out its PNG
+* and zip exporters, while an embedder after just the simulation imports
+* this package, which draws nothing and exports nothing.
+ */
+
+// features maps every compiled in subsystem to what it does
+var features = map[string]string{
+	"splash": "splash particles thrown up by fast impacts",
+	"foam":   "turbulence foam speckle",
+	"teach":  "step-by-step teaching mode",
+}
+
+// RegisterFeature adds an optional subsystem, called from the init of the
+// files behind its build tag
+func RegisterFeature(name, about string) {
+	features[name] = about
+}
+
+// FeatureEnabled reports whether a subsystem is compiled in and enabled for
+// this game
+func (g *Game) FeatureEnabled(name string) bool {
+	_, ok := features[name]
+	return ok && !g.disabled[name]
+}
+
+// FeatureOffError is returned by subsystems that are compiled out or disabled
+func FeatureOffError(name string) error {
+	if _, ok := features[name]; !ok {
+		return fmt.Errorf("%s support is not compiled into this binary", name)
+	}
+	return fmt.Errorf("%s is disabled", name)
+}
+
+// DisableFeatures switches off a comma separated list of features for this
+// game
+func (g *Game) DisableFeatures(list string) error {
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := features[name]; !ok {
+			return fmt.Errorf("unknown or not compiled in feature %q", name)
+		}
+		if g.disabled == nil {
+			g.disabled = map[string]bool{}
+		}
+		g.disabled[name] = true
+	}
+	return nil
+}
+
+// DisabledFeatures is the comma separated list of features switched off
+// for this game, as DisableFeatures takes it
+func (g *Game) DisabledFeatures() string {
+	names := make([]string, 0, len(g.disabled))
+	for name := range g.disabled {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// FeaturesReport lists every compiled in feature and whether it is enabled
+// for this game
+func (g *Game) FeaturesReport() string {
+	names := make([]string, 0, len(features))
+	for name := range features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("features:")
+	for _, name := range names {
+		state := "on"
+		if !g.FeatureEnabled(name) {
+			state = "off"
+		}
+		fmt.Fprintf(&b, "\n  %-8s %-3s %s", name, state, features[name])
+	}
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package gridfluid
+
+/*
+* Fire
+*
+* Wooden obstacles are flammable. A burning cell slowly chars away and,
+* once burnt through, leaves an open cell behind. Every tick the fire may
+* jump to the wood around it, though soaked wood resists. Water touching a
+* burning cell puts it out and boils off a little of itself as steam.
+ */
+
+const (
+	fireSpread    = 0.6  // Chance per second that fire jumps to a neighbouring piece of wood
+	fireBurnRate  = 0.05 // Share of a wooden cell burnt away per second
+	fireWetResist = 0.3  // Wetness above which wood won't catch
+	fireSteamCost = 0.05 // Water boiled off when it puts out a burning cell
+	fireHeat      = 2.0  // °C per tick added to water next to a fire
+)
+
+// CreateWoodBlock fills a w by h rectangle with flammable wooden obstacles
+func CreateWoodBlock(x, y, w, h int, state *[][]Droplet) {
+	for cy := y; cy < y+h && cy < len(*state); cy++ {
+		for cx := x; cx < x+w && cx < len((*state)[cy]); cx++ {
+			d := &(*state)[cy][cx]
+			d.Material = MaterialWood
+			d.Volume = 0
+		}
+	}
+}
+
+// Ignite sets the wood at (x, y) on fire
+func (g *Game) Ignite(x, y int) bool {
+	if !g.InBounds(x, y) {
+		return false
+	}
+	d := &g.State[y][x]
+	if d.Material != MaterialWood || d.Moving {
+		return false
+	}
+	d.Fire = 1
+	return true
+}
+
+// updateFire spreads, feeds and puts out fires
+func (g *Game) updateFire(state *[][]Droplet) {
+	// Catch after the sweep so fire crawls at most one cell per tick
+	var ignite [][2]int
+	for y := range *state {
+		for x := range (*state)[y] {
+			d := &(*state)[y][x]
+			if d.Fire <= 0 {
+				continue
+			}
+			if g.quench(x, y, state) {
+				d.Fire = 0
+				continue
+			}
+
+			for _, dpos := range pressureDirections {
+				nx, ny, ok := g.neighbor(x, y, dpos[0], dpos[1], state)
+				if !ok {
+					continue
+				}
+				n := &(*state)[ny][nx]
+				if n.Material != MaterialWood || n.Fire > 0 || n.Wetness > fireWetResist {
+					continue
+				}
+				if g.rng.Float64() < fireSpread/ticksPerSecond {
+					ignite = append(ignite, [2]int{nx, ny})
+				}
+			}
+
+			d.Burnt += fireBurnRate / ticksPerSecond
+			if d.Burnt >= 1 {
+				// Burnt through, nothing left to hold water back
+				*d = Droplet{Size: d.Size, Temperature: d.Temperature}
+			}
+		}
+	}
+	for _, c := range ignite {
+		(*state)[c[1]][c[0]].Fire = 1
+	}
+}
+
+// quench boils off water touching the burning cell at (x, y) and reports
+// whether there was any
+func (g *Game) quench(x, y int, state *[][]Droplet) bool {
+	quenched := false
+	for _, dpos := range pressureDirections {
+		nx, ny, ok := g.neighbor(x, y, dpos[0], dpos[1], state)
+		if !ok {
+			continue
+		}
+		n := &(*state)[ny][nx]
+		if n.Material.IsSolid() || n.Volume <= WetThreshold {
+			continue
+		}
+		if !quenched {
+			g.boil(nx, ny, fireSteamCost, state)
+		}
+		n.Temperature += fireHeat
+		quenched = true
+	}
+	return quenched
+}
@@ -0,0 +1,124 @@
+package gridfluid
+
+import "math"
+
+/*
+* Fluids
+ */
+
+// Fluid identifies which liquid a cell holds. Each cell holds predominantly
+// one fluid; how much a different fluid may push into it is limited by the
+// interface tension parameter.
+type Fluid int
+
+const (
+	FluidWater Fluid = iota
+	FluidOil
+	FluidAcid
+)
+
+type fluidProps struct {
+	name    string
+	density float64 // Relative to water, lighter fluids rise through heavier ones
+}
+
+var fluids = []fluidProps{
+	FluidWater: {"water", 1.0},
+	FluidOil:   {"oil", 0.8},
+	FluidAcid:  {"acid", 1.0},
+}
+
+func (f Fluid) density() float64 { return fluids[f].density }
+
+// Name is the fluid's name, as LookupFluid takes it
+func (f Fluid) Name() string { return fluids[f].name }
+
+// LookupFluid finds a fluid by name
+func LookupFluid(name string) (Fluid, bool) {
+	for i, f := range fluids {
+		if f.name == name {
+			return Fluid(i), true
+		}
+	}
+	return 0, false
+}
+
+// moveVolume moves up to amount of fluid from current into target, carrying
+// momentum along and applying the immiscibility rule. Returns the amount moved.
+func (g *Game) moveVolume(current, target *Droplet, amount float64) float64 {
+	if amount == 0 {
+		return 0
+	}
+
+	// Porous materials slow down flow through either side
+	amount *= min(g.conductance(current), g.conductance(target))
+	return g.transferVolume(current, target, amount)
+}
+
+// transferVolume moves amount of fluid from current into target regardless
+// of what fills the cells, applying the immiscibility rule. Returns the
+// amount moved.
+func (g *Game) transferVolume(current, target *Droplet, amount float64) float64 {
+	if amount == 0 {
+		return 0
+	}
+
+	// Different fluids resist mixing, at full tension they never share a cell
+	if target.Volume > WetThreshold && !miscible(target.Fluid, current.Fluid) {
+		amount *= 1 - g.Params.InterfaceTension
+		if amount == 0 {
+			return 0
+		}
+	}
+
+	// The majority fluid decides the label of the cell
+	if target.Fluid != current.Fluid && amount > target.Volume {
+		target.Fluid = current.Fluid
+	}
+
+	carryMomentum(current, target, amount)
+	mixDye(&target.Dye, target.Volume, current.Dye, amount)
+	mixTemperature(&target.Temperature, target.Volume, current.Temperature, amount)
+	mixStagnation(&target.Stagnation, target.Volume, current.Stagnation, amount)
+	mixConcentration(&target.Acidity, target.Volume, current.Acidity, amount)
+	mixConcentration(&target.Salinity, target.Volume, current.Salinity, amount)
+	mixConcentration(&target.Sediment, target.Volume, current.Sediment, amount)
+	current.Volume -= amount
+	target.Volume += amount
+	relabelAcid(target)
+	target.Flux += math.Max(amount, 0)
+	return amount
+}
+
+// separateFluids lets lighter fluids rise through heavier ones by swapping
+// cells adjacent along gravity, so stacked fluids settle into layers. Salt
+// makes water heavier, so brine sinks below fresh water too.
+func (g *Game) separateFluids(state *[][]Droplet) {
+	dx, dy := g.Gravity.toGrid(0, 1)
+	for y := range *state {
+		for x := range (*state)[y] {
+			bx, by, ok := g.below(x, y, state)
+			if !ok {
+				continue
+			}
+			upper := &(*state)[y][x]
+			lower := &(*state)[by][bx]
+			if upper.Material.IsSolid() || lower.Material.IsSolid() || !connected(upper, lower, dx, dy) || upper.Volume <= WetThreshold || lower.Volume <= WetThreshold {
+				continue
+			}
+			if cellDensity(upper) <= cellDensity(lower)+densityEpsilon {
+				continue
+			}
+			upper.Fluid, lower.Fluid = lower.Fluid, upper.Fluid
+			upper.Volume, lower.Volume = lower.Volume, upper.Volume
+			upper.VX, lower.VX = lower.VX, upper.VX
+			upper.VY, lower.VY = lower.VY, upper.VY
+			upper.Dye, lower.Dye = lower.Dye, upper.Dye
+			upper.Temperature, lower.Temperature = lower.Temperature, upper.Temperature
+			upper.Stagnation, lower.Stagnation = lower.Stagnation, upper.Stagnation
+			upper.Acidity, lower.Acidity = lower.Acidity, upper.Acidity
+			upper.Salinity, lower.Salinity = lower.Salinity, upper.Salinity
+			upper.Sediment, lower.Sediment = lower.Sediment, upper.Sediment
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package gridfluid
+
+import "math"
+
+/*
+* Flux accumulation
+ */
+
+// MaxFlux returns the largest accumulated flux in the grid, used to
+// normalise the heat map
+func (g *Game) MaxFlux() float64 {
+	maxF := 0.0
+	for y := range g.State {
+		for x := range g.State[y] {
+			maxF = math.Max(maxF, g.State[y][x].Flux)
+		}
+	}
+	return maxF
+}
+
+// ResetFlux clears the accumulated flow so a new measurement can start
+func (g *Game) ResetFlux() {
+	for y := range g.State {
+		for x := range g.State[y] {
+			g.State[y][x].Flux = 0
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package gridfluid
+
+import "math"
+
+/*
+* Foam / turbulence
+*
+* Foam appears where fast water slams into obstacles or into a pool, and
+* wherever the flow spreads apart or converges sharply (high velocity
+* divergence). It shows as white speckle and fades within a couple of
+* seconds.
+ */
+
+const (
+	foamDecay       = 0.97 // Per-frame decay, about two seconds to fade out
+	foamDivergence  = 0.3  // Divergence at which foam starts forming
+	foamImpactSpeed = 0.4  // Speed into a blocked cell at which foam forms
+	foamGain        = 0.5  // Foam added per unit of excess turbulence
+)
+
+// divergence estimates the velocity divergence at (x, y) with central
+// differences, treating obstacles and dry cells as still water
+func (g *Game) divergence(x, y int, state *[][]Droplet) float64 {
+	velocity := func(dx, dy int) (float64, float64) {
+		nx, ny, ok := g.neighbor(x, y, dx, dy, state)
+		if !ok {
+			return 0, 0
+		}
+		n := (*state)[ny][nx]
+		if n.Material.IsSolid() || n.Volume <= WetThreshold {
+			return 0, 0
+		}
+		return n.VX, n.VY
+	}
+	right, _ := velocity(1, 0)
+	left, _ := velocity(-1, 0)
+	_, down := velocity(0, 1)
+	_, up := velocity(0, -1)
+	return (right-left)/2 + (down-up)/2
+}
+
+// impact returns how hard the water in (x, y) runs into whatever is ahead
+// of it: an obstacle or a pool
+func (g *Game) impact(x, y int, state *[][]Droplet) float64 {
+	d := (*state)[y][x]
+	speed := math.Hypot(d.VX, d.VY)
+	if speed < foamImpactSpeed {
+		return 0
+	}
+	dx, dy := 0, sign(d.VY)
+	if math.Abs(d.VX) > math.Abs(d.VY) {
+		dx, dy = sign(d.VX), 0
+	}
+	nx, ny, ok := g.neighbor(x, y, dx, dy, state)
+	if !ok || (*state)[ny][nx].Material.IsSolid() || (*state)[ny][nx].Volume > 0.5 {
+		return speed
+	}
+	return 0
+}
+
+// updateFoam spawns foam in turbulent cells and lets existing foam decay
+func (g *Game) updateFoam(state *[][]Droplet) {
+	if !g.FeatureEnabled("foam") {
+		return
+	}
+	for y := range *state {
+		for x := range (*state)[y] {
+			d := &(*state)[y][x]
+			if d.Material.IsSolid() || d.Volume <= WetThreshold {
+				d.Foam = 0
+				continue
+			}
+			d.Foam *= foamDecay
+
+			turbulence := math.Max(math.Abs(g.divergence(x, y, state))-foamDivergence, 0)
+			if hit := g.impact(x, y, state); hit > 0 {
+				turbulence = math.Max(turbulence, hit-foamImpactSpeed+0.1)
+			}
+			d.Foam = math.Min(1, d.Foam+turbulence*foamGain)
+		}
+	}
+}
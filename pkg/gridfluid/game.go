@@ -0,0 +1,454 @@
+// Package gridfluid is the cellular water simulation behind gridSim. It
+// holds the grid, its features and the update rules, and knows nothing
+// about drawing or input, so it is itself the simulation-only build: an
+// embedder importing it gets no window, audio or exporters.
+package gridfluid
+
+import (
+	"math/rand/v2"
+	"runtime"
+	"time"
+)
+
+/*
+* Droplets
+ */
+
+type Droplet struct {
+	Volume    float64 // How much water this cell contains (0.0 to 1.0)
+	Size      int
+	Fluid     Fluid      // Which fluid the volume is made of
+	Material  MaterialID // What fills the cell, see material.go
+	Ramp      Ramp       // Half tile slope, only meaningful for obstacles
+	Pipe      Pipe       // Openings of an enclosed pipe segment
+	Moving    bool       // Obstacle stamped by a mover rather than static terrain
+	Salt      bool       // Soluble obstacle, see salt.go
+	Vortex    bool       // Open cell that swirls and drains water, see vortex.go
+	Fan       Fan        // Direction a fan obstacle blows, see fan.go
+	FanOn     bool       // Whether the fan is running
+	Portal    uint8      // Channel linking the cell to its twin, see portal.go
+	Heater    bool       // Obstacle warming what touches it, see heater.go
+	Ice       bool       // Frozen water, an obstacle that keeps its volume
+	Thawed    float64    // Share of the latent heat a melting ice cell has taken in
+	Snow      bool       // Ice packed from snowflakes, see weather.go
+	Drift     float64    // Depth of the snow settled on an open cell (0.0 to 1.0)
+	Connector bool       // Open cell passing water to the next layer, see layers.go
+	Air       float64    // Air held, in cell volumes at atmospheric pressure, see air.go
+	Vent      bool       // Obstacle blowing bubbles into the water, see bubbles.go
+	Sensor    SensorKind // Plate or float switch on an open cell, see signals.go
+	Signal    bool       // Whether the sensor is switched on
+	Gate      bool       // Obstacle that opens while its wires carry a signal
+	Spring    bool       // Open cell that fills with water while running
+	SpringOn  bool       // Whether the spring is running
+	Supply    float64    // Water a spring has left to give, endless at zero
+
+	VX, VY   float64 // Velocity components
+	Pressure float64 // hydrostatic pressure
+
+	Wetness  float64 // Recent contact with water, decays once dry (0.0 to 1.0)
+	FoamLine float64 // Fading mark left where the surface recently was
+	Foam     float64 // Short-lived turbulence foam (0.0 to 1.0)
+	Flux     float64 // Total volume that has flowed into this cell
+	Wave     float64 // Surface flow through the right face, kept between ticks
+	Dye      Dye     // Passive colour tracer carried with the volume
+
+	Temperature float64 // °C, carried with the volume
+	Stagnation  float64 // Updates the water has been sitting still, carried with the volume
+	Growth      float64 // Water a plant has drunk and not yet used, see plants.go
+	Fire        float64 // Burning when above zero, only on wood
+	Burnt       float64 // Share of a wooden obstacle burnt away (0.0 to 1.0)
+	Acidity     float64 // Acid concentration of the volume (0.0 to 1.0)
+	Eroded      float64 // Share of an obstacle eaten away by acid (0.0 to 1.0)
+	Salinity    float64 // Dissolved salt concentration of the volume (0.0 to 1.0)
+	Dissolved   float64 // Share of a salt block dissolved away (0.0 to 1.0)
+	Sediment    float64 // Suspended sand concentration of the volume (0.0 to 1.0)
+	Settled     float64 // Sand settled on the floor of an open cell
+	Scoured     float64 // Share of a sand obstacle worn away (0.0 to 1.0)
+	Moss        float64 // Moss cover on a wet obstacle (0.0 to 1.0), see moss.go
+}
+
+func CreateWaterGenerator(x, y, tileSize int, state *[][]Droplet) {
+	for xOffset := 0; xOffset <= 4; xOffset++ {
+		droplet := Droplet{Size: tileSize, Volume: 1.0}
+		(*state)[y][x+xOffset] = droplet
+	}
+}
+
+/*
+* Game / GameState
+ */
+
+type Game struct {
+	Width    int
+	Height   int
+	State    [][]Droplet // 2D grid of droplets
+	back     [][]Droplet // Grid the next update is written into, then swapped with State
+	snapshot [][]Droplet // Scratch copy of the grid advection reads from
+	chunks   chunkMap    // Parts of the grid the flow rules visit, see chunks.go
+	tileSize int
+
+	Params  Params  // Solver tuning, see params.go
+	Wind    Vector  // Global wind acting on exposed water
+	Gravity Gravity // Direction water falls in
+	Weather Weather // Rain or snow falling from the open sky
+	Sponge  Sponge  // Absorbing bands along the edges
+
+	Boundary   [EdgeCount]BoundaryMode // How each grid edge treats water
+	Outflow    [EdgeCount]float64      // Volume that left through each open edge
+	Absorbed   float64                 // Volume drunk by plants
+	Evaporated float64                 // Volume boiled off as steam
+	Swallowed  float64                 // Volume drained by vortices
+	Supplied   float64                 // Volume added by springs, rain and snow
+	Seed       uint64                  // Seed of the random source used by the sim
+	Workers    int                     // Goroutines the flow rules run on, see parallel.go
+	TimeScale  float64                 // Sim time per real time, 1 if unset, see timestep.go
+
+	AirTemperature float64 // °C the water cools towards and the snow warms to
+
+	rng        *rand.Rand
+	pcg        *rand.PCG // Source behind rng, kept so saves can restore it
+	frame      int       // Number of updates run so far
+	behind     float64   // Seconds of real time not yet simulated, see timestep.go
+	movers     []*Mover  // Moving obstacles and platforms
+	debris     []*Debris // Floating objects carried by the water
+	wheels     []*Wheel  // Water wheels turned by the flow
+	splashes   []*Splash // Airborne water thrown up by impacts
+	steam      []*Steam  // Puffs of boiled off water
+	flakes     []*Flake  // Snowflakes still falling
+	bubbles    []*Bubble // Air rising through the water
+	pockets    []pocket  // Sealed pockets of air found this tick
+	gauges     []*Gauge  // Placeable pressure/temperature/flow readouts
+	openPortal uint8     // Channel of a portal still waiting for its twin
+	wires      []Wire    // Signal graph from sensors to devices
+	gust       float64   // Sideways speed given to new rain drops
+	gustTarget float64   // Gust the current one is easing towards
+
+	layers []Layer // Depth layers front to back, the focused one parked empty
+	layer  int     // Index of the focused layer, the one held in State
+
+	regions []*Region          // Metered rectangles, see measure.go
+	sinks   map[[2]int]float64 // Volume taken out per cell this tick
+
+	undo, redo []edit // Edits to undo and undone edits to redo, see undo.go
+
+	events   events // Callbacks of an embedding application, see events.go
+	lastFlow Flow   // Water in and out during the last update, see stats.go
+	audit    *audit // Conservation audit, nil while off, see audit.go
+
+	trace *teachTrace // Records what each rule does during a traced update
+
+	disabled map[string]bool // Features switched off for this game, see features.go
+}
+
+func NewGame(w, h, ts int) *Game {
+
+	g := &Game{Width: w, Height: h, tileSize: ts, Params: DefaultParams()}
+	g.AirTemperature = ambientTemperature
+	g.SetSeed(uint64(time.Now().UnixNano()))
+	g.Workers = runtime.NumCPU()
+
+	// Create the new game state
+	// divide pixel dimensions by tile size to get grid size
+	g.State = CreateGameState(g.Width/g.tileSize, g.Height/g.tileSize, ts)
+	return g
+}
+
+// TileSize is the width and height of a cell in pixels
+func (g *Game) TileSize() int {
+	return g.tileSize
+}
+
+// Frame is the number of updates run so far
+func (g *Game) Frame() int {
+	return g.frame
+}
+
+// SetSeed restarts the random source from seed. The sim never reads the
+// clock and walks cells, lists and maps in a fixed order, so the same seed,
+// scene and inputs give the same grid after the same number of updates.
+func (g *Game) SetSeed(seed uint64) {
+	g.Seed = seed
+	g.pcg = rand.NewPCG(seed, seed)
+	g.rng = rand.New(g.pcg)
+}
+
+// Rand is the random source the sim draws from, seeded with Seed
+func (g *Game) Rand() *rand.Rand {
+	return g.rng
+}
+
+func CreateGameState(w, h, ts int) [][]Droplet {
+	// Create the new game state
+	newState := make([][]Droplet, h)
+	// Loop through each row of the grid
+	for y := range h {
+		// Create the columns
+		newState[y] = make([]Droplet, w)
+
+		// Loop through each cell and create a new droplet
+		for x := range newState[y] {
+			newState[y][x] = Droplet{
+				Size:        ts,
+				Temperature: ambientTemperature,
+			}
+		}
+	}
+	return newState
+}
+
+func (g *Game) Update() {
+	var before float64
+	if len(g.regions) > 0 || g.audit != nil {
+		before = g.balance()
+	}
+	flow := g.flowTotals()
+
+	// Movers displace water before the flow rules see the grid
+	g.updateMovers()
+	g.blowGusts()
+	g.stepLayers()
+
+	g.updateDebris()
+	g.updateSteam()
+	g.updateGauges()
+	g.updateRegions(before)
+	g.lastFlow = g.flowTotals().since(flow)
+	g.frame++
+	g.auditUpdate(before)
+	g.fireEvents()
+}
+
+// step runs the flow rules once over the grid in State. The rules read the
+// previous state from State and write the next one into the back buffer,
+// then the two swap, so no grid is allocated per update.
+func (g *Game) step() {
+	g.markChunks()
+	newState := g.backBuffer()
+
+	// Copy current state to new state
+	for y := range g.State {
+		copy(newState[y], g.State[y])
+	}
+
+	g.computePressures(&newState)
+	g.dampenVelocity(&newState)
+	audited := g.auditPass("flow rules", &newState)
+	g.bottomUpBanded(len(g.State[0]), len(g.State), func(x, y int) {
+		// Water resting in a still chunk is left as it is
+		if !g.chunkActive(x, y) {
+			return
+		}
+		if g.State[y][x].Material.IsSolid() {
+			newState[y][x] = g.State[y][x]
+			if g.State[y][x].Ramp != RampNone {
+				g.drainRamp(x, y, &newState)
+			}
+			return
+		}
+		// Pipes have a pass of their own
+		if g.State[y][x].Pipe != PipeNone {
+			return
+		}
+		// Only process cells that contain water
+		if g.State[y][x].Volume > 0 {
+			// Check if we are at the bottom
+			if _, _, ok := g.below(x, y, &newState); ok {
+				if g.trace != nil {
+					g.traceCell(x, y, &newState)
+				} else {
+					g.processWaterCell(x, y, &newState)
+				}
+			}
+		}
+	})
+	audited()
+
+	g.runPass("pipes: pressurized flow", &newState, g.flowPipes)
+	g.runPass("portals: water comes out of the twin", &newState, g.teleport)
+	g.runPass("fluid separation: lighter fluids rise", &newState, g.separateFluids)
+	g.runPass("rain falls from the sky", &newState, g.precipitate)
+	g.runPass("wind", &newState, g.applyWind)
+	g.runPass("fans blow", &newState, g.blowFans)
+	g.runPass("vortices swirl and drain", &newState, g.swirlVortices)
+	g.runPass("water wheels", &newState, g.turnWheels)
+	g.runPass("surface waves", &newState, g.propagateWaves)
+	g.runPass("sponge absorption", &newState, g.absorbWaves)
+	g.runPass("advection: volume follows velocity", &newState, g.advect)
+	g.runPass("splashes: fast impacts throw water up", &newState, g.splash)
+	g.runPass("vents blow bubbles", &newState, g.blowVents)
+	g.runPass("air pockets: bubbles escape", &newState, g.releaseBubbles)
+	g.updateFoam(&newState)
+	updateStagnation(&newState)
+	g.runPass("dye diffusion", &newState, g.diffuseDye)
+	g.runPass("open edges drain", &newState, g.drainOpenEdges)
+	g.runPass("acid eats obstacles", &newState, g.corrode)
+	g.runPass("salt dissolves", &newState, g.dissolveSalt)
+	g.runPass("salt diffusion", &newState, g.diffuseSalt)
+	g.runPass("sediment: pickup, carry, deposit", &newState, g.transportSediment)
+	g.runPass("plants drink and grow", &newState, g.updatePlants)
+	g.runPass("moss grows on wet walls", &newState, g.growMoss)
+	g.runPass("heat conduction", &newState, g.conductHeat)
+	g.runPass("heaters, melting and boiling", &newState, g.warm)
+	g.runPass("evaporation from open surfaces", &newState, g.evaporate)
+	g.runPass("sensors drive devices", &newState, g.updateSignals)
+	updateWetness(&newState)
+	g.updateFire(&newState)
+
+	// Replace old state with new calculated state, keeping the old grid
+	// to write the next update into
+	g.State, g.back = newState, g.State
+	g.settleChunks()
+}
+
+// backBuffer returns the grid the next update is written into
+func (g *Game) backBuffer() [][]Droplet {
+	g.back = reuseGrid(g.back, g.State, g.tileSize)
+	return g.back
+}
+
+// reuseGrid returns buf to hold a copy of state, allocating a new grid only
+// when state changed size or is buf itself
+func reuseGrid(buf, state [][]Droplet, ts int) [][]Droplet {
+	h, w := len(state), len(state[0])
+	if len(buf) != h || len(buf[0]) != w || &buf[0][0] == &state[0][0] {
+		return CreateGameState(w, h, ts)
+	}
+	return buf
+}
+
+func (g *Game) processWaterCell(x, y int, newState *[][]Droplet) {
+	// Try to flow downards, as if by gravity(but not into obstacles)
+	if bx, by, ok := g.below(x, y, newState); ok && g.conductance(&(*newState)[by][bx]) > 0 && !supports(&(*newState)[by][bx], &(*newState)[y][x]) {
+		moved := g.fill(&(*newState)[y][x], &(*newState)[by][bx], 1.0, g.Params.FallRate)
+		gx, gy := g.Gravity.toGrid(0, 1)
+		g.push(&(*newState)[by][bx], gx, gy, moved)
+		if moved > 0 {
+			g.note("gravity: fall into the cell below")
+		}
+	}
+
+	// Ramps deflect falling water sideways instead of stopping it flat
+	if g.tryRampDeflection(x, y, newState) {
+		g.note("ramp: slide towards the low side")
+		if (*newState)[y][x].Volume <= 0 {
+			return
+		}
+	}
+
+	// If water can still flow down, don't try other directions yet
+	if g.canFlowDown(x, y, newState) {
+		g.note("still falling: sideways rules skipped")
+		return
+	}
+
+	current := &(*newState)[y][x]
+
+	// Water spreads sideways when blocked below
+	before := current.Volume
+	g.tryHorizontalFlow(x, y, newState)
+	if current.Volume < before {
+		g.note("cascade: spread sideways over water")
+	}
+
+	if current.Volume > 0 {
+		before = current.Volume
+		g.tryDiagonalFlow(x, y, newState)
+		if current.Volume < before {
+			g.note("diagonal: slip down past the corner")
+		}
+	}
+
+	before = current.Volume
+	g.applyPressureFlow(x, y, newState)
+	if current.Volume < before {
+		g.note("pressure: equalize with neighbours")
+	}
+}
+
+func (g *Game) dampenVelocity(state *[][]Droplet) {
+	for y := range *state {
+		for x := range (*state)[y] {
+			d := &(*state)[y][x]
+			d.VX *= g.Params.VelocityDamping
+			d.VY *= g.Params.VelocityDamping
+		}
+	}
+}
+func (g *Game) canFlowDown(x, y int, state *[][]Droplet) bool {
+	bx, by, ok := g.below(x, y, state)
+	return ok && (*state)[by][bx].Volume < 1.0 && isOpen(&(*state)[by][bx]) && !supports(&(*state)[by][bx], &(*state)[y][x])
+}
+
+func (g *Game) tryHorizontalFlow(x, y int, state *[][]Droplet) {
+	current := &(*state)[y][x]
+
+	// Only cascade if there's water below
+	bx, by, ok := g.below(x, y, state)
+	hasWaterBelow := ok && (*state)[by][bx].Volume > 0.5
+	if !hasWaterBelow {
+		return
+	}
+
+	// Cascade right, then left - distribute to multiple cells
+	for _, side := range []int{1, -1} {
+		dx, dy := g.Gravity.toGrid(side, 0)
+		for offset := 1; offset <= 3; offset++ {
+			nx, ny, ok := g.local(x, y, side*offset, 0, state)
+			if !ok {
+				break
+			}
+			target := &(*state)[ny][nx]
+			if target.Volume < current.Volume && g.conductance(target) > 0 {
+				flowRate := (current.Volume - target.Volume) * g.Params.CascadeRate / float64(offset)
+				// Wind and the VOF faces are measured in the grid, so they
+				// only shape sideways flow under the default gravity
+				if g.Gravity == GravityDown {
+					flowRate *= g.windBias(x, y, side, state) * g.vofFlowScale(x, y, side, state)
+				}
+				g.push(target, dx, dy, g.fill(current, target, 1.0, flowRate))
+			}
+		}
+	}
+}
+
+func (g *Game) tryDiagonalFlow(x, y int, state *[][]Droplet) {
+	current := &(*state)[y][x]
+
+	// Flow diagonally down-right, then down-left, if space is available
+	for _, side := range []int{1, -1} {
+		nx, ny, ok := g.local(x, y, side, 1, state)
+		if !ok {
+			continue
+		}
+		target := &(*state)[ny][nx]
+		if target.Volume < 1.0 && g.conductance(target) > 0 && !supports(target, current) {
+			dx, dy := g.Gravity.toGrid(side, 1)
+			g.push(target, dx, dy, g.fill(current, target, 1.0, g.Params.DiagonalRate))
+		}
+	}
+
+}
+
+// Calculate how much more water a droplet can hold
+func remainder(droplet Droplet, maxVolume float64) float64 {
+	return maxVolume - droplet.Volume
+}
+
+// Fill transfers water between two droplets at a controlled rate and
+// returns how much was moved
+func (g *Game) fill(current, target *Droplet, maxVolume, flowRate float64) float64 {
+
+	// Calculate how much water can be transferred
+	transfer := remainder(*target, maxVolume)
+
+	// Limit transfer to the flow rate (prevents instant teleportation)
+	if transfer > flowRate {
+		transfer = flowRate
+	}
+
+	// Never move more water than the source holds
+	transfer = min(transfer, max(current.Volume, 0))
+
+	// Move water from source to target, carrying its momentum along
+	return g.moveVolume(current, target, transfer)
+}
@@ -0,0 +1,79 @@
+package gridfluid
+
+import "math"
+
+/*
+* Gauges
+*
+* Gauges are placeable scene entities that show the pressure, temperature
+* or flow at their cell as an analog dial or a bar. They are saved as part
+* of the scene code.
+ */
+
+type GaugeKind int
+
+const (
+	GaugePressure GaugeKind = iota
+	GaugeTemperature
+	GaugeFlow
+	GaugeKindCount
+)
+
+type GaugeStyle int
+
+const (
+	GaugeDial GaugeStyle = iota
+	GaugeBar
+	GaugeStyleCount
+)
+
+type Gauge struct {
+	X, Y  int
+	Kind  GaugeKind
+	Style GaugeStyle
+
+	Value    float64 // Smoothed reading
+	lastFlux float64 // Flux seen last tick, for the flow rate
+}
+
+// Gauges lists the gauges placed in the scene
+func (g *Game) Gauges() []*Gauge {
+	return g.gauges
+}
+
+// ToggleGauge places a gauge at (x, y), or removes the one already there
+func (g *Game) ToggleGauge(x, y int, kind GaugeKind, style GaugeStyle) {
+	if !g.InBounds(x, y) {
+		return
+	}
+	for i, gauge := range g.gauges {
+		if gauge.X == x && gauge.Y == y {
+			g.gauges = append(g.gauges[:i], g.gauges[i+1:]...)
+			return
+		}
+	}
+	g.gauges = append(g.gauges, &Gauge{X: x, Y: y, Kind: kind, Style: style, lastFlux: g.State[y][x].Flux})
+}
+
+// updateGauges takes a new reading for every gauge
+func (g *Game) updateGauges() {
+	for _, gauge := range g.gauges {
+		if !g.InBounds(gauge.X, gauge.Y) {
+			continue
+		}
+		d := &g.State[gauge.Y][gauge.X]
+		var reading float64
+		switch gauge.Kind {
+		case GaugePressure:
+			reading = d.Pressure
+		case GaugeTemperature:
+			reading = d.Temperature
+		case GaugeFlow:
+			// Flux resets make the delta negative for a tick
+			reading = math.Max(0, d.Flux-gauge.lastFlux)
+			gauge.lastFlux = d.Flux
+		}
+		// Smooth so the needle doesn't jitter every tick
+		gauge.Value += (reading - gauge.Value) * 0.2
+	}
+}
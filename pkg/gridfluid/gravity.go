@@ -0,0 +1,90 @@
+package gridfluid
+
+/*
+* Gravity direction
+*
+* The flow rules are written in a local frame where "down" is the direction
+* of gravity and "side" is perpendicular to it. Gravity can be rotated in
+* 90° steps; local offsets are turned into grid offsets before every lookup,
+* so the whole grid settles towards the new down. Ramps and surface waves
+* assume the usual downward gravity and sit out while it is rotated.
+ */
+
+type Gravity int
+
+const (
+	GravityDown Gravity = iota
+	GravityLeft
+	GravityUp
+	GravityRight
+	gravityCount
+)
+
+var GravityNames = [gravityCount]string{"down", "left", "up", "right"}
+
+// toGrid turns a local (side, down) offset into a grid offset by rotating
+// it a quarter turn clockwise per step
+func (gd Gravity) toGrid(side, down int) (dx, dy int) {
+	switch gd {
+	case GravityLeft:
+		return -down, side
+	case GravityUp:
+		return -side, -down
+	case GravityRight:
+		return down, -side
+	}
+	return side, down
+}
+
+// Vector is the unit grid direction gravity pulls in
+func (gd Gravity) Vector() (float64, float64) {
+	dx, dy := gd.toGrid(0, 1)
+	return float64(dx), float64(dy)
+}
+
+// RotateGravity turns gravity by steps quarter turns clockwise
+func (g *Game) RotateGravity(steps int) {
+	g.Gravity = Gravity(((int(g.Gravity)+steps)%int(gravityCount) + int(gravityCount)) % int(gravityCount))
+}
+
+// local resolves the cell at the local offset (side, down) from (x, y)
+func (g *Game) local(x, y, side, down int, state *[][]Droplet) (nx, ny int, ok bool) {
+	dx, dy := g.Gravity.toGrid(side, down)
+	return g.neighbor(x, y, dx, dy, state)
+}
+
+// below resolves the cell gravity pulls (x, y) into
+func (g *Game) below(x, y int, state *[][]Droplet) (nx, ny int, ok bool) {
+	return g.local(x, y, 0, 1, state)
+}
+
+// bottomUp visits every cell of a w by h grid starting with the row
+// furthest along gravity, so falling water is never moved twice
+func (g *Game) bottomUp(w, h int, visit func(x, y int)) {
+	switch g.Gravity {
+	case GravityDown:
+		for y := h - 1; y >= 0; y-- {
+			for x := range w {
+				visit(x, y)
+			}
+		}
+	case GravityUp:
+		for y := range h {
+			for x := range w {
+				visit(x, y)
+			}
+		}
+	case GravityRight:
+		for x := w - 1; x >= 0; x-- {
+			for y := range h {
+				visit(x, y)
+			}
+		}
+	case GravityLeft:
+		for x := range w {
+			for y := range h {
+				visit(x, y)
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package gridfluid
+
+import "math"
+
+/*
+* Heaters, ice and boiling
+*
+* Heaters are obstacle cells that warm the water and ice touching them by
+* HeaterPower °C per second, up to heaterMax. Ice is frozen water: an
+* obstacle that keeps its volume and starts out at iceTemperature. Heat
+* spreads between touching water and ice cells (see conductHeat), so warm
+* water melts ice as well. Ice held above freezingPoint takes in heat until
+* it has absorbed latentHeat and then melts back into water. Water above
+* boilingPoint boils off as steam while the boiling holds it at that
+* temperature. Hot water left alone cools back towards the air. Snow is
+* ice as well, see weather.go.
+ */
+
+const (
+	freezingPoint  = 0.0   // °C above which ice melts
+	boilingPoint   = 100.0 // °C above which water boils
+	iceTemperature = -10.0 // °C of freshly placed ice
+	heaterMax      = 120.0 // °C a heater can't warm past
+	boilRate       = 0.3   // Volume per second boiling off a cell at boiling point
+	heatLoss       = 0.02  // Share per second of the difference to the air lost by water
+	latentHeat     = 80.0  // °C of warming an ice cell takes in while melting
+)
+
+// CreateHeater turns the cell at (x, y) into a heater
+func CreateHeater(x, y int, state *[][]Droplet) {
+	d := &(*state)[y][x]
+	d.Material = MaterialStone
+	d.Heater = true
+	d.Volume = 0
+}
+
+// CreateIceBlock fills a w by h rectangle with ice
+func CreateIceBlock(x, y, w, h int, state *[][]Droplet) {
+	for cy := y; cy < y+h && cy < len(*state); cy++ {
+		for cx := x; cx < x+w && cx < len((*state)[cy]); cx++ {
+			d := &(*state)[cy][cx]
+			d.Material = MaterialStone
+			d.Ice = true
+			d.Fluid = FluidWater
+			d.Volume = 1
+			d.Temperature = iceTemperature
+			d.Thawed = 0
+		}
+	}
+}
+
+// PlaceHeater puts a heater on a dry open cell, or removes the one there
+func (g *Game) PlaceHeater(x, y int) bool {
+	if !g.InBounds(x, y) {
+		return false
+	}
+	d := &g.State[y][x]
+	if d.Heater {
+		*d = Droplet{Size: d.Size, Temperature: ambientTemperature}
+		return true
+	}
+	if d.Pipe != PipeNone || d.Material != MaterialOpen || d.Volume > WetThreshold {
+		return false
+	}
+	CreateHeater(x, y, &g.State)
+	return true
+}
+
+// PlaceIce freezes a dry open cell into a block of ice
+func (g *Game) PlaceIce(x, y int) bool {
+	if !g.InBounds(x, y) {
+		return false
+	}
+	d := &g.State[y][x]
+	if d.Pipe != PipeNone || d.Material != MaterialOpen || d.Volume > WetThreshold {
+		return false
+	}
+	CreateIceBlock(x, y, 1, 1, &g.State)
+	return true
+}
+
+// warm lets heaters heat their neighbours, melts ice that got too warm and
+// boils water that got too hot
+func (g *Game) warm(state *[][]Droplet) {
+	heat := g.Params.HeaterPower / ticksPerSecond
+	loss := heatLoss / ticksPerSecond
+	for y := range *state {
+		for x := range (*state)[y] {
+			d := &(*state)[y][x]
+			switch {
+			case d.Heater && heat > 0:
+				for _, dpos := range pressureDirections {
+					nx, ny, ok := g.neighbor(x, y, dpos[0], dpos[1], state)
+					if !ok {
+						continue
+					}
+					n := &(*state)[ny][nx]
+					if !n.Ice && (n.Material.IsSolid() || n.Volume <= WetThreshold) {
+						continue
+					}
+					n.Temperature = math.Max(n.Temperature, math.Min(n.Temperature+heat, heaterMax))
+				}
+			case d.Ice:
+				if d.Snow {
+					g.weatherSnow(x, y, d, state)
+				}
+				if d.Temperature > freezingPoint {
+					d.Thawed += (d.Temperature - freezingPoint) / latentHeat
+					d.Temperature = freezingPoint
+				}
+				if d.Thawed >= 1 {
+					d.Material = MaterialOpen
+					d.Ice, d.Snow = false, false
+					d.Thawed = 0
+				}
+			case !d.Material.IsSolid() && d.Volume > 0:
+				if d.Temperature > boilingPoint && d.Fluid != FluidOil {
+					g.boil(x, y, boilRate/ticksPerSecond, state)
+					d.Temperature = boilingPoint
+				}
+				d.Temperature += (g.AirTemperature - d.Temperature) * loss
+			}
+		}
+	}
+}
+
+// conductHeat evens out the temperature between touching water and ice
+// cells at HeatConduction per tick
+func (g *Game) conductHeat(state *[][]Droplet) {
+	rate := g.Params.HeatConduction
+	if rate <= 0 {
+		return
+	}
+	thermal := func(d *Droplet) bool {
+		return d.Ice || (!d.Material.IsSolid() && d.Pipe == PipeNone && d.Volume > WetThreshold)
+	}
+	for y := range *state {
+		for x := range (*state)[y] {
+			a := &(*state)[y][x]
+			if !thermal(a) {
+				continue
+			}
+			for _, dpos := range [][2]int{{1, 0}, {0, 1}} {
+				nx, ny, ok := g.neighbor(x, y, dpos[0], dpos[1], state)
+				if !ok {
+					continue
+				}
+				b := &(*state)[ny][nx]
+				if !thermal(b) {
+					continue
+				}
+				flow := (a.Temperature - b.Temperature) * rate / 2
+				a.Temperature -= flow
+				b.Temperature += flow
+			}
+		}
+	}
+}
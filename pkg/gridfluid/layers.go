@@ -0,0 +1,178 @@
+package gridfluid
+
+/*
+* Depth layers
+*
+* A scene can stack up to maxLayers grids of the same size, front to back.
+* Each layer runs all the flow rules on its own and keeps its own splashes,
+* snowflakes, bubbles, water wheels and wires. Gauges, regions, debris and
+* movers stay with whichever layer has the focus. The focused layer is the one
+* held in Game.State, so edits and readouts go to it; the others wait in
+* Game.layers. Water passes between two neighbouring layers only through
+* connection cells: where the same cell is a connector in both, water
+* flows from the higher pressure to the lower one like through a pipe, at
+* PipeRate. The pressure solver counts a joined cell as one more
+* neighbour, so a sealed tunnel behind feels the weight of the water
+* pressing into it. The layers behind the front
+* are drawn first and dimmed, so a tunnel behind a structure shows
+* through its gaps. Scene codes hold the focused layer only.
+ */
+
+const maxLayers = 3
+
+// Layer is a depth layer parked while another one has the focus
+type Layer struct {
+	State    [][]Droplet
+	splashes []*Splash
+	flakes   []*Flake
+	bubbles  []*Bubble
+	wheels   []*Wheel
+	wires    []Wire
+}
+
+// Layers is the number of depth layers in the scene
+func (g *Game) Layers() int {
+	return max(len(g.layers), 1)
+}
+
+// FocusedLayer is the index of the layer held in State
+func (g *Game) FocusedLayer() int {
+	return g.layer
+}
+
+// AddLayer adds an empty layer behind the others
+func (g *Game) AddLayer() bool {
+	if g.Layers() >= maxLayers {
+		return false
+	}
+	if len(g.layers) == 0 {
+		// The front layer's slot stays empty while it has the focus
+		g.layers = make([]Layer, 1)
+	}
+	g.layers = append(g.layers, Layer{State: CreateGameState(len(g.State[0]), len(g.State), g.tileSize)})
+	return true
+}
+
+// FocusLayer moves the focus to layer i, 0 being the front
+func (g *Game) FocusLayer(i int) bool {
+	if i < 0 || i >= g.Layers() {
+		return false
+	}
+	g.swapLayer(i)
+	g.resetEvents()
+	return true
+}
+
+// swapLayer parks the focused layer and loads layer i in its place
+func (g *Game) swapLayer(i int) {
+	if i == g.layer {
+		return
+	}
+	g.layers[g.layer] = Layer{g.State, g.splashes, g.flakes, g.bubbles, g.wheels, g.wires}
+	l := g.layers[i]
+	g.State, g.splashes, g.flakes, g.bubbles, g.wheels, g.wires = l.State, l.splashes, l.flakes, l.bubbles, l.wheels, l.wires
+	g.layers[i] = Layer{}
+	g.layer = i
+}
+
+// layerState is the grid of layer i, wherever it is held
+func (g *Game) layerState(i int) *[][]Droplet {
+	if i == g.layer {
+		return &g.State
+	}
+	return &g.layers[i].State
+}
+
+// layerSplashes are the airborne particles of layer i
+func (g *Game) layerSplashes(i int) []*Splash {
+	if i == g.layer {
+		return g.splashes
+	}
+	return g.layers[i].splashes
+}
+
+// stepLayers runs the flow rules on every layer and lets the connection
+// cells pass water between them
+func (g *Game) stepLayers() {
+	if len(g.layers) == 0 {
+		g.step()
+		return
+	}
+	focus, trace := g.layer, g.trace
+	for i := range g.layers {
+		g.swapLayer(i)
+		// Teaching mode only follows the focused layer
+		if i != focus {
+			g.trace = nil
+		}
+		g.events.quiet = i != focus
+		g.step()
+		g.trace = trace
+	}
+	g.events.quiet = false
+	g.swapLayer(focus)
+	g.connectLayers()
+}
+
+// connectLayers lets water flow between matching connection cells of
+// neighbouring layers
+func (g *Game) connectLayers() {
+	for i := 0; i+1 < len(g.layers); i++ {
+		front, back := g.layerState(i), g.layerState(i+1)
+		for y := range *front {
+			for x := range (*front)[y] {
+				a, b := &(*front)[y][x], &(*back)[y][x]
+				if !a.Connector || !b.Connector || !isOpen(a) || !isOpen(b) {
+					continue
+				}
+				// Flow follows the pressure like in a pipe
+				if a.Pressure < b.Pressure {
+					a, b = b, a
+				}
+				head := a.Pressure - b.Pressure
+				if head < pressureEpsilon {
+					continue
+				}
+				g.transferVolume(a, b, min(g.Params.PipeRate*head, max(a.Volume, 0), remainder(*b, 1.0)))
+			}
+		}
+	}
+}
+
+// linked lists the connection cells in the layers next to the focused one
+// that the cell d at (x, y) is joined to
+func (g *Game) linked(x, y int, d *Droplet) []*Droplet {
+	if !d.Connector || len(g.layers) == 0 {
+		return nil
+	}
+	var cells []*Droplet
+	for _, i := range [2]int{g.layer - 1, g.layer + 1} {
+		if i < 0 || i >= len(g.layers) {
+			continue
+		}
+		if l := &(*g.layerState(i))[y][x]; l.Connector && isOpen(l) {
+			cells = append(cells, l)
+		}
+	}
+	return cells
+}
+
+// PlaceConnector makes the cell at (x, y) a connection between the focused
+// layer and the one behind it, or in front of it for the back layer, or
+// removes the connection there
+func (g *Game) PlaceConnector(x, y int) bool {
+	if !g.InBounds(x, y) || len(g.layers) == 0 {
+		return false
+	}
+	other := g.layer + 1
+	if other >= len(g.layers) {
+		other = g.layer - 1
+	}
+	a, b := &g.State[y][x], &(*g.layerState(other))[y][x]
+	if !a.Connector && (!isOpen(a) || !isOpen(b)) {
+		return false
+	}
+	a.Connector = !a.Connector
+	b.Connector = a.Connector
+	return true
+}
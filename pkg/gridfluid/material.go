@@ -0,0 +1,108 @@
+package gridfluid
+
+import "image/color"
+
+/*
+* Materials
+*
+* Every cell is filled with a material: open air, solid stone, or something
+* in between that water seeps through. The update rules only ask a material
+* whether it is solid and how freely water flows through it, so a new cell
+* type is added by registering it here rather than by touching the solver.
+* Devices like fans, heaters and gates are flags on top of a solid cell.
+ */
+
+// Material describes the substance filling a cell
+type Material interface {
+	Name() string
+	FlowRate(p *Params) float64 // How freely water flows through, from 0 to 1
+	IsSolid() bool              // Solid materials hold no water and block flow
+	Color() color.RGBA          // Base colour the renderer tints from
+}
+
+// MaterialID indexes the material registry; it is what a cell stores. The
+// zero value is open air, so a fresh cell is empty.
+type MaterialID uint8
+
+// Built-in materials, in registry order. Scene codes rely on the first four
+// keeping their numbers.
+const (
+	MaterialOpen   MaterialID = iota // Plain air / water
+	MaterialGravel                   // Coarse, drains fairly quickly
+	MaterialCloth                    // Fine weave, barely lets water through
+	MaterialPlant                    // Living foliage, see plants.go
+	MaterialStone                    // Plain obstacle
+	MaterialWood                     // Flammable obstacle, see fire.go
+	MaterialSand                     // Loose obstacle fast water wears away, see sediment.go
+)
+
+// basicMaterial is a Material described by fixed values
+type basicMaterial struct {
+	name  string
+	flow  func(p *Params) float64 // nil for solids and open air
+	solid bool
+	color color.RGBA
+}
+
+func (m basicMaterial) Name() string      { return m.name }
+func (m basicMaterial) IsSolid() bool     { return m.solid }
+func (m basicMaterial) Color() color.RGBA { return m.color }
+
+func (m basicMaterial) FlowRate(p *Params) float64 {
+	switch {
+	case m.solid:
+		return 0
+	case m.flow == nil:
+		return 1
+	}
+	return m.flow(p)
+}
+
+var materials = []Material{
+	MaterialOpen: basicMaterial{name: "open"},
+	MaterialGravel: basicMaterial{name: "gravel", color: color.RGBA{110, 105, 100, 255},
+		flow: func(p *Params) float64 { return p.GravelPermeability }},
+	MaterialCloth: basicMaterial{name: "cloth", color: color.RGBA{170, 160, 140, 255},
+		flow: func(p *Params) float64 { return p.ClothPermeability }},
+	MaterialPlant: basicMaterial{name: "plant", color: color.RGBA{60, 160, 50, 255},
+		flow: func(p *Params) float64 { return p.PlantPermeability }},
+	MaterialStone: basicMaterial{name: "stone", solid: true, color: color.RGBA{127, 106, 79, 255}},
+	MaterialWood:  basicMaterial{name: "wood", solid: true, color: color.RGBA{150, 100, 55, 255}},
+	MaterialSand:  basicMaterial{name: "sand", solid: true, color: color.RGBA{210, 185, 130, 255}},
+}
+
+// solidMaterials caches IsSolid of every registered material, the solver
+// asks it for every cell many times per update
+var solidMaterials [256]bool
+
+func init() {
+	for i, m := range materials {
+		solidMaterials[i] = m.IsSolid()
+	}
+}
+
+// RegisterMaterial adds a cell type and returns the ID cells store for it
+func RegisterMaterial(m Material) MaterialID {
+	if len(materials) > 255 {
+		panic("gridfluid: too many materials")
+	}
+	materials = append(materials, m)
+	solidMaterials[len(materials)-1] = m.IsSolid()
+	return MaterialID(len(materials) - 1)
+}
+
+// LookupMaterial finds a registered material by name
+func LookupMaterial(name string) (MaterialID, bool) {
+	for i, m := range materials {
+		if m.Name() == name {
+			return MaterialID(i), true
+		}
+	}
+	return 0, false
+}
+
+// A MaterialID is itself a Material, forwarding to the registry entry
+func (id MaterialID) Name() string               { return materials[id].Name() }
+func (id MaterialID) FlowRate(p *Params) float64 { return materials[id].FlowRate(p) }
+func (id MaterialID) IsSolid() bool              { return solidMaterials[id] }
+func (id MaterialID) Color() color.RGBA          { return materials[id].Color() }
@@ -0,0 +1,160 @@
+package gridfluid
+
+import "math"
+
+/*
+* Measurement regions
+*
+* A region is a rectangle of cells whose water is metered every tick: how
+* much it holds, the net flow in (positive) or out (negative) per second,
+* and how much went missing. Water leaving a region has to cross into the
+* ring of cells around it or be taken by a sink inside it: a vortex, an
+* open edge, a plant, boiling or a splash thrown up (see recordSink).
+* Fast water can cross a few cells in one tick, so the ring is ringWidth
+* cells wide. What the region loses beyond that counts as lost, but only
+* as far as the whole world came up short over the tick (see balance):
+* water the simulation kept track of somewhere else was not lost. A region
+* that lost more than leakAlert over the last second raises an alert.
+ */
+
+const (
+	leakAlert  = 0.05           // Volume lost within a second that raises an alert
+	flowSmooth = 0.1            // Weight of the newest tick in the smoothed flow
+	leakWindow = ticksPerSecond // Ticks of losses summed for the alert
+	ringWidth  = 3              // Cells around a region water can reach in a tick
+)
+
+type Region struct {
+	Name       string
+	X, Y, W, H int
+
+	Volume float64 // Water held right now
+	Flow   float64 // Smoothed net inflow per second, negative when draining
+	Lost   float64 // Total volume that vanished without crossing the border
+	Alert  bool    // Lost more than leakAlert within the last second
+
+	ring   float64             // Water in the ring around the region last tick
+	recent [leakWindow]float64 // Loss per tick over the last second
+	tick   int
+	primed bool // Volume and ring hold a previous reading
+}
+
+// AddRegion starts metering the w by h rectangle with its top left corner
+// at (x, y)
+func (g *Game) AddRegion(name string, x, y, w, h int) *Region {
+	r := &Region{Name: name, X: x, Y: y, W: w, H: h}
+	g.regions = append(g.regions, r)
+	return r
+}
+
+// RemoveRegion stops metering a region
+func (g *Game) RemoveRegion(r *Region) {
+	for i, other := range g.regions {
+		if other == r {
+			g.regions = append(g.regions[:i], g.regions[i+1:]...)
+			return
+		}
+	}
+}
+
+// Regions lists the measurement regions
+func (g *Game) Regions() []*Region {
+	return g.regions
+}
+
+// RegionAt returns the region covering (x, y), if any
+func (g *Game) RegionAt(x, y int) *Region {
+	for _, r := range g.regions {
+		if r.contains(x, y) {
+			return r
+		}
+	}
+	return nil
+}
+
+func (r *Region) contains(x, y int) bool {
+	return x >= r.X && x < r.X+r.W && y >= r.Y && y < r.Y+r.H
+}
+
+// recordSink notes volume taken out of the simulation at (x, y) this tick,
+// so the regions don't mistake it for a leak
+func (g *Game) recordSink(x, y int, amount float64) {
+	if len(g.regions) == 0 || amount <= 0 {
+		return
+	}
+	if g.sinks == nil {
+		g.sinks = map[[2]int]float64{}
+	}
+	g.sinks[[2]int{x, y}] += amount
+}
+
+// heldVolume is the water a cell holds, counting ice and ramps
+func heldVolume(d *Droplet) float64 {
+	if !holdsWater(d) {
+		return 0
+	}
+	return math.Max(d.Volume, 0)
+}
+
+// balance is the water in the world plus all the simulation took out of
+// it, less what it added. A tick that loses no water keeps it unchanged.
+func (g *Game) balance() float64 {
+	b := g.TotalVolume() + g.Absorbed + g.Evaporated + g.Swallowed - g.Supplied
+	for _, out := range g.Outflow {
+		b += out
+	}
+	return b
+}
+
+// updateRegions takes a new reading for every region, given the balance
+// at the start of the tick
+func (g *Game) updateRegions(before float64) {
+	defer clear(g.sinks)
+	if len(g.regions) == 0 {
+		return
+	}
+	missing := math.Max(before-g.balance(), 0)
+	h := len(g.State)
+	if h == 0 {
+		return
+	}
+	w := len(g.State[0])
+	for _, r := range g.regions {
+		var volume, ring, sunk float64
+		for y := r.Y - ringWidth; y < r.Y+r.H+ringWidth; y++ {
+			for x := r.X - ringWidth; x < r.X+r.W+ringWidth; x++ {
+				if y < 0 || y >= h || x < 0 || x >= w {
+					continue
+				}
+				if r.contains(x, y) {
+					volume += heldVolume(&g.State[y][x])
+					sunk += g.sinks[[2]int{x, y}]
+				} else {
+					ring += heldVolume(&g.State[y][x])
+				}
+			}
+		}
+
+		loss := 0.0
+		if r.primed {
+			change := volume - r.Volume
+			r.Flow += (change*ticksPerSecond - r.Flow) * flowSmooth
+			// Water that left has to show up in the ring or a sink, and
+			// the region and its ring can't shrink by more than the ring
+			// passed on
+			gained := ring - r.ring
+			loss = math.Max(-change-math.Max(gained, 0), -change-gained) - sunk
+			loss = math.Min(math.Max(loss, 0), missing)
+			missing -= loss
+		}
+		r.Lost += loss
+		r.recent[r.tick%leakWindow] = loss
+		r.tick++
+		recent := 0.0
+		for _, l := range r.recent {
+			recent += l
+		}
+		r.Alert = recent > leakAlert
+		r.Volume, r.ring, r.primed = volume, ring, true
+	}
+}
@@ -0,0 +1,64 @@
+package gridfluid
+
+/*
+* Moss
+*
+* Moss grows on obstacles that stay wet. An obstacle in touch with water
+* for a while (wetness above mossWetness) gains MossGrowth of cover per
+* second, slowing down as it closes in on full cover; one that has dried
+* out (wetness below mossDry) loses mossDieBack per second, so old water
+* lines stay green long after the water has gone. Ice, snow, sand, salt,
+* heaters and movers never grow any, and fire burns it off. Water loses
+* up to MossDrag of its speed per tick, in proportion to the moss on its
+* four faces, so mossy channels run a little slower.
+ */
+
+const (
+	mossWetness = 0.9    // Wetness an obstacle needs to keep growing moss
+	mossDry     = 0.5    // Wetness below which the moss dies back
+	mossDieBack = 0.0005 // Cover lost per second by dry moss
+)
+
+// canGrowMoss reports whether moss takes hold on a cell
+func canGrowMoss(d *Droplet) bool {
+	return d.Material.IsSolid() && !d.Moving && !d.Ice && d.Material != MaterialSand && !d.Salt && !d.Heater && d.Fire <= 0
+}
+
+// growMoss grows and withers the moss on the obstacles and slows the water
+// running over it
+func (g *Game) growMoss(state *[][]Droplet) {
+	growth := g.Params.MossGrowth / ticksPerSecond
+	for y := range *state {
+		for x := range (*state)[y] {
+			d := &(*state)[y][x]
+			switch {
+			case !canGrowMoss(d):
+				d.Moss = 0
+			case d.Wetness >= mossWetness:
+				d.Moss += growth * (1 - d.Moss)
+			case d.Wetness < mossDry:
+				d.Moss = max(d.Moss-mossDieBack/ticksPerSecond, 0)
+			}
+		}
+	}
+	if g.Params.MossDrag <= 0 {
+		return
+	}
+	for y := range *state {
+		for x := range (*state)[y] {
+			d := &(*state)[y][x]
+			if d.Material.IsSolid() || d.Volume <= WetThreshold {
+				continue
+			}
+			cover := 0.0
+			for _, dpos := range pressureDirections {
+				if nx, ny, ok := g.neighbor(x, y, dpos[0], dpos[1], state); ok {
+					cover += (*state)[ny][nx].Moss
+				}
+			}
+			keep := 1 - g.Params.MossDrag*cover/4
+			d.VX *= keep
+			d.VY *= keep
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package gridfluid
+
+import "math"
+
+/*
+* Moving obstacles
+*
+* Movers are rectangles that travel back and forth along a path of
+* waypoints. They stamp themselves onto the grid as obstacles flagged
+* "moving" so they can be lifted off again next tick without touching the
+* static obstacles underneath.
+ */
+
+type Mover struct {
+	W, H  int      // Size in cells
+	Path  []Vector // Waypoints of the top-left corner, in cells
+	Speed float64  // Cells per tick
+
+	pos     Vector
+	target  int // Index of the waypoint being travelled to
+	forward bool
+	x, y    int // Cell the mover is currently stamped at
+	stamped bool
+	size    Vector // W and H before rounding to whole cells, see resample.go
+}
+
+// AddMover creates a mover that ping-pongs along path
+func (g *Game) AddMover(w, h int, path []Vector, speed float64) *Mover {
+	m := &Mover{W: w, H: h, Path: path, Speed: speed, pos: path[0], forward: true}
+	if len(path) > 1 {
+		m.target = 1
+	}
+	g.movers = append(g.movers, m)
+	return m
+}
+
+// advance moves the mover along its path and returns its velocity
+func (m *Mover) advance() Vector {
+	if len(m.Path) < 2 {
+		return Vector{}
+	}
+	goal := m.Path[m.target]
+	dx, dy := goal.X-m.pos.X, goal.Y-m.pos.Y
+	dist := math.Hypot(dx, dy)
+	if dist <= m.Speed {
+		m.pos = goal
+		// Turn around at either end of the path
+		if m.forward && m.target == len(m.Path)-1 {
+			m.forward = false
+		} else if !m.forward && m.target == 0 {
+			m.forward = true
+		}
+		if m.forward {
+			m.target++
+		} else {
+			m.target--
+		}
+		return Vector{dx, dy}
+	}
+	v := Vector{dx / dist * m.Speed, dy / dist * m.Speed}
+	m.pos.X += v.X
+	m.pos.Y += v.Y
+	return v
+}
+
+func (m *Mover) covers(x, y, cx, cy int) bool {
+	return x >= cx && x < cx+m.W && y >= cy && y < cy+m.H
+}
+
+// updateMovers is the pre-update pass: movers advance, water in cells they
+// move into is pushed out, and the cells they leave are freed
+func (g *Game) updateMovers() {
+	for _, m := range g.movers {
+		v := m.advance()
+		nx, ny := int(math.Round(m.pos.X)), int(math.Round(m.pos.Y))
+		if m.stamped && nx == m.x && ny == m.y {
+			continue
+		}
+
+		// Free the cells that are no longer covered
+		if m.stamped {
+			for y := m.y; y < m.y+m.H; y++ {
+				for x := m.x; x < m.x+m.W; x++ {
+					if !g.InBounds(x, y) || m.covers(x, y, nx, ny) {
+						continue
+					}
+					if d := &g.State[y][x]; d.Moving {
+						d.Moving = false
+						d.Material = MaterialOpen
+					}
+				}
+			}
+		}
+
+		// Occupy the new cells, displacing any water in them
+		dx, dy := sign0(nx-m.x), sign0(ny-m.y)
+		for y := ny; y < ny+m.H; y++ {
+			for x := nx; x < nx+m.W; x++ {
+				if !g.InBounds(x, y) {
+					continue
+				}
+				d := &g.State[y][x]
+				if d.Material.IsSolid() {
+					continue
+				}
+				if d.Volume > 0 {
+					g.displaceWater(x, y, dx, dy, v, m, nx, ny)
+				}
+				d.Material = MaterialStone
+				d.Moving = true
+			}
+		}
+		m.x, m.y, m.stamped = nx, ny, true
+	}
+}
+
+// displaceWater pushes the water out of (x, y) into free neighbours,
+// preferring the direction of motion, and hands it the mover's velocity
+func (g *Game) displaceWater(x, y, dx, dy int, v Vector, m *Mover, mx, my int) {
+	d := &g.State[y][x]
+	directions := [][2]int{{dx, dy}, {0, -1}, {-1, 0}, {1, 0}, {0, 1}}
+	free := func(tx, ty int) *Droplet {
+		if !g.InBounds(tx, ty) || m.covers(tx, ty, mx, my) {
+			return nil
+		}
+		t := &g.State[ty][tx]
+		if t.Material.IsSolid() {
+			return nil
+		}
+		return t
+	}
+
+	for _, dir := range directions {
+		if dir == [2]int{0, 0} {
+			continue
+		}
+		// Look a few cells ahead so fast movers can plough through a pool
+		for step := 1; step <= m.W+m.H && d.Volume > 0; step++ {
+			t := free(x+dir[0]*step, y+dir[1]*step)
+			if t == nil {
+				break
+			}
+			amount := math.Min(d.Volume, remainder(*t, 1.0))
+			if amount > 0 {
+				t.VX, t.VY = v.X, v.Y
+				g.moveVolume(d, t, amount)
+			}
+		}
+	}
+
+	// Nowhere to go nearby, as deep inside a thick mover: overfill the
+	// first free neighbour, or pour it into the nearest cells with room
+	// rather than lose water
+	if d.Volume > 0 {
+		for _, dir := range directions {
+			if t := free(x+dir[0], y+dir[1]); t != nil {
+				t.Volume += d.Volume
+				d.Volume = 0
+				return
+			}
+		}
+		spill := map[[2]int]float64{{x, y}: d.Volume}
+		d.Volume = 0
+		d.Material = MaterialStone
+		pour(g.State, spill)
+	}
+}
+
+func (g *Game) InBounds(x, y int) bool {
+	return y >= 0 && y < len(g.State) && x >= 0 && x < len(g.State[0])
+}
+
+func sign0(v int) int {
+	switch {
+	case v < 0:
+		return -1
+	case v > 0:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,98 @@
+package gridfluid
+
+import "sync"
+
+/*
+* Parallel bands
+*
+* The per-cell flow rules and the pressure sweeps only touch a cell and its
+* direct neighbours, so on large grids they are split into bands of
+* bandLines lines across gravity and run on several goroutines. The bands
+* go in two phases, every other band at a time, so no two bands working at
+* once share a line of cells or a neighbour of one; with an odd number of
+* bands the last one gets a phase of its own, as it borders the first
+* across a wrapping edge. Each band still goes bottom up.
+*
+* The split only depends on the grid size, so a grid comes out the same
+* whatever Workers is. Grids with fewer than minBandedLines lines keep the
+* plain bottom up sweep, as does teaching mode.
+ */
+
+const (
+	bandLines      = 32            // Lines of cells per band
+	minBandedLines = 4 * bandLines // Fewer lines than this are swept serially
+)
+
+// bottomUpBanded visits every cell like bottomUp, in parallel bands on
+// large grids. visit must only touch the cell and its direct neighbours.
+func (g *Game) bottomUpBanded(w, h int, visit func(x, y int)) {
+	lines, length := h, w
+	if g.Gravity == GravityLeft || g.Gravity == GravityRight {
+		lines, length = w, h
+	}
+	if lines < minBandedLines || g.trace != nil {
+		g.bottomUp(w, h, visit)
+		return
+	}
+
+	// Lines are counted from the bottom along gravity
+	cell := func(line, pos int) (int, int) {
+		switch g.Gravity {
+		case GravityUp:
+			return pos, line
+		case GravityRight:
+			return w - 1 - line, pos
+		case GravityLeft:
+			return line, pos
+		}
+		return pos, h - 1 - line
+	}
+	sweep := func(band int) {
+		for line := band * bandLines; line < min((band+1)*bandLines, lines); line++ {
+			for pos := range length {
+				visit(cell(line, pos))
+			}
+		}
+	}
+
+	bands := (lines + bandLines - 1) / bandLines
+	phases := [][]int{{}, {}, {}}
+	for b := range bands {
+		switch {
+		case bands%2 == 1 && b == bands-1:
+			phases[2] = append(phases[2], b)
+		default:
+			phases[b%2] = append(phases[b%2], b)
+		}
+	}
+	for _, phase := range phases {
+		g.runBands(phase, sweep)
+	}
+}
+
+// runBands sweeps the bands of one phase on up to Workers goroutines
+func (g *Game) runBands(bands []int, sweep func(band int)) {
+	workers := min(max(g.Workers, 1), len(bands))
+	if workers <= 1 {
+		for _, b := range bands {
+			sweep(b)
+		}
+		return
+	}
+	next := make(chan int, len(bands))
+	for _, b := range bands {
+		next <- b
+	}
+	close(next)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range next {
+				sweep(b)
+			}
+		}()
+	}
+	wg.Wait()
+}
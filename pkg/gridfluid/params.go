@@ -0,0 +1,193 @@
+package gridfluid
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+)
+
+/*
+* Solver parameters
+ */
+
+// Params holds the tunable constants of the flow rules
+type Params struct {
+	FallRate           float64 `json:"fallRate"`           // Volume moved straight down per tick
+	CascadeRate        float64 `json:"cascadeRate"`        // Sideways spreading over water
+	DiagonalRate       float64 `json:"diagonalRate"`       // Volume moved diagonally down per tick
+	RampSlideRate      float64 `json:"rampSlideRate"`      // Sideways slide along ramps
+	PressureRate       float64 `json:"pressureRate"`       // Flow per unit of pressure difference
+	PressureSideScale  float64 `json:"pressureSideScale"`  // Horizontal pressure flow multiplier
+	VelocityDamping    float64 `json:"velocityDamping"`    // Per-tick velocity retention
+	AdvectionRate      float64 `json:"advectionRate"`      // Fraction of velocity-carried volume moved
+	Restitution        float64 `json:"restitution"`        // Speed kept when bouncing off obstacles
+	GravityAccel       float64 `json:"gravityAccel"`       // Downward acceleration of falling water
+	FlowImpulse        float64 `json:"flowImpulse"`        // Velocity gained per unit of volume moved
+	WaveSpeed          float64 `json:"waveSpeed"`          // Surface flow gained per unit of height difference
+	WaveDamping        float64 `json:"waveDamping"`        // Fraction of surface flow lost per tick
+	WindCoupling       float64 `json:"windCoupling"`       // Velocity gained per tick per unit of wind
+	WindBias           float64 `json:"windBias"`           // How strongly wind skews sideways spreading
+	InterfaceTension   float64 `json:"interfaceTension"`   // 0 mixes fluids freely, 1 keeps them fully separate
+	GravelPermeability float64 `json:"gravelPermeability"` // Flow multiplier through gravel
+	ClothPermeability  float64 `json:"clothPermeability"`  // Flow multiplier through cloth
+	PlantPermeability  float64 `json:"plantPermeability"`  // Flow multiplier through plants
+	DyeDiffusion       float64 `json:"dyeDiffusion"`       // Dye exchanged between touching cells per tick
+	SplashImpactSpeed  float64 `json:"splashImpactSpeed"`  // Slowest impact that throws up a splash
+	SplashFallHeight   float64 `json:"splashFallHeight"`   // Shortest fall in cells that throws up a splash
+	SplashBudget       float64 `json:"splashBudget"`       // Most splash particles spawned per tick
+	SplashFraction     float64 `json:"splashFraction"`     // Share of an impacting cell's volume thrown up
+	PipeRate           float64 `json:"pipeRate"`           // Pipe flow per unit of head difference
+	AcidErosion        float64 `json:"acidErosion"`        // Obstacle cells eaten per second by full strength acid
+	SaltDissolve       float64 `json:"saltDissolve"`       // Salinity gained per second by fresh water touching salt
+	SaltDiffusion      float64 `json:"saltDiffusion"`      // Share of the salinity difference evened out per tick
+	VortexDrain        float64 `json:"vortexDrain"`        // Volume per second a vortex swallows
+	FanStrength        float64 `json:"fanStrength"`        // Speed a fan adds per tick right in front of it
+	HeaterPower        float64 `json:"heaterPower"`        // °C per second a heater adds to each cell it touches
+	HeatConduction     float64 `json:"heatConduction"`     // Share of the temperature difference evened out per tick
+	SpringRate         float64 `json:"springRate"`         // Volume per second a running spring adds
+	RainRate           float64 `json:"rainRate"`           // Drops per second falling on each open sky cell
+	RainGust           float64 `json:"rainGust"`           // Strongest sideways speed a gust gives the rain or snow
+	SnowRate           float64 `json:"snowRate"`           // Flakes per second falling from each open sky cell
+	MossGrowth         float64 `json:"mossGrowth"`         // Share of the bare face moss covers per second while wet
+	MossDrag           float64 `json:"mossDrag"`           // Speed lost per tick by water between fully mossy faces
+	GeneratorRate      float64 `json:"generatorRate"`      // Volume a generator pours into each of its cells per top up
+	Evaporation        float64 `json:"evaporation"`        // Volume per second evaporating from each surface cell open to the air
+
+	SedimentPickupSpeed  float64 `json:"sedimentPickupSpeed"`  // Slowest flow that picks sand up
+	SedimentDepositSpeed float64 `json:"sedimentDepositSpeed"` // Fastest flow that lets its load settle
+	SedimentErosion      float64 `json:"sedimentErosion"`      // Sand scoured per second from each face by very fast flow
+	SedimentSettle       float64 `json:"sedimentSettle"`       // Share of the load settling per second in slow flow
+
+	VOF bool `json:"vof"` // Reconstruct sharp interfaces in surface cells (advanced)
+}
+
+func DefaultParams() Params {
+	return Params{
+		FallRate:           0.5,
+		CascadeRate:        0.1,
+		DiagonalRate:       0.25,
+		RampSlideRate:      0.4,
+		PressureRate:       0.05,
+		PressureSideScale:  0.7,
+		VelocityDamping:    0.9,
+		AdvectionRate:      0.5,
+		Restitution:        0.3,
+		GravityAccel:       0.05,
+		FlowImpulse:        0.5,
+		WaveSpeed:          0.15,
+		WaveDamping:        0.05,
+		WindCoupling:       0.02,
+		WindBias:           0.8,
+		InterfaceTension:   0.9,
+		GravelPermeability: 0.2,
+		ClothPermeability:  0.05,
+		PlantPermeability:  0.15,
+		DyeDiffusion:       0.02,
+		SplashImpactSpeed:  0.5,
+		SplashFallHeight:   3,
+		SplashBudget:       8,
+		SplashFraction:     0.3,
+		PipeRate:           0.4,
+		AcidErosion:        0.1,
+		SaltDissolve:       0.05,
+		SaltDiffusion:      0.01,
+		VortexDrain:        2.0,
+		FanStrength:        0.15,
+		HeaterPower:        30.0,
+		HeatConduction:     0.1,
+		SpringRate:         3.0,
+		RainRate:           0.5,
+		RainGust:           0.3,
+		SnowRate:           0.3,
+		MossGrowth:         0.005,
+		MossDrag:           0.05,
+		GeneratorRate:      1.0,
+		Evaporation:        0,
+
+		SedimentPickupSpeed:  0.25,
+		SedimentDepositSpeed: 0.08,
+		SedimentErosion:      0.2,
+		SedimentSettle:       0.5,
+	}
+}
+
+// ParamSpec describes the safe range of a parameter
+type ParamSpec struct {
+	Name     string
+	Field    func(p *Params) *float64
+	Min, Max float64
+}
+
+var paramSpecs = []ParamSpec{
+	{"fallRate", func(p *Params) *float64 { return &p.FallRate }, 0.1, 1.0},
+	{"cascadeRate", func(p *Params) *float64 { return &p.CascadeRate }, 0.01, 0.3},
+	{"diagonalRate", func(p *Params) *float64 { return &p.DiagonalRate }, 0.05, 0.5},
+	{"rampSlideRate", func(p *Params) *float64 { return &p.RampSlideRate }, 0.1, 0.8},
+	{"pressureRate", func(p *Params) *float64 { return &p.PressureRate }, 0.0, 0.2},
+	{"pressureSideScale", func(p *Params) *float64 { return &p.PressureSideScale }, 0.2, 1.0},
+	{"velocityDamping", func(p *Params) *float64 { return &p.VelocityDamping }, 0.5, 0.98},
+	{"advectionRate", func(p *Params) *float64 { return &p.AdvectionRate }, 0.0, 1.0},
+	{"restitution", func(p *Params) *float64 { return &p.Restitution }, 0.0, 0.8},
+	{"gravityAccel", func(p *Params) *float64 { return &p.GravityAccel }, 0.01, 0.2},
+	{"flowImpulse", func(p *Params) *float64 { return &p.FlowImpulse }, 0.0, 1.5},
+	{"waveSpeed", func(p *Params) *float64 { return &p.WaveSpeed }, 0.0, 0.4},
+	{"waveDamping", func(p *Params) *float64 { return &p.WaveDamping }, 0.01, 0.3},
+	{"windCoupling", func(p *Params) *float64 { return &p.WindCoupling }, 0.0, 0.1},
+	{"windBias", func(p *Params) *float64 { return &p.WindBias }, 0.0, 1.0},
+	{"interfaceTension", func(p *Params) *float64 { return &p.InterfaceTension }, 0.0, 1.0},
+	{"gravelPermeability", func(p *Params) *float64 { return &p.GravelPermeability }, 0.05, 0.5},
+	{"clothPermeability", func(p *Params) *float64 { return &p.ClothPermeability }, 0.01, 0.2},
+	{"plantPermeability", func(p *Params) *float64 { return &p.PlantPermeability }, 0.05, 0.5},
+	{"dyeDiffusion", func(p *Params) *float64 { return &p.DyeDiffusion }, 0.0, 0.1},
+	{"splashImpactSpeed", func(p *Params) *float64 { return &p.SplashImpactSpeed }, 0.2, 1.0},
+	{"splashFallHeight", func(p *Params) *float64 { return &p.SplashFallHeight }, 1, 10},
+	{"splashBudget", func(p *Params) *float64 { return &p.SplashBudget }, 0, 32},
+	{"splashFraction", func(p *Params) *float64 { return &p.SplashFraction }, 0.0, 0.6},
+	{"pipeRate", func(p *Params) *float64 { return &p.PipeRate }, 0.1, 1.0},
+	{"acidErosion", func(p *Params) *float64 { return &p.AcidErosion }, 0.0, 0.5},
+	{"saltDissolve", func(p *Params) *float64 { return &p.SaltDissolve }, 0.0, 0.2},
+	{"saltDiffusion", func(p *Params) *float64 { return &p.SaltDiffusion }, 0.0, 0.05},
+	{"vortexDrain", func(p *Params) *float64 { return &p.VortexDrain }, 0.0, 10.0},
+	{"fanStrength", func(p *Params) *float64 { return &p.FanStrength }, 0.0, 0.5},
+	{"heaterPower", func(p *Params) *float64 { return &p.HeaterPower }, 0.0, 120.0},
+	{"heatConduction", func(p *Params) *float64 { return &p.HeatConduction }, 0.0, 0.5},
+	{"springRate", func(p *Params) *float64 { return &p.SpringRate }, 0.0, 20.0},
+	{"rainRate", func(p *Params) *float64 { return &p.RainRate }, 0.0, 5.0},
+	{"rainGust", func(p *Params) *float64 { return &p.RainGust }, 0.0, 1.0},
+	{"snowRate", func(p *Params) *float64 { return &p.SnowRate }, 0.0, 5.0},
+	{"mossGrowth", func(p *Params) *float64 { return &p.MossGrowth }, 0.0, 0.05},
+	{"mossDrag", func(p *Params) *float64 { return &p.MossDrag }, 0.0, 0.2},
+	{"generatorRate", func(p *Params) *float64 { return &p.GeneratorRate }, 0.05, 1.0},
+	{"evaporation", func(p *Params) *float64 { return &p.Evaporation }, 0.0, 0.05},
+	{"sedimentPickupSpeed", func(p *Params) *float64 { return &p.SedimentPickupSpeed }, 0.1, 1.0},
+	{"sedimentDepositSpeed", func(p *Params) *float64 { return &p.SedimentDepositSpeed }, 0.0, 0.2},
+	{"sedimentErosion", func(p *Params) *float64 { return &p.SedimentErosion }, 0.0, 1.0},
+	{"sedimentSettle", func(p *Params) *float64 { return &p.SedimentSettle }, 0.0, 2.0},
+}
+
+// ParamSpecs lists the tunable parameters with their safe ranges. The list
+// is a copy, changing it doesn't change the ranges.
+func ParamSpecs() []ParamSpec {
+	return slices.Clone(paramSpecs)
+}
+
+// SaveParams writes the parameters as indented JSON
+func SaveParams(path string, p Params) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// LoadParams reads parameters written by SaveParams. Missing fields keep
+// their default value.
+func LoadParams(path string) (Params, error) {
+	p := DefaultParams()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	err = json.Unmarshal(data, &p)
+	return p, err
+}
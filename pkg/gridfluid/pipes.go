@@ -0,0 +1,165 @@
+package gridfluid
+
+/*
+* Pipes
+*
+* Pipe cells are enclosed: the open-channel rules treat them as solid, and
+* water only moves through their openings in a flow pass of their own.
+* Inside a pipe water doesn't fall or spread, it just follows the head
+* difference between connected cells, so a pipe fed from the bottom of a
+* tank carries water across the map and up walls as far as the tank's
+* level allows. An opening facing an ordinary cell is a mouth where water
+* enters or leaves the pipe.
+ */
+
+// Pipe is the set of sides a pipe cell opens to
+type Pipe uint8
+
+const PipeNone Pipe = 0
+
+const (
+	PipeUp Pipe = 1 << iota
+	PipeDown
+	PipeLeft
+	PipeRight
+
+	PipeHorizontal = PipeLeft | PipeRight
+	PipeVertical   = PipeUp | PipeDown
+)
+
+// pipeSides pairs each opening with the grid offset it faces
+var pipeSides = [4]struct {
+	side Pipe
+	dir  [2]int
+}{{PipeUp, [2]int{0, -1}}, {PipeDown, [2]int{0, 1}}, {PipeLeft, [2]int{-1, 0}}, {PipeRight, [2]int{1, 0}}}
+
+// pipeSide returns the opening facing the grid offset (dx, dy)
+func pipeSide(dx, dy int) Pipe {
+	switch [2]int{dx, dy} {
+	case [2]int{0, -1}:
+		return PipeUp
+	case [2]int{0, 1}:
+		return PipeDown
+	case [2]int{-1, 0}:
+		return PipeLeft
+	case [2]int{1, 0}:
+		return PipeRight
+	}
+	return PipeNone
+}
+
+// opens reports whether the pipe has an opening towards grid offset (dx, dy)
+func (p Pipe) opens(dx, dy int) bool {
+	return p&pipeSide(dx, dy) != 0
+}
+
+// connected reports whether water may pass from a into its neighbour b at
+// grid offset (dx, dy). Pipes only connect through their openings.
+func connected(a, b *Droplet, dx, dy int) bool {
+	if a.Pipe != PipeNone && !a.Pipe.opens(dx, dy) {
+		return false
+	}
+	return b.Pipe == PipeNone || b.Pipe.opens(-dx, -dy)
+}
+
+// CreatePipe lays a pipe along a path of cells joined by straight
+// horizontal or vertical runs, turning corners where the path does. The
+// ends of the path become mouths facing away from it, unless they land on
+// an existing pipe, which then gains a branch (a T-junction).
+func CreatePipe(path [][2]int, state *[][]Droplet) {
+	inGrid := func(x, y int) bool {
+		return y >= 0 && y < len(*state) && x >= 0 && x < len((*state)[0])
+	}
+	var cells [][2]int
+	for i, p := range path {
+		if i == 0 {
+			cells = append(cells, p)
+			continue
+		}
+		// Diagonal legs go sideways first
+		x, y := cells[len(cells)-1][0], cells[len(cells)-1][1]
+		for x != p[0] || y != p[1] {
+			if x != p[0] {
+				x += sign0(p[0] - x)
+			} else {
+				y += sign0(p[1] - y)
+			}
+			cells = append(cells, [2]int{x, y})
+		}
+	}
+	if len(cells) < 2 {
+		return
+	}
+
+	existing := make([]bool, len(cells))
+	for i, c := range cells {
+		existing[i] = inGrid(c[0], c[1]) && (*state)[c[1]][c[0]].Pipe != PipeNone
+	}
+	open := func(i int, dx, dy int) {
+		c := cells[i]
+		if !inGrid(c[0], c[1]) {
+			return
+		}
+		d := &(*state)[c[1]][c[0]]
+		d.Material = MaterialOpen
+		d.Ramp = RampNone
+		d.Pipe |= pipeSide(dx, dy)
+	}
+	for i := 1; i < len(cells); i++ {
+		dx, dy := cells[i][0]-cells[i-1][0], cells[i][1]-cells[i-1][1]
+		open(i-1, dx, dy)
+		open(i, -dx, -dy)
+	}
+
+	// Mouths at the free ends
+	last := len(cells) - 1
+	if !existing[0] {
+		open(0, cells[0][0]-cells[1][0], cells[0][1]-cells[1][1])
+	}
+	if !existing[last] {
+		open(last, cells[last][0]-cells[last-1][0], cells[last][1]-cells[last-1][1])
+	}
+}
+
+// flowPipes moves water through pipe openings from higher to lower head,
+// both between pipe cells and in and out of the mouths
+func (g *Game) flowPipes(state *[][]Droplet) {
+	g.bottomUp(len((*state)[0]), len(*state), func(x, y int) {
+		current := &(*state)[y][x]
+		if current.Pipe == PipeNone {
+			return
+		}
+		for _, dpos := range pressureDirections {
+			dx, dy := g.Gravity.toGrid(dpos[0], dpos[1])
+			nx, ny, ok := g.neighbor(x, y, dx, dy, state)
+			if !ok {
+				continue
+			}
+			neighbor := &(*state)[ny][nx]
+			if neighbor.Material.IsSolid() || !connected(current, neighbor, dx, dy) {
+				continue
+			}
+
+			// Head difference; a neighbour below sits one cell lower
+			headDiff := current.Pressure - neighbor.Pressure + float64(dpos[1])
+			from, to := current, neighbor
+			if headDiff < 0 && neighbor.Pipe == PipeNone {
+				// Mouths have no pass of their own, so pipes draw water in
+				from, to = neighbor, current
+				dx, dy, headDiff = -dx, -dy, -headDiff
+			}
+			if headDiff < pressureEpsilon {
+				continue
+			}
+			amount := min(g.Params.PipeRate*headDiff, max(from.Volume, 0), remainder(*to, 1.0))
+			if amount <= 0 {
+				continue
+			}
+			moved := g.transferVolume(from, to, amount)
+			if to.Volume < fullCell {
+				to.Pressure = to.Volume
+			}
+			g.push(to, dx, dy, moved)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package gridfluid
+
+/*
+* Plants
+*
+* Plants are a porous material with a life of their own. Every plant cell
+* drinks a little of the water in and around it, passes what it drank
+* along the stem, and grows a new cell upward once it has drunk enough. A
+* plant that goes dry withers and eventually dies back, leaving the cell
+* open again. Water soaks slowly through the leaves, so a hedge works as a
+* soft obstacle. The water drunk leaves the simulation and is tallied in
+* Game.Absorbed.
+ */
+
+const (
+	plantDrink      = 0.01 // Volume drunk per second from each wet cell touching the plant
+	plantNourish    = 3.0  // Growth gained per unit of volume drunk
+	plantUpkeep     = 0.02 // Growth used up per second just staying alive
+	plantShare      = 0.05 // Share of the growth difference passed along the stem per tick
+	plantSeedGrowth = 0.5  // Growth of a fresh seedling or a newly grown cell
+	plantMaxGrowth  = 2.0  // A plant cell stops drinking once it holds this much
+)
+
+var plantOffsets = [5][2]int{{0, 0}, {0, -1}, {0, 1}, {-1, 0}, {1, 0}}
+
+// PlantSeed turns an open cell into a seedling
+func (g *Game) PlantSeed(x, y int) bool {
+	if !g.InBounds(x, y) {
+		return false
+	}
+	d := &g.State[y][x]
+	if d.Pipe != PipeNone || d.Material != MaterialOpen {
+		return false
+	}
+	d.Material = MaterialPlant
+	d.Growth = plantSeedGrowth
+	return true
+}
+
+// updatePlants lets every plant drink, share along the stem, grow and wither
+func (g *Game) updatePlants(state *[][]Droplet) {
+	for y := range *state {
+		for x := range (*state)[y] {
+			d := &(*state)[y][x]
+			if d.Material != MaterialPlant {
+				continue
+			}
+
+			// Drink from the cell itself and its neighbours
+			for _, off := range plantOffsets {
+				nx, ny, ok := g.neighbor(x, y, off[0], off[1], state)
+				if !ok || d.Growth >= plantMaxGrowth {
+					continue
+				}
+				n := &(*state)[ny][nx]
+				if n.Material.IsSolid() || n.Pipe != PipeNone || n.Volume <= WetThreshold {
+					continue
+				}
+				amount := min(plantDrink/ticksPerSecond, n.Volume)
+				n.Volume -= amount
+				g.Absorbed += amount
+				g.recordSink(nx, ny, amount)
+				d.Growth += amount * plantNourish
+			}
+
+			// Share with the plant cells above and below, so roots feed the tip
+			for _, down := range []int{-1, 1} {
+				nx, ny, ok := g.local(x, y, 0, down, state)
+				if !ok {
+					continue
+				}
+				n := &(*state)[ny][nx]
+				if n.Material != MaterialPlant || n.Growth >= d.Growth {
+					continue
+				}
+				share := (d.Growth - n.Growth) * plantShare
+				d.Growth -= share
+				n.Growth += share
+			}
+
+			d.Growth -= plantUpkeep / ticksPerSecond
+			if d.Growth <= 0 {
+				d.Material = MaterialOpen
+				d.Growth = 0
+				continue
+			}
+
+			// Grow a new cell upward once there's enough to spare
+			if d.Growth < 1+plantSeedGrowth {
+				continue
+			}
+			ux, uy, ok := g.local(x, y, 0, -1, state)
+			if !ok {
+				continue
+			}
+			up := &(*state)[uy][ux]
+			if up.Pipe != PipeNone || up.Material != MaterialOpen {
+				continue
+			}
+			up.Material = MaterialPlant
+			up.Growth = plantSeedGrowth
+			d.Growth -= plantSeedGrowth
+		}
+	}
+}
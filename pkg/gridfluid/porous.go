@@ -0,0 +1,28 @@
+package gridfluid
+
+/*
+* Porous materials
+ */
+
+// conductance returns how freely water flows through the cell, from 0
+// (solid) to 1 (open). Pipes are solid to everything but their own pass.
+func (g *Game) conductance(d *Droplet) float64 {
+	if d.Pipe != PipeNone {
+		return 0
+	}
+	return d.Material.FlowRate(&g.Params)
+}
+
+// isOpen reports whether water can fall freely through the cell
+func isOpen(d *Droplet) bool {
+	return d.Pipe == PipeNone && d.Material == MaterialOpen
+}
+
+// CreatePorousBlock fills a w by h rectangle with a porous material
+func CreatePorousBlock(x, y, w, h int, material MaterialID, state *[][]Droplet) {
+	for cy := y; cy < y+h && cy < len(*state); cy++ {
+		for cx := x; cx < x+w && cx < len((*state)[cy]); cx++ {
+			(*state)[cy][cx].Material = material
+		}
+	}
+}
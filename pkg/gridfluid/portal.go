@@ -0,0 +1,97 @@
+package gridfluid
+
+/*
+* Portals
+*
+* Portals come in linked pairs of open cells sharing a channel number.
+* Each tick the fuller end hands half the difference to its twin, as if
+* the two cells were neighbours, so water poured into one end comes out of
+* the other. The transfer goes through transferVolume and keeps the water's
+* velocity, dye and temperature, so a stream falling into a portal keeps
+* falling out of its twin. A portal without a twin does nothing.
+ */
+
+const maxPortals = 255 // Channel numbers available, 0 means no portal
+
+// CreatePortalPair links two open cells as the ends of a portal
+func CreatePortalPair(a, b [2]int, channel uint8, state *[][]Droplet) {
+	for _, p := range [][2]int{a, b} {
+		d := &(*state)[p[1]][p[0]]
+		d.Material = MaterialOpen
+		d.Portal = channel
+	}
+}
+
+// PlacePortal removes the portal at (x, y) together with its twin, or
+// opens a portal end there. Every second end placed closes the pair.
+func (g *Game) PlacePortal(x, y int) bool {
+	if !g.InBounds(x, y) {
+		return false
+	}
+	d := &g.State[y][x]
+	if d.Portal != 0 {
+		channel := d.Portal
+		for _, p := range portalEnds(&g.State)[channel] {
+			g.State[p[1]][p[0]].Portal = 0
+		}
+		if g.openPortal == channel {
+			g.openPortal = 0
+		}
+		return true
+	}
+	if d.Material.IsSolid() || d.Pipe != PipeNone {
+		return false
+	}
+
+	if g.openPortal != 0 {
+		d.Portal = g.openPortal
+		g.openPortal = 0
+		return true
+	}
+	ends := portalEnds(&g.State)
+	for channel := 1; channel <= maxPortals; channel++ {
+		if len(ends[channel]) == 0 {
+			d.Portal = uint8(channel)
+			g.openPortal = d.Portal
+			return true
+		}
+	}
+	return false
+}
+
+// OpenPortal is the channel of the portal end still waiting for its twin,
+// zero if there is none
+func (g *Game) OpenPortal() uint8 {
+	return g.openPortal
+}
+
+// portalEnds lists the cells of every portal by channel
+func portalEnds(state *[][]Droplet) *[maxPortals + 1][][2]int {
+	var ends [maxPortals + 1][][2]int
+	for y := range *state {
+		for x := range (*state)[y] {
+			if c := (*state)[y][x].Portal; c != 0 {
+				ends[c] = append(ends[c], [2]int{x, y})
+			}
+		}
+	}
+	return &ends
+}
+
+// teleport evens out the volume between the two ends of every portal
+func (g *Game) teleport(state *[][]Droplet) {
+	for _, ends := range portalEnds(state) {
+		if len(ends) != 2 {
+			continue
+		}
+		a := &(*state)[ends[0][1]][ends[0][0]]
+		b := &(*state)[ends[1][1]][ends[1][0]]
+		if a.Material.IsSolid() || b.Material.IsSolid() {
+			continue
+		}
+		if a.Volume < b.Volume {
+			a, b = b, a
+		}
+		g.transferVolume(a, b, (a.Volume-b.Volume)/2)
+	}
+}
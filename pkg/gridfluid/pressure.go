@@ -0,0 +1,128 @@
+package gridfluid
+
+/*
+* Pressure
+*
+* Pressure is solved as hydrostatic head rather than counted up each column,
+* so it carries through water sealed under an obstacle. Cells that are not
+* full have open water above them and their pressure is just their depth.
+* Full cells relax towards the head of the water around them, which lets
+* the far side of a U-bend feel the weight of the taller column and
+* connected vessels settle to a common level. Air trapped in a sealed
+* pocket adds its own pressure to the cells it fills, see air.go.
+*
+* The head of a cell is its pressure plus its height along gravity. Water
+* moves from higher to lower head; a full cell pushes up into the cell
+* above once its pressure exceeds a cell's worth of water.
+ */
+
+const (
+	fullCell           = 0.95   // Volume above which a cell counts as full
+	pressureIterations = 20     // Relaxation sweeps per update, warm started
+	pressureEpsilon    = 0.0001 // Head difference below which nothing flows
+)
+
+// Neighbour offsets in the gravity frame: up, down, left, right
+var pressureDirections = [4][2]int{{0, -1}, {0, 1}, {-1, 0}, {1, 0}}
+
+// computePressures relaxes the pressure of full cells towards hydrostatic
+// equilibrium, starting from last update's solution
+func (g *Game) computePressures(state *[][]Droplet) {
+	for y := range *state {
+		for x := range (*state)[y] {
+			if !g.chunkActive(x, y) {
+				continue
+			}
+			d := &(*state)[y][x]
+			switch {
+			case d.Material.IsSolid():
+				d.Pressure = 0
+			case d.Volume < fullCell:
+				d.Pressure = max(d.Volume, 0)
+			default:
+				d.Pressure = max(d.Pressure, d.Volume)
+			}
+		}
+	}
+	// Trapped air presses on the water around it
+	g.trapAir(state)
+
+	for range pressureIterations {
+		g.bottomUpBanded(len((*state)[0]), len(*state), func(x, y int) {
+			if !g.chunkActive(x, y) {
+				return
+			}
+			d := &(*state)[y][x]
+			if d.Material.IsSolid() || d.Volume < fullCell {
+				return
+			}
+			sum, n := 0.0, 0
+			for _, dpos := range pressureDirections {
+				dx, dy := g.Gravity.toGrid(dpos[0], dpos[1])
+				nx, ny, ok := g.neighbor(x, y, dx, dy, state)
+				if !ok || (*state)[ny][nx].Material.IsSolid() || !connected(d, &(*state)[ny][nx], dx, dy) {
+					continue
+				}
+				// The neighbour's head taken relative to this cell's height
+				sum += (*state)[ny][nx].Pressure - float64(dpos[1])
+				n++
+			}
+			// Connection cells to other layers sit at the same height
+			for _, l := range g.linked(x, y, d) {
+				sum += l.Pressure
+				n++
+			}
+			if n > 0 {
+				d.Pressure = max(sum/float64(n), d.Volume)
+			}
+		})
+	}
+}
+
+// supports reports whether a full cell is under enough pressure to hold up
+// the water resting on it, like the rising side of a U-bend
+func supports(below, above *Droplet) bool {
+	return below.Pressure >= above.Pressure+1
+}
+
+// applyPressureFlow moves water towards neighbours with a lower head
+func (g *Game) applyPressureFlow(x, y int, newState *[][]Droplet) {
+	current := &(*newState)[y][x]
+	if current.Material.IsSolid() || current.Volume <= 0 {
+		return
+	}
+
+	for _, dpos := range pressureDirections {
+		nx, ny, ok := g.local(x, y, dpos[0], dpos[1], newState)
+		if !ok {
+			continue
+		}
+		neighbor := &(*newState)[ny][nx]
+		if neighbor.Material.IsSolid() || g.conductance(neighbor) == 0 {
+			continue
+		}
+
+		// Head difference; a neighbour below sits one cell lower
+		headDiff := current.Pressure - neighbor.Pressure + float64(dpos[1])
+		if headDiff < pressureEpsilon {
+			continue
+		}
+		flow := g.Params.PressureRate * headDiff
+		if dpos[1] == 0 {
+			flow *= g.Params.PressureSideScale
+		}
+		moved := g.fill(current, neighbor, 1.0, min(flow, current.Volume))
+
+		// Keep the head of the receiving cell consistent for the cells
+		// processed after this one
+		if neighbor.Volume < fullCell {
+			neighbor.Pressure = neighbor.Volume
+		}
+
+		dx, dy := g.Gravity.toGrid(dpos[0], dpos[1])
+		g.push(neighbor, dx, dy, moved)
+		if current.Volume <= 0 {
+			return
+		}
+	}
+}
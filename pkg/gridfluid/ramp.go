@@ -0,0 +1,121 @@
+package gridfluid
+
+/*
+* Ramps
+*
+* A ramp is a 45° half tile obstacle: the solid half sits below the
+* diagonal and the other half is open, through the top and the low side.
+* The cell is an obstacle to every rule but these, and its volume is the
+* water in the open half, up to RampCapacity. Water falls into it from
+* above and runs out of the low side, and water above a full one slides
+* off towards the low side too. Like surface waves, ramps sit out while
+* gravity is rotated.
+ */
+
+// RampCapacity is the volume the open half of a ramp tile holds
+const RampCapacity = 0.5
+
+// Ramp describes a 45° half tile obstacle
+type Ramp int
+
+const (
+	RampNone  Ramp = iota
+	RampLeft       // High on the right, water slides left
+	RampRight      // High on the left, water slides right
+)
+
+// Horizontal direction water is deflected towards
+func (r Ramp) dir() int {
+	switch r {
+	case RampLeft:
+		return -1
+	case RampRight:
+		return 1
+	}
+	return 0
+}
+
+// CreateRamp builds a staircase of empty ramp tiles starting at (x, y) and
+// going one cell down per cell in the slide direction
+func CreateRamp(x, y, length int, ramp Ramp, state *[][]Droplet) {
+	for offset := 0; offset < length; offset++ {
+		cx, cy := x+offset*ramp.dir(), y+offset
+		if cy < 0 || cy >= len(*state) || cx < 0 || cx >= len((*state)[0]) {
+			return
+		}
+		(*state)[cy][cx].Material = MaterialStone
+		(*state)[cy][cx].Ramp = ramp
+		(*state)[cy][cx].Volume = 0
+	}
+}
+
+// holdsWater reports whether the volume of a cell is water in the world:
+// that of open cells, ice and the open half of ramps
+func holdsWater(d *Droplet) bool {
+	return !d.Material.IsSolid() || d.Ice || d.Ramp != RampNone
+}
+
+// tryRampDeflection lets water above a ramp fall into its open half, and
+// what doesn't fall in slide towards the low side, first diagonally down and
+// otherwise sideways. Reports whether the cell below is a ramp.
+func (g *Game) tryRampDeflection(x, y int, state *[][]Droplet) bool {
+	bx, by, ok := g.neighbor(x, y, 0, 1, state)
+	if !ok || g.Gravity != GravityDown {
+		return false
+	}
+	below := &(*state)[by][bx]
+	if !below.Material.IsSolid() || below.Ramp == RampNone {
+		return false
+	}
+
+	current := &(*state)[y][x]
+	// Falling in, it is turned down the slope
+	g.push(below, below.Ramp.dir(), 1, g.fillRamp(current, below, RampCapacity, g.Params.FallRate))
+	nx, _, ok := g.neighbor(x, y, below.Ramp.dir(), 0, state)
+	if !ok || current.Volume <= 0 {
+		return true
+	}
+
+	if diag := &(*state)[by][nx]; g.conductance(diag) > 0 {
+		g.push(diag, below.Ramp.dir(), 1, g.fill(current, diag, 1.0, g.Params.FallRate))
+	}
+	if side := &(*state)[y][nx]; g.conductance(side) > 0 && current.Volume > 0 {
+		g.push(side, below.Ramp.dir(), 0, g.fill(current, side, 1.0, g.Params.RampSlideRate))
+	}
+	return true
+}
+
+// drainRamp runs the water in the open half of the ramp at (x, y) out of
+// its low side
+func (g *Game) drainRamp(x, y int, state *[][]Droplet) {
+	d := &(*state)[y][x]
+	if d.Volume <= 0 || g.Gravity != GravityDown {
+		return
+	}
+	dir := d.Ramp.dir()
+	nx, ny, ok := g.neighbor(x, y, dir, 0, state)
+	if !ok || g.conductance(&(*state)[ny][nx]) <= 0 {
+		return
+	}
+	side := &(*state)[ny][nx]
+	if moved := g.fillRamp(d, side, 1.0, g.Params.RampSlideRate); moved > 0 {
+		g.push(side, dir, 1, moved)
+		g.note("ramp: run out of the low side")
+	}
+}
+
+// fillRamp is fill for water crossing the open face of a ramp, which
+// conductance takes for a wall
+func (g *Game) fillRamp(current, target *Droplet, maxVolume, flowRate float64) float64 {
+	transfer := min(remainder(*target, maxVolume), flowRate, max(current.Volume, 0))
+	if transfer <= 0 {
+		return 0
+	}
+	open := func(d *Droplet) float64 {
+		if d.Ramp != RampNone && d.Material.IsSolid() {
+			return 1
+		}
+		return g.conductance(d)
+	}
+	return g.transferVolume(current, target, transfer*min(open(current), open(target)))
+}
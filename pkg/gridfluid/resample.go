@@ -0,0 +1,389 @@
+package gridfluid
+
+import (
+	"errors"
+	"math"
+)
+
+/*
+* Re-resolution
+*
+* The grid can be rebuilt at half or double its tile size while it runs.
+* Volumes are fill fractions, so refining hands every cell's water on to
+* the 2x2 cells replacing it unchanged, and coarsening fills a cell with a
+* quarter of the water of the 2x2 block it replaces; the scalars carried
+* with the volume are mixed by volume like any other flow. A coarse cell
+* is solid when at least half of its block was, so thin walls thicken
+* rather than vanish. Water left inside a cell that turned solid is poured
+* into the nearest cells with room. Things that live on a single cell, like
+* portals and sensors, go to the top left fine cell of their block, and
+* pipes are re-laid so their openings still meet. The world keeps its
+* size in pixels, and keeps filling the window; any row or column left
+* over is cropped and its water poured back in. Velocities, pressures, entities and the volume tallies are
+* rescaled along with the grid, so the water in the scene stays the same.
+ */
+
+const (
+	minTileSize = 4
+	maxTileSize = 80
+)
+
+// SetTileSize rebuilds the scene at half or double the current tile size
+func (g *Game) SetTileSize(ts int) error {
+	switch {
+	case ts < minTileSize || ts > maxTileSize:
+		return errors.New("tile size out of range")
+	case ts != g.tileSize*2 && ts*2 != g.tileSize:
+		return errors.New("tile size can only be halved or doubled")
+	}
+	refine := ts < g.tileSize
+	scale := 0.5 // Length of an old cell in new cells
+	if refine {
+		scale = 2
+	}
+	area := scale * scale
+
+	g.liftMovers()
+	// The world keeps its size, and fills the window at least
+	w := max(len(g.State[0])*g.tileSize/ts, g.Width/ts)
+	h := max(len(g.State)*g.tileSize/ts, g.Height/ts)
+	focus := g.layer
+	for i := range g.Layers() {
+		g.swapLayer(i)
+		if refine {
+			g.State = refineState(g.State, ts)
+		} else {
+			g.State = coarsenState(g.State, ts)
+		}
+		g.State = fitState(g.State, w, h, ts)
+		g.rescaleEntities(scale, area)
+	}
+	g.swapLayer(focus)
+	g.tileSize = ts
+	g.forgetEdits()
+	g.resetEvents()
+
+	for i := range g.Outflow {
+		g.Outflow[i] *= area
+	}
+	g.Absorbed *= area
+	g.Evaporated *= area
+	g.Swallowed *= area
+	g.Supplied *= area
+	clear(g.sinks)
+
+	cell := func(v int) int { return int(math.Floor(float64(v) * scale)) }
+	for _, gauge := range g.gauges {
+		gauge.X, gauge.Y = min(cell(gauge.X), len(g.State[0])-1), min(cell(gauge.Y), len(g.State)-1)
+		gauge.lastFlux = g.State[gauge.Y][gauge.X].Flux
+	}
+	for _, r := range g.regions {
+		x1, y1 := cell(r.X+r.W-1)+1, cell(r.Y+r.H-1)+1
+		if refine {
+			x1, y1 = cell(r.X+r.W), cell(r.Y+r.H)
+		}
+		r.X, r.Y = cell(r.X), cell(r.Y)
+		r.W, r.H = x1-r.X, y1-r.Y
+		r.primed = false
+	}
+	for _, d := range g.debris {
+		d.Pos = Vector{d.Pos.X * scale, d.Pos.Y * scale}
+		d.Vel = Vector{d.Vel.X * scale, d.Vel.Y * scale}
+		d.Size *= scale
+	}
+	for _, m := range g.movers {
+		if m.size == (Vector{}) {
+			m.size = Vector{float64(m.W), float64(m.H)}
+		}
+		m.size = Vector{m.size.X * scale, m.size.Y * scale}
+		m.W, m.H = max(int(math.Round(m.size.X)), 1), max(int(math.Round(m.size.Y)), 1)
+		for i, p := range m.Path {
+			m.Path[i] = Vector{p.X * scale, p.Y * scale}
+		}
+		m.pos = Vector{m.pos.X * scale, m.pos.Y * scale}
+		m.Speed *= scale
+	}
+	g.restampMovers()
+	for _, s := range g.steam {
+		s.Pos = Vector{s.Pos.X * scale, s.Pos.Y * scale}
+		s.Vel = Vector{s.Vel.X * scale, s.Vel.Y * scale}
+	}
+	g.Sponge.Left, g.Sponge.Right = cell(g.Sponge.Left), cell(g.Sponge.Right)
+	g.Sponge.Top, g.Sponge.Bottom = cell(g.Sponge.Top), cell(g.Sponge.Bottom)
+	return nil
+}
+
+// liftMovers takes every mover off the grid so it can be resampled
+// without them
+func (g *Game) liftMovers() {
+	for _, m := range g.movers {
+		if !m.stamped {
+			continue
+		}
+		for y := m.y; y < m.y+m.H; y++ {
+			for x := m.x; x < m.x+m.W; x++ {
+				if !g.InBounds(x, y) {
+					continue
+				}
+				if d := &g.State[y][x]; d.Moving {
+					d.Moving, d.Material = false, MaterialOpen
+				}
+			}
+		}
+		m.stamped = false
+	}
+}
+
+// restampMovers puts the movers back where they are on the new grid,
+// pouring the water they cover out around them
+func (g *Game) restampMovers() {
+	spill := map[[2]int]float64{}
+	for _, m := range g.movers {
+		m.x, m.y = int(math.Round(m.pos.X)), int(math.Round(m.pos.Y))
+		for y := m.y; y < m.y+m.H; y++ {
+			for x := m.x; x < m.x+m.W; x++ {
+				if !g.InBounds(x, y) || g.State[y][x].Material.IsSolid() {
+					continue
+				}
+				d := &g.State[y][x]
+				spill[[2]int{x, y}] += math.Max(d.Volume, 0)
+				d.Volume = 0
+				d.Material, d.Moving = MaterialStone, true
+			}
+		}
+		m.stamped = true
+	}
+	pour(g.State, spill)
+}
+
+// rescaleEntities scales the things kept with the layer in State
+func (g *Game) rescaleEntities(scale, area float64) {
+	for _, s := range g.splashes {
+		s.Pos = Vector{s.Pos.X * scale, s.Pos.Y * scale}
+		s.Vel = Vector{s.Vel.X * scale, s.Vel.Y * scale}
+		s.Volume *= area
+	}
+	for _, f := range g.flakes {
+		f.Pos = Vector{f.Pos.X * scale, f.Pos.Y * scale}
+	}
+	for _, b := range g.bubbles {
+		b.Pos = Vector{b.Pos.X * scale, b.Pos.Y * scale}
+		b.Air *= area
+	}
+	for _, w := range g.wheels {
+		w.X, w.Y = int(float64(w.X)*scale), int(float64(w.Y)*scale)
+		w.Radius *= scale
+	}
+	for i, w := range g.wires {
+		g.wires[i].From = [2]int{int(float64(w.From[0]) * scale), int(float64(w.From[1]) * scale)}
+		g.wires[i].To = [2]int{int(float64(w.To[0]) * scale), int(float64(w.To[1]) * scale)}
+	}
+}
+
+// refineState splits every cell into 2x2 cells of tile size ts
+func refineState(state [][]Droplet, ts int) [][]Droplet {
+	h, w := len(state), len(state[0])
+	fine := CreateGameState(w*2, h*2, ts)
+	spill := map[[2]int]float64{}
+	for y := range state {
+		for x := range state[y] {
+			p := state[y][x]
+			for k := range 4 {
+				kx, ky := k%2, k/2
+				d := p
+				d.Size = ts
+				d.VX, d.VY = p.VX*2, p.VY*2
+				d.Pressure = p.Pressure * 2
+				if k > 0 {
+					// Single cell things stay on the top left cell
+					d.Portal, d.Vortex = 0, false
+					d.Sensor, d.Signal = SensorNone, false
+					d.Spring, d.SpringOn = false, false
+				}
+				if p.Pipe != PipeNone {
+					// The pipe runs through the top left cell and on
+					// through the cells on its right and bottom edges
+					switch {
+					case k == 0:
+					case k == 1 && p.Pipe&PipeRight != 0:
+						d.Pipe = PipeHorizontal
+					case k == 2 && p.Pipe&PipeDown != 0:
+						d.Pipe = PipeVertical
+					default:
+						spill[[2]int{x * 2, y * 2}] += d.Volume
+						d = Droplet{Size: ts, Material: MaterialStone, Temperature: p.Temperature}
+					}
+				}
+				fine[y*2+ky][x*2+kx] = d
+			}
+		}
+	}
+	pour(fine, spill)
+	return fine
+}
+
+// coarsenState merges every 2x2 block into a cell of tile size ts
+func coarsenState(state [][]Droplet, ts int) [][]Droplet {
+	h, w := len(state), len(state[0])
+	nh, nw := (h+1)/2, (w+1)/2
+	coarse := CreateGameState(nw, nh, ts)
+	spill := map[[2]int]float64{}
+	for y := range nh {
+		for x := range nw {
+			var kids []*Droplet
+			solid := 0
+			for k := range 4 {
+				kx, ky := x*2+k%2, y*2+k/2
+				if kx >= w || ky >= h {
+					continue
+				}
+				kid := &state[ky][kx]
+				kids = append(kids, kid)
+				if kid.Material.IsSolid() {
+					solid++
+				}
+			}
+			obstacle := solid*2 >= len(kids)
+
+			// The parent takes after its wettest kid of the winning kind
+			rep := kids[0]
+			for _, kid := range kids {
+				if kid.Material.IsSolid() == obstacle && (rep.Material.IsSolid() != obstacle || kid.Volume > rep.Volume) {
+					rep = kid
+				}
+			}
+			p := *rep
+			p.Size = ts
+			p.Pressure = rep.Pressure / 2
+			p.Volume, p.VX, p.VY = 0, 0, 0
+			p.Flux, p.Growth, p.Drift, p.Settled, p.Air = 0, 0, 0, 0, 0
+			p.Pipe = PipeNone
+
+			water := 0.0
+			held := make([]float64, len(fluids)) // Volume per fluid, a slice so ties break in a fixed order
+			for i, kid := range kids {
+				p.Flux += kid.Flux / 4
+				p.Growth += kid.Growth / 4
+				p.Drift += kid.Drift / 4
+				p.Settled += kid.Settled / 4
+				p.Air += kid.Air / 4
+				p.Wetness = math.Max(p.Wetness, kid.Wetness)
+				if p.Portal == 0 {
+					p.Portal = kid.Portal
+				}
+				if p.Sensor == SensorNone {
+					p.Sensor, p.Signal = kid.Sensor, kid.Signal
+				}
+				p.Vortex = p.Vortex || kid.Vortex
+				if kid.Spring && !p.Spring {
+					p.Spring, p.SpringOn = true, kid.SpringOn
+				}
+				if kid.Pipe != PipeNone {
+					// Keep the openings that lead out of the block
+					for _, o := range pipeSides {
+						ox, oy := i%2+o.dir[0], i/2+o.dir[1]
+						if len(kids) == 4 && kid.Pipe&o.side != 0 && (ox < 0 || ox > 1 || oy < 0 || oy > 1) {
+							p.Pipe |= o.side
+						}
+					}
+				}
+				if !holdsWater(kid) || kid.Volume <= 0 {
+					continue
+				}
+				mixTemperature(&p.Temperature, water, kid.Temperature, kid.Volume)
+				mixStagnation(&p.Stagnation, water, kid.Stagnation, kid.Volume)
+				mixConcentration(&p.Acidity, water, kid.Acidity, kid.Volume)
+				mixConcentration(&p.Salinity, water, kid.Salinity, kid.Volume)
+				mixConcentration(&p.Sediment, water, kid.Sediment, kid.Volume)
+				mixDye(&p.Dye, water, kid.Dye, kid.Volume)
+				p.VX += kid.VX * kid.Volume
+				p.VY += kid.VY * kid.Volume
+				held[kid.Fluid] += kid.Volume
+				water += kid.Volume
+			}
+			if p.Pipe != PipeNone {
+				p.Material = MaterialOpen
+			}
+			if water > 0 {
+				p.VX, p.VY = p.VX/water/2, p.VY/water/2
+				for f, v := range held {
+					if v > held[p.Fluid] || held[p.Fluid] == 0 {
+						p.Fluid = Fluid(f)
+					}
+				}
+			}
+			if p.Material.IsSolid() && !p.Ice {
+				spill[[2]int{x, y}] += water / 4
+			} else {
+				p.Volume = water / 4
+			}
+			coarse[y][x] = p
+		}
+	}
+	pour(coarse, spill)
+	return coarse
+}
+
+// fitState crops or pads a resampled grid to w by h cells, so it keeps
+// filling the window. Water in cropped cells is poured back in and the
+// solid edge carries on into new cells.
+func fitState(state [][]Droplet, w, h, ts int) [][]Droplet {
+	sh, sw := len(state), len(state[0])
+	if sw == w && sh == h {
+		return state
+	}
+	fitted := CreateGameState(w, h, ts)
+	spill := map[[2]int]float64{}
+	for y := range max(sh, h) {
+		for x := range max(sw, w) {
+			switch {
+			case x >= w || y >= h:
+				spill[[2]int{min(x, w-1), min(y, h-1)}] += heldVolume(&state[y][x])
+			case x >= sw || y >= sh:
+				if state[min(y, sh-1)][min(x, sw-1)].Material.IsSolid() {
+					fitted[y][x].Material = MaterialStone
+				}
+			default:
+				fitted[y][x] = state[y][x]
+			}
+		}
+	}
+	pour(fitted, spill)
+	return fitted
+}
+
+// pour puts water spilled out of cells that turned solid into the nearest
+// open cells with room, searching outwards from where it spilled
+func pour(state [][]Droplet, spill map[[2]int]float64) {
+	h, w := len(state), len(state[0])
+	for y := range h {
+		for x := range w {
+			amount := spill[[2]int{x, y}]
+			if amount <= 0 {
+				continue
+			}
+			seen := map[[2]int]bool{{x, y}: true}
+			queue := [][2]int{{x, y}}
+			for len(queue) > 0 && amount > 0 {
+				c := queue[0]
+				queue = queue[1:]
+				d := &state[c[1]][c[0]]
+				if !d.Material.IsSolid() && d.Pipe == PipeNone {
+					if d.Volume <= WetThreshold {
+						d.Fluid = FluidWater
+					}
+					fill := math.Min(amount, remainder(*d, 1.0))
+					d.Volume += fill
+					amount -= fill
+				}
+				for _, dir := range pressureDirections {
+					n := [2]int{c[0] + dir[0], c[1] + dir[1]}
+					if n[0] >= 0 && n[0] < w && n[1] >= 0 && n[1] < h && !seen[n] {
+						seen[n] = true
+						queue = append(queue, n)
+					}
+				}
+			}
+		}
+	}
+}
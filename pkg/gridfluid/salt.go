@@ -0,0 +1,115 @@
+package gridfluid
+
+import "math"
+
+/*
+* Salt
+*
+* Salt blocks are obstacles that slowly dissolve into the water touching
+* them until nothing is left. The dissolved salt is a per-cell salinity
+* carried with the volume and spread by a slow diffusion. Brine is denser
+* than fresh water, so it sinks and pools at the bottom while the fluid
+* separation pass sorts the column by density.
+ */
+
+const (
+	saltBlockMass  = 5.0  // Salinity-volume a block releases before it is gone
+	saltDensity    = 0.2  // Extra density of saturated brine relative to water
+	densityEpsilon = 0.01 // Density difference below which cells don't swap
+)
+
+// CreateSaltBlock fills a w by h rectangle with soluble salt
+func CreateSaltBlock(x, y, w, h int, state *[][]Droplet) {
+	for cy := y; cy < y+h && cy < len(*state); cy++ {
+		for cx := x; cx < x+w && cx < len((*state)[cy]); cx++ {
+			d := &(*state)[cy][cx]
+			d.Material = MaterialStone
+			d.Salt = true
+			d.Volume = 0
+		}
+	}
+}
+
+// PlaceSalt turns a dry open cell into a salt block
+func (g *Game) PlaceSalt(x, y int) bool {
+	if !g.InBounds(x, y) {
+		return false
+	}
+	d := &g.State[y][x]
+	if d.Pipe != PipeNone || d.Material != MaterialOpen || d.Volume > WetThreshold {
+		return false
+	}
+	CreateSaltBlock(x, y, 1, 1, &g.State)
+	return true
+}
+
+// cellDensity is the density of the fluid in a cell including its salt
+func cellDensity(d *Droplet) float64 {
+	return d.Fluid.density() + d.Salinity*saltDensity
+}
+
+// dissolveSalt lets salt blocks dissolve into the water around them
+func (g *Game) dissolveSalt(state *[][]Droplet) {
+	rate := g.Params.SaltDissolve / ticksPerSecond
+	if rate <= 0 {
+		return
+	}
+	for y := range *state {
+		for x := range (*state)[y] {
+			d := &(*state)[y][x]
+			if !d.Material.IsSolid() || !d.Salt {
+				continue
+			}
+			for _, dpos := range pressureDirections {
+				nx, ny, ok := g.neighbor(x, y, dpos[0], dpos[1], state)
+				if !ok {
+					continue
+				}
+				n := &(*state)[ny][nx]
+				if n.Material.IsSolid() || n.Volume <= WetThreshold || n.Fluid == FluidOil {
+					continue
+				}
+				// Saturated water takes up no more
+				mass := rate * (1 - n.Salinity) * n.Volume
+				n.Salinity = math.Min(n.Salinity+mass/n.Volume, 1)
+				d.Dissolved += mass / saltBlockMass
+				if mass > 0 {
+					g.fizz(state, nx, ny)
+				}
+			}
+			if d.Dissolved >= 1 {
+				*d = Droplet{Size: d.Size, Temperature: d.Temperature}
+			}
+		}
+	}
+}
+
+// diffuseSalt lets salinity even out slowly between touching water cells
+func (g *Game) diffuseSalt(state *[][]Droplet) {
+	rate := g.Params.SaltDiffusion
+	if rate <= 0 {
+		return
+	}
+	blend := func(a, b *Droplet) {
+		if a.Material.IsSolid() || b.Material.IsSolid() || a.Volume <= WetThreshold || b.Volume <= WetThreshold {
+			return
+		}
+		if a.Salinity == b.Salinity || !miscible(a.Fluid, b.Fluid) {
+			return
+		}
+		// Move salt mass down the gradient, conserving the total
+		mass := rate * (a.Salinity - b.Salinity) * math.Min(a.Volume, b.Volume) / 2
+		a.Salinity -= mass / a.Volume
+		b.Salinity += mass / b.Volume
+	}
+	for y := range *state {
+		for x := range (*state)[y] {
+			if x+1 < len((*state)[y]) {
+				blend(&(*state)[y][x], &(*state)[y][x+1])
+			}
+			if y+1 < len(*state) {
+				blend(&(*state)[y][x], &(*state)[y+1][x])
+			}
+		}
+	}
+}
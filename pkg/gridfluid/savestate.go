@@ -0,0 +1,292 @@
+package gridfluid
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/gob"
+	"errors"
+	"io"
+	"os"
+)
+
+/*
+* Save states
+*
+* A save state holds the whole world, unlike a scene code which only keeps
+* what a scene is built from: every cell of every layer with its volume,
+* material, velocity and springs, the moving and floating objects, the
+* particles in flight, the devices and wires, the settings and tallies, the
+* undo history and the state of the random source. Loading one and running on gives the same
+* grid as running on from the moment it was saved.
+*
+* The file is stateMagic followed by the state as zlib compressed gob.
+* Gob matches fields by name, so saves survive fields being added.
+ */
+
+const stateMagic = "WSSTATE1"
+
+// savedState is the gob encoded form of the world
+type savedState struct {
+	TileSize  int
+	Frame     int
+	Behind    float64 // Seconds not yet simulated, see timestep.go
+	TimeScale float64
+	Rand      []byte // Marshalled random source
+
+	Params         Params
+	Wind           Vector
+	Gravity        Gravity
+	Weather        Weather
+	Sponge         Sponge
+	Boundary       [EdgeCount]BoundaryMode
+	Outflow        [EdgeCount]float64
+	Absorbed       float64
+	Evaporated     float64
+	Swallowed      float64
+	Supplied       float64
+	Seed           uint64
+	AirTemperature float64
+	Gust           float64
+	GustTarget     float64
+	OpenPortal     uint8
+
+	Layers  []savedLayer // Front to back
+	Focus   int
+	Movers  []savedMover
+	Debris  []Debris
+	Steam   []Steam
+	Gauges  []savedGauge
+	Regions []savedRegion
+
+	Undo, Redo []edit
+}
+
+type savedLayer struct {
+	State    [][]Droplet
+	Splashes []savedSplash
+	Flakes   []Flake
+	Bubbles  []Bubble
+	Wheels   []savedWheel
+	Wires    []Wire
+}
+
+type savedMover struct {
+	W, H    int
+	Path    []Vector
+	Speed   float64
+	Pos     Vector
+	Target  int
+	Forward bool
+	X, Y    int
+	Stamped bool
+	Size    Vector
+}
+
+type savedSplash struct {
+	Splash
+	Temperature, Acidity, Salinity, Sediment float64
+}
+
+type savedWheel struct {
+	Wheel
+	Omega float64
+}
+
+type savedGauge struct {
+	Gauge
+	LastFlux float64
+}
+
+type savedRegion struct {
+	Name       string
+	X, Y, W, H int
+	Lost       float64
+}
+
+// SaveState writes the whole world to path
+func (g *Game) SaveState(path string) error {
+	var buf bytes.Buffer
+	if err := g.WriteState(&buf); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// WriteState writes the whole world to w in the save state format
+func (g *Game) WriteState(w io.Writer) error {
+	rng, err := g.pcg.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	s := savedState{
+		TileSize: g.tileSize, Frame: g.frame, Behind: g.behind, TimeScale: g.TimeScale, Rand: rng,
+		Params: g.Params, Wind: g.Wind, Gravity: g.Gravity, Weather: g.Weather, Sponge: g.Sponge,
+		Boundary: g.Boundary, Outflow: g.Outflow,
+		Absorbed: g.Absorbed, Evaporated: g.Evaporated, Swallowed: g.Swallowed, Supplied: g.Supplied,
+		Seed: g.Seed, AirTemperature: g.AirTemperature,
+		Gust: g.gust, GustTarget: g.gustTarget, OpenPortal: g.openPortal,
+		Undo: g.undo, Redo: g.redo,
+		Focus: g.layer,
+	}
+	for i := range g.Layers() {
+		l := Layer{g.State, g.splashes, g.flakes, g.bubbles, g.wheels, g.wires}
+		if i != g.layer {
+			l = g.layers[i]
+		}
+		s.Layers = append(s.Layers, saveLayer(l))
+	}
+	for _, m := range g.movers {
+		s.Movers = append(s.Movers, savedMover{m.W, m.H, m.Path, m.Speed, m.pos, m.target, m.forward, m.x, m.y, m.stamped, m.size})
+	}
+	for _, d := range g.debris {
+		s.Debris = append(s.Debris, *d)
+	}
+	for _, p := range g.steam {
+		s.Steam = append(s.Steam, *p)
+	}
+	for _, gauge := range g.gauges {
+		s.Gauges = append(s.Gauges, savedGauge{*gauge, gauge.lastFlux})
+	}
+	for _, r := range g.regions {
+		s.Regions = append(s.Regions, savedRegion{r.Name, r.X, r.Y, r.W, r.H, r.Lost})
+	}
+
+	if _, err := io.WriteString(w, stateMagic); err != nil {
+		return err
+	}
+	zw := zlib.NewWriter(w)
+	if err := gob.NewEncoder(zw).Encode(&s); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func saveLayer(l Layer) savedLayer {
+	s := savedLayer{State: l.State, Wires: l.wires}
+	for _, p := range l.splashes {
+		s.Splashes = append(s.Splashes, savedSplash{*p, p.temperature, p.acidity, p.salinity, p.sediment})
+	}
+	for _, f := range l.flakes {
+		s.Flakes = append(s.Flakes, *f)
+	}
+	for _, b := range l.bubbles {
+		s.Bubbles = append(s.Bubbles, *b)
+	}
+	for _, w := range l.wheels {
+		s.Wheels = append(s.Wheels, savedWheel{*w, w.omega})
+	}
+	return s
+}
+
+// LoadState replaces the world with one written by SaveState. The grid
+// takes the size and tile size of the save.
+func (g *Game) LoadState(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return g.ReadState(bufio.NewReader(f))
+}
+
+// ReadState replaces the world with one written by WriteState
+func (g *Game) ReadState(r io.Reader) error {
+	magic := make([]byte, len(stateMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != stateMagic {
+		return errors.New("not a save state")
+	}
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return err
+	}
+	var s savedState
+	if err := gob.NewDecoder(zr).Decode(&s); err != nil {
+		return err
+	}
+
+	if len(s.Layers) == 0 || len(s.Layers[0].State) == 0 || len(s.Layers[0].State[0]) == 0 ||
+		s.Focus < 0 || s.Focus >= len(s.Layers) || s.TileSize <= 0 {
+		return errors.New("save state holds no grid")
+	}
+	h, w := len(s.Layers[0].State), len(s.Layers[0].State[0])
+	for _, l := range s.Layers {
+		if len(l.State) != h {
+			return errors.New("save state layers differ in size")
+		}
+		for _, row := range l.State {
+			if len(row) != w {
+				return errors.New("save state grid is ragged")
+			}
+		}
+	}
+	if err := g.pcg.UnmarshalBinary(s.Rand); err != nil {
+		return err
+	}
+
+	g.tileSize, g.frame, g.behind, g.TimeScale = s.TileSize, s.Frame, s.Behind, s.TimeScale
+	g.Params, g.Wind, g.Gravity, g.Weather, g.Sponge = s.Params, s.Wind, s.Gravity, s.Weather, s.Sponge
+	g.Boundary, g.Outflow = s.Boundary, s.Outflow
+	g.Absorbed, g.Evaporated, g.Swallowed, g.Supplied = s.Absorbed, s.Evaporated, s.Swallowed, s.Supplied
+	g.Seed, g.AirTemperature = s.Seed, s.AirTemperature
+	g.gust, g.gustTarget, g.openPortal = s.Gust, s.GustTarget, s.OpenPortal
+
+	g.layers = nil
+	if len(s.Layers) > 1 {
+		g.layers = make([]Layer, len(s.Layers))
+		for i, l := range s.Layers {
+			g.layers[i] = loadLayer(l)
+		}
+		// The focused layer's slot stays empty while it has the focus
+		g.layers[s.Focus] = Layer{}
+	}
+	l := loadLayer(s.Layers[s.Focus])
+	g.State, g.splashes, g.flakes, g.bubbles, g.wheels, g.wires = l.State, l.splashes, l.flakes, l.bubbles, l.wheels, l.wires
+	g.layer = s.Focus
+
+	g.movers = nil
+	for _, m := range s.Movers {
+		g.movers = append(g.movers, &Mover{W: m.W, H: m.H, Path: m.Path, Speed: m.Speed,
+			pos: m.Pos, target: m.Target, forward: m.Forward, x: m.X, y: m.Y, stamped: m.Stamped, size: m.Size})
+	}
+	g.debris = nil
+	for _, d := range s.Debris {
+		g.debris = append(g.debris, &d)
+	}
+	g.steam = nil
+	for _, p := range s.Steam {
+		g.steam = append(g.steam, &p)
+	}
+	g.gauges = nil
+	for _, gauge := range s.Gauges {
+		gauge.lastFlux = gauge.LastFlux
+		g.gauges = append(g.gauges, &gauge.Gauge)
+	}
+	g.regions = nil
+	for _, r := range s.Regions {
+		g.regions = append(g.regions, &Region{Name: r.Name, X: r.X, Y: r.Y, W: r.W, H: r.H, Lost: r.Lost})
+	}
+	g.undo, g.redo = s.Undo, s.Redo
+	g.resetEvents()
+	g.pockets, g.sinks = nil, nil
+	return nil
+}
+
+func loadLayer(s savedLayer) Layer {
+	l := Layer{State: s.State, wires: s.Wires}
+	for _, p := range s.Splashes {
+		p.temperature, p.acidity, p.salinity, p.sediment = p.Temperature, p.Acidity, p.Salinity, p.Sediment
+		l.splashes = append(l.splashes, &p.Splash)
+	}
+	for _, f := range s.Flakes {
+		l.flakes = append(l.flakes, &f)
+	}
+	for _, b := range s.Bubbles {
+		l.bubbles = append(l.bubbles, &b)
+	}
+	for _, w := range s.Wheels {
+		w.omega = w.Omega
+		l.wheels = append(l.wheels, &w.Wheel)
+	}
+	return l
+}
@@ -0,0 +1,484 @@
+package gridfluid
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+/*
+* Scene codes
+*
+* A scene code is a short text blob describing the obstacles, materials and
+* water of a grid, meant to be pasted into chat or an issue:
+*
+*	WS1.<base64(zlib(version + header + cells + trailers))>
+*
+* The version is a byte, sceneCodeVersion for the codes written now. The
+* header is the grid width and height as little-endian uint16, followed by
+* two bytes per cell: a packed kind byte and the volume quantized to 0-255.
+* Four trailers follow, each a uint16 count and then its entries, written
+* even when empty. The first lists the gauges: x and y as uint16 plus a
+* kind and a style byte per gauge. The second lists the pipe cells: x and y
+* as uint16 plus the openings byte per cell. The third lists special cells:
+* x and y as uint16 plus a kind and a data byte per cell. The fourth lists
+* the wires of the signal graph: the sensor and device cells as four uint16
+* plus a flags byte per wire. A code that ends early, or goes on after the
+* wires, is refused.
+ */
+
+const sceneCodePrefix = "WS1."
+
+// sceneCodeVersion is the format EncodeScene writes. A later version that
+// adds a trailer reads the codes of earlier ones as having none of it.
+const sceneCodeVersion = 1
+
+// Bit layout of the packed kind byte
+const (
+	kindObstacle      = 1 << 0
+	kindRampShift     = 1 // 2 bits
+	kindMaterialShift = 3 // 2 bits
+	kindFluidShift    = 5 // 2 bits
+	kindWood          = 1 << 7
+)
+
+// Kinds of special cells in the third trailer
+const (
+	specialSalt byte = iota + 1
+	specialVortex
+	specialFan
+	specialPortal
+	specialHeater
+	specialIce
+	specialSensor
+	specialGate
+	specialSpring
+	specialSnow
+	specialDrift
+	specialSand
+	specialSettled
+	specialVent
+)
+
+// fanOnBit marks a running fan in the data byte of its trailer entry
+const fanOnBit = 1 << 7
+
+// specialCell returns the trailer entry of a cell that needs one
+func specialCell(d Droplet) (kind, data byte, ok bool) {
+	switch {
+	case d.Material.IsSolid() && d.Salt:
+		return specialSalt, 0, true
+	case d.Vortex:
+		return specialVortex, 0, true
+	case d.Fan != FanNone:
+		data = byte(d.Fan)
+		if d.FanOn {
+			data |= fanOnBit
+		}
+		return specialFan, data, true
+	case d.Portal != 0:
+		return specialPortal, d.Portal, true
+	case d.Heater:
+		return specialHeater, 0, true
+	case d.Vent:
+		return specialVent, 0, true
+	case d.Ice:
+		// Ice is never warmer than freezing, store how far below it is
+		data = byte(math.Round(math.Min(math.Max(freezingPoint-d.Temperature, 0), 255)))
+		if d.Snow {
+			return specialSnow, data, true
+		}
+		return specialIce, data, true
+	case d.Material == MaterialSand:
+		return specialSand, byte(math.Round(math.Min(d.Scoured, 1) * 255)), true
+	case d.Settled > 0:
+		return specialSettled, byte(math.Round(math.Min(d.Settled/SettledFull, 1) * 255)), true
+	case d.Drift > 0:
+		return specialDrift, byte(math.Round(math.Min(d.Drift, 1) * 255)), true
+	case d.Sensor != SensorNone:
+		return specialSensor, byte(d.Sensor), true
+	case d.Gate:
+		return specialGate, 0, true
+	case d.Spring:
+		if d.SpringOn {
+			data = 1
+		}
+		return specialSpring, data, true
+	}
+	return 0, 0, false
+}
+
+// applySpecial restores a cell from its trailer entry
+func applySpecial(kind, data byte, d *Droplet) {
+	switch kind {
+	case specialSalt:
+		d.Salt = true
+	case specialVortex:
+		d.Vortex = true
+	case specialFan:
+		d.Fan = Fan(data &^ fanOnBit)
+		d.FanOn = data&fanOnBit != 0
+	case specialPortal:
+		d.Portal = data
+	case specialHeater:
+		d.Heater = true
+	case specialVent:
+		d.Vent = true
+	case specialIce, specialSnow:
+		d.Ice = true
+		d.Snow = kind == specialSnow
+		d.Temperature = freezingPoint - float64(data)
+	case specialDrift:
+		d.Drift = float64(data) / 255
+	case specialSand:
+		d.Material = MaterialSand
+		d.Scoured = float64(data) / 255
+	case specialSettled:
+		d.Settled = float64(data) / 255 * SettledFull
+	case specialSensor:
+		d.Sensor = SensorKind(data)
+	case specialGate:
+		d.Gate = true
+	case specialSpring:
+		d.Spring = true
+		d.SpringOn = data != 0
+	}
+}
+
+func packKind(d Droplet) byte {
+	var b byte
+	switch {
+	case d.Material <= MaterialPlant:
+		b |= byte(d.Material) << kindMaterialShift
+	case d.Material == MaterialWood:
+		b |= kindObstacle | kindWood
+	case d.Material.IsSolid():
+		// Sand is told apart in the special trailer, other registered
+		// materials come back as stone or open air
+		b |= kindObstacle
+	}
+	b |= byte(d.Ramp&3) << kindRampShift
+	b |= byte(d.Fluid&3) << kindFluidShift
+	return b
+}
+
+// unpackKind sets the cell's material, ramp and fluid from b, failing on
+// a fluid there is none of
+func unpackKind(b byte, d *Droplet) error {
+	switch {
+	case b&kindWood != 0:
+		d.Material = MaterialWood
+	case b&kindObstacle != 0:
+		d.Material = MaterialStone
+	default:
+		d.Material = MaterialID(b>>kindMaterialShift) & 3
+	}
+	d.Ramp = Ramp(b>>kindRampShift) & 3
+	d.Fluid = Fluid(b>>kindFluidShift) & 3
+	if int(d.Fluid) >= len(fluids) {
+		return fmt.Errorf("no fluid %d", d.Fluid)
+	}
+	// Nor is the acid concentration, pasted acid is at full strength
+	if d.Fluid == FluidAcid {
+		d.Acidity = 1
+	}
+	// Plant health isn't stored, pasted plants start out as seedlings
+	if d.Material == MaterialPlant {
+		d.Growth = plantSeedGrowth
+	}
+	return nil
+}
+
+// Flags byte of a wire in the fourth trailer
+const wireInverted = 1 << 0
+
+// EncodeScene turns the grid, its gauges and wires into a compact shareable
+// string
+func EncodeScene(state [][]Droplet, gauges []*Gauge, wires []Wire) (string, error) {
+	var raw bytes.Buffer
+	raw.WriteByte(sceneCodeVersion)
+	header := [2]uint16{uint16(len(state[0])), uint16(len(state))}
+	if err := binary.Write(&raw, binary.LittleEndian, header); err != nil {
+		return "", err
+	}
+	for y := range state {
+		for x := range state[y] {
+			d := state[y][x]
+			volume := math.Round(math.Min(1.0, math.Max(0.0, d.Volume)) * 255)
+			raw.WriteByte(packKind(d))
+			raw.WriteByte(byte(volume))
+		}
+	}
+	if err := binary.Write(&raw, binary.LittleEndian, uint16(len(gauges))); err != nil {
+		return "", err
+	}
+	for _, gauge := range gauges {
+		pos := [2]uint16{uint16(gauge.X), uint16(gauge.Y)}
+		if err := binary.Write(&raw, binary.LittleEndian, pos); err != nil {
+			return "", err
+		}
+		raw.WriteByte(byte(gauge.Kind))
+		raw.WriteByte(byte(gauge.Style))
+	}
+
+	var pipes [][2]int
+	for y := range state {
+		for x := range state[y] {
+			if state[y][x].Pipe != PipeNone {
+				pipes = append(pipes, [2]int{x, y})
+			}
+		}
+	}
+	if err := binary.Write(&raw, binary.LittleEndian, uint16(len(pipes))); err != nil {
+		return "", err
+	}
+	for _, p := range pipes {
+		pos := [2]uint16{uint16(p[0]), uint16(p[1])}
+		if err := binary.Write(&raw, binary.LittleEndian, pos); err != nil {
+			return "", err
+		}
+		raw.WriteByte(byte(state[p[1]][p[0]].Pipe))
+	}
+
+	type special struct {
+		x, y       int
+		kind, data byte
+	}
+	var specials []special
+	for y := range state {
+		for x := range state[y] {
+			if kind, data, ok := specialCell(state[y][x]); ok {
+				specials = append(specials, special{x, y, kind, data})
+			}
+		}
+	}
+	if err := binary.Write(&raw, binary.LittleEndian, uint16(len(specials))); err != nil {
+		return "", err
+	}
+	for _, s := range specials {
+		pos := [2]uint16{uint16(s.x), uint16(s.y)}
+		if err := binary.Write(&raw, binary.LittleEndian, pos); err != nil {
+			return "", err
+		}
+		raw.WriteByte(s.kind)
+		raw.WriteByte(s.data)
+	}
+
+	if err := binary.Write(&raw, binary.LittleEndian, uint16(len(wires))); err != nil {
+		return "", err
+	}
+	for _, wire := range wires {
+		ends := [4]uint16{uint16(wire.From[0]), uint16(wire.From[1]), uint16(wire.To[0]), uint16(wire.To[1])}
+		if err := binary.Write(&raw, binary.LittleEndian, ends); err != nil {
+			return "", err
+		}
+		var flags byte
+		if wire.Invert {
+			flags |= wireInverted
+		}
+		raw.WriteByte(flags)
+	}
+
+	var compressed bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&compressed, zlib.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := zw.Write(raw.Bytes()); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return sceneCodePrefix + base64.StdEncoding.EncodeToString(compressed.Bytes()), nil
+}
+
+// DecodeScene parses a string produced by EncodeScene into a fresh grid,
+// which must be cols by rows. The size is checked before anything is
+// allocated, so a pasted code can't ask for a huge grid.
+func DecodeScene(code string, cols, rows, tileSize int) ([][]Droplet, []*Gauge, []Wire, error) {
+	code = strings.TrimSpace(code)
+	if !strings.HasPrefix(code, sceneCodePrefix) {
+		return nil, nil, nil, errors.New("not a scene code")
+	}
+	compressed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(code, sceneCodePrefix))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("scene code: %w", err)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("scene code: %w", err)
+	}
+	defer zr.Close()
+
+	r := sceneReader{zr}
+	var version byte
+	if err := r.read(&version); err != nil {
+		return nil, nil, nil, fmt.Errorf("scene code version: %w", err)
+	}
+	if version == 0 || version > sceneCodeVersion {
+		return nil, nil, nil, fmt.Errorf("scene code version %d is not one this build reads", version)
+	}
+	var header [2]uint16
+	if err := r.read(&header); err != nil {
+		return nil, nil, nil, fmt.Errorf("scene code header: %w", err)
+	}
+	w, h := int(header[0]), int(header[1])
+	if w != cols || h != rows {
+		return nil, nil, nil, fmt.Errorf("scene is %dx%d, grid is %dx%d", w, h, cols, rows)
+	}
+
+	cells := make([]byte, w*h*2)
+	if err := r.read(cells); err != nil {
+		return nil, nil, nil, fmt.Errorf("scene code cells: %w", err)
+	}
+
+	state := CreateGameState(w, h, tileSize)
+	for y := range state {
+		for x := range state[y] {
+			i := (y*w + x) * 2
+			if err := unpackKind(cells[i], &state[y][x]); err != nil {
+				return nil, nil, nil, fmt.Errorf("scene code cells: cell %d,%d: %v", x, y, err)
+			}
+			state[y][x].Volume = float64(cells[i+1]) / 255
+		}
+	}
+	inGrid := func(x, y uint16) bool { return int(x) < w && int(y) < h }
+
+	var count uint16
+	if err := r.read(&count); err != nil {
+		return nil, nil, nil, fmt.Errorf("scene code gauges: %w", err)
+	}
+	gauges := make([]*Gauge, 0, count)
+	for range count {
+		var e struct {
+			X, Y        uint16
+			Kind, Style byte
+		}
+		if err := r.read(&e); err != nil {
+			return nil, nil, nil, fmt.Errorf("scene code gauges: %w", err)
+		}
+		if !inGrid(e.X, e.Y) {
+			return nil, nil, nil, fmt.Errorf("scene code gauges: cell %d,%d outside the grid", e.X, e.Y)
+		}
+		if GaugeKind(e.Kind) >= GaugeKindCount || GaugeStyle(e.Style) >= GaugeStyleCount {
+			return nil, nil, nil, fmt.Errorf("scene code gauges: no gauge of kind %d and style %d", e.Kind, e.Style)
+		}
+		gauges = append(gauges, &Gauge{X: int(e.X), Y: int(e.Y), Kind: GaugeKind(e.Kind), Style: GaugeStyle(e.Style)})
+	}
+
+	if err := r.read(&count); err != nil {
+		return nil, nil, nil, fmt.Errorf("scene code pipes: %w", err)
+	}
+	for range count {
+		var e struct {
+			X, Y     uint16
+			Openings byte
+		}
+		if err := r.read(&e); err != nil {
+			return nil, nil, nil, fmt.Errorf("scene code pipes: %w", err)
+		}
+		if !inGrid(e.X, e.Y) {
+			return nil, nil, nil, fmt.Errorf("scene code pipes: cell %d,%d outside the grid", e.X, e.Y)
+		}
+		state[e.Y][e.X].Pipe = Pipe(e.Openings)
+	}
+
+	if err := r.read(&count); err != nil {
+		return nil, nil, nil, fmt.Errorf("scene code special cells: %w", err)
+	}
+	for range count {
+		var e struct {
+			X, Y       uint16
+			Kind, Data byte
+		}
+		if err := r.read(&e); err != nil {
+			return nil, nil, nil, fmt.Errorf("scene code special cells: %w", err)
+		}
+		if !inGrid(e.X, e.Y) {
+			return nil, nil, nil, fmt.Errorf("scene code special cells: cell %d,%d outside the grid", e.X, e.Y)
+		}
+		applySpecial(e.Kind, e.Data, &state[e.Y][e.X])
+	}
+
+	if err := r.read(&count); err != nil {
+		return nil, nil, nil, fmt.Errorf("scene code wires: %w", err)
+	}
+	wires := make([]Wire, 0, count)
+	for range count {
+		var e struct {
+			Ends  [4]uint16
+			Flags byte
+		}
+		if err := r.read(&e); err != nil {
+			return nil, nil, nil, fmt.Errorf("scene code wires: %w", err)
+		}
+		if !inGrid(e.Ends[0], e.Ends[1]) || !inGrid(e.Ends[2], e.Ends[3]) {
+			return nil, nil, nil, fmt.Errorf("scene code wires: wire %d,%d to %d,%d outside the grid", e.Ends[0], e.Ends[1], e.Ends[2], e.Ends[3])
+		}
+		wires = append(wires, Wire{
+			From:   [2]int{int(e.Ends[0]), int(e.Ends[1])},
+			To:     [2]int{int(e.Ends[2]), int(e.Ends[3])},
+			Invert: e.Flags&wireInverted != 0,
+		})
+	}
+
+	// Reading to the end has zlib check the checksum as well
+	if err := r.end(); err != nil {
+		return nil, nil, nil, fmt.Errorf("scene code: %w", err)
+	}
+	return state, gauges, wires, nil
+}
+
+// sceneReader reads a scene code's stream, where running out before the
+// end of the wires is an error rather than the end of the code
+type sceneReader struct {
+	r io.Reader
+}
+
+func (s sceneReader) read(data any) error {
+	err := binary.Read(s.r, binary.LittleEndian, data)
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// end checks that nothing follows the last trailer
+func (s sceneReader) end() error {
+	var b [1]byte
+	switch _, err := io.ReadFull(s.r, b[:]); err {
+	case io.EOF:
+		return nil
+	case nil:
+		return errors.New("data after the wires")
+	default:
+		return err
+	}
+}
+
+// SceneCode encodes the current grid and gauges
+func (g *Game) SceneCode() (string, error) {
+	return EncodeScene(g.State, g.gauges, g.wires)
+}
+
+// LoadSceneCode replaces the grid with a pasted scene. The scene must match
+// the current grid size since the demo's generators sit at fixed cells.
+func (g *Game) LoadSceneCode(code string) error {
+	state, gauges, wires, err := DecodeScene(code, len(g.State[0]), len(g.State), g.tileSize)
+	if err != nil {
+		return err
+	}
+	g.State = state
+	g.gauges = gauges
+	g.wires = wires
+	g.forgetEdits()
+	g.resetEvents()
+	return nil
+}
@@ -0,0 +1,131 @@
+package gridfluid
+
+import "math"
+
+/*
+* Sediment
+*
+* Sand is a loose obstacle that fast water wears away. Water moving faster
+* than SedimentPickupSpeed scours the sand it touches and carries it as a
+* suspended load, a per-cell concentration mixed along with the volume
+* like salt. Each cell of water carries at most sedimentCapacity. Where
+* the water slows below SedimentDepositSpeed the load sinks: through the
+* water below it, and onto the floor once there is none, where it builds
+* up as a layer of settled sand. A full layer packs into a new sand
+* obstacle as soon as its water has somewhere to go, so a river slowly
+* digs out its bed upstream and fills it in downstream. Fast water picks
+* settled sand back up before it scours anything else.
+ */
+
+const (
+	SedimentCapacity = 0.3 // Most sand a cell of water can carry
+	SettledFull      = 1.0 // Settled sand that packs into an obstacle
+)
+
+// CreateSandBlock fills a w by h rectangle with loose sand
+func CreateSandBlock(x, y, w, h int, state *[][]Droplet) {
+	for cy := y; cy < y+h && cy < len(*state); cy++ {
+		for cx := x; cx < x+w && cx < len((*state)[cy]); cx++ {
+			d := &(*state)[cy][cx]
+			d.Material = MaterialSand
+			d.Scoured = 0
+			d.Volume = 0
+		}
+	}
+}
+
+// PlaceSand turns a dry open cell into sand
+func (g *Game) PlaceSand(x, y int) bool {
+	if !g.InBounds(x, y) {
+		return false
+	}
+	d := &g.State[y][x]
+	if d.Pipe != PipeNone || d.Material != MaterialOpen || d.Volume > WetThreshold {
+		return false
+	}
+	CreateSandBlock(x, y, 1, 1, &g.State)
+	return true
+}
+
+// transportSediment lets fast water pick sand up and slow water drop it
+func (g *Game) transportSediment(state *[][]Droplet) {
+	erosion := g.Params.SedimentErosion / ticksPerSecond
+	settle := g.Params.SedimentSettle / ticksPerSecond
+	pickup, deposit := g.Params.SedimentPickupSpeed, g.Params.SedimentDepositSpeed
+	g.bottomUp(len((*state)[0]), len(*state), func(x, y int) {
+		d := &(*state)[y][x]
+		if !isOpen(d) {
+			return
+		}
+		if d.Volume <= WetThreshold {
+			// Water drying up leaves its load behind
+			d.Settled += d.Sediment * math.Max(d.Volume, 0)
+			d.Sediment = 0
+			g.packSand(x, y, state)
+			return
+		}
+
+		load := d.Sediment * d.Volume
+		speed := math.Hypot(d.VX, d.VY)
+		switch {
+		case speed > pickup && pickup > 0:
+			amount := erosion * math.Min((speed-pickup)/pickup, 1)
+			room := math.Max(SedimentCapacity*d.Volume-load, 0)
+			take := math.Min(math.Min(amount, d.Settled), room)
+			d.Settled -= take
+			load += take
+			room -= take
+			for _, dpos := range pressureDirections {
+				nx, ny, ok := g.neighbor(x, y, dpos[0], dpos[1], state)
+				if !ok || room <= 0 {
+					continue
+				}
+				n := &(*state)[ny][nx]
+				if n.Material != MaterialSand || n.Moving {
+					continue
+				}
+				take := math.Min(math.Min(amount, 1-n.Scoured), room)
+				n.Scoured += take
+				load += take
+				room -= take
+				if n.Scoured >= 1-1e-9 {
+					*n = Droplet{Size: n.Size, Temperature: n.Temperature}
+				}
+			}
+		case speed < deposit && load > 0:
+			drop := load * settle
+			bx, by, ok := g.below(x, y, state)
+			switch {
+			case !ok || !isOpen(&(*state)[by][bx]):
+				d.Settled += drop
+			case (*state)[by][bx].Volume > WetThreshold:
+				// Sinking through the water beneath
+				b := &(*state)[by][bx]
+				drop = math.Min(drop, math.Max(b.Volume-b.Sediment*b.Volume, 0))
+				b.Sediment += drop / b.Volume
+			default:
+				drop = 0
+			}
+			load -= drop
+		}
+		d.Sediment = math.Max(load, 0) / d.Volume
+		g.packSand(x, y, state)
+	})
+}
+
+// packSand turns a full layer of settled sand into a sand obstacle once its
+// water has been pushed aside
+func (g *Game) packSand(x, y int, state *[][]Droplet) {
+	d := &(*state)[y][x]
+	if d.Settled < SettledFull || !g.pushOut(x, y, state) {
+		return
+	}
+	rest := d.Settled - SettledFull
+	CreateSandBlock(x, y, 1, 1, state)
+	d.Settled, d.Sediment = 0, 0
+	d.VX, d.VY = 0, 0
+	// Whatever didn't fit is left on top
+	if ux, uy, ok := g.local(x, y, 0, -1, state); ok && isOpen(&(*state)[uy][ux]) {
+		(*state)[uy][ux].Settled += rest
+	}
+}
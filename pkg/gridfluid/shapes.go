@@ -0,0 +1,159 @@
+package gridfluid
+
+import (
+	"math"
+	"slices"
+)
+
+/*
+* Shapes
+*
+* Obstacles are built by filling a shape with a material and knocked down
+* by clearing it. A shape is rasterised onto the grid cell by cell: a
+* rectangle, a line of some thickness, a circle or a polygon, whose cells
+* are those with their centre inside it plus its outline, so even a thin
+* sliver leaves no gaps for water to slip through. Cells outside the grid
+* are left out.
+ */
+
+// Shape is an area of the grid obstacles can be built in or cleared from
+type Shape interface {
+	// Cells calls visit for every cell of the shape, some possibly more
+	// than once and outside the grid
+	Cells(visit func(x, y int))
+}
+
+// Rect is the w by h cells with their top left corner at (X, Y)
+type Rect struct{ X, Y, W, H int }
+
+// Line runs from (X0, Y0) to (X1, Y1), Thickness cells wide (1 if unset)
+type Line struct{ X0, Y0, X1, Y1, Thickness int }
+
+// Circle is the cells within Radius of (X, Y)
+type Circle struct{ X, Y, Radius int }
+
+// Polygon is the area inside its corners, joined in order and back to the
+// first
+type Polygon [][2]int
+
+func (r Rect) Cells(visit func(x, y int)) {
+	for y := r.Y; y < r.Y+r.H; y++ {
+		for x := r.X; x < r.X+r.W; x++ {
+			visit(x, y)
+		}
+	}
+}
+
+func (l Line) Cells(visit func(x, y int)) {
+	t := max(l.Thickness, 1)
+	// Thick lines stamp a square at every step, centred on the line
+	off := (t - 1) / 2
+	steps := max(abs(l.X1-l.X0), abs(l.Y1-l.Y0), 1)
+	for i := 0; i <= steps; i++ {
+		x := l.X0 + (l.X1-l.X0)*i/steps
+		y := l.Y0 + (l.Y1-l.Y0)*i/steps
+		Rect{x - off, y - off, t, t}.Cells(visit)
+	}
+}
+
+func (c Circle) Cells(visit func(x, y int)) {
+	r := max(c.Radius, 0)
+	for y := c.Y - r; y <= c.Y+r; y++ {
+		for x := c.X - r; x <= c.X+r; x++ {
+			// r*r+r rounds the circle out a little, so small ones aren't
+			// plus shaped
+			if dx, dy := x-c.X, y-c.Y; dx*dx+dy*dy <= r*r+r {
+				visit(x, y)
+			}
+		}
+	}
+}
+
+func (p Polygon) Cells(visit func(x, y int)) {
+	if len(p) == 0 {
+		return
+	}
+	y0, y1 := p[0][1], p[0][1]
+	for _, c := range p {
+		y0, y1 = min(y0, c[1]), max(y1, c[1])
+	}
+	// Even-odd fill of the cell centres, one row at a time
+	for y := y0; y <= y1; y++ {
+		cy := float64(y) + 0.5
+		var cross []float64
+		for i, a := range p {
+			b := p[(i+1)%len(p)]
+			ay, by := float64(a[1])+0.5, float64(b[1])+0.5
+			if (ay <= cy) == (by <= cy) {
+				continue
+			}
+			ax, bx := float64(a[0])+0.5, float64(b[0])+0.5
+			cross = append(cross, ax+(cy-ay)/(by-ay)*(bx-ax))
+		}
+		slices.Sort(cross)
+		for i := 0; i+1 < len(cross); i += 2 {
+			for x := int(math.Ceil(cross[i] - 0.5)); float64(x)+0.5 < cross[i+1]; x++ {
+				visit(x, y)
+			}
+		}
+	}
+	for i, a := range p {
+		b := p[(i+1)%len(p)]
+		Line{X0: a[0], Y0: a[1], X1: b[0], Y1: b[1]}.Cells(visit)
+	}
+}
+
+// FillShape builds an obstacle of material over the shape. Water in cells
+// turned solid is gone.
+func FillShape(s Shape, material MaterialID, state *[][]Droplet) {
+	s.Cells(func(x, y int) {
+		if y < 0 || y >= len(*state) || x < 0 || x >= len((*state)[y]) {
+			return
+		}
+		d := &(*state)[y][x]
+		d.Material = material
+		if material.IsSolid() {
+			d.Volume, d.VX, d.VY = 0, 0, 0
+		}
+	})
+}
+
+// ClearShape knocks down the obstacles in the shape, ice included, leaving
+// moving obstacles alone
+func ClearShape(s Shape, state *[][]Droplet) {
+	s.Cells(func(x, y int) {
+		if y < 0 || y >= len(*state) || x < 0 || x >= len((*state)[y]) {
+			return
+		}
+		d := &(*state)[y][x]
+		if d.Ice {
+			// Melted at once, the water it held stays
+			d.Material, d.Ice, d.Snow = MaterialOpen, false, false
+		}
+		if d.Material.IsSolid() && !d.Moving {
+			clearObstacle(d)
+		}
+	})
+}
+
+// clearObstacle turns an obstacle cell into an empty open one
+func clearObstacle(d *Droplet) {
+	// The water in the open half of a ramp stays
+	if d.Ramp == RampNone {
+		d.Volume = 0
+	}
+	d.Material = MaterialOpen
+	d.Ramp = RampNone
+	d.Fire, d.Burnt = 0, 0
+	d.Salt, d.Dissolved = false, 0
+	d.Scoured = 0
+	d.Fan, d.FanOn = FanNone, false
+	d.Heater, d.Gate = false, false
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
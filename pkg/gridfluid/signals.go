@@ -0,0 +1,270 @@
+package gridfluid
+
+import "math"
+
+/*
+* Sensors and signals
+*
+* Sensors are open cells that switch on when water covers them: a pressure
+* plate on the floor waits for plateDepth cells of water to rest on it, a
+* float switch at a height waits for the water level to reach it. Both
+* switch back off a little below the level that turned them on, so a level
+* hovering at the threshold doesn't make them chatter.
+*
+* Wires connect a sensor to a device cell and form the signal graph. A
+* device with wires is on while any of them carries a signal; an inverted
+* wire carries one while its sensor is off. Devices without wires keep
+* whatever state they were left in. The devices are gates (obstacles that
+* open), springs (cells that fill with water at SpringRate, until a supply
+* set with SetSpringSupply runs out) and fans.
+ */
+
+type SensorKind uint8
+
+const (
+	SensorNone SensorKind = iota
+	SensorPlate
+	SensorFloat
+)
+
+const (
+	plateDepth  = 3.0 // Pressure, in cells of water, that presses a plate down
+	floatLevel  = 0.6 // Volume that lifts a float switch
+	sensorSlack = 0.2 // Share below the trigger level at which a sensor lets go
+)
+
+// Wire carries the signal of the sensor at From to the device at To
+type Wire struct {
+	From, To [2]int
+	Invert   bool
+}
+
+// CreateSensor puts a sensor of the given kind on the cell at (x, y)
+func CreateSensor(x, y int, kind SensorKind, state *[][]Droplet) {
+	d := &(*state)[y][x]
+	d.Sensor = kind
+	d.Signal = false
+}
+
+// PlaceSensor puts a pressure plate on an open cell, turns a plate into a
+// float switch and removes a float switch
+func (g *Game) PlaceSensor(x, y int) bool {
+	if !g.InBounds(x, y) {
+		return false
+	}
+	d := &g.State[y][x]
+	if d.Material.IsSolid() || d.Pipe != PipeNone {
+		return false
+	}
+	switch d.Sensor {
+	case SensorNone:
+		d.Sensor = SensorPlate
+	case SensorPlate:
+		d.Sensor = SensorFloat
+	default:
+		d.Sensor = SensorNone
+		g.Unwire([2]int{x, y})
+	}
+	d.Signal = false
+	return true
+}
+
+// PlaceGate turns a dry open cell into a closed gate, or removes the gate
+// there
+func (g *Game) PlaceGate(x, y int) bool {
+	if !g.InBounds(x, y) {
+		return false
+	}
+	d := &g.State[y][x]
+	if d.Gate {
+		d.Gate = false
+		d.Material = MaterialOpen
+		g.Unwire([2]int{x, y})
+		return true
+	}
+	if d.Pipe != PipeNone || d.Material != MaterialOpen || d.Volume > WetThreshold {
+		return false
+	}
+	d.Gate = true
+	d.Material = MaterialStone
+	d.Volume = 0
+	return true
+}
+
+// PlaceSpring turns an open cell into a running spring, or removes the
+// spring there
+func (g *Game) PlaceSpring(x, y int) bool {
+	if !g.InBounds(x, y) {
+		return false
+	}
+	d := &g.State[y][x]
+	if d.Spring {
+		d.Spring, d.SpringOn = false, false
+		g.Unwire([2]int{x, y})
+		return true
+	}
+	if d.Material.IsSolid() || d.Pipe != PipeNone {
+		return false
+	}
+	d.Spring, d.SpringOn = true, true
+	return true
+}
+
+// IsDevice reports whether a cell can be driven by a wire
+func IsDevice(d *Droplet) bool {
+	return d.Gate || d.Spring || d.Fan != FanNone
+}
+
+// Connect wires the sensor at from to the device at to
+func (g *Game) Connect(from, to [2]int, invert bool) bool {
+	if !g.InBounds(from[0], from[1]) || !g.InBounds(to[0], to[1]) {
+		return false
+	}
+	if g.State[from[1]][from[0]].Sensor == SensorNone || !IsDevice(&g.State[to[1]][to[0]]) {
+		return false
+	}
+	for i, w := range g.wires {
+		if w.From == from && w.To == to {
+			g.wires[i].Invert = invert
+			return true
+		}
+	}
+	g.wires = append(g.wires, Wire{From: from, To: to, Invert: invert})
+	return true
+}
+
+// Wires lists the wires of the signal graph
+func (g *Game) Wires() []Wire {
+	return g.wires
+}
+
+// Unwire removes every wire touching the cell
+func (g *Game) Unwire(cell [2]int) {
+	kept := g.wires[:0]
+	for _, w := range g.wires {
+		if w.From != cell && w.To != cell {
+			kept = append(kept, w)
+		}
+	}
+	g.wires = kept
+}
+
+// updateSignals reads the sensors, drives the wired devices and runs the
+// springs
+func (g *Game) updateSignals(state *[][]Droplet) {
+	for y := range *state {
+		for x := range (*state)[y] {
+			d := &(*state)[y][x]
+			if d.Sensor == SensorNone {
+				continue
+			}
+			reading, trigger := d.Pressure, plateDepth
+			if d.Sensor == SensorFloat {
+				reading, trigger = d.Volume, floatLevel
+			}
+			if d.Material.IsSolid() {
+				reading = 0
+			}
+			if d.Signal {
+				d.Signal = reading >= trigger*(1-sensorSlack)
+			} else {
+				d.Signal = reading >= trigger
+			}
+		}
+	}
+
+	// Devices are driven in wiring order so the update stays deterministic
+	var targets [][2]int
+	driven := map[[2]int]bool{}
+	for _, w := range g.wires {
+		if !g.InBounds(w.From[0], w.From[1]) || !g.InBounds(w.To[0], w.To[1]) {
+			continue
+		}
+		if _, ok := driven[w.To]; !ok {
+			targets = append(targets, w.To)
+		}
+		on := (*state)[w.From[1]][w.From[0]].Signal != w.Invert
+		driven[w.To] = driven[w.To] || on
+	}
+	for _, cell := range targets {
+		x, y, on := cell[0], cell[1], driven[cell]
+		d := &(*state)[y][x]
+		switch {
+		case d.Gate && on:
+			d.Material = MaterialOpen
+		case d.Gate:
+			g.closeGate(x, y, state)
+		case d.Spring:
+			d.SpringOn = on
+		case d.Fan != FanNone:
+			d.FanOn = on
+		}
+	}
+
+	rate := g.Params.SpringRate / ticksPerSecond
+	for y := range *state {
+		for x := range (*state)[y] {
+			d := &(*state)[y][x]
+			if !d.Spring || !d.SpringOn || d.Material.IsSolid() || (d.Volume > WetThreshold && d.Fluid != FluidWater) {
+				continue
+			}
+			amount := math.Min(rate, remainder(*d, 1.0))
+			if amount <= 0 {
+				continue
+			}
+			if d.Supply > 0 {
+				amount = math.Min(amount, d.Supply)
+				d.Supply -= amount
+				if d.Supply <= 0 {
+					d.Supply, d.Spring, d.SpringOn = 0, false, false
+					g.events.dry = append(g.events.dry, [2]int{x, y})
+				}
+			}
+			if d.Volume <= 0 {
+				d.Fluid = FluidWater
+			}
+			mixTemperature(&d.Temperature, d.Volume, ambientTemperature, amount)
+			mixStagnation(&d.Stagnation, d.Volume, 0, amount)
+			mixConcentration(&d.Acidity, d.Volume, 0, amount)
+			mixConcentration(&d.Salinity, d.Volume, 0, amount)
+			mixConcentration(&d.Sediment, d.Volume, 0, amount)
+			d.Volume += amount
+			g.Supplied += amount
+		}
+	}
+}
+
+// closeGate shuts the gate at (x, y) once its water has been pushed into
+// the open cells around it. A gate holding water it can't get rid of stays
+// open for another tick.
+func (g *Game) closeGate(x, y int, state *[][]Droplet) {
+	d := &(*state)[y][x]
+	if d.Material.IsSolid() || !g.pushOut(x, y, state) {
+		return
+	}
+	d.VX, d.VY = 0, 0
+	d.Material = MaterialStone
+}
+
+// pushOut moves the water at (x, y) into the open cells around it and
+// reports whether the cell ended up empty
+func (g *Game) pushOut(x, y int, state *[][]Droplet) bool {
+	d := &(*state)[y][x]
+	for _, dpos := range pressureDirections {
+		if d.Volume <= 0 {
+			break
+		}
+		dx, dy := g.Gravity.toGrid(dpos[0], dpos[1])
+		nx, ny, ok := g.neighbor(x, y, dx, dy, state)
+		if !ok || !isOpen(&(*state)[ny][nx]) {
+			continue
+		}
+		n := &(*state)[ny][nx]
+		g.transferVolume(d, n, math.Min(d.Volume, remainder(*n, 1.0)))
+	}
+	if d.Volume > 1e-9 {
+		return false
+	}
+	d.Volume = 0
+	return true
+}
@@ -0,0 +1,223 @@
+package gridfluid
+
+import "math"
+
+/*
+* Splashes
+*
+* Water hitting the floor or a pool fast enough throws some of its volume
+* into the air as splash particles. The volume is debited from the grid
+* when a particle is spawned and credited back wherever it lands, so the
+* total of grid and airborne volume stays the same across the coupling.
+ */
+
+const (
+	splashMaxVolume = 0.2 // Largest volume a single particle carries
+	splashSpread    = 0.5 // Sideways speed relative to the impact speed
+)
+
+type Splash struct {
+	Pos, Vel    Vector // Cells and cells per tick
+	Volume      float64
+	Fluid       Fluid
+	Dye         Dye
+	temperature float64
+	acidity     float64
+	salinity    float64
+	sediment    float64
+}
+
+// impactSpeed is the speed water must hit something at to splash. A fall
+// from SplashFallHeight reaches sqrt(2 g h).
+func (g *Game) impactSpeed() float64 {
+	fall := math.Sqrt(2 * g.Params.GravityAccel * g.Params.SplashFallHeight)
+	return math.Max(g.Params.SplashImpactSpeed, fall)
+}
+
+// landing is the speed of the water in (x, y) if it is landing on
+// something fast enough to splash
+func (g *Game) landing(state *[][]Droplet, x, y int) (float64, bool) {
+	d := &(*state)[y][x]
+	if d.Material.IsSolid() || d.Volume <= WetThreshold {
+		return 0, false
+	}
+	gx, gy := g.Gravity.Vector()
+	speed := d.VX*gx + d.VY*gy
+	if speed < g.impactSpeed() {
+		return 0, false
+	}
+	// Only water landing on something splashes
+	bx, by, ok := g.below(x, y, state)
+	if ok && !(*state)[by][bx].Material.IsSolid() && (*state)[by][bx].Volume < 0.5 {
+		return 0, false
+	}
+	return speed, true
+}
+
+// gatherImpacts notes where water is landing for OnImpact, before the
+// splashes take the speed out of it
+func (g *Game) gatherImpacts(state *[][]Droplet) {
+	e := &g.events
+	if len(e.impact) == 0 || e.quiet {
+		return
+	}
+	for y := range *state {
+		for x := range (*state)[y] {
+			if speed, ok := g.landing(state, x, y); ok {
+				e.impacts = append(e.impacts, impact{x, y, speed})
+			}
+		}
+	}
+}
+
+// spawnSplashes turns fast impacts into splash particles, up to the per
+// frame budget
+func (g *Game) spawnSplashes(state *[][]Droplet) {
+	budget := int(g.Params.SplashBudget)
+	gx, gy := g.Gravity.Vector()
+	for y := range *state {
+		for x := range (*state)[y] {
+			if budget <= 0 {
+				return
+			}
+			speed, ok := g.landing(state, x, y)
+			if !ok {
+				continue
+			}
+			d := &(*state)[y][x]
+
+			amount := d.Volume * g.Params.SplashFraction
+			for _, side := range []float64{-1, 1} {
+				if budget <= 0 || amount <= 0 {
+					break
+				}
+				part := math.Min(amount/2, splashMaxVolume)
+				// Thrown back up and out to the side
+				up := speed * g.Params.Restitution
+				out := side * speed * splashSpread * (0.5 + g.rng.Float64())
+				g.splashes = append(g.splashes, &Splash{
+					Pos:         Vector{float64(x) + 0.5 - gx, float64(y) + 0.5 - gy},
+					Vel:         Vector{-gx*up - gy*out, -gy*up + gx*out},
+					Volume:      part,
+					Fluid:       d.Fluid,
+					Dye:         d.Dye,
+					temperature: d.Temperature,
+					acidity:     d.Acidity,
+					salinity:    d.Salinity,
+					sediment:    d.Sediment,
+				})
+				d.Volume -= part
+				g.recordSink(x, y, part)
+				budget--
+			}
+			// Plunging into a pool drags air under
+			g.entrain(state, x, y)
+			d.VX -= gx * speed
+			d.VY -= gy * speed
+		}
+	}
+}
+
+// updateSplashes moves the particles ballistically and puts their volume
+// back into the grid when they land
+func (g *Game) updateSplashes(state *[][]Droplet) {
+	gx, gy := g.Gravity.Vector()
+	h, w := len(*state), len((*state)[0])
+	alive := g.splashes[:0]
+	for _, s := range g.splashes {
+		prev := s.Pos
+		s.Vel.X += gx * g.Params.GravityAccel
+		s.Vel.Y += gy * g.Params.GravityAccel
+		s.Pos = Vector{
+			math.Min(float64(w)-0.01, math.Max(0, s.Pos.X+s.Vel.X)),
+			math.Min(float64(h)-0.01, math.Max(0, s.Pos.Y+s.Vel.Y)),
+		}
+
+		x, y := int(s.Pos.X), int(s.Pos.Y)
+		landed := (*state)[y][x].Volume > WetThreshold
+		if (*state)[y][x].Material.IsSolid() || (*state)[y][x].Pipe != PipeNone {
+			// Land in the last free cell instead
+			s.Pos, s.Vel = prev, Vector{}
+			x, y = int(prev.X), int(prev.Y)
+			landed = true
+		}
+		if landed && g.conductance(&(*state)[y][x]) > 0 {
+			if (*state)[y][x].Volume > 0.5 {
+				g.entrain(state, x, y)
+			}
+			s.Volume -= g.creditSplash(&(*state)[y][x], s)
+			// Under a full pool there is no room, what is left rises to the
+			// surface rather than resting on the floor for good
+			for s.Volume > 1e-9 {
+				ux, uy := x-int(gx), y-int(gy)
+				if !g.InBounds(ux, uy) || g.conductance(&(*state)[uy][ux]) <= 0 {
+					break
+				}
+				x, y = ux, uy
+				s.Pos, s.Vel = Vector{float64(x) + 0.5, float64(y) + 0.5}, Vector{}
+				s.Volume -= g.creditSplash(&(*state)[y][x], s)
+			}
+		}
+		if s.Volume > 1e-9 {
+			alive = append(alive, s)
+		}
+	}
+	g.splashes = alive
+}
+
+// creditSplash adds a particle's volume to a cell and returns how much fit
+func (g *Game) creditSplash(d *Droplet, s *Splash) float64 {
+	amount := math.Min(s.Volume, remainder(*d, 1.0))
+	if amount <= 0 {
+		return 0
+	}
+	if d.Volume <= WetThreshold {
+		d.Fluid = s.Fluid
+	}
+	mixDye(&d.Dye, d.Volume, s.Dye, amount)
+	mixTemperature(&d.Temperature, d.Volume, s.temperature, amount)
+	// Water thrown through the air lands aerated
+	mixStagnation(&d.Stagnation, d.Volume, 0, amount)
+	mixConcentration(&d.Acidity, d.Volume, s.acidity, amount)
+	mixConcentration(&d.Salinity, d.Volume, s.salinity, amount)
+	mixConcentration(&d.Sediment, d.Volume, s.sediment, amount)
+	d.Volume += amount
+	relabelAcid(d)
+	return amount
+}
+
+// splash runs the grid to particle coupling for one update
+func (g *Game) splash(state *[][]Droplet) {
+	g.gatherImpacts(state)
+	if !g.FeatureEnabled("splash") {
+		return
+	}
+	g.updateSplashes(state)
+	g.spawnSplashes(state)
+}
+
+// Splashes lists the water thrown up into the air
+func (g *Game) Splashes() []*Splash {
+	return g.splashes
+}
+
+// TotalVolume is the volume held by the grids of all layers plus the
+// airborne splashes
+func (g *Game) TotalVolume() float64 {
+	var total float64
+	for i := range g.Layers() {
+		state := *g.layerState(i)
+		for y := range state {
+			for x := range state[y] {
+				// Ice and ramps are obstacles but still hold water
+				if holdsWater(&state[y][x]) {
+					total += state[y][x].Volume
+				}
+			}
+		}
+		for _, s := range g.layerSplashes(i) {
+			total += s.Volume
+		}
+	}
+	return total
+}
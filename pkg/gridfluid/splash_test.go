@@ -0,0 +1,103 @@
+package gridfluid
+
+import (
+	"math"
+	"testing"
+)
+
+// splashScene is a walled box with a column of water held up above a
+// stone floor, ready to fall and splash
+func splashScene(budget float64) *Game {
+	const w, h = 12, 24
+	g := NewGame(w, h, 1)
+	g.SetSeed(1)
+	g.Params.SplashImpactSpeed = 0.2
+	g.Params.SplashFallHeight = 1
+	g.Params.SplashBudget = budget
+	for x := range w {
+		g.State[h-1][x].Material = MaterialStone
+	}
+	for y := 2; y < 10; y++ {
+		for x := 4; x < 8; x++ {
+			g.State[y][x].Volume = 1
+		}
+	}
+	return g
+}
+
+// gridVolume is the water held by the grid, leaving out the splashes
+func gridVolume(g *Game) float64 {
+	var total float64
+	for y := range g.State {
+		for x := range g.State[y] {
+			if !g.State[y][x].Material.IsSolid() {
+				total += g.State[y][x].Volume
+			}
+		}
+	}
+	return total
+}
+
+// airborne is the water the splashes carry
+func airborne(g *Game) float64 {
+	var total float64
+	for _, s := range g.Splashes() {
+		total += s.Volume
+	}
+	return total
+}
+
+func TestSplashConservesVolume(t *testing.T) {
+	const tolerance = 1e-9
+	g := splashScene(8)
+	start := gridVolume(g)
+
+	flew := false
+	for frame := 0; frame < 2000; frame++ {
+		g.Update()
+		if n := len(g.Splashes()); n > 0 {
+			flew = true
+		}
+		if total := gridVolume(g) + airborne(g); math.Abs(total-start) > tolerance {
+			t.Fatalf("update %d: grid and splashes hold %.12f, started with %.12f", frame, total, start)
+		}
+		if flew && len(g.Splashes()) == 0 {
+			break
+		}
+	}
+	if !flew {
+		t.Fatal("no splash was thrown up")
+	}
+	if n := len(g.Splashes()); n > 0 {
+		t.Fatalf("%d splashes still in the air", n)
+	}
+	if total := gridVolume(g); math.Abs(total-start) > tolerance {
+		t.Fatalf("grid holds %.12f once the splashes landed, started with %.12f", total, start)
+	}
+}
+
+func TestSplashBudget(t *testing.T) {
+	const budget = 3
+	g := splashScene(budget)
+	spawned := 0
+	for frame := 0; frame < 200; frame++ {
+		before := map[*Splash]bool{}
+		for _, s := range g.Splashes() {
+			before[s] = true
+		}
+		g.Update()
+		n := 0
+		for _, s := range g.Splashes() {
+			if !before[s] {
+				n++
+			}
+		}
+		if n > budget {
+			t.Fatalf("update %d: %d splashes spawned, budget is %d", frame, n, budget)
+		}
+		spawned += n
+	}
+	if spawned == 0 {
+		t.Fatal("no splash was thrown up")
+	}
+}
@@ -0,0 +1,59 @@
+package gridfluid
+
+/*
+* Sponge boundaries
+*
+* Sponge zones are bands along the grid edges where velocities and surface
+* waves are damped gradually, so waves running into the edge die out
+* instead of reflecting back into the scene.
+ */
+
+// Sponge configures the absorbing band width (in cells) for each edge.
+// Strength is the per-tick damping at the very edge; it falls off
+// quadratically towards the inner side of the band.
+type Sponge struct {
+	Left, Right, Top, Bottom int
+	Strength                 float64
+	Enabled                  bool
+}
+
+// edgeProfile returns how deep (0 to 1) a cell at distance dist from the
+// edge sits inside a band of the given width
+func edgeProfile(dist, width int) float64 {
+	if width <= 0 || dist >= width {
+		return 0
+	}
+	t := float64(width-dist) / float64(width)
+	return t * t
+}
+
+// damping returns the velocity multiplier for the cell at (x, y)
+func (s Sponge) damping(x, y, w, h int) float64 {
+	depth := max(
+		edgeProfile(x, s.Left),
+		edgeProfile(w-1-x, s.Right),
+		edgeProfile(y, s.Top),
+		edgeProfile(h-1-y, s.Bottom),
+	)
+	return 1 - s.Strength*depth
+}
+
+// absorbWaves damps movement inside the sponge bands
+func (g *Game) absorbWaves(state *[][]Droplet) {
+	if !g.Sponge.Enabled {
+		return
+	}
+	h, w := len(*state), len((*state)[0])
+	for y := range *state {
+		for x := range (*state)[y] {
+			k := g.Sponge.damping(x, y, w, h)
+			if k >= 1 {
+				continue
+			}
+			d := &(*state)[y][x]
+			d.VX *= k
+			d.VY *= k
+			d.Wave *= k
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package gridfluid
+
+import "math"
+
+/*
+* Stagnation
+*
+* Every water cell counts how many updates it has been sitting nearly
+* still. The count travels with the volume like temperature does, so fresh
+* inflow dilutes it, and stirring the cell clears it. Stagnant water is
+* drawn progressively murkier, and the region metric lets scenes check how
+* well a pool is being kept fresh.
+ */
+
+const (
+	stillSpeed     = 0.02 // Speed below which water counts as still
+	stirSpeed      = 0.2  // Speed at which a cell counts as stirred and turns fresh
+	stagnantFrames = 1800 // Updates of stillness until water is fully murky
+)
+
+// updateStagnation ages still water and freshens stirred water
+func updateStagnation(state *[][]Droplet) {
+	for y := range *state {
+		for x := range (*state)[y] {
+			d := &(*state)[y][x]
+			if d.Material.IsSolid() || d.Volume <= WetThreshold {
+				d.Stagnation = 0
+				continue
+			}
+			speed := math.Hypot(d.VX, d.VY)
+			switch {
+			case speed >= stirSpeed:
+				d.Stagnation = 0
+			case speed < stillSpeed:
+				d.Stagnation++
+			}
+		}
+	}
+}
+
+// Murkiness is how stagnant the water of a cell looks, from 0 (fresh) to 1
+func Murkiness(d *Droplet) float64 {
+	return math.Min(d.Stagnation/stagnantFrames, 1)
+}
+
+// mixStagnation blends amount of incoming water that has been still for
+// stagnation updates into a cell holding volume of water
+func mixStagnation(dst *float64, volume, stagnation, amount float64) {
+	total := volume + amount
+	if amount <= 0 || total <= 0 {
+		return
+	}
+	*dst = (*dst*max(volume, 0) + stagnation*amount) / total
+}
+
+// StagnationReport summarises the water quality of a region
+type StagnationReport struct {
+	Volume   float64 // Water held by the region
+	Murk     float64 // Volume weighted murkiness, 0 (fresh) to 1 (stagnant)
+	Stagnant float64 // Share of the volume that is fully stagnant
+}
+
+// Stagnation reports the water quality of the w by h cells at (x, y)
+func (g *Game) Stagnation(x, y, w, h int) StagnationReport {
+	var r StagnationReport
+	var stagnant float64
+	for cy := y; cy < y+h; cy++ {
+		for cx := x; cx < x+w; cx++ {
+			if !g.InBounds(cx, cy) {
+				continue
+			}
+			d := &g.State[cy][cx]
+			if d.Material.IsSolid() || d.Volume <= WetThreshold {
+				continue
+			}
+			r.Volume += d.Volume
+			r.Murk += Murkiness(d) * d.Volume
+			if d.Stagnation >= stagnantFrames {
+				stagnant += d.Volume
+			}
+		}
+	}
+	if r.Volume > 0 {
+		r.Murk /= r.Volume
+		r.Stagnant = stagnant / r.Volume
+	}
+	return r
+}
@@ -0,0 +1,88 @@
+package gridfluid
+
+/*
+* Statistics
+*
+* Stats sums up the sim in one pass over the grid, for a HUD or for
+* logging from outside. The fields carry JSON names so a reading can be
+* written out as it is. Flow holds what came in and went out during the
+* last update alone, where the counters on Game add up since the start.
+ */
+
+// Stats is a reading of the sim after the last update
+type Stats struct {
+	Frame         int     `json:"frame"`
+	Volume        float64 `json:"volume"`        // Water in all layers and in the air, see TotalVolume
+	WetCells      int     `json:"wetCells"`      // Open cells holding water on the focused layer
+	ActiveCells   int     `json:"activeCells"`   // Wet cells the flow rules visited, see chunks.go
+	WettestColumn int     `json:"wettestColumn"` // Column holding the most water
+	ColumnHeight  float64 `json:"columnHeight"`  // Water in that column, in cells
+	MaxPressure   float64 `json:"maxPressure"`
+	Flow          Flow    `json:"flow"`
+}
+
+// Flow is the water that came in and went out during one update
+type Flow struct {
+	Supplied   float64 `json:"supplied"` // By springs, rain and snow
+	Outflow    float64 `json:"outflow"`  // Through open edges
+	Absorbed   float64 `json:"absorbed"` // By plants
+	Evaporated float64 `json:"evaporated"`
+	Swallowed  float64 `json:"swallowed"` // By vortices
+}
+
+// Net is the water gained, negative when more went out than came in
+func (f Flow) Net() float64 {
+	return f.Supplied - f.Outflow - f.Absorbed - f.Evaporated - f.Swallowed
+}
+
+// flowTotals is the flow since the start, for taking differences
+func (g *Game) flowTotals() Flow {
+	f := Flow{Supplied: g.Supplied, Absorbed: g.Absorbed, Evaporated: g.Evaporated, Swallowed: g.Swallowed}
+	for _, v := range g.Outflow {
+		f.Outflow += v
+	}
+	return f
+}
+
+// since is the flow between the totals before and f
+func (f Flow) since(before Flow) Flow {
+	return Flow{
+		Supplied:   f.Supplied - before.Supplied,
+		Outflow:    f.Outflow - before.Outflow,
+		Absorbed:   f.Absorbed - before.Absorbed,
+		Evaporated: f.Evaporated - before.Evaporated,
+		Swallowed:  f.Swallowed - before.Swallowed,
+	}
+}
+
+// Stats reads the sim as it is now
+func (g *Game) Stats() Stats {
+	s := Stats{Frame: g.frame, Volume: g.TotalVolume(), Flow: g.lastFlow}
+	// The chunks only cover the grid once an update has run on it
+	h, w := len(g.State), len(g.State[0])
+	chunked := g.chunks.w == (w+chunkSize-1)/chunkSize && g.chunks.h == (h+chunkSize-1)/chunkSize
+	columns := make([]float64, w)
+	for y := range g.State {
+		for x := range g.State[y] {
+			d := &g.State[y][x]
+			if d.Material.IsSolid() {
+				continue
+			}
+			s.MaxPressure = max(s.MaxPressure, d.Pressure)
+			if d.Volume <= 0 {
+				continue
+			}
+			s.WetCells++
+			if chunked && g.chunkActive(x, y) {
+				s.ActiveCells++
+			}
+			columns[x] += d.Volume
+		}
+	}
+	for x, height := range columns {
+		if height > s.ColumnHeight {
+			s.WettestColumn, s.ColumnHeight = x, height
+		}
+	}
+	return s
+}
@@ -0,0 +1,94 @@
+package gridfluid
+
+import "math"
+
+/*
+* Steam
+*
+* Water that boils off leaves the grid as puffs of steam that drift
+* against gravity and fade. Steam isn't simulated as a fluid, the volume
+* is gone for good and tallied in Game.Evaporated. Water whose surface is
+* open to the air also evaporates slowly at any temperature, Evaporation
+* volume per second from each surface cell, without any steam to show for
+* it.
+ */
+
+const (
+	SteamLife    = 90   // Ticks a puff stays visible
+	steamRise    = 0.08 // Cells per tick a puff drifts against gravity
+	steamPerPuff = 0.02 // Boiled volume per puff
+	steamMaxPuff = 4    // Most puffs spawned by a single boil
+)
+
+type Steam struct {
+	Pos, Vel Vector // Cells and cells per tick
+	Life     int    // Ticks left
+}
+
+// boil turns up to amount of the water at (x, y) into steam and returns how
+// much boiled off
+func (g *Game) boil(x, y int, amount float64, state *[][]Droplet) float64 {
+	d := &(*state)[y][x]
+	amount = math.Min(amount, math.Max(d.Volume, 0))
+	if amount <= 0 {
+		return 0
+	}
+	d.Volume -= amount
+	g.Evaporated += amount
+	g.recordSink(x, y, amount)
+
+	gx, gy := g.Gravity.Vector()
+	puffs := min(int(math.Ceil(amount/steamPerPuff)), steamMaxPuff)
+	for range puffs {
+		drift := (g.rng.Float64() - 0.5) * steamRise
+		g.steam = append(g.steam, &Steam{
+			Pos:  Vector{float64(x) + g.rng.Float64(), float64(y) + g.rng.Float64()},
+			Vel:  Vector{-gx*steamRise - gy*drift, -gy*steamRise + gx*drift},
+			Life: SteamLife,
+		})
+	}
+	return amount
+}
+
+// evaporate takes what evaporates in a tick off every water surface open
+// to the air
+func (g *Game) evaporate(state *[][]Droplet) {
+	rate := g.Params.Evaporation / ticksPerSecond
+	if rate <= 0 {
+		return
+	}
+	for y := range *state {
+		for x := range (*state)[y] {
+			d := &(*state)[y][x]
+			if d.Volume <= WetThreshold || d.Fluid == FluidOil || !isOpen(d) {
+				continue
+			}
+			if ax, ay, ok := g.local(x, y, 0, -1, state); ok && ((*state)[ay][ax].Material.IsSolid() || (*state)[ay][ax].Volume > WetThreshold) {
+				continue
+			}
+			amount := math.Min(rate, d.Volume)
+			d.Volume -= amount
+			g.Evaporated += amount
+			g.recordSink(x, y, amount)
+		}
+	}
+}
+
+// Steam lists the puffs of boiled off water
+func (g *Game) Steam() []*Steam {
+	return g.steam
+}
+
+// updateSteam drifts and ages the puffs
+func (g *Game) updateSteam() {
+	alive := g.steam[:0]
+	for _, s := range g.steam {
+		s.Pos.X += s.Vel.X
+		s.Pos.Y += s.Vel.Y
+		s.Life--
+		if s.Life > 0 {
+			alive = append(alive, s)
+		}
+	}
+	g.steam = alive
+}
@@ -0,0 +1,106 @@
+package gridfluid
+
+import "math"
+
+/*
+* Teaching mode
+*
+* Teaching mode replays a single Update() in slow motion. The update runs
+* with tracing enabled, recording for every processed cell which rules
+* fired and how much volume moved where, followed by one step per
+* whole-grid pass. Playback starts from the state before the update and
+* applies the recorded changes one step at a time.
+ */
+
+const teachNeighborhood = 3 // Cascades reach up to 3 cells sideways, whichever way gravity points
+
+type CellChange struct {
+	X, Y   int
+	Volume float64 // Volume change of the cell
+}
+
+type TraceStep struct {
+	X, Y    int // Cell being processed, -1 for whole-grid passes
+	Rules   []string
+	Changes []CellChange
+}
+
+type teachTrace struct {
+	steps []TraceStep
+}
+
+// note records that a rule fired for the cell being traced
+func (g *Game) note(rule string) {
+	if g.trace == nil || len(g.trace.steps) == 0 {
+		return
+	}
+	step := &g.trace.steps[len(g.trace.steps)-1]
+	step.Rules = append(step.Rules, rule)
+}
+
+func CopyState(state [][]Droplet) [][]Droplet {
+	out := make([][]Droplet, len(state))
+	for y := range state {
+		out[y] = make([]Droplet, len(state[y]))
+		copy(out[y], state[y])
+	}
+	return out
+}
+
+// diffVolumes appends the volume changes between before and state in the
+// given rectangle to step
+func diffVolumes(step *TraceStep, before, state [][]Droplet, x0, y0, x1, y1 int) {
+	for y := max(y0, 0); y <= min(y1, len(state)-1); y++ {
+		for x := max(x0, 0); x <= min(x1, len(state[y])-1); x++ {
+			dv := state[y][x].Volume - before[y][x].Volume
+			if math.Abs(dv) > 1e-6 {
+				step.Changes = append(step.Changes, CellChange{x, y, dv})
+			}
+		}
+	}
+}
+
+// traceCell runs the flow rules for one cell and records what they did
+func (g *Game) traceCell(x, y int, state *[][]Droplet) {
+	before := make([][]Droplet, len(*state))
+	for ny := max(y-teachNeighborhood, 0); ny <= min(y+teachNeighborhood, len(*state)-1); ny++ {
+		before[ny] = make([]Droplet, len((*state)[ny]))
+		copy(before[ny], (*state)[ny])
+	}
+
+	g.trace.steps = append(g.trace.steps, TraceStep{X: x, Y: y})
+	g.processWaterCell(x, y, state)
+
+	step := &g.trace.steps[len(g.trace.steps)-1]
+	diffVolumes(step, before, *state, x-teachNeighborhood, y-teachNeighborhood, x+teachNeighborhood, y+teachNeighborhood)
+	if len(step.Changes) == 0 {
+		g.trace.steps = g.trace.steps[:len(g.trace.steps)-1]
+	}
+}
+
+// runPass runs a whole-grid pass of the update, recording it as a single
+// step while tracing
+func (g *Game) runPass(name string, state *[][]Droplet, pass func(*[][]Droplet)) {
+	defer g.auditPass(name, state)()
+	if g.trace == nil {
+		pass(state)
+		return
+	}
+	before := CopyState(*state)
+	pass(state)
+	step := TraceStep{X: -1, Y: -1, Rules: []string{name}}
+	diffVolumes(&step, before, *state, 0, 0, len((*state)[0])-1, len(*state)-1)
+	if len(step.Changes) > 0 {
+		g.trace.steps = append(g.trace.steps, step)
+	}
+}
+
+// TraceUpdate runs one update with tracing enabled and returns the steps
+// it recorded
+func (g *Game) TraceUpdate() []TraceStep {
+	g.trace = &teachTrace{}
+	g.Update()
+	steps := g.trace.steps
+	g.trace = nil
+	return steps
+}
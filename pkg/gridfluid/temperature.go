@@ -0,0 +1,18 @@
+package gridfluid
+
+/*
+* Temperature
+ */
+
+// Temperature of fresh cells and of the surrounding air, in °C
+const ambientTemperature = 20.0
+
+// mixTemperature blends incoming water at temperature t into a cell holding
+// volume of water, weighted by volume
+func mixTemperature(dst *float64, volume, t, amount float64) {
+	total := volume + amount
+	if amount <= 0 || total <= 0 {
+		return
+	}
+	*dst = (*dst*max(volume, 0) + t*amount) / total
+}
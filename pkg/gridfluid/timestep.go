@@ -0,0 +1,62 @@
+package gridfluid
+
+import "math"
+
+/*
+* Fixed timestep
+*
+* Every update advances the sim by the same TickDuration, however often it
+* is called. Step takes the real time that passed since the last call and
+* runs as many updates as fit into it, carrying the remainder over, so the
+* water moves at the same pace whatever the frame rate. A slow frame is
+* caught up with several updates, up to maxCatchUp; anything beyond that is
+* dropped rather than letting the sim fall further and further behind.
+*
+* TimeScale runs the sim slower or faster than real time, from
+* MinTimeScale to MaxTimeScale, by changing how many updates fit into a
+* frame rather than how long an update is, so the water behaves the same at
+* any speed. The catch up limit grows with the scale so fast forward isn't
+* cut short.
+ */
+
+const (
+	ticksPerSecond = 60                   // Updates per second of sim time
+	TickDuration   = 1.0 / ticksPerSecond // Seconds of sim time one update covers
+	maxCatchUp     = 5                    // Most updates one call runs to catch up, at 1x
+	MinTimeScale   = 0.1                  // Slowest slow motion
+	MaxTimeScale   = 8.0                  // Fastest fast forward
+)
+
+// Due adds dt seconds of real time and returns how many updates are now
+// owed. Callers that wrap Update run it that many times themselves.
+func (g *Game) Due(dt float64) int {
+	scale := g.Speed()
+	g.behind += max(dt, 0) * scale
+	n := int(g.behind / TickDuration)
+	if limit := maxCatchUp * int(math.Ceil(scale)); n > limit {
+		n = limit
+		g.behind = 0
+	} else {
+		g.behind -= float64(n) * TickDuration
+	}
+	return n
+}
+
+// Step advances the sim by dt seconds in fixed updates and returns how many
+// ran
+func (g *Game) Step(dt float64) int {
+	n := g.Due(dt)
+	for range n {
+		g.Update()
+	}
+	return n
+}
+
+// Speed is the time scale in use: TimeScale kept within MinTimeScale and
+// MaxTimeScale, 1 while it is unset
+func (g *Game) Speed() float64 {
+	if g.TimeScale == 0 {
+		return 1
+	}
+	return min(max(g.TimeScale, MinTimeScale), MaxTimeScale)
+}
@@ -0,0 +1,223 @@
+package gridfluid
+
+import "slices"
+
+/*
+* Undo
+*
+* Tools change the world through Edit, which notes the cells a tool at the
+* cursor can reach before and after it runs: the cell itself, the other end
+* of a portal on it and the cells behind and in front of it on the other
+* layers. Only the cells that changed are kept, along with the gauges and
+* wires when the tool changed those, never a copy of the grid. Undo and
+* Redo put them back.
+*
+* An edit undone before the sim has run on comes back exactly. Once the
+* water has moved, undo still puts the touched cells back as they were,
+* water included. Resampling the grid or loading a scene code forgets the
+* history, as the cells it refers to are gone.
+ */
+
+const maxUndo = 200 // Edits kept for undo
+
+// cellEdit is one cell changed by an edit
+type cellEdit struct {
+	Layer, X, Y   int
+	Before, After Droplet
+}
+
+// edit is what one tool did
+type edit struct {
+	Cells      []cellEdit
+	Layer      int        // Layer whose wires the edit changed
+	Gauges     [2][]Gauge // Before and after, if GaugesChanged
+	Wires      [2][]Wire  // Before and after, if WiresChanged
+	OpenPortal [2]uint8
+
+	GaugesChanged, WiresChanged bool
+}
+
+// Edit runs tool, a change to the world made at the cell (x, y), as one
+// undoable edit and reports whether it changed anything
+func (g *Game) Edit(x, y int, tool func()) bool {
+	return g.edit(x, y, tool, false)
+}
+
+// ExtendEdit runs tool like Edit, adding what it changes to the last edit so
+// that a stroke painted over many cells is undone at once
+func (g *Game) ExtendEdit(x, y int, tool func()) bool {
+	return g.edit(x, y, tool, true)
+}
+
+func (g *Game) edit(x, y int, tool func(), extend bool) bool {
+	if !g.InBounds(x, y) {
+		tool()
+		return false
+	}
+	reach := g.editReach(x, y)
+	before := make([]Droplet, len(reach))
+	for i, c := range reach {
+		before[i] = (*g.layerState(c[0]))[c[2]][c[1]]
+	}
+	gauges, wires, portal := g.gaugeValues(), slices.Clone(g.wires), g.openPortal
+
+	tool()
+
+	e := edit{Layer: g.layer, OpenPortal: [2]uint8{portal, g.openPortal}}
+	for i, c := range reach {
+		after := (*g.layerState(c[0]))[c[2]][c[1]]
+		if after != before[i] {
+			e.Cells = append(e.Cells, cellEdit{c[0], c[1], c[2], before[i], after})
+		}
+	}
+	if now := g.gaugeValues(); !slices.Equal(gauges, now) {
+		e.Gauges, e.GaugesChanged = [2][]Gauge{gauges, now}, true
+	}
+	if !slices.Equal(wires, g.wires) {
+		e.Wires, e.WiresChanged = [2][]Wire{wires, slices.Clone(g.wires)}, true
+	}
+	if len(e.Cells) == 0 && !e.GaugesChanged && !e.WiresChanged && portal == g.openPortal {
+		return false
+	}
+	g.redo = nil
+	if n := len(g.undo); extend && n > 0 && g.undo[n-1].Layer == e.Layer {
+		g.undo[n-1].join(&e)
+		return true
+	}
+	g.undo = append(g.undo, e)
+	if len(g.undo) > maxUndo {
+		g.undo = g.undo[1:]
+	}
+	return true
+}
+
+// join adds next, made after e, to e
+func (e *edit) join(next *edit) {
+	e.Cells = append(e.Cells, next.Cells...)
+	e.OpenPortal[1] = next.OpenPortal[1]
+	if next.GaugesChanged {
+		if !e.GaugesChanged {
+			e.Gauges[0] = next.Gauges[0]
+		}
+		e.Gauges[1], e.GaugesChanged = next.Gauges[1], true
+	}
+	if next.WiresChanged {
+		if !e.WiresChanged {
+			e.Wires[0] = next.Wires[0]
+		}
+		e.Wires[1], e.WiresChanged = next.Wires[1], true
+	}
+}
+
+// editReach lists the cells, as layer, x and y, a tool at (x, y) can change
+func (g *Game) editReach(x, y int) [][3]int {
+	reach := [][3]int{{g.layer, x, y}}
+	if p := g.State[y][x].Portal; p != 0 {
+		for _, end := range portalEnds(&g.State)[p] {
+			if end != [2]int{x, y} {
+				reach = append(reach, [3]int{g.layer, end[0], end[1]})
+			}
+		}
+	}
+	for _, l := range []int{g.layer - 1, g.layer + 1} {
+		if l >= 0 && l < g.Layers() {
+			reach = append(reach, [3]int{l, x, y})
+		}
+	}
+	return reach
+}
+
+// gaugeValues copies the gauges, for comparing and restoring them
+func (g *Game) gaugeValues() []Gauge {
+	values := make([]Gauge, len(g.gauges))
+	for i, gauge := range g.gauges {
+		values[i] = *gauge
+		values[i].Value, values[i].lastFlux = 0, 0
+	}
+	return values
+}
+
+// Undo reverts the last edit, reporting whether there was one
+func (g *Game) Undo() bool {
+	if len(g.undo) == 0 {
+		return false
+	}
+	e := g.undo[len(g.undo)-1]
+	g.undo = g.undo[:len(g.undo)-1]
+	g.applyEdit(&e, 0)
+	g.redo = append(g.redo, e)
+	return true
+}
+
+// Redo makes the last undone edit again, reporting whether there was one
+func (g *Game) Redo() bool {
+	if len(g.redo) == 0 {
+		return false
+	}
+	e := g.redo[len(g.redo)-1]
+	g.redo = g.redo[:len(g.redo)-1]
+	g.applyEdit(&e, 1)
+	g.undo = append(g.undo, e)
+	return true
+}
+
+// CanUndo and CanRedo report how many edits there are to undo and redo
+func (g *Game) CanUndo() int { return len(g.undo) }
+func (g *Game) CanRedo() int { return len(g.redo) }
+
+// applyEdit puts the world as it was before (side 0) or after (side 1) e.
+// A cell changed twice by a joined edit is listed twice, so undoing walks
+// the cells backwards.
+func (g *Game) applyEdit(e *edit, side int) {
+	for i := range e.Cells {
+		c := e.Cells[i]
+		if side == 0 {
+			c = e.Cells[len(e.Cells)-1-i]
+		}
+		if c.Layer >= g.Layers() {
+			continue
+		}
+		state := g.layerState(c.Layer)
+		if c.Y < len(*state) && c.X < len((*state)[c.Y]) {
+			(*state)[c.Y][c.X] = [2]Droplet{c.Before, c.After}[side]
+		}
+	}
+	g.openPortal = e.OpenPortal[side]
+	if e.GaugesChanged {
+		g.restoreGauges(e.Gauges[side])
+	}
+	if e.WiresChanged && e.Layer < g.Layers() {
+		wires := slices.Clone(e.Wires[side])
+		if e.Layer == g.layer {
+			g.wires = wires
+		} else {
+			g.layers[e.Layer].wires = wires
+		}
+	}
+}
+
+// restoreGauges sets the gauges to values, keeping the readings of the
+// gauges that stay
+func (g *Game) restoreGauges(values []Gauge) {
+	gauges := make([]*Gauge, 0, len(values))
+	for _, v := range values {
+		i := slices.IndexFunc(g.gauges, func(gauge *Gauge) bool {
+			return gauge.X == v.X && gauge.Y == v.Y && gauge.Kind == v.Kind && gauge.Style == v.Style
+		})
+		if i >= 0 {
+			gauges = append(gauges, g.gauges[i])
+			continue
+		}
+		gauge := v
+		if g.InBounds(v.X, v.Y) {
+			gauge.lastFlux = g.State[v.Y][v.X].Flux
+		}
+		gauges = append(gauges, &gauge)
+	}
+	g.gauges = gauges
+}
+
+// forgetEdits drops the undo history
+func (g *Game) forgetEdits() {
+	g.undo, g.redo = nil, nil
+}
@@ -0,0 +1,74 @@
+package gridfluid
+
+import "math"
+
+/*
+* Vortex drains
+*
+* A vortex is an open cell that swallows the water reaching it. Before it
+* goes, the water around it is set spinning: every wet cell within
+* vortexRadius gets a tangential push and a weaker pull towards the centre,
+* both fading with distance, and advection turns that velocity into a
+* swirl. The centre cell drains at VortexDrain per second; the swallowed
+* volume is tallied in Game.Swallowed.
+ */
+
+const (
+	vortexRadius = 6.0  // Cells around a vortex that feel its pull
+	vortexSwirl  = 0.08 // Tangential speed added per tick one cell from the centre
+	vortexPull   = 0.03 // Inward speed added per tick one cell from the centre
+)
+
+// PlaceVortex turns an open cell into a vortex drain, or back again
+func (g *Game) PlaceVortex(x, y int) bool {
+	if !g.InBounds(x, y) {
+		return false
+	}
+	d := &g.State[y][x]
+	if d.Material.IsSolid() || d.Pipe != PipeNone {
+		return false
+	}
+	d.Vortex = !d.Vortex
+	return true
+}
+
+// swirlVortices spins the water around every vortex and drains its centre
+func (g *Game) swirlVortices(state *[][]Droplet) {
+	reach := int(vortexRadius)
+	for y := range *state {
+		for x := range (*state)[y] {
+			center := &(*state)[y][x]
+			if !center.Vortex || center.Material.IsSolid() {
+				continue
+			}
+			for dy := -reach; dy <= reach; dy++ {
+				for dx := -reach; dx <= reach; dx++ {
+					dist := math.Hypot(float64(dx), float64(dy))
+					if dist == 0 || dist > vortexRadius {
+						continue
+					}
+					nx, ny, ok := g.neighbor(x, y, dx, dy, state)
+					if !ok {
+						continue
+					}
+					d := &(*state)[ny][nx]
+					if d.Material.IsSolid() || d.Pipe != PipeNone || d.Volume <= WetThreshold {
+						continue
+					}
+					// Strongest next to the centre, gone at the rim
+					falloff := (1 - dist/vortexRadius) / dist
+					ux, uy := float64(dx)/dist, float64(dy)/dist
+					d.VX += (-uy*vortexSwirl - ux*vortexPull) * falloff
+					d.VY += (ux*vortexSwirl - uy*vortexPull) * falloff
+				}
+			}
+
+			amount := math.Min(g.Params.VortexDrain/ticksPerSecond, math.Max(center.Volume, 0))
+			if amount > 0 {
+				center.Volume -= amount
+				g.Swallowed += amount
+				g.recordSink(x, y, amount)
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package gridfluid
+
+import "math"
+
+/*
+* Surface waves
+ */
+
+// IsSurfaceCell reports whether the cell holds water with open air above it
+func IsSurfaceCell(x, y int, state *[][]Droplet) bool {
+	d := (*state)[y][x]
+	if d.Material.IsSolid() || d.Volume <= WetThreshold {
+		return false
+	}
+	return y == 0 || (*state)[y-1][x].Material.IsSolid() || (*state)[y-1][x].Volume <= WetThreshold
+}
+
+// surfaceElevation returns the height of the water surface of the column at
+// (x, y), measured in cells from the bottom of the grid
+func surfaceElevation(x, y int, state *[][]Droplet) float64 {
+	top := y
+	for top > 0 && !(*state)[top-1][x].Material.IsSolid() && (*state)[top-1][x].Volume > WetThreshold {
+		top--
+	}
+	return float64(len(*state)-top-1) + (*state)[top][x].Volume
+}
+
+// propagateWaves exchanges volume between horizontally adjacent surface cells
+// through a damped "virtual pipe". The flow keeps its momentum between ticks,
+// so disturbances travel as ripples and slosh instead of flattening instantly.
+func (g *Game) propagateWaves(state *[][]Droplet) {
+	if g.Gravity != GravityDown {
+		return
+	}
+	for y := range *state {
+		for x := range (*state)[y] {
+			nx, _, ok := g.neighbor(x, y, 1, 0, state)
+			if !ok {
+				continue
+			}
+			left := &(*state)[y][x]
+			right := &(*state)[y][nx]
+
+			surface := IsSurfaceCell(x, y, state) || IsSurfaceCell(nx, y, state)
+			if left.Material.IsSolid() || right.Material.IsSolid() || !surface {
+				left.Wave = 0
+				continue
+			}
+
+			diff := surfaceElevation(x, y, state) - surfaceElevation(nx, y, state)
+			left.Wave += g.Params.WaveSpeed * diff
+			left.Wave *= 1 - g.Params.WaveDamping
+
+			if left.Wave > 0 {
+				transfer := math.Min(left.Wave, math.Min(left.Volume, remainder(*right, 1.0)))
+				g.moveVolume(left, right, transfer)
+			} else if left.Wave < 0 {
+				transfer := math.Min(-left.Wave, math.Min(right.Volume, remainder(*left, 1.0)))
+				g.moveVolume(right, left, transfer)
+			}
+		}
+	}
+}
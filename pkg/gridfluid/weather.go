@@ -0,0 +1,276 @@
+package gridfluid
+
+import "math"
+
+/*
+* Weather
+*
+* Rain falls from the open sky: every open cell along the edge gravity
+* points away from may get a drop, RainRate times per second on average.
+* A drop is a small volume of water added to that cell, so it falls
+* through the grid like any other water and stops on the first roof it
+* meets; rooms sealed by a ceiling stay dry. Gusts come and go at random
+* up to RainGust cells per tick and skew the drops sideways as they are
+* spawned. The rain is tallied in Game.Supplied.
+*
+* Snow falls from the same sky as flakes that drift down slowly, pushed
+* around by the gusts. A flake stops on the first solid cell below it and
+* adds flakeDepth to the drift on that cell; a full drift packs into a
+* snow cell, a kind of ice that holds snowDensity of water. Flakes falling
+* into water melt into it straight away, and so does a drift the water
+* reaches. Snow takes on the temperature of the air (Game.AirTemperature),
+* melts like ice once that is above freezingPoint and is washed away by
+* the water touching it. Switching to snow chills the air to snowAir,
+* switching away warms it back up, so the snow of one season melts in
+* the next. Snow is tallied in Game.Supplied once it lands in the grid.
+ */
+
+type Weather int
+
+const (
+	WeatherClear Weather = iota
+	WeatherRain
+	WeatherSnow
+	weatherCount
+)
+
+var (
+	WeatherNames = [weatherCount]string{"clear", "rain", "snow"}
+	weatherAir   = [weatherCount]float64{ambientTemperature, ambientTemperature, snowAir}
+)
+
+const (
+	rainDrop   = 0.1                  // Volume of a single drop
+	gustChance = 1.0 / ticksPerSecond // Chance per tick that the gust changes
+	gustEase   = 0.05                 // Share of the gap to the new gust closed per tick
+
+	snowAir         = -5.0 // °C of the air while it snows
+	snowTemperature = -2.0 // °C of freshly packed snow
+	snowFall        = 0.05 // Cells per tick a flake falls
+	snowWobble      = 0.03 // Largest random sideways drift of a flake per tick
+	snowDensity     = 0.3  // Water held by a cell packed full of snow
+	snowWarming     = 0.1  // Share per second of the difference to the air snow takes on
+	snowWash        = 0.5  // Share of a snow cell washed away per second by each wet neighbour
+	flakeDepth      = 0.1  // Drift depth a single flake adds
+)
+
+// Flake is a snowflake on its way down
+type Flake struct {
+	Pos Vector // Cells
+}
+
+// CycleWeather switches to the next kind of weather and sets the air to
+// match
+func (g *Game) CycleWeather() {
+	g.Weather = (g.Weather + 1) % weatherCount
+	g.AirTemperature = weatherAir[g.Weather]
+}
+
+// Flakes lists the snowflakes still falling
+func (g *Game) Flakes() []*Flake {
+	return g.flakes
+}
+
+// Gust is the sideways speed currently given to new rain drops
+func (g *Game) Gust() float64 {
+	return g.gust
+}
+
+// skyCells lists the cells along the edge gravity points away from
+func (g *Game) skyCells(state *[][]Droplet) [][2]int {
+	h, w := len(*state), len((*state)[0])
+	gx, gy := g.Gravity.Vector()
+	var cells [][2]int
+	switch {
+	case gy > 0:
+		for x := range w {
+			cells = append(cells, [2]int{x, 0})
+		}
+	case gy < 0:
+		for x := range w {
+			cells = append(cells, [2]int{x, h - 1})
+		}
+	case gx > 0:
+		for y := range h {
+			cells = append(cells, [2]int{0, y})
+		}
+	default:
+		for y := range h {
+			cells = append(cells, [2]int{w - 1, y})
+		}
+	}
+	return cells
+}
+
+// blowGusts lets the gust ease towards a new random strength now and then
+func (g *Game) blowGusts() {
+	if g.Weather == WeatherClear {
+		g.gust, g.gustTarget = 0, 0
+		return
+	}
+	if g.rng.Float64() < gustChance {
+		g.gustTarget = (2*g.rng.Float64() - 1) * g.Params.RainGust
+	}
+	g.gust += (g.gustTarget - g.gust) * gustEase
+}
+
+// precipitate lets the rain or snow fall from the open sky and settles the
+// flakes already falling
+func (g *Game) precipitate(state *[][]Droplet) {
+	switch g.Weather {
+	case WeatherRain:
+		g.rain(state)
+	case WeatherSnow:
+		for _, cell := range g.skyCells(state) {
+			if g.rng.Float64() < g.Params.SnowRate/ticksPerSecond && isOpen(&(*state)[cell[1]][cell[0]]) {
+				g.flakes = append(g.flakes, &Flake{Pos: Vector{float64(cell[0]) + g.rng.Float64(), float64(cell[1]) + g.rng.Float64()}})
+			}
+		}
+	}
+	g.updateFlakes(state)
+	g.meltDrifts(state)
+}
+
+// rain adds drops to the open sky cells
+func (g *Game) rain(state *[][]Droplet) {
+	chance := g.Params.RainRate / ticksPerSecond
+	sx, sy := g.Gravity.toGrid(1, 0)
+	for _, cell := range g.skyCells(state) {
+		if g.rng.Float64() >= chance {
+			continue
+		}
+		d := &(*state)[cell[1]][cell[0]]
+		if !isOpen(d) || (d.Volume > WetThreshold && d.Fluid != FluidWater) {
+			continue
+		}
+		amount := math.Min(rainDrop, remainder(*d, 1.0))
+		if amount <= 0 {
+			continue
+		}
+		if d.Volume <= 0 {
+			d.Fluid = FluidWater
+		}
+		mixTemperature(&d.Temperature, d.Volume, ambientTemperature, amount)
+		mixStagnation(&d.Stagnation, d.Volume, 0, amount)
+		mixConcentration(&d.Acidity, d.Volume, 0, amount)
+		mixConcentration(&d.Salinity, d.Volume, 0, amount)
+		mixConcentration(&d.Sediment, d.Volume, 0, amount)
+		d.Volume += amount
+		d.VX += float64(sx) * g.gust
+		d.VY += float64(sy) * g.gust
+		g.Supplied += amount
+	}
+}
+
+// updateFlakes lets the flakes fall and settles the ones that landed
+func (g *Game) updateFlakes(state *[][]Droplet) {
+	gx, gy := g.Gravity.Vector()
+	sx, sy := g.Gravity.toGrid(1, 0)
+	h, w := len(*state), len((*state)[0])
+	alive := g.flakes[:0]
+	for _, f := range g.flakes {
+		prev := f.Pos
+		side := g.gust + (2*g.rng.Float64()-1)*snowWobble
+		f.Pos.X += gx*snowFall + float64(sx)*side
+		f.Pos.Y += gy*snowFall + float64(sy)*side
+		if f.Pos.X < 0 || f.Pos.Y < 0 || f.Pos.X >= float64(w) || f.Pos.Y >= float64(h) {
+			continue
+		}
+		x, y := int(f.Pos.X), int(f.Pos.Y)
+		d := &(*state)[y][x]
+		if !isOpen(d) {
+			// Flakes never pass through a solid, they stop in front of it
+			x, y = int(prev.X), int(prev.Y)
+			if d = &(*state)[y][x]; isOpen(d) {
+				g.settle(d)
+			}
+			continue
+		}
+		if d.Volume > WetThreshold {
+			g.meltSnow(d, flakeDepth*snowDensity)
+			continue
+		}
+		if bx, by, ok := g.below(x, y, state); !ok || !isOpen(&(*state)[by][bx]) {
+			g.settle(d)
+			continue
+		}
+		alive = append(alive, f)
+	}
+	g.flakes = alive
+}
+
+// settle adds a landed flake to the drift on a cell and packs a full drift
+// into snow
+func (g *Game) settle(d *Droplet) {
+	if d.Volume > WetThreshold {
+		g.meltSnow(d, flakeDepth*snowDensity)
+		return
+	}
+	d.Drift += flakeDepth
+	if d.Drift < 1-1e-9 {
+		return
+	}
+	d.Drift = 0
+	d.Material = MaterialStone
+	d.Ice, d.Snow = true, true
+	d.Thawed = 0
+	d.Fluid = FluidWater
+	d.Temperature = snowTemperature
+	d.Volume += snowDensity
+	g.Supplied += snowDensity
+}
+
+// meltSnow adds the water of melted snow to a cell
+func (g *Game) meltSnow(d *Droplet, amount float64) {
+	if d.Volume <= 0 {
+		d.Fluid = FluidWater
+	}
+	mixTemperature(&d.Temperature, d.Volume, freezingPoint, amount)
+	mixStagnation(&d.Stagnation, d.Volume, 0, amount)
+	mixConcentration(&d.Acidity, d.Volume, 0, amount)
+	mixConcentration(&d.Salinity, d.Volume, 0, amount)
+	mixConcentration(&d.Sediment, d.Volume, 0, amount)
+	d.Volume += amount
+	g.Supplied += amount
+}
+
+// meltDrifts melts the drifts the water reached at once and the others as
+// fast as the air warms them
+func (g *Game) meltDrifts(state *[][]Droplet) {
+	thaw := math.Max(g.AirTemperature-freezingPoint, 0) * snowWarming / latentHeat / ticksPerSecond
+	for y := range *state {
+		for x := range (*state)[y] {
+			d := &(*state)[y][x]
+			if d.Drift <= 0 {
+				continue
+			}
+			if !isOpen(d) {
+				d.Drift = 0
+				continue
+			}
+			melted := math.Min(d.Drift, thaw)
+			if d.Volume > WetThreshold {
+				melted = d.Drift
+			}
+			if melted > 0 {
+				d.Drift -= melted
+				g.meltSnow(d, melted*snowDensity)
+			}
+		}
+	}
+}
+
+// weatherSnow lets a snow cell take on the temperature of the air and the
+// wash of the water touching it
+func (g *Game) weatherSnow(x, y int, d *Droplet, state *[][]Droplet) {
+	d.Temperature += (g.AirTemperature - d.Temperature) * snowWarming / ticksPerSecond
+	for _, dpos := range pressureDirections {
+		nx, ny, ok := g.neighbor(x, y, dpos[0], dpos[1], state)
+		if !ok {
+			continue
+		}
+		if n := &(*state)[ny][nx]; !n.Material.IsSolid() && n.Volume > WetThreshold {
+			d.Thawed += snowWash / ticksPerSecond
+		}
+	}
+}
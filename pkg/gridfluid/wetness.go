@@ -0,0 +1,59 @@
+package gridfluid
+
+/*
+* Wetness / foam lines
+ */
+
+const (
+	WetThreshold  = 0.05  // Minimum volume that counts as "water is here"
+	wetnessDecay  = 0.995 // Per-frame decay of wet marks once the water is gone
+	foamLineDecay = 0.97  // Per-frame decay of foam lines left by the surface
+)
+
+// updateWetness refreshes the visual memory of where water has recently been.
+// Cells touched by water are fully wet, everything else slowly dries out.
+func updateWetness(state *[][]Droplet) {
+	for y := range *state {
+		for x := range (*state)[y] {
+			d := &(*state)[y][x]
+
+			// Obstacles get wet from any neighbouring water
+			if d.Material.IsSolid() {
+				if touchesWater(x, y, state) {
+					d.Wetness = 1.0
+				} else {
+					d.Wetness *= wetnessDecay
+				}
+				continue
+			}
+
+			if d.Volume > WetThreshold {
+				d.Wetness = 1.0
+			} else {
+				d.Wetness *= wetnessDecay
+			}
+
+			if IsSurfaceCell(x, y, state) {
+				d.FoamLine = 1.0
+			} else {
+				d.FoamLine *= foamLineDecay
+			}
+		}
+	}
+}
+
+// touchesWater reports whether any of the 4 neighbours holds water
+func touchesWater(x, y int, state *[][]Droplet) bool {
+	directions := [][2]int{{0, -1}, {0, 1}, {-1, 0}, {1, 0}}
+	for _, dpos := range directions {
+		nx, ny := x+dpos[0], y+dpos[1]
+		if ny < 0 || ny >= len(*state) || nx < 0 || nx >= len((*state)[0]) {
+			continue
+		}
+		neighbor := (*state)[ny][nx]
+		if !neighbor.Material.IsSolid() && neighbor.Volume > WetThreshold {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package gridfluid
+
+import "math"
+
+/*
+* Water wheels
+*
+* A water wheel is an entity spanning the cells within Radius of its hub.
+* Its paddles don't block the water, but water flowing sideways past them
+* turns the wheel: flow under the hub pushes one way, flow over it the
+* other, weighted by how much water each cell holds. The wheel has some
+* inertia, and its paddles drag the water towards their own speed, so a
+* turning wheel slows the flow that drives it a little. Each wheel reports
+* its speed through RPM.
+ */
+
+const (
+	wheelInertia = 0.05 // Share of the gap to the driven speed closed per tick
+	wheelDrag    = 0.1  // Share of the gap to the paddle speed taken from the water per tick
+)
+
+type Wheel struct {
+	X, Y   int     // Hub cell
+	Radius float64 // Paddle length in cells
+
+	Angle float64 // Radians, clockwise on screen
+	omega float64 // Radians per tick, clockwise on screen
+}
+
+// AddWheel mounts a water wheel with its hub at (x, y)
+func (g *Game) AddWheel(x, y int, radius float64) *Wheel {
+	w := &Wheel{X: x, Y: y, Radius: radius}
+	g.wheels = append(g.wheels, w)
+	return w
+}
+
+// Wheels lists the water wheels in the scene
+func (g *Game) Wheels() []*Wheel {
+	return g.wheels
+}
+
+// RPM is the wheel's speed in turns per minute, positive when it turns
+// clockwise on screen
+func (w *Wheel) RPM() float64 {
+	return w.omega * ticksPerSecond * 60 / (2 * math.Pi)
+}
+
+// turnWheels lets the water drive every wheel and the paddles drag the
+// water in return
+func (g *Game) turnWheels(state *[][]Droplet) {
+	for _, w := range g.wheels {
+		reach := int(math.Ceil(w.Radius))
+		var torque, weight float64
+		for dy := -reach; dy <= reach; dy++ {
+			for dx := -reach; dx <= reach; dx++ {
+				r := math.Hypot(float64(dx), float64(dy))
+				if dy == 0 || r > w.Radius {
+					continue
+				}
+				x, y := w.X+dx, w.Y+dy
+				if y < 0 || y >= len(*state) || x < 0 || x >= len((*state)[y]) {
+					continue
+				}
+				d := &(*state)[y][x]
+				if d.Material.IsSolid() || d.Volume <= WetThreshold {
+					continue
+				}
+				// Sideways flow above the hub turns it clockwise, below
+				// it anticlockwise; the lever arm is the height offset
+				arm := -float64(dy)
+				torque += d.Volume * d.VX * arm
+				weight += d.Volume * arm * arm
+			}
+		}
+		driven := 0.0
+		if weight > 0 {
+			driven = torque / weight
+		}
+		w.omega += (driven - w.omega) * wheelInertia
+		w.Angle = math.Mod(w.Angle+w.omega, 2*math.Pi)
+
+		// Paddles pull the water towards their own sideways speed
+		for dy := -reach; dy <= reach; dy++ {
+			for dx := -reach; dx <= reach; dx++ {
+				if math.Hypot(float64(dx), float64(dy)) > w.Radius {
+					continue
+				}
+				x, y := w.X+dx, w.Y+dy
+				if y < 0 || y >= len(*state) || x < 0 || x >= len((*state)[y]) {
+					continue
+				}
+				d := &(*state)[y][x]
+				if d.Material.IsSolid() || d.Volume <= WetThreshold {
+					continue
+				}
+				paddle := -w.omega * float64(dy)
+				d.VX += (paddle - d.VX) * wheelDrag
+			}
+		}
+	}
+}
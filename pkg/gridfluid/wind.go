@@ -0,0 +1,51 @@
+package gridfluid
+
+import "math"
+
+/*
+* Wind
+ */
+
+// Vector is a 2D direction or force in cell units
+type Vector struct{ X, Y float64 }
+
+const maxWind = 1.0
+
+// AdjustWind changes the wind vector, clamped to ±maxWind per component
+func (g *Game) AdjustWind(dx, dy float64) {
+	g.Wind.X = math.Min(maxWind, math.Max(-maxWind, g.Wind.X+dx))
+	g.Wind.Y = math.Min(maxWind, math.Max(-maxWind, g.Wind.Y+dy))
+}
+
+// isExposed reports whether the wind can reach the cell: either it is a
+// surface cell or the water is falling freely
+func (g *Game) isExposed(x, y int, state *[][]Droplet) bool {
+	return IsSurfaceCell(x, y, state) || ((*state)[y][x].Volume > 0 && g.canFlowDown(x, y, state))
+}
+
+// applyWind accelerates exposed water along the wind vector, which skews
+// falling streams and drags the surface sideways
+func (g *Game) applyWind(state *[][]Droplet) {
+	if g.Wind.X == 0 && g.Wind.Y == 0 {
+		return
+	}
+	for y := range *state {
+		for x := range (*state)[y] {
+			d := &(*state)[y][x]
+			if d.Material.IsSolid() || d.Volume <= 0 || !g.isExposed(x, y, state) {
+				continue
+			}
+			d.VX += g.Wind.X * g.Params.WindCoupling
+			d.VY += g.Wind.Y * g.Params.WindCoupling
+		}
+	}
+}
+
+// windBias scales sideways spreading of surface cells in direction dir so
+// water piles up downwind
+func (g *Game) windBias(x, y, dir int, state *[][]Droplet) float64 {
+	if g.Wind.X == 0 || !IsSurfaceCell(x, y, state) {
+		return 1.0
+	}
+	return math.Max(0, 1+float64(dir)*g.Wind.X*g.Params.WindBias)
+}